@@ -15,8 +15,13 @@ import (
 	"strconv"
 	"time"
 
+	"done/lib/cli"
+	"done/lib/client"
 	"done/lib/database"
 	"done/lib/database/bolt"
+	dbinterface "done/lib/database/interface"
+	"done/lib/hooks"
+	"done/lib/mail"
 	"done/lib/webview"
 )
 
@@ -34,14 +39,33 @@ var (
 	dbPathPtr      *string // Path to the database file
 	nativePtr      *bool   // Flag to open in native window (macOS)
 	chromePtr      *bool   // Flag to open in Chrome app mode
+
+	trashRetentionPtr *time.Duration // How long deleted tasks stay in the trash
+	timezonePtr       *string        // Time zone quick-add dates and plans are read in
+	userPtr           *string        // User whose tasks subcommands work on
+	listPtr           *string        // Shared list subcommands work on
+	tokenPtr          *string        // API token subcommands sign in with
+	authPtr           *string        // How the server identifies users
+	hooksDirPtr       *string        // Directory of the hook executables
+	hookTimeoutPtr    *time.Duration // How long a hook may run
+	remindEveryPtr    *time.Duration // How often deadlines are checked for reminders
+	smtpPtr           *string        // SMTP relay email is sent through
+	smtpFromPtr       *string        // Sender address of email
+	smtpUserPtr       *string        // User name to sign in to the SMTP relay
+	webhookPublicPtr  *bool          // Whether webhooks may only reach public addresses
 )
 
+// location is the time zone named by -timezone
+var location = time.Local
+
 func init() {
 	// Get home directory for default database path
 	homeDir, err := os.UserHomeDir()
 	defaultDBPath := "./tasks.db"
+	defaultHooksDir := "./hooks"
 	if err == nil {
 		defaultDBPath = filepath.Join(homeDir, "tasks.db")
+		defaultHooksDir = filepath.Join(homeDir, ".done", "hooks")
 	}
 	
 	dbUpgradePtr = flag.Bool("dbupgrade", false, "Upgrade database for new version compatibility")
@@ -50,10 +74,41 @@ func init() {
 	dbPathPtr = flag.String("dbpath", defaultDBPath, "Path to database file")
 	nativePtr = flag.Bool("native", false, "Open in native window (macOS Safari app mode)")
 	chromePtr = flag.Bool("chrome", false, "Open in Chrome app mode (macOS)")
+	trashRetentionPtr = flag.Duration("trashretention", 30*24*time.Hour, "How long deleted tasks are kept in the trash (0 keeps them forever)")
+	timezonePtr = flag.String("timezone", "", "IANA time zone for quick-add dates and plans, e.g. Europe/Berlin (default local)")
+	userPtr = flag.String("user", os.Getenv("DONE_USER"), "User whose tasks subcommands work on, on a server with users (default $DONE_USER)")
+	listPtr = flag.String("list", os.Getenv("DONE_LIST"), "Shared list subcommands work on instead of the user's own (default $DONE_LIST)")
+	tokenPtr = flag.String("token", os.Getenv("DONE_TOKEN"), "API token subcommands sign in to the server with (default $DONE_TOKEN)")
+	authPtr = flag.String("auth", "password", "How the server identifies users: password, or none to trust the name a client sends")
+	hooksDirPtr = flag.String("hooks", defaultHooksDir, "Directory of executables run when tasks are added and change (pre-add, on-add, on-complete, ...)")
+	hookTimeoutPtr = flag.Duration("hooktimeout", hooks.DefaultTimeout, "How long a hook may run before it is killed")
+	remindEveryPtr = flag.Duration("remindevery", time.Minute, "How often the server checks deadlines for reminders (0 sends none)")
+	smtpPtr = flag.String("smtp", "", "host:port of the SMTP relay email is sent through (default none)")
+	smtpFromPtr = flag.String("smtpfrom", "done@localhost", "Sender address of email")
+	smtpUserPtr = flag.String("smtpuser", "", "User name to sign in to the SMTP relay with; the password is $DONE_SMTP_PASSWORD")
+	webhookPublicPtr = flag.Bool("webhookpublic", false, "Only send webhooks to public addresses, not to this machine or private networks")
 }
 
 func main() {
 	flag.Parse()
+
+	if *timezonePtr != "" {
+		loc, err := time.LoadLocation(*timezonePtr)
+		if err != nil {
+			log.Fatal("Invalid time zone: ", err)
+		}
+		location = loc
+	}
+	cli.Location = location
+
+	// Task management subcommands, e.g. "done add" or "done ls"
+	if args := flag.Args(); len(args) > 0 && cli.IsCommand(args[0]) {
+		if err := cli.Run(args, openClient, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "done:", err)
+			os.Exit(1)
+		}
+		return
+	}
 	
 	// Check if another instance is already running
 	if !*versionPtr && isAlreadyRunning(*servicePortPtr) {
@@ -75,6 +130,51 @@ func main() {
 	submain(flag.Args())
 }
 
+// openClient connects subcommands to the running server, or to the database
+// file when no server is running
+func openClient() (client.Client, error) {
+	if isAlreadyRunning(*servicePortPtr) {
+		remote := client.NewRemote(fmt.Sprintf("http://localhost:%d", *servicePortPtr))
+		remote.SetUser(*userPtr)
+		remote.SetList(*listPtr)
+		remote.SetPassword(os.Getenv("DONE_PASSWORD"))
+		remote.SetToken(*tokenPtr)
+		return remote, nil
+	}
+	local, err := client.OpenLocal(*dbPathPtr)
+	if err != nil {
+		return nil, err
+	}
+	local.SetHooks(hooks.New(*hooksDirPtr, *hookTimeoutPtr))
+	if *smtpPtr != "" {
+		local.SetMail(mailSender())
+	}
+	if *userPtr != "" {
+		if err := local.SetUser(*userPtr); err != nil {
+			local.Close()
+			return nil, err
+		}
+	}
+	if *listPtr != "" {
+		if err := local.SetList(*listPtr); err != nil {
+			local.Close()
+			return nil, err
+		}
+	}
+	local.SetLocation(location)
+	return local, nil
+}
+
+// mailSender sends email through the relay named by -smtp
+func mailSender() *mail.Sender {
+	return &mail.Sender{
+		Addr:     *smtpPtr,
+		From:     *smtpFromPtr,
+		Username: *smtpUserPtr,
+		Password: os.Getenv("DONE_SMTP_PASSWORD"),
+	}
+}
+
 // submain is the main entry point after flag parsing
 // isAlreadyRunning checks if the application is already running on the given port
 func isAlreadyRunning(port int) bool {
@@ -127,23 +227,46 @@ func startService() {
 	}
 	defer db.Disconnect()
 
-	handler := database.NewHandler(db)
+	// Each user gets their own handler; without users everyone shares one
+	accounts := database.NewAccounts(db)
+	accounts.Location = location
+	accounts.Hooks = hooks.New(*hooksDirPtr, *hookTimeoutPtr)
+	accounts.PublicWebhooks = *webhookPublicPtr
+	if *smtpPtr != "" {
+		accounts.Mail = mailSender()
+		accounts.Notifiers[dbinterface.NotifyEmail] = database.EmailNotifier(accounts.Mail)
+	}
+
+	switch *authPtr {
+	case "password":
+	case "none":
+		accounts.Auth = database.NewTrustedAuth(db)
+		log.Println("Authentication is off: anyone who can reach the server may act as any user")
+	default:
+		log.Fatalf("Unknown -auth %q: use password or none", *authPtr)
+	}
+
+	// Purge tasks that have been in the trash longer than the retention period
+	go accounts.RunTrashPurger(*trashRetentionPtr, time.Hour)
+
+	// Send webhook deliveries, including those queued while the server was down
+	go accounts.RunWebhooks()
+
+	// Remind users of deadlines
+	if *remindEveryPtr > 0 {
+		go accounts.RunReminders(*remindEveryPtr)
+	}
+
+	// Email the reports users opted in to
+	if accounts.Mail != nil {
+		go accounts.RunReports(time.Minute)
+	}
 
 	// Set up HTTP routes
 	mux := http.NewServeMux()
 
-	// API endpoints
-	apiPath := "/api"
-	mux.HandleFunc(apiPath+"/version", testApi)                                                      // Get version information
-	mux.HandleFunc(apiPath+"/getTasks", handler.GetTasks)                                           // Get all tasks
-	mux.HandleFunc(apiPath+"/getTodayResults", handler.GetTodayResults)                             // Get today's completed tasks
-	mux.HandleFunc(apiPath+"/addTask", handler.AddTask)                                             // Create a new task
-	mux.HandleFunc(apiPath+"/removeTask", handler.RemoveTask)                                       // Delete a task
-	mux.HandleFunc(apiPath+"/rearrangeTasks", handler.RearrangeTasks)                               // Reorder tasks (drag & drop)
-	mux.HandleFunc(apiPath+"/completeTask", handler.CompleteTask)                                   // Mark task as completed
-	mux.HandleFunc(apiPath+"/updateTaskExecutionRealSeconds", handler.UpdateTaskExecutionRealSeconds) // Update task timer
-	mux.HandleFunc(apiPath+"/getGamification", handler.GetGamification)                             // Get gamification stats
-	mux.HandleFunc(apiPath+"/updateGamification", handler.UpdateGamification)                       // Update gamification stats
+	// API endpoints, each behind authentication unless it is public
+	mux.Handle("/api/", accounts.Protect(apiRoutes(accounts)))
 
 	// Serve static files from frontend directory
 	fileServer := http.FileServer(http.Dir("./frontend"))
@@ -174,6 +297,68 @@ func startService() {
 	log.Fatal(http.ListenAndServe(":"+servicePortString, mux))
 }
 
+// apiRoutes routes the API requests to the handler of the user who made
+// them. Only the routes marked with Reads or ReadsOnGet are open to read-only
+// API tokens.
+func apiRoutes(accounts *database.Accounts) *http.ServeMux {
+	serve := accounts.Serve
+	api := http.NewServeMux()
+	apiPath := "/api"
+	api.HandleFunc(apiPath+"/version", testApi)                                                                          // Get version information
+	api.HandleFunc(apiPath+"/getTasks", database.Reads(serve((*database.Handler).GetTasks)))                             // Get all tasks
+	api.HandleFunc(apiPath+"/getTodayResults", database.Reads(serve((*database.Handler).GetTodayResults)))               // Get today's completed tasks
+	api.HandleFunc(apiPath+"/getCompletedHistory", database.Reads(serve((*database.Handler).GetCompletedHistory)))       // Page through completed tasks by date
+	api.HandleFunc(apiPath+"/addTask", serve((*database.Handler).AddTask))                                               // Create a new task
+	api.HandleFunc(apiPath+"/parseTask", database.Reads(serve((*database.Handler).ParseTask)))                           // Preview a quick-add line
+	api.HandleFunc(apiPath+"/quickAdd", serve((*database.Handler).QuickAddTask))                                         // Create a task from a quick-add line
+	api.HandleFunc(apiPath+"/estimate", database.Reads(serve((*database.Handler).EstimateTask)))                         // Suggest a duration from similar completed tasks
+	api.HandleFunc(apiPath+"/updateTask", serve((*database.Handler).UpdateTask))                                         // Edit a task in place (PATCH)
+	api.HandleFunc(apiPath+"/getTaskHistory", database.Reads(serve((*database.Handler).GetTaskHistory)))                 // Get a task's edit history
+	api.HandleFunc(apiPath+"/search", database.Reads(serve((*database.Handler).SearchTasks)))                            // Search active, completed and trashed tasks
+	api.HandleFunc(apiPath+"/removeTask", serve((*database.Handler).RemoveTask))                                         // Move a task to the trash
+	api.HandleFunc(apiPath+"/getTrash", database.Reads(serve((*database.Handler).GetTrash)))                             // Get trashed tasks
+	api.HandleFunc(apiPath+"/restoreTask", serve((*database.Handler).RestoreTask))                                       // Move a trashed task back to the list
+	api.HandleFunc(apiPath+"/purgeTask", serve((*database.Handler).PurgeTask))                                           // Permanently delete a trashed task
+	api.HandleFunc(apiPath+"/emptyTrash", serve((*database.Handler).EmptyTrash))                                         // Permanently delete all trashed tasks
+	api.HandleFunc(apiPath+"/rearrangeTasks", serve((*database.Handler).RearrangeTasks))                                 // Reorder tasks (drag & drop)
+	api.HandleFunc(apiPath+"/completeTask", serve((*database.Handler).CompleteTask))                                     // Mark task as completed
+	api.HandleFunc(apiPath+"/reopenTask", serve((*database.Handler).ReopenTask))                                         // Move a completed task back to the list
+	api.HandleFunc(apiPath+"/updateTaskExecutionRealSeconds", serve((*database.Handler).UpdateTaskExecutionRealSeconds)) // Update task timer
+	api.HandleFunc(apiPath+"/importTasks", serve((*database.Handler).ImportTasks))                                       // Import a Taskwarrior, todo.txt or Todoist export
+	api.HandleFunc(apiPath+"/undo", serve((*database.Handler).Undo))                                                     // Revert the session's last change
+	api.HandleFunc(apiPath+"/redo", serve((*database.Handler).Redo))                                                     // Apply the session's last undone change again
+	api.HandleFunc(apiPath+"/unpinTasks", serve((*database.Handler).UnpinTasks))                                         // Release tasks pinned by dragging in smart sort
+	api.HandleFunc(apiPath+"/getSettings", database.Reads(serve((*database.Handler).GetSettings)))                       // Get settings such as the sort mode
+	api.HandleFunc(apiPath+"/updateSettings", serve((*database.Handler).UpdateSettings))                                 // Update settings such as working hours
+	api.HandleFunc(apiPath+"/snoozeReminder", serve((*database.Handler).SnoozeReminder))                                 // Hold off a task's deadline reminders
+	api.HandleFunc(apiPath+"/sendReport", serve((*database.Handler).SendReport))                                         // Email the daily or weekly report now
+	api.HandleFunc(apiPath+"/getPlan", database.Reads(serve((*database.Handler).GetPlan)))                               // Propose which tasks fit into the coming days
+	api.HandleFunc(apiPath+"/acceptPlan", serve((*database.Handler).AcceptPlan))                                         // Record a plan
+	api.HandleFunc(apiPath+"/getPlanReport", database.Reads(serve((*database.Handler).GetPlanReport)))                   // Compare a day's plan with what was done
+	api.HandleFunc(apiPath+"/getGamification", database.Reads(serve((*database.Handler).GetGamification)))               // Get gamification stats
+	api.HandleFunc(apiPath+"/events", database.Reads(serve((*database.Handler).StreamEvents)))                           // Stream changes as Server-Sent Events
+	api.HandleFunc(apiPath+"/login", accounts.Login)                                                                     // Sign in as a user
+	api.HandleFunc(apiPath+"/logout", accounts.Logout)                                                                   // Sign out
+	api.HandleFunc(apiPath+"/me", database.Reads(accounts.Me))                                                           // Get the signed in user
+	api.HandleFunc(apiPath+"/users", accounts.Users)                                                                     // List users or add one (admins)
+	api.HandleFunc(apiPath+"/updateUser", accounts.UpdateUser)                                                           // Disable or enable a user (admins)
+	api.HandleFunc(apiPath+"/updatePassword", accounts.UpdatePassword)                                                   // Change your password, or anyone's (admins)
+	api.HandleFunc(apiPath+"/tokens", accounts.Tokens)                                                                   // List your API tokens or create one
+	api.HandleFunc(apiPath+"/deleteToken", accounts.DeleteToken)                                                         // Revoke an API token
+	api.HandleFunc(apiPath+"/leaderboard", database.Reads(accounts.GetLeaderboard))                                      // Rank opted-in users by points, completions or streak
+	api.HandleFunc(apiPath+"/challenges", database.ReadsOnGet(accounts.Challenges))                                      // List team challenges or start one
+	api.HandleFunc(apiPath+"/joinChallenge", accounts.JoinChallenge)                                                     // Join a team challenge
+	api.HandleFunc(apiPath+"/leaveChallenge", accounts.LeaveChallenge)                                                   // Leave a team challenge
+	api.HandleFunc(apiPath+"/deleteChallenge", accounts.DeleteChallenge)                                                 // Delete a team challenge (creator or admins)
+	api.HandleFunc(apiPath+"/lists", database.ReadsOnGet(accounts.Lists))                                                // List your shared lists or create one
+	api.HandleFunc(apiPath+"/updateListMembers", accounts.UpdateListMembers)                                             // Set the members of a shared list (creator or admins)
+	api.HandleFunc(apiPath+"/assignTask", accounts.AssignTask)                                                           // Assign a task on a shared list (?list=)
+	api.HandleFunc(apiPath+"/webhooks", accounts.Webhooks)                                                               // List your webhooks or add one
+	api.HandleFunc(apiPath+"/deleteWebhook", accounts.DeleteWebhook)                                                     // Remove a webhook and its queued deliveries
+	api.HandleFunc(apiPath+"/testWebhook", accounts.TestWebhook)                                                         // Send a ping event to a webhook
+	return api
+}
+
 // Test struct is deprecated but kept for backward compatibility
 type Test struct {
 	Message  string
@@ -3,8 +3,17 @@ package main
 import (
 	"bytes"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"done/lib/auth"
+	"done/lib/database"
+	"done/lib/database/bolt"
+	dbinterface "done/lib/database/interface"
 )
 
 func TestMain(m *testing.M) {
@@ -45,6 +54,103 @@ func TestVersionParameterPassed(t *testing.T) {
 	}
 }
 
+func TestReadTokenCannotPurge(t *testing.T) {
+	db := bolt.NewBoltDB(filepath.Join(t.TempDir(), "done.db"))
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Disconnect()
+
+	accounts := database.NewAccounts(db)
+	defer accounts.Close()
+	user, err := accounts.AddUser("ann", true, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := accounts.CreateToken(user, "dashboard", auth.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write, err := accounts.CreateToken(user, "laptop", auth.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := accounts.Handler(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.AddTask(&dbinterface.Task{UUID: "trashed", Body: "Old task"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Remove("trashed"); err != nil {
+		t.Fatal(err)
+	}
+
+	api := accounts.Protect(apiRoutes(accounts))
+	request := func(method, path, token string) int {
+		r := httptest.NewRequest(method, path, strings.NewReader("trashed"))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if code := request(method, "/api/purgeTask", read.Secret); code != http.StatusForbidden && code != http.StatusMethodNotAllowed {
+			t.Errorf("%s /api/purgeTask with a read token: %d, want 403 or 405", method, code)
+		}
+	}
+	if code := request(http.MethodGet, "/api/purgeTask", write.Secret); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /api/purgeTask with a write token: %d, want 405", code)
+	}
+	if _, err := h.DB.GetTrashedTaskByUUID("trashed"); err != nil {
+		t.Errorf("trashed task was purged: %v", err)
+	}
+
+	if code := request(http.MethodGet, "/api/getTrash", read.Secret); code != http.StatusOK {
+		t.Errorf("GET /api/getTrash with a read token: %d, want 200", code)
+	}
+	if code := request(http.MethodPost, "/api/purgeTask", write.Secret); code != http.StatusOK {
+		t.Errorf("POST /api/purgeTask with a write token: %d, want 200", code)
+	}
+}
+
+func TestGamificationIsNotWritable(t *testing.T) {
+	db := bolt.NewBoltDB(filepath.Join(t.TempDir(), "done.db"))
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Disconnect()
+
+	accounts := database.NewAccounts(db)
+	defer accounts.Close()
+	user, err := accounts.AddUser("ann", true, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := accounts.CreateToken(user, "laptop", auth.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Points, streaks and achievements only change by completing tasks
+	r := httptest.NewRequest(http.MethodPost, "/api/updateGamification", strings.NewReader(`{"total_points":100000}`))
+	r.Header.Set("Authorization", "Bearer "+token.Secret)
+	w := httptest.NewRecorder()
+	accounts.Protect(apiRoutes(accounts)).ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("POST /api/updateGamification: %d, want 404", w.Code)
+	}
+	h, err := accounts.Handler(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gamification, err := h.DB.GetGamification(); err != nil || gamification.TotalPoints != 0 {
+		t.Errorf("gamification = %+v (%v)", gamification, err)
+	}
+}
+
 func BenchmarkVersionParameterPassed(b *testing.B) {
 	// Save original stdout
 	oldStdout := os.Stdout
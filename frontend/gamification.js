@@ -221,6 +221,10 @@
     // Handle task completion - Points are now calculated on the backend
     async function onTaskComplete() {
         // Wait a bit for the backend to update
+        // Remember the state before completion to show what it earned
+        const previousAchievements = cachedData ? (cachedData.achievements || []) : [];
+        const previousPoints = cachedData ? (cachedData.total_points || 0) : 0;
+
         setTimeout(async () => {
             // Clear cache to force refresh
             cachedData = null;
@@ -228,33 +232,11 @@
             // Fetch updated data
             const data = await fetchGamificationData();
             
-            // Check achievements based on the new data
+            // Achievements are unlocked on the backend - show the new ones
             const achievements = data.achievements || [];
-            const totalPoints = data.total_points || 0;
-            const currentStreak = data.current_streak || 0;
-            const newAchievements = [];
-            
-            // Check for new achievements
-            if (data.completed_tasks === 1 && !achievements.includes('firstTask')) {
-                newAchievements.push(ACHIEVEMENTS.firstTask);
-            }
-            
-            if (currentStreak >= 3 && !achievements.includes('streak3')) {
-                newAchievements.push(ACHIEVEMENTS.streak3);
-            }
-            if (currentStreak >= 7 && !achievements.includes('streak7')) {
-                newAchievements.push(ACHIEVEMENTS.streak7);
-            }
-            if (currentStreak >= 30 && !achievements.includes('streak30')) {
-                newAchievements.push(ACHIEVEMENTS.streak30);
-            }
-            
-            if (totalPoints >= 1000 && !achievements.includes('points1000')) {
-                newAchievements.push(ACHIEVEMENTS.points1000);
-            }
-            if (totalPoints >= 5000 && !achievements.includes('points5000')) {
-                newAchievements.push(ACHIEVEMENTS.points5000);
-            }
+            const newAchievements = achievements
+                .filter(id => !previousAchievements.includes(id) && ACHIEVEMENTS[id])
+                .map(id => ACHIEVEMENTS[id]);
             
             // Show new achievements
             newAchievements.forEach(achievement => {
@@ -264,10 +246,11 @@
             // Update display
             updatePointsDisplay();
             
-            // Show points earned animation (calculate approximate points)
-            // The actual calculation is done on the backend
-            const pointsEarned = 10; // Base points - actual calculation is on backend
-            showPointsEarned(pointsEarned);
+            // Show points earned animation
+            const pointsEarned = (data.total_points || 0) - previousPoints;
+            if (pointsEarned > 0) {
+                showPointsEarned(pointsEarned);
+            }
         }, 500);
     }
     
@@ -304,8 +287,8 @@
         
         // Hook into Done.completeTask to trigger gamification update
         const originalCompleteTask = window.exports.Done.completeTask;
-        window.exports.Done.completeTask = function(taskUUID) {
-            originalCompleteTask.call(this, taskUUID);
+        window.exports.Done.completeTask = function(taskUUID, force) {
+            originalCompleteTask.call(this, taskUUID, force);
             
             // Trigger gamification update after task is completed
             setTimeout(() => onTaskComplete(), 100);
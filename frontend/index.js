@@ -10,6 +10,15 @@ window.exports.timerId = 0; // Currently active timer ID
 
 (function (Done) {
 
+    // UUID of the task being edited in the new task form, null when adding
+    var editingTaskUUID = null;
+    // Revision of that task when editing started
+    var editingTaskRevision = null;
+
+    // ETag of the task list last rendered; a move sent with it is refused
+    // when the list has changed since
+    var tasksETag = null;
+
     /**
      * Parse JSON response from backend
      * TODO: Replace eval with JSON.parse for better security
@@ -30,7 +39,15 @@ window.exports.timerId = 0; // Currently active timer ID
      */
     function FromRFC3339ToJSTime(objArray) {
         if (objArray != null) {
+            var bodies = {};
+            for (var i = 0; i < objArray.length; i++) {
+                bodies[objArray[i]["uuid"]] = objArray[i]["body"];
+            }
             for (var i = 0; i < objArray.length; i++) {
+                objArray[i]["tags_readable"] = tagsReadable(objArray[i]["tags"]);
+                objArray[i]["blocked_readable"] = blockedReadable(objArray[i], bodies);
+                objArray[i]["priority"] = objArray[i]["priority"] || "";
+                objArray[i]["priority_readable"] = priorityReadable(objArray[i]);
                 for (var property in objArray[i]) {
                     if (objArray[i].hasOwnProperty(property)) {
                         if (property.indexOf('duration_execution_estimated_seconds') == 0) {
@@ -59,12 +76,24 @@ window.exports.timerId = 0; // Currently active timer ID
                             //     + " (" + date.getDay() + ") "
                             //     + date.getHours() + ":" + date.getMinutes();
                         } else if(property.indexOf('time_hard_dead_line') == 0) {
-                            var date = new Date(objArray[i][property]);
-                            if (date.getFullYear() == 9999) {
+                            if (objArray[i][property] == null) {
                                 objArray[i][property + "_readable"] = "";
                             } else {
+                                var date = new Date(objArray[i][property]);
                                 // Month is 0-indexed in JS, so add 1 for display
-                                objArray[i][property + "_readable"] = date.getDate() + " / " + (date.getMonth() + 1) + " / " + date.getFullYear();
+                                var readable = "⚠️ " + date.getDate() + " / " + (date.getMonth() + 1) + " / " + date.getFullYear();
+                                if (objArray[i]["deadline_has_time"]) {
+                                    readable += " " + date.getHours() + ":" + ("0" + date.getMinutes()).slice(-2);
+                                }
+                                objArray[i][property + "_readable"] = readable;
+                            }
+
+                        } else if (property == 'time_planned') {
+                            if (objArray[i][property] == null) {
+                                objArray[i][property + "_readable"] = "";
+                            } else {
+                                var plannedDate = new Date(objArray[i][property]);
+                                objArray[i][property + "_readable"] = "📅 " + plannedDate.getDate() + " / " + (plannedDate.getMonth() + 1) + " / " + plannedDate.getFullYear();
                             }
 
                         } else if (property.indexOf('duration_execution_real_seconds') == 0) {
@@ -94,6 +123,60 @@ window.exports.timerId = 0; // Currently active timer ID
 
 
 
+    /**
+     * Render task tags as "#tag" labels, escaped for use in HTML
+     * @param {Array} tags - Tag names, may be missing
+     * @returns {string} - Space separated labels, empty without tags
+     */
+    function tagsReadable(tags) {
+        if (!tags) {
+            return "";
+        }
+        return tags.map(function(tag) {
+            return "#" + escapeHTML(tag);
+        }).join(" ");
+    }
+
+    /**
+     * Render a task's priority, with a pin when smart sort keeps it in place
+     * @param {Object} task - Task from the backend
+     * @returns {string} - Label, empty without priority or pin
+     */
+    function priorityReadable(task) {
+        var labels = {high: "‼ High", medium: "! Medium", low: "↓ Low"};
+        var readable = labels[task["priority"]] || "";
+        if (task["pinned"]) {
+            readable += (readable ? " " : "") + "📌";
+        }
+        return readable;
+    }
+
+    /**
+     * Render the open tasks a blocked task waits for, escaped for use in HTML
+     * @param {Object} task - Task from the backend
+     * @param {Object} bodies - Text of the listed tasks by UUID
+     * @returns {string} - Label, empty unless the task is blocked
+     */
+    function blockedReadable(task, bodies) {
+        if (!task["blocked"]) {
+            return "";
+        }
+        var names = (task["blocked_by"] || []).filter(function(uuid) {
+            return bodies.hasOwnProperty(uuid);
+        }).map(function(uuid) {
+            return escapeHTML(window.TaskUtils.cleanTaskText(bodies[uuid]).split("\n")[0]);
+        });
+        return "⛔ Waiting for: " + names.join(", ");
+    }
+
+    /**
+     * Escape text for use in HTML
+     */
+    function escapeHTML(text) {
+        return String(text).replace(/&/g, "&amp;").replace(/</g, "&lt;")
+            .replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+    }
+
     /**
      * Sort array of objects by field
      * @param {string} field - Field name to sort by
@@ -131,8 +214,12 @@ window.exports.timerId = 0; // Currently active timer ID
      * Render all tasks in the UI
      * Handles task display, ordering, and planning calculations
      * @param {string} tasksJSON - JSON string containing task data
+     * @param {XMLHttpRequest} [xhr] - Request the tasks came from, for the ETag of the list
      */
-    function renderTasks(tasksJSON) {
+    function renderTasks(tasksJSON, xhr) {
+        if (xhr && xhr.getResponseHeader("ETag")) {
+            tasksETag = xhr.getResponseHeader("ETag");
+        }
 
         var tasksData = makeObjectFromBackendJSON(tasksJSON);
         // var secondInDay = 8*60*60;
@@ -281,15 +368,117 @@ window.exports.timerId = 0; // Currently active timer ID
         xhr.send(null);
         xhr.onreadystatechange = function(data) {
             if (xhr.readyState == XMLHttpRequest.DONE) {
-                renderTasks(xhr.responseText);
+                renderTasks(xhr.responseText, xhr);
+            }
+        }
+    }
+
+    // Views to reload because of changes made elsewhere, see watchEvents
+    var staleViews = {};
+    var staleTimeout = null;
+
+    /**
+     * Reload views after a short delay, so a burst of changes reloads them once
+     * @param {string[]} views - Any of "tasks", "today", "trash", "settings" and "gamification"
+     */
+    function markStale(views) {
+        views.forEach(function(view) { staleViews[view] = true; });
+        if (staleTimeout === null) {
+            staleTimeout = setTimeout(reloadStaleViews, 150);
+        }
+    }
+
+    function reloadStaleViews() {
+        staleTimeout = null;
+        // Rendering the task list again would detach a running timer from its task
+        if (staleViews.tasks && window.exports.timerId != 0) {
+            staleTimeout = setTimeout(reloadStaleViews, 2000);
+            return;
+        }
+
+        var views = staleViews;
+        staleViews = {};
+        if (views.tasks) getTasks();
+        if (views.today && shownDay === null) getTodayResults();
+        if (views.trash) getTrash();
+        if (views.settings) getSettings();
+        if (views.gamification && window.Gamification) window.Gamification.updatePointsDisplay();
+    }
+
+    /**
+     * Keep the views up to date with changes made in other tabs, the app or
+     * the command line, streamed by the server as Server-Sent Events. The
+     * browser reconnects on its own, and the server then sends what was
+     * missed.
+     */
+    function watchEvents() {
+        if (!window.EventSource) {
+            return;
+        }
+        var source = new EventSource("/api/events");
+        source.onmessage = function(e) {
+            var event = JSON.parse(e.data);
+            switch (event["type"]) {
+                case "task.added":
+                case "task.removed":
+                    markStale(["tasks", "trash"]);
+                    break;
+                case "task.updated":
+                case "task.unblocked":
+                case "tasks.reordered":
+                    markStale(["tasks"]);
+                    break;
+                case "task.completed":
+                case "completed.removed":
+                    markStale(["tasks", "today"]);
+                    break;
+                case "gamification.updated":
+                    markStale(["gamification"]);
+                    break;
+                case "settings.updated":
+                    markStale(["settings", "tasks"]);
+                    break;
+                case "resync":
+                    markStale(["tasks", "today", "trash", "settings", "gamification"]);
+                    break;
+                case "reminder.due":
+                    showReminder(event["data"]);
+                    break;
             }
+        };
+    }
+
+    /**
+     * Show a deadline reminder as a browser notification, asking for
+     * permission the first time, or in a dialog where notifications are
+     * unavailable or denied
+     */
+    function showReminder(reminder) {
+        if (!window.Notification || Notification.permission === "denied") {
+            alert(reminder["text"]);
+            return;
         }
+        if (Notification.permission === "granted") {
+            new Notification("Done", { body: reminder["text"], tag: reminder["task"]["uuid"] });
+            return;
+        }
+        Notification.requestPermission().then(function(permission) {
+            if (permission === "granted") {
+                new Notification("Done", { body: reminder["text"], tag: reminder["task"]["uuid"] });
+            } else {
+                alert(reminder["text"]);
+            }
+        });
     }
 
     /**
      * Fetch today's completed tasks from the API
      */
     function getTodayResults() {
+        shownDay = null;
+        document.getElementsByClassName("todayLabel")[0].textContent = "Done today:";
+        document.getElementsByClassName("page_today_nextButton")[0].disabled = true;
+
         var xhr = new XMLHttpRequest();
         xhr.open('GET', "/api/getTodayResults", true);
         xhr.setRequestHeader('Content-Type', 'application/json')
@@ -301,6 +490,39 @@ window.exports.timerId = 0; // Currently active timer ID
         }
     }
 
+    // Day shown in the done section instead of today, or null for today
+    var shownDay = null;
+
+    /**
+     * Show the tasks completed on an earlier or later day in the done section
+     * @param {number} offset - Days to move, e.g. -1 for the previous day
+     */
+    function showDay(offset) {
+        var day = shownDay ? new Date(shownDay) : new Date();
+        day.setHours(0, 0, 0, 0);
+        day.setDate(day.getDate() + offset);
+
+        var today = new Date();
+        today.setHours(0, 0, 0, 0);
+        if (day >= today) {
+            getTodayResults();
+            return;
+        }
+        shownDay = day;
+
+        var date = day.getFullYear() + "-" + ("0" + (day.getMonth() + 1)).slice(-2) + "-" + ("0" + day.getDate()).slice(-2);
+        var xhr = new XMLHttpRequest();
+        xhr.open('GET', "/api/getCompletedHistory?order=oldest&limit=500&from=" + date + "&to=" + date, true);
+        xhr.send(null);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200 && shownDay === day) {
+                document.getElementsByClassName("todayLabel")[0].textContent = "Done " + day.toDateString() + ":";
+                document.getElementsByClassName("page_today_nextButton")[0].disabled = false;
+                Done.renderTodayResults(JSON.stringify(JSON.parse(xhr.responseText)["tasks"]));
+            }
+        }
+    }
+
     /**
      * Render today's completed tasks in the UI
      * @param {string} tasksJSON - JSON string containing completed task data
@@ -325,14 +547,114 @@ window.exports.timerId = 0; // Currently active timer ID
                 taskTime.className = 'task-time';
                 taskTime.textContent = '⏱️ Time spent: ' + tasksCompletedData[i]["duration_execution_real_seconds_readable"];
                 
+                var reopenButton = document.createElement('button');
+                reopenButton.className = 'taskButton task-reopen';
+                reopenButton.textContent = 'Reopen';
+                reopenButton.title = 'Move back to tasks and take back the points';
+                reopenButton.addEventListener('click', (function(taskUUID) {
+                    return function() {
+                        Done.reopenTask(taskUUID);
+                    };
+                })(tasksCompletedData[i].uuid));
+
                 taskDiv.appendChild(taskName);
                 taskDiv.appendChild(taskTime);
+                taskDiv.appendChild(reopenButton);
                 today.appendChild(taskDiv);
             }
         }
 
     }
 
+    /**
+     * Smart date auto-completion for partially filled DD / MM / YYYY fields
+     * Returns "0" for every field when no date was entered
+     * @param {string} monthRaw - Month field value
+     * @param {string} dayRaw - Day field value
+     * @param {string} yearRaw - Year field value
+     */
+    function completeDateFields(monthRaw, dayRaw, yearRaw) {
+        var currentDate = new Date();
+        var currentYear = currentDate.getFullYear();
+        var currentMonth = currentDate.getMonth() + 1; // JS months are 0-indexed
+        var currentDay = currentDate.getDate();
+        
+        // Check if any date field has actual data (not empty or placeholder)
+        var hasMonth = monthRaw && monthRaw !== "MM" && monthRaw !== "0";
+        var hasDay = dayRaw && dayRaw !== "DD" && dayRaw !== "0";
+        var hasYear = yearRaw && yearRaw !== "YYYY" && yearRaw !== "0";
+        
+        var month, day, year;
+        
+        // If no date fields are filled, there is no date
+        if (!hasMonth && !hasDay && !hasYear) {
+            return { month: "0", day: "0", year: "0" };
+        }
+
+        // Smart auto-completion with logic for partial dates
+        if (hasDay && !hasMonth && !hasYear) {
+            // Only day specified - use current month/year, but if day is in past, use next month
+            year = currentYear.toString();
+            month = currentMonth.toString();
+            day = dayRaw;
+            
+            if (parseInt(dayRaw) < currentDay) {
+                // Day is in the past for current month, use next month
+                var nextMonth = currentMonth + 1;
+                var nextYear = currentYear;
+                if (nextMonth > 12) {
+                    nextMonth = 1;
+                    nextYear = currentYear + 1;
+                }
+                month = nextMonth.toString();
+                year = nextYear.toString();
+            }
+        } else if (hasMonth && !hasDay && !hasYear) {
+            // Only month specified - use current year, and first day if month is current or future
+            year = currentYear.toString();
+            month = monthRaw;
+            
+            if (parseInt(monthRaw) < currentMonth || 
+                (parseInt(monthRaw) == currentMonth && currentDay > 28)) {
+                // Month is in past or current month is almost over, use next year
+                year = (currentYear + 1).toString();
+                day = "1";
+            } else if (parseInt(monthRaw) == currentMonth) {
+                // Current month, use current day or later
+                day = currentDay.toString();
+            } else {
+                // Future month, use first day
+                day = "1";
+            }
+        } else {
+            // If any field is filled, auto-complete missing fields with current date
+            month = hasMonth ? monthRaw : currentMonth.toString();
+            day = hasDay ? dayRaw : currentDay.toString();
+            year = hasYear ? yearRaw : currentYear.toString();
+        }
+        
+        console.log('Auto-completed date:', day, month, year);
+        
+        // Validate the constructed date
+        var testDate = new Date(parseInt(year), parseInt(month) - 1, parseInt(day));
+        var isValidDate = testDate.getFullYear() == parseInt(year) &&
+                         testDate.getMonth() == parseInt(month) - 1 &&
+                         testDate.getDate() == parseInt(day);
+        
+        // If date is invalid, use tomorrow
+        if (!isValidDate) {
+            console.log('Date is invalid, adjusting to tomorrow');
+            var tomorrow = new Date(currentDate);
+            tomorrow.setDate(tomorrow.getDate() + 1);
+            month = (tomorrow.getMonth() + 1).toString();
+            day = tomorrow.getDate().toString();
+            year = tomorrow.getFullYear().toString();
+            console.log('Adjusted to:', day, month, year);
+        }
+
+        return { month: month, day: day, year: year };
+    }
+
     /**
      * Create a new task
      * Validates input and sends task data to the API
@@ -348,6 +670,11 @@ window.exports.timerId = 0; // Currently active timer ID
         var deadlineMonthElement = document.getElementsByClassName("page_newTask_timeHardDeadlineMonth_value")[0];
         var deadlineDayElement = document.getElementsByClassName("page_newTask_timeHardDeadlineDay_value")[0];
         var deadlineYearElement = document.getElementsByClassName("page_newTask_timeHardDeadlineYear_value")[0];
+        var deadlineTimeElement = document.getElementsByClassName("page_newTask_timeHardDeadlineTime_value")[0];
+        var plannedMonthElement = document.getElementsByClassName("page_newTask_timePlannedMonth_value")[0];
+        var plannedDayElement = document.getElementsByClassName("page_newTask_timePlannedDay_value")[0];
+        var plannedYearElement = document.getElementsByClassName("page_newTask_timePlannedYear_value")[0];
+        var priorityElement = document.getElementsByClassName("page_newTask_priority_value")[0];
 
         // Encode special characters for safe transmission
         var taskText = window.TaskUtils.encodeTaskText(taskTextElement.value);
@@ -376,92 +703,19 @@ window.exports.timerId = 0; // Currently active timer ID
 
 
         // Smart deadline processing with auto-completion
-        var currentDate = new Date();
-        var currentYear = currentDate.getFullYear();
-        var currentMonth = currentDate.getMonth() + 1; // JS months are 0-indexed
-        var currentDay = currentDate.getDate();
-        
-        console.log('Current date:', currentDay, currentMonth, currentYear);
-        
-        // Get raw values from form fields
-        var deadlineMonthRaw = deadlineMonthElement.value.trim();
-        var deadlineDayRaw = deadlineDayElement.value.trim();
-        var deadlineYearRaw = deadlineYearElement.value.trim();
-        
-        // Check if any deadline field has actual data (not empty or placeholder)
-        var hasMonth = deadlineMonthRaw && deadlineMonthRaw !== "MM" && deadlineMonthRaw !== "0";
-        var hasDay = deadlineDayRaw && deadlineDayRaw !== "DD" && deadlineDayRaw !== "0";
-        var hasYear = deadlineYearRaw && deadlineYearRaw !== "YYYY" && deadlineYearRaw !== "0";
-        
-        var deadlineMonth, deadlineDay, deadlineYear;
-        
-        // If no deadline fields are filled, set as no deadline
-        if (!hasMonth && !hasDay && !hasYear) {
-            deadlineMonth = "0";
-            deadlineDay = "0";
-            deadlineYear = "0";
-        } else {
-            // Smart auto-completion with logic for partial dates
-            if (hasDay && !hasMonth && !hasYear) {
-                // Only day specified - use current month/year, but if day is in past, use next month
-                deadlineYear = currentYear.toString();
-                deadlineMonth = currentMonth.toString();
-                deadlineDay = deadlineDayRaw;
-                
-                if (parseInt(deadlineDayRaw) < currentDay) {
-                    // Day is in the past for current month, use next month
-                    var nextMonth = currentMonth + 1;
-                    var nextYear = currentYear;
-                    if (nextMonth > 12) {
-                        nextMonth = 1;
-                        nextYear = currentYear + 1;
-                    }
-                    deadlineMonth = nextMonth.toString();
-                    deadlineYear = nextYear.toString();
-                }
-            } else if (hasMonth && !hasDay && !hasYear) {
-                // Only month specified - use current year, and first day if month is current or future
-                deadlineYear = currentYear.toString();
-                deadlineMonth = deadlineMonthRaw;
-                
-                if (parseInt(deadlineMonthRaw) < currentMonth || 
-                    (parseInt(deadlineMonthRaw) == currentMonth && currentDay > 28)) {
-                    // Month is in past or current month is almost over, use next year
-                    deadlineYear = (currentYear + 1).toString();
-                    deadlineDay = "1";
-                } else if (parseInt(deadlineMonthRaw) == currentMonth) {
-                    // Current month, use current day or later
-                    deadlineDay = currentDay.toString();
-                } else {
-                    // Future month, use first day
-                    deadlineDay = "1";
-                }
-            } else {
-                // If any field is filled, auto-complete missing fields with current date
-                deadlineMonth = hasMonth ? deadlineMonthRaw : currentMonth.toString();
-                deadlineDay = hasDay ? deadlineDayRaw : currentDay.toString();
-                deadlineYear = hasYear ? deadlineYearRaw : currentYear.toString();
-            }
-            
-            console.log('Auto-completed deadline:', deadlineDay, deadlineMonth, deadlineYear);
-            
-            // Validate the constructed date
-            var testDate = new Date(parseInt(deadlineYear), parseInt(deadlineMonth) - 1, parseInt(deadlineDay));
-            var isValidDate = testDate.getFullYear() == parseInt(deadlineYear) &&
-                             testDate.getMonth() == parseInt(deadlineMonth) - 1 &&
-                             testDate.getDate() == parseInt(deadlineDay);
-            
-            // If date is invalid, use tomorrow
-            if (!isValidDate) {
-                console.log('Date is invalid, adjusting to tomorrow');
-                var tomorrow = new Date(currentDate);
-                tomorrow.setDate(tomorrow.getDate() + 1);
-                deadlineMonth = (tomorrow.getMonth() + 1).toString();
-                deadlineDay = tomorrow.getDate().toString();
-                deadlineYear = tomorrow.getFullYear().toString();
-                console.log('Adjusted to:', deadlineDay, deadlineMonth, deadlineYear);
-            }
-        }
+        var deadline = completeDateFields(deadlineMonthElement.value.trim(),
+            deadlineDayElement.value.trim(), deadlineYearElement.value.trim());
+        var deadlineMonth = deadline.month;
+        var deadlineDay = deadline.day;
+        var deadlineYear = deadline.year;
+
+        // Optional time of day for the deadline
+        var deadlineTime = deadlineTimeElement.value.trim();
+        var hasValidTime = deadlineTime === "" || /^([01]?\d|2[0-3]):[0-5]\d$/.test(deadlineTime);
+
+        // Soft deadline - the day the task is planned for
+        var planned = completeDateFields(plannedMonthElement.value.trim(),
+            plannedDayElement.value.trim(), plannedYearElement.value.trim());
 
         var newTask = {};
         newTask.body = taskText;
@@ -469,6 +723,11 @@ window.exports.timerId = 0; // Currently active timer ID
         newTask.deadlineMonth = deadlineMonth;
         newTask.deadlineDay = deadlineDay;
         newTask.deadlineYear = deadlineYear;
+        newTask.deadlineTime = deadlineTime;
+        newTask.plannedMonth = planned.month;
+        newTask.plannedDay = planned.day;
+        newTask.plannedYear = planned.year;
+        newTask.priority = priorityElement.value;
 
         estimationMinutesElement.value = "";
         estimationHoursElement.value = "";
@@ -476,20 +735,36 @@ window.exports.timerId = 0; // Currently active timer ID
         deadlineMonthElement.value = "";
         deadlineDayElement.value = "";
         deadlineYearElement.value = "";
+        deadlineTimeElement.value = "";
+        plannedMonthElement.value = "";
+        plannedDayElement.value = "";
+        plannedYearElement.value = "";
+        priorityElement.value = "";
 
 
         if ((newTask.body.localeCompare("") != 0) && (Number(estimationDays) <=31) && (Number(estimationHours) <= 23)
-        && (Number(estimationMinutes) <= 59) && (Number(deadlineMonth) <=12) && (Number(deadlineDay) <= 31)) {
+        && (Number(estimationMinutes) <= 59) && (Number(deadlineMonth) <=12) && (Number(deadlineDay) <= 31)
+        && hasValidTime && (Number(planned.month) <= 12) && (Number(planned.day) <= 31)) {
+            if (editingTaskUUID) {
+                updateTask(editingTaskUUID, newTask, editingTaskRevision);
+                stopEditing();
+                taskTextElement.value = "";
+                return;
+            }
+
             var xhr = new XMLHttpRequest();
             xhr.open('POST', "/api/addTask", true);
             xhr.setRequestHeader('Content-Type', 'application/json');
             xhr.send(newTask.body + "$;" + newTask.estimation + "$;"
-                + newTask.deadlineMonth + "$;" + newTask.deadlineDay + "$;" + newTask.deadlineYear);
+                + newTask.deadlineMonth + "$;" + newTask.deadlineDay + "$;" + newTask.deadlineYear + "$;"
+                + newTask.deadlineTime + "$;"
+                + newTask.plannedMonth + "$;" + newTask.plannedDay + "$;" + newTask.plannedYear + "$;"
+                + newTask.priority);
             taskTextElement.value = "";
             taskTextElement.focus();
             xhr.onreadystatechange = function() {
                 if (xhr.readyState == XMLHttpRequest.DONE) {
-                    Done.renderTasks(xhr.responseText);
+                    Done.renderTasks(xhr.responseText, xhr);
                     if (window.NinstyleSounds) {
                         window.NinstyleSounds.taskCreate();
                     }
@@ -500,19 +775,505 @@ window.exports.timerId = 0; // Currently active timer ID
         }
     }
 
+    /**
+     * Show how the server reads a quick-add line
+     * @param {string} line - Text of the quick-add field
+     */
+    function previewQuickAdd(line) {
+        var preview = document.getElementsByClassName("page_quickAdd_preview")[0];
+        if (line.trim() === "") {
+            preview.textContent = "";
+            suggestionRequest++;
+            return;
+        }
+
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/parseTask", true);
+        xhr.setRequestHeader('Content-Type', 'text/plain; charset=utf-8');
+        xhr.send(line);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                var parsed = JSON.parse(xhr.responseText);
+                FromRFC3339ToJSTime([parsed]);
+
+                // Parts are HTML; tags_readable is already escaped
+                var parts = [escapeHTML(parsed["body"] || "(no text)")];
+                if (parsed["duration_execution_estimated_seconds"] > 0) {
+                    parts.push("⏱ " + parsed["duration_execution_estimated_seconds_readable"]);
+                }
+                parts.push(parsed["time_hard_dead_line_readable"], parsed["time_planned_readable"],
+                    parsed["tags_readable"]);
+                if (parsed["priority"]) {
+                    parts.push("!" + parsed["priority"]);
+                }
+
+                preview.innerHTML = parts.filter(Boolean).map(function(part) {
+                    return "<span>" + part + "</span>";
+                }).join("");
+                suggestEstimate(parsed, preview);
+            }
+        }
+    }
+
+    // Only the suggestion for the latest preview is shown
+    var suggestionRequest = 0;
+
+    /**
+     * Add how long similar completed tasks took to a quick-add preview
+     * @param {Object} parsed - Task parsed from the quick-add line
+     * @param {Element} preview - Preview element to append to
+     */
+    function suggestEstimate(parsed, preview) {
+        var request = ++suggestionRequest;
+        if (!parsed["body"]) {
+            return;
+        }
+
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/estimate", true);
+        xhr.setRequestHeader('Content-Type', 'application/json');
+        xhr.send(JSON.stringify({
+            body: parsed["body"],
+            tags: parsed["tags"] || [],
+            estimate_seconds: parsed["duration_execution_estimated_seconds"] || 0
+        }));
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                var suggestion = JSON.parse(xhr.responseText);
+                if (request !== suggestionRequest || suggestion["confidence"] === "none") {
+                    return;
+                }
+                var span = document.createElement('span');
+                span.className = 'page_quickAdd_suggestion';
+                span.textContent = "≈ " + formatDuration(suggestion["seconds"]) +
+                    " (" + formatDuration(suggestion["low_seconds"]) + "–" + formatDuration(suggestion["high_seconds"]) + ")";
+                span.title = suggestion["similar"].map(function(match) {
+                    return match["body"] + ": " + formatDuration(match["real_seconds"]);
+                }).join("\n") || "Your estimate, corrected by how past estimates turned out";
+                preview.appendChild(span);
+            }
+        }
+    }
+
+    /**
+     * Create a task from the quick-add field
+     */
+    function quickAdd() {
+        var input = document.getElementsByClassName("page_quickAdd_input")[0];
+        var line = input.value.trim();
+        if (line === "") {
+            return;
+        }
+
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/quickAdd", true);
+        xhr.setRequestHeader('Content-Type', 'text/plain; charset=utf-8');
+        xhr.send(line);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE) {
+                if (xhr.status !== 200) {
+                    console.log('Cannot add task:', xhr.responseText);
+                    return;
+                }
+                input.value = "";
+                previewQuickAdd("");
+                Done.renderTasks(xhr.responseText, xhr);
+                if (window.NinstyleSounds) {
+                    window.NinstyleSounds.taskCreate();
+                }
+            }
+        }
+    }
+
+    /**
+     * Build a local Date from completed date fields, or null when unset
+     */
+    function dateFromFields(month, day, year, time) {
+        if (month == "0" && day == "0" && year == "0") {
+            return null;
+        }
+        var date = new Date(parseInt(year), parseInt(month) - 1, parseInt(day));
+        if (time) {
+            var parts = time.split(":");
+            date.setHours(parseInt(parts[0]), parseInt(parts[1]));
+        }
+        return date;
+    }
+
+    /**
+     * Format a date without a time of day as its calendar date at midnight
+     * UTC, which the server reads as that day in its own time zone
+     * @param {Date} date - A date from dateFromFields
+     */
+    function calendarDate(date) {
+        function pad(n) { return (n < 10 ? "0" : "") + n; }
+        return date.getFullYear() + "-" + pad(date.getMonth() + 1) + "-" + pad(date.getDate()) + "T00:00:00Z";
+    }
+
+    /**
+     * Edit an existing task in place, keeping its UUID and timer data
+     * @param {string} taskUUID - UUID of the task being edited
+     * @param {Object} task - Values collected by postTask
+     */
+    function updateTask(taskUUID, task, revision) {
+        var deadline = dateFromFields(task.deadlineMonth, task.deadlineDay, task.deadlineYear, task.deadlineTime);
+        var planned = dateFromFields(task.plannedMonth, task.plannedDay, task.plannedYear, "");
+
+        var patch = {
+            uuid: taskUUID,
+            body: task.body,
+            duration_execution_estimated_seconds: task.estimation,
+            time_hard_dead_line: deadline ? (task.deadlineTime !== "" ? deadline.toISOString() : calendarDate(deadline)) : null,
+            deadline_has_time: deadline != null && task.deadlineTime !== "",
+            time_planned: planned ? calendarDate(planned) : null,
+            priority: task.priority
+        };
+
+        var xhr = new XMLHttpRequest();
+        xhr.open('PATCH', "/api/updateTask", true);
+        xhr.setRequestHeader('Content-Type', 'application/json');
+        if (revision) {
+            xhr.setRequestHeader('If-Match', '"task-' + revision + '"');
+        }
+        xhr.send(JSON.stringify(patch));
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE) {
+                if (xhr.status === 200) {
+                    Done.renderTasks(xhr.responseText, xhr);
+                } else if (xhr.status === 409) {
+                    Done.renderTasks(xhr.responseText, xhr);
+                    notify('⚠️', 'Not saved', 'The task was changed elsewhere in the meantime');
+                } else {
+                    console.error('Failed to update task:', xhr.status, xhr.responseText);
+                    Done.getTasks();
+                }
+            }
+        }
+    }
+
+    /**
+     * Switch the new task form into editing mode for an existing task
+     * @param {string} taskUUID - UUID of the task to edit
+     */
+    function startEditing(taskUUID, revision) {
+        editingTaskUUID = taskUUID;
+        editingTaskRevision = revision || null;
+        var newTaskForm = document.getElementsByClassName("page_newTask")[0];
+        var addTaskButton = document.getElementsByClassName("taskButton")[0];
+        newTaskForm.classList.add("page_newTask_editing");
+        addTaskButton.textContent = "Save";
+        document.getElementsByClassName("taskText")[0].focus();
+    }
+
+    /**
+     * Leave editing mode, the form schedules new tasks again
+     */
+    function stopEditing() {
+        editingTaskUUID = null;
+        editingTaskRevision = null;
+        var newTaskForm = document.getElementsByClassName("page_newTask")[0];
+        var addTaskButton = document.getElementsByClassName("taskButton")[0];
+        newTaskForm.classList.remove("page_newTask_editing");
+        addTaskButton.textContent = "Schedule";
+    }
+
+    /**
+     * Show whether smart sort is on
+     * @param {string} sortMode - "manual" or "smart"
+     */
+    function renderSortMode(sortMode) {
+        sortMode = sortMode === "smart" ? "smart" : "manual";
+        var sortButton = document.getElementsByClassName("page_tasks_sortButton")[0];
+        sortButton.dataset.sortMode = sortMode;
+        sortButton.textContent = "Smart sort: " + (sortMode === "smart" ? "on" : "off");
+        document.getElementsByClassName("page_tasks_unpinButton")[0].style.display = sortMode === "smart" ? "" : "none";
+    }
+
+    /**
+     * Fetch the settings from the API
+     */
+    function getSettings() {
+        var xhr = new XMLHttpRequest();
+        xhr.open('GET', "/api/getSettings", true);
+        xhr.send(null);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                renderSortMode(JSON.parse(xhr.responseText)["sort_mode"]);
+            }
+        }
+    }
+
+    /**
+     * Switch between manual order and smart sort by priority, deadline and
+     * estimate
+     */
+    function toggleSortMode() {
+        var sortButton = document.getElementsByClassName("page_tasks_sortButton")[0];
+        var sortMode = sortButton.dataset.sortMode === "smart" ? "manual" : "smart";
+
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/updateSettings", true);
+        xhr.setRequestHeader('Content-Type', 'application/json');
+        xhr.send(JSON.stringify({sort_mode: sortMode}));
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                renderSortMode(sortMode);
+                Done.renderTasks(xhr.responseText, xhr);
+            }
+        }
+    }
+
+    /**
+     * Release the tasks pinned by dragging in smart sort
+     */
+    function unpinTasks() {
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/unpinTasks", true);
+        xhr.send(null);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                Done.renderTasks(xhr.responseText, xhr);
+            }
+        }
+    }
+
+    /**
+     * Fetch a proposal of which tasks fit into the working hours of the
+     * coming week
+     */
+    function getPlan() {
+        var xhr = new XMLHttpRequest();
+        xhr.open('GET', "/api/getPlan?days=7", true);
+        xhr.send(null);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                renderPlan(JSON.parse(xhr.responseText), false);
+            }
+        }
+    }
+
+    /**
+     * Record the shown plan, so the daily report compares it with what was
+     * done
+     */
+    function acceptPlan() {
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/acceptPlan", true);
+        xhr.setRequestHeader('Content-Type', 'application/json');
+        xhr.send(JSON.stringify(shownPlan));
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                renderPlan(JSON.parse(xhr.responseText), true);
+            }
+        }
+    }
+
+    /**
+     * Hide the plan
+     */
+    function closePlan() {
+        document.getElementsByClassName("page_plan")[0].classList.add("page_plan_hidden");
+    }
+
+    // The plan shown, which "Accept plan" records
+    var shownPlan = null;
+
+    /**
+     * Format seconds as 1h30m
+     * @param {number} seconds
+     * @returns {string}
+     */
+    function formatDuration(seconds) {
+        var hours = Math.floor(seconds / 3600);
+        var minutes = Math.floor((seconds % 3600) / 60);
+        return (hours > 0 ? hours + "h" : "") + (minutes > 0 || hours == 0 ? minutes + "m" : "");
+    }
+
+    /**
+     * Format the time of day of an RFC 3339 time, in the zone it was planned in
+     * @param {string} time
+     * @returns {string}
+     */
+    function planClock(time) {
+        return time.substr(11, 5);
+    }
+
+    /**
+     * Show a plan day by day, with the tasks left out and deadline warnings
+     * @param {Object} plan - Plan returned by the API
+     * @param {boolean} accepted - Whether the plan was just recorded
+     */
+    function renderPlan(plan, accepted) {
+        shownPlan = plan;
+        var page = document.getElementsByClassName("page_plan")[0];
+        var content = document.getElementsByClassName("page_plan_content")[0];
+        var acceptButton = document.getElementsByClassName("page_plan_acceptButton")[0];
+        page.classList.remove("page_plan_hidden");
+        acceptButton.textContent = accepted ? "Accepted ✓" : "Accept plan";
+        acceptButton.disabled = accepted;
+        content.innerHTML = "";
+
+        function line(className, text) {
+            var div = document.createElement('div');
+            div.className = className;
+            div.textContent = text;
+            return div;
+        }
+
+        plan.days.forEach(function(day) {
+            if (day.capacity_seconds == 0 && day.tasks.length == 0) {
+                return;
+            }
+            var dayDiv = document.createElement('div');
+            dayDiv.className = 'plan-day';
+            var date = new Date(day.date + "T00:00:00");
+            dayDiv.appendChild(line('plan-day-title', date.toDateString() + " — " +
+                formatDuration(day.planned_seconds) + " of " + formatDuration(day.capacity_seconds)));
+            day.tasks.forEach(function(task) {
+                dayDiv.appendChild(line('plan-task', planClock(task.start) + "–" + planClock(task.end) + "  " + task.body));
+            });
+            content.appendChild(dayDiv);
+        });
+
+        plan.warnings.forEach(function(note) {
+            content.appendChild(line('plan-note plan-warning', "⚠️ " + note.body + ": " + note.reason));
+        });
+        plan.unscheduled.forEach(function(note) {
+            content.appendChild(line('plan-note', "Not planned: " + note.body + " (" + note.reason + ")"));
+        });
+    }
+
+    /**
+     * Fetch trashed tasks from the API
+     */
+    function getTrash() {
+        var xhr = new XMLHttpRequest();
+        xhr.open('GET', "/api/getTrash", true);
+        xhr.send(null);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                renderTrash(xhr.responseText);
+            }
+        }
+    }
+
+    /**
+     * Render trashed tasks with restore and purge buttons
+     * @param {string} tasksJSON - JSON string containing trashed task data
+     */
+    function renderTrash(tasksJSON) {
+        var trash = document.getElementsByClassName("page_trash")[0];
+        var content = document.getElementsByClassName("page_trash_content")[0];
+        var trashedTasks = replaceQuotes(JSON.parse(tasksJSON));
+        content.innerHTML = "";
+
+        trash.classList.toggle("page_trash_empty", trashedTasks == null || trashedTasks.length == 0);
+        if (trashedTasks == null) {
+            return;
+        }
+
+        trashedTasks.forEach(function(task) {
+            var taskDiv = document.createElement('div');
+            taskDiv.className = 'trashed-task';
+
+            var taskName = document.createElement('div');
+            taskName.className = 'task-name';
+            taskName.textContent = task.body;
+
+            var restoreButton = document.createElement('button');
+            restoreButton.className = 'trashButton';
+            restoreButton.textContent = 'Restore';
+            restoreButton.addEventListener('click', function() {
+                restoreTask(task.uuid);
+            });
+
+            var purgeButton = document.createElement('button');
+            purgeButton.className = 'trashButton';
+            purgeButton.textContent = 'Delete forever';
+            purgeButton.addEventListener('click', function() {
+                purgeTask(task.uuid);
+            });
+
+            taskDiv.appendChild(taskName);
+            taskDiv.appendChild(restoreButton);
+            taskDiv.appendChild(purgeButton);
+            content.appendChild(taskDiv);
+        });
+    }
+
+    /**
+     * Move a trashed task back to the task list
+     * @param {string} taskUUID - UUID of the trashed task
+     */
+    function restoreTask(taskUUID) {
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/restoreTask", true);
+        xhr.setRequestHeader('Content-Type', 'text/plain');
+        xhr.send(taskUUID);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE) {
+                if (xhr.status === 200) {
+                    Done.renderTasks(xhr.responseText, xhr);
+                }
+                getTrash();
+            }
+        }
+    }
+
+    /**
+     * Permanently delete a trashed task
+     * @param {string} taskUUID - UUID of the trashed task
+     */
+    function purgeTask(taskUUID) {
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/purgeTask", true);
+        xhr.setRequestHeader('Content-Type', 'text/plain');
+        xhr.send(taskUUID);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                renderTrash(xhr.responseText);
+            }
+        }
+    }
+
+    /**
+     * Permanently delete every trashed task
+     */
+    function emptyTrash() {
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/emptyTrash", true);
+        xhr.send(null);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE && xhr.status === 200) {
+                renderTrash(xhr.responseText);
+            }
+        }
+    }
+
     /**
      * Mark a task as completed
      * @param {string} taskUUID - UUID of the task to complete
      */
-    function completeTask(taskUUID) {
+    function completeTask(taskUUID, force) {
         var xhr = new XMLHttpRequest();
-        xhr.open('POST', "/api/completeTask", true);
+        xhr.open('POST', "/api/completeTask" + (force ? "?force=true" : ""), true);
         xhr.setRequestHeader('Content-Type', 'text/plain')
         xhr.send(taskUUID);
         xhr.onreadystatechange = function() {
             if (xhr.readyState == XMLHttpRequest.DONE) {
-                Done.renderTasks(xhr.responseText);
+                // Blocked by open tasks: ask before completing it anyway
+                if (xhr.status === 409) {
+                    window.customConfirm(xhr.responseText.trim() + '. Complete it anyway?', 'complete')
+                        .then(function(confirmed) {
+                            if (confirmed) {
+                                Done.completeTask(taskUUID, true);
+                            }
+                        });
+                    return;
+                }
+                Done.renderTasks(xhr.responseText, xhr);
                 Done.getTodayResults();
+                showUnblocked(xhr.getResponseHeader("X-Done-Unblocked"), xhr.responseText);
                 if (window.NinstyleSounds) {
                     window.NinstyleSounds.taskComplete();
                 }
@@ -520,6 +1281,173 @@ window.exports.timerId = 0; // Currently active timer ID
         }
     }
 
+    /**
+     * Announce the tasks a completion unblocked
+     * @param {string} header - Comma separated UUIDs, may be null
+     * @param {string} tasksJSON - Task list returned with the header
+     */
+    function showUnblocked(header, tasksJSON) {
+        if (!header) {
+            return;
+        }
+        var uuids = header.split(",");
+        var names = JSON.parse(tasksJSON).filter(function(task) {
+            return uuids.indexOf(task["uuid"]) >= 0;
+        }).map(function(task) {
+            return window.TaskUtils.cleanTaskText(task["body"]).split("\n")[0];
+        });
+
+        notify('🔓', 'Ready to start', names.join(", "));
+    }
+
+    /**
+     * Show a short notification in the style of the achievements
+     * @param {string} icon - Emoji shown on the left
+     * @param {string} title - Title of the notification
+     * @param {string} description - Text below the title
+     */
+    function notify(icon, title, description) {
+        var notification = document.createElement('div');
+        notification.className = 'achievement-notification';
+        notification.innerHTML = '<div class="achievement-icon"></div>'
+            + '<div class="achievement-content"><div class="achievement-title"></div>'
+            + '<div class="achievement-description"></div></div>';
+        notification.getElementsByClassName("achievement-icon")[0].textContent = icon;
+        notification.getElementsByClassName("achievement-title")[0].textContent = title;
+        notification.getElementsByClassName("achievement-description")[0].textContent = description;
+        document.body.appendChild(notification);
+
+        setTimeout(function() {
+            notification.classList.add('show');
+        }, 100);
+        setTimeout(function() {
+            notification.classList.remove('show');
+            setTimeout(function() { notification.remove(); }, 500);
+        }, 4000);
+    }
+
+    /**
+     * Ask who is signed in. On a server with users, shows the user with a
+     * sign-out link, or the sign-in form when nobody is signed in.
+     * @param {Function} ready - Called once the lists can be loaded
+     */
+    function getMe(ready) {
+        var xhr = new XMLHttpRequest();
+        xhr.open('GET', "/api/me", true);
+        xhr.send(null);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState != XMLHttpRequest.DONE) {
+                return;
+            }
+            var passwords = xhr.getResponseHeader("X-Done-Auth") !== "trusted";
+            if (xhr.status === 401 || xhr.status === 403) {
+                showSignIn(xhr.responseText.trim(), passwords);
+                return;
+            }
+            if (xhr.status !== 200) {
+                return;
+            }
+
+            var user = JSON.parse(xhr.responseText);
+            if (user) {
+                var userElement = document.getElementsByClassName("footer_user")[0];
+                userElement.textContent = "Signed in as " + user.name + ". ";
+                var signOut = document.createElement("a");
+                signOut.href = "#";
+                signOut.textContent = "Sign out";
+                signOut.addEventListener("click", function(e) {
+                    e.preventDefault();
+                    signOutUser();
+                });
+                userElement.appendChild(signOut);
+            }
+            ready();
+        };
+    }
+
+    /**
+     * Show the sign-in form; signing in reloads the page as the new user
+     * @param {string} message - Why sign-in is needed, shown above the form
+     * @param {boolean} passwords - Whether the server asks for a password
+     */
+    function showSignIn(message, passwords) {
+        var modal = document.createElement("div");
+        modal.className = "confirm-modal signIn";
+        modal.innerHTML = '<form class="confirm-modal-content">'
+            + '<div class="confirm-modal-title">👤 Sign In</div>'
+            + '<div class="confirm-modal-message"></div>'
+            + '<input type="text" class="form-control page_input_text signIn_name" placeholder="Your name" autocomplete="username"/>'
+            + (passwords ? '<input type="password" class="form-control page_input_text signIn_password" placeholder="Password" autocomplete="current-password"/>' : '')
+            + '<div class="confirm-modal-buttons">'
+            + '<button type="submit" class="confirm-modal-button confirm">Sign in</button>'
+            + '</div></form>';
+        modal.getElementsByClassName("confirm-modal-message")[0].textContent = message;
+        document.body.appendChild(modal);
+        setTimeout(function() { modal.classList.add('show'); }, 10);
+
+        var nameElement = modal.getElementsByClassName("signIn_name")[0];
+        nameElement.focus();
+        modal.getElementsByTagName("form")[0].addEventListener("submit", function(e) {
+            e.preventDefault();
+            var xhr = new XMLHttpRequest();
+            xhr.open('POST', "/api/login", true);
+            xhr.setRequestHeader('Content-Type', 'application/json');
+            var passwordElement = modal.getElementsByClassName("signIn_password")[0];
+            xhr.send(JSON.stringify({
+                name: nameElement.value.trim(),
+                password: passwordElement ? passwordElement.value : ""
+            }));
+            xhr.onreadystatechange = function() {
+                if (xhr.readyState != XMLHttpRequest.DONE) {
+                    return;
+                }
+                if (xhr.status === 200) {
+                    window.location.reload();
+                    return;
+                }
+                modal.getElementsByClassName("confirm-modal-message")[0].textContent = xhr.responseText.trim();
+            };
+        });
+    }
+
+    /**
+     * Sign out and show the sign-in form
+     */
+    function signOutUser() {
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/logout", true);
+        xhr.send(null);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE) {
+                window.location.reload();
+            }
+        };
+    }
+
+    /**
+     * Move a completed task back to the task list
+     * @param {string} taskUUID - UUID of the completed task
+     */
+    function reopenTask(taskUUID) {
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/reopenTask", true);
+        xhr.setRequestHeader('Content-Type', 'text/plain')
+        xhr.send(taskUUID);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE) {
+                if (xhr.status === 200) {
+                    Done.renderTasks(xhr.responseText, xhr);
+                } else {
+                    console.error('Failed to reopen task:', xhr.status, xhr.responseText);
+                }
+                Done.getTodayResults();
+                if (window.Gamification) {
+                    window.Gamification.updatePointsDisplay();
+                }
+            }
+        }
+    }
+
     /**
      * Delete a task
      * @param {string} taskUUID - UUID of the task to delete
@@ -531,7 +1459,8 @@ window.exports.timerId = 0; // Currently active timer ID
         xhr.send(taskUUID);
         xhr.onreadystatechange = function() {
             if (xhr.readyState == XMLHttpRequest.DONE) {
-                Done.renderTasks(xhr.responseText);
+                Done.renderTasks(xhr.responseText, xhr);
+                Done.getTrash();
                 if (window.NinstyleSounds) {
                     window.NinstyleSounds.taskDelete();
                 }
@@ -552,13 +1481,19 @@ window.exports.timerId = 0; // Currently active timer ID
         var xhr = new XMLHttpRequest();
         xhr.open('POST', "/api/rearrangeTasks", true);
         xhr.setRequestHeader('Content-Type', 'text/plain')
+        if (tasksETag) {
+            xhr.setRequestHeader('If-Match', tasksETag);
+        }
         xhr.send(rearrangeTasksData);
         xhr.onreadystatechange = function() {
             if (xhr.readyState == XMLHttpRequest.DONE) {
                 console.log('Rearrange response status:', xhr.status);
                 console.log('Rearrange response:', xhr.responseText);
                 if (xhr.status === 200) {
-                    Done.renderTasks(xhr.responseText);
+                    Done.renderTasks(xhr.responseText, xhr);
+                } else if (xhr.status === 409) {
+                    Done.renderTasks(xhr.responseText, xhr);
+                    notify('⚠️', 'Not moved', 'The list was changed elsewhere in the meantime');
                 } else {
                     console.error('Failed to rearrange tasks:', xhr.status, xhr.responseText);
                     // Reload tasks to restore original order
@@ -568,15 +1503,75 @@ window.exports.timerId = 0; // Currently active timer ID
         }
     }
 
+    /**
+     * Revert or re-apply the last change made in this browser session
+     * @param {string} action - "undo" or "redo"
+     */
+    function replay(action) {
+        var xhr = new XMLHttpRequest();
+        xhr.open('POST', "/api/" + action, true);
+        xhr.send(null);
+        xhr.onreadystatechange = function() {
+            if (xhr.readyState == XMLHttpRequest.DONE) {
+                if (xhr.status !== 200) {
+                    console.log('Cannot ' + action + ':', xhr.responseText);
+                    return;
+                }
+                Done.stopEditing();
+                Done.renderTasks(xhr.responseText, xhr);
+                Done.getTodayResults();
+                Done.getTrash();
+                if (window.Gamification) {
+                    window.Gamification.updatePointsDisplay();
+                }
+            }
+        }
+    }
+
+    /**
+     * Revert the last change
+     */
+    function undo() {
+        replay("undo");
+    }
+
+    /**
+     * Apply the last undone change again
+     */
+    function redo() {
+        replay("redo");
+    }
+
     // Export public API
     Done.renderTasks = renderTasks;
     Done.getTasks = getTasks;
     Done.postTask = postTask;
+    Done.previewQuickAdd = previewQuickAdd;
+    Done.quickAdd = quickAdd;
     Done.completeTask = completeTask;
+    Done.reopenTask = reopenTask;
+    Done.getTrash = getTrash;
+    Done.getSettings = getSettings;
+    Done.toggleSortMode = toggleSortMode;
+    Done.unpinTasks = unpinTasks;
+    Done.getPlan = getPlan;
+    Done.acceptPlan = acceptPlan;
+    Done.closePlan = closePlan;
+    Done.restoreTask = restoreTask;
+    Done.purgeTask = purgeTask;
+    Done.emptyTrash = emptyTrash;
     Done.removeTask = removeTask;
     Done.getTodayResults = getTodayResults;
     Done.renderTodayResults = renderTodayResults;
+    Done.showDay = showDay;
+    Done.watchEvents = watchEvents;
+    Done.getMe = getMe;
     Done.rearrangeTasks = rearrangeTasks;
+    Done.updateTask = updateTask;
+    Done.startEditing = startEditing;
+    Done.stopEditing = stopEditing;
+    Done.undo = undo;
+    Done.redo = redo;
 })(window.exports.Done || (window.exports.Done = {}));
 
 // Make Done available globally
@@ -604,8 +1599,13 @@ var Done = window.exports.Done;
         deadlineMonthElement.placeholder = 0;
         deadlineDayElement.placeholder = 0;
 
-        Done.getTasks();
-        Done.getTodayResults();
+        Done.getMe(function() {
+            Done.getTasks();
+            Done.getTodayResults();
+            Done.getTrash();
+            Done.watchEvents();
+            Done.getSettings();
+        });
         
         // Fetch and display build info
         fetchBuildInfo();
@@ -613,6 +1613,31 @@ var Done = window.exports.Done;
         var addTaskButton = document.getElementsByClassName("taskButton")[0];
 
         addTaskButton.addEventListener('click', Done.postTask);
+
+        var sortButton = document.getElementsByClassName("page_tasks_sortButton")[0];
+        sortButton.addEventListener('click', Done.toggleSortMode);
+        var unpinButton = document.getElementsByClassName("page_tasks_unpinButton")[0];
+        unpinButton.addEventListener('click', Done.unpinTasks);
+        var prevDayButton = document.getElementsByClassName("page_today_prevButton")[0];
+        prevDayButton.addEventListener('click', function() { Done.showDay(-1); });
+        var nextDayButton = document.getElementsByClassName("page_today_nextButton")[0];
+        nextDayButton.addEventListener('click', function() { Done.showDay(1); });
+        var planButton = document.getElementsByClassName("page_tasks_planButton")[0];
+        planButton.addEventListener('click', Done.getPlan);
+        var acceptPlanButton = document.getElementsByClassName("page_plan_acceptButton")[0];
+        acceptPlanButton.addEventListener('click', Done.acceptPlan);
+        var closePlanButton = document.getElementsByClassName("page_plan_closeButton")[0];
+        closePlanButton.addEventListener('click', Done.closePlan);
+
+        var emptyTrashButton = document.getElementsByClassName("page_trash_emptyButton")[0];
+        emptyTrashButton.addEventListener('click', function() {
+            window.customConfirm('Permanently delete all tasks in the trash?', 'delete')
+                .then(function(confirmed) {
+                    if (confirmed) {
+                        Done.emptyTrash();
+                    }
+                });
+        });
         
         // Add drop zone for empty task list
         var tasksContent = document.querySelector('.page_tasks_content');
@@ -634,6 +1659,41 @@ var Done = window.exports.Done;
             });
         }
         
+        // Ctrl/Cmd+Z undoes the last change, Ctrl/Cmd+Shift+Z redoes it. Text
+        // fields keep their own undo.
+        document.addEventListener('keydown', function(e) {
+            if (!(e.ctrlKey || e.metaKey) || e.key.toLowerCase() !== 'z') {
+                return;
+            }
+            var target = e.target;
+            if (target.tagName === 'INPUT' || target.tagName === 'TEXTAREA' || target.isContentEditable) {
+                return;
+            }
+            e.preventDefault();
+            if (e.shiftKey) {
+                Done.redo();
+            } else {
+                Done.undo();
+            }
+        });
+
+        // Quick add previews the parsed line while typing and adds it on Enter
+        var quickAddElement = document.getElementsByClassName("page_quickAdd_input")[0];
+        var quickAddTimer = null;
+        quickAddElement.addEventListener('input', function() {
+            clearTimeout(quickAddTimer);
+            quickAddTimer = setTimeout(function() {
+                Done.previewQuickAdd(quickAddElement.value);
+            }, 250);
+        });
+        quickAddElement.addEventListener('keydown', function(e) {
+            if (e.key === 'Enter') {
+                e.preventDefault();
+                clearTimeout(quickAddTimer);
+                Done.quickAdd();
+            }
+        });
+
         // Add keyboard shortcuts and clipboard support for textarea
         var taskTextElement = document.getElementsByClassName("taskText")[0];
         taskTextElement.addEventListener('keydown', function(e) {
@@ -641,6 +1701,10 @@ var Done = window.exports.Done;
                 e.preventDefault();
                 Done.postTask();
             }
+            // Escape leaves task editing mode
+            if (e.key === 'Escape') {
+                Done.stopEditing();
+            }
             // Ensure default clipboard hotkeys still work
             if ((e.ctrlKey || e.metaKey) && (e.key === 'v' || e.key === 'c' || e.key === 'x' || e.key === 'a')) {
                 return true;
@@ -122,6 +122,11 @@
             var deadlineMonthElement = document.getElementsByClassName("page_newTask_timeHardDeadlineMonth_value")[0];
             var deadlineDayElement = document.getElementsByClassName("page_newTask_timeHardDeadlineDay_value")[0];
             var deadlineYearElement = document.getElementsByClassName("page_newTask_timeHardDeadlineYear_value")[0];
+            var deadlineTimeElement = document.getElementsByClassName("page_newTask_timeHardDeadlineTime_value")[0];
+            var plannedMonthElement = document.getElementsByClassName("page_newTask_timePlannedMonth_value")[0];
+            var plannedDayElement = document.getElementsByClassName("page_newTask_timePlannedDay_value")[0];
+            var plannedYearElement = document.getElementsByClassName("page_newTask_timePlannedYear_value")[0];
+            var priorityElement = document.getElementsByClassName("page_newTask_priority_value")[0];
 
             // Get text content preserving line breaks
             var taskContent = task_visible_content.innerText || task_visible_content.textContent;
@@ -137,20 +142,41 @@
             estimationHoursElement.value = duration_hours;
             estimationMinutesElement.value = duration_minutes;
 
-            var deadlineDate = new Date(currentTemplate.dataset.time_hard_dead_line);
-            var deadlineYear = deadlineDate.getFullYear();
-            var deadMonth = deadlineDate.getMonth() + 1; // JS months are 0-indexed
-            var deadDay = deadlineDate.getDate();
-            if (deadlineYear != 9999) {
-                deadlineMonthElement.value = deadMonth;
-                deadlineDayElement.value = deadDay;
-                deadlineYearElement.value = deadlineYear;
+            var deadline = currentTemplate.dataset.time_hard_dead_line;
+            if (deadline && deadline != "null") {
+                var deadlineDate = new Date(deadline);
+                deadlineMonthElement.value = deadlineDate.getMonth() + 1; // JS months are 0-indexed
+                deadlineDayElement.value = deadlineDate.getDate();
+                deadlineYearElement.value = deadlineDate.getFullYear();
+                if (currentTemplate.dataset.deadline_has_time == "true") {
+                    deadlineTimeElement.value = ("0" + deadlineDate.getHours()).slice(-2) + ":"
+                        + ("0" + deadlineDate.getMinutes()).slice(-2);
+                } else {
+                    deadlineTimeElement.value = "";
+                }
             } else {
                 deadlineMonthElement.value = "";
                 deadlineDayElement.value = "";
                 deadlineYearElement.value = "";
+                deadlineTimeElement.value = "";
             }
 
+            var planned = currentTemplate.dataset.time_planned;
+            if (planned && planned != "null") {
+                var plannedDate = new Date(planned);
+                plannedMonthElement.value = plannedDate.getMonth() + 1;
+                plannedDayElement.value = plannedDate.getDate();
+                plannedYearElement.value = plannedDate.getFullYear();
+            } else {
+                plannedMonthElement.value = "";
+                plannedDayElement.value = "";
+                plannedYearElement.value = "";
+            }
+
+            priorityElement.value = currentTemplate.dataset.priority;
+
+            Done.startEditing(currentTemplate.dataset.uuid, currentTemplate.dataset.revision);
+
 
 
         }
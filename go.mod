@@ -3,11 +3,11 @@ module done
 require (
 	github.com/boltdb/bolt v1.3.1
 	github.com/satori/go.uuid v1.2.0
+	golang.org/x/sys v0.34.0
 )
 
 require (
 	github.com/kr/pretty v0.3.1 // indirect
-	golang.org/x/sys v0.34.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 )
 
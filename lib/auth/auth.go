@@ -0,0 +1,160 @@
+// Package auth hashes passwords and generates the secrets behind sessions and
+// API tokens. Secrets are only ever stored as their hashes.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ScopeRead allows reading tasks, history and statistics
+	ScopeRead = "read"
+	// ScopeWrite allows everything a signed in user may do
+	ScopeWrite = "write"
+
+	// TokenPrefix starts every API token, so leaked tokens are easy to find
+	TokenPrefix = "done_"
+
+	// MinPasswordLength is the length passwords must have at least
+	MinPasswordLength = 8
+
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 210000
+	saltBytes          = 16
+	keyBytes           = 32
+	secretBytes        = 32
+)
+
+// ErrInvalidHash is returned for a stored password hash in an unknown format
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// ValidScope reports whether scope is ScopeRead or ScopeWrite
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeWrite
+}
+
+// Allows reports whether scope grants the access a route needs, ScopeRead
+// for routes that change nothing and ScopeWrite for the others
+func Allows(scope, access string) bool {
+	switch scope {
+	case ScopeWrite:
+		return ValidScope(access)
+	case ScopeRead:
+		return access == ScopeRead
+	}
+	return false
+}
+
+// CheckPasswordStrength returns an error for passwords too weak to protect
+// a server others can reach
+func CheckPasswordStrength(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must have at least %d characters", MinPasswordLength)
+	}
+	return nil
+}
+
+// HashPassword returns a salted PBKDF2 hash of password, in the form
+// pbkdf2-sha256$<iterations>$<salt>$<key>
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, saltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return encodeHash(passwordIterations, salt, pbkdf2([]byte(password), salt, passwordIterations, keyBytes)), nil
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword
+func CheckPassword(hash, password string) (bool, error) {
+	iterations, salt, key, err := decodeHash(hash)
+	if err != nil {
+		return false, err
+	}
+	derived := pbkdf2([]byte(password), salt, iterations, len(key))
+	return subtle.ConstantTimeCompare(derived, key) == 1, nil
+}
+
+func encodeHash(iterations int, salt, key []byte) string {
+	return strings.Join([]string{
+		passwordScheme,
+		strconv.Itoa(iterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$")
+}
+
+func decodeHash(hash string) (iterations int, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	if iterations, err = strconv.Atoi(parts[1]); err != nil || iterations < 1 {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil || len(key) == 0 {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	return iterations, salt, key, nil
+}
+
+// pbkdf2 derives a key of keyLen bytes from password as in RFC 8018, with
+// HMAC-SHA256 as the pseudorandom function
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	size := prf.Size()
+	blocks := (keyLen + size - 1) / size
+
+	key := make([]byte, 0, blocks*size)
+	u := make([]byte, size)
+	t := make([]byte, size)
+	var counter [4]byte
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// NewSecret returns a random secret starting with prefix, for a session
+// cookie or an API token
+func NewSecret(prefix string) (string, error) {
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashSecret returns the hash a secret is stored and looked up by. Secrets
+// are random and long, so a fast hash is enough.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2MatchesRFC7914Vectors(t *testing.T) {
+	cases := []struct {
+		password, salt string
+		iterations     int
+		key            string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, c := range cases {
+		got := hex.EncodeToString(pbkdf2([]byte(c.password), []byte(c.salt), c.iterations, len(c.key)/2))
+		if got != c.key {
+			t.Errorf("pbkdf2(%q, %q, %d) = %s, want %s", c.password, c.salt, c.iterations, got, c.key)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$") {
+		t.Fatalf("hash %q has no scheme", hash)
+	}
+
+	if ok, err := CheckPassword(hash, "correct horse"); !ok || err != nil {
+		t.Errorf("right password: %v, %v", ok, err)
+	}
+	if ok, err := CheckPassword(hash, "correct horse "); ok || err != nil {
+		t.Errorf("wrong password: %v, %v", ok, err)
+	}
+
+	other, _ := HashPassword("correct horse")
+	if other == hash {
+		t.Error("hashes of the same password share a salt")
+	}
+}
+
+func TestCheckPasswordRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{"", "plain", "bcrypt$1$c2FsdA$a2V5", "pbkdf2-sha256$0$c2FsdA$a2V5", "pbkdf2-sha256$10$!!$a2V5"} {
+		if _, err := CheckPassword(hash, "x"); err != ErrInvalidHash {
+			t.Errorf("CheckPassword(%q) error = %v, want ErrInvalidHash", hash, err)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	cases := []struct {
+		scope, access string
+		allowed       bool
+	}{
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeWrite, false},
+		{ScopeRead, "", false},
+		{ScopeWrite, ScopeRead, true},
+		{ScopeWrite, ScopeWrite, true},
+		{"admin", ScopeRead, false},
+		{"", ScopeRead, false},
+	}
+	for _, c := range cases {
+		if got := Allows(c.scope, c.access); got != c.allowed {
+			t.Errorf("Allows(%q, %q) = %v, want %v", c.scope, c.access, got, c.allowed)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret(TokenPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSecret(TokenPrefix)
+	if !strings.HasPrefix(a, TokenPrefix) || a == b {
+		t.Errorf("secrets %q and %q", a, b)
+	}
+	if HashSecret(a) == HashSecret(b) || HashSecret(a) != HashSecret(a) {
+		t.Error("HashSecret is not a function of the secret")
+	}
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// parseInterspersed parses flags that may come before, between or after the
+// positional arguments, which the flag package alone does not allow. Anything
+// after "--" is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// Location is the time zone dates on the command line are read in, the one
+// set with -timezone
+var Location = time.Local
+
+// dateLayouts are the accepted forms of --due and --plan, in Location
+var dateLayouts = []struct {
+	layout  string
+	hasTime bool
+}{
+	{"2006-01-02", false},
+	{"2006-01-02T15:04", true},
+	{"2006-01-02 15:04", true},
+}
+
+// parseDate parses a date with an optional time of day. Dates without a
+// time are returned at midnight and hasTime is false.
+func parseDate(s string) (date *time.Time, hasTime bool, err error) {
+	s = strings.TrimSpace(s)
+	for _, l := range dateLayouts {
+		t, err := time.ParseInLocation(l.layout, s, Location)
+		if err == nil {
+			return &t, l.hasTime, nil
+		}
+	}
+	return nil, false, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or YYYY-MM-DDTHH:MM", s)
+}
+
+// resolveTask finds the task a command argument refers to: its position in
+// the list, counting from 1, or a unique prefix of its UUID
+func resolveTask(tasks []database.Task, ref string) (*database.Task, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if ref == "" {
+		return nil, fmt.Errorf("empty task reference")
+	}
+
+	if position, err := strconv.Atoi(ref); err == nil && position >= 1 && position <= len(tasks) {
+		return &tasks[position-1], nil
+	}
+
+	var matches []*database.Task
+	for i := range tasks {
+		if strings.HasPrefix(tasks[i].UUID, ref) {
+			matches = append(matches, &tasks[i])
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no task matches %q", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		ids := make([]string, len(matches))
+		for i, task := range matches {
+			ids[i] = shortUUID(task.UUID)
+		}
+		return nil, fmt.Errorf("%q matches several tasks: %s", ref, strings.Join(ids, ", "))
+	}
+}
+
+// parseClockRange splits working or busy hours such as 09:00-17:00
+func parseClockRange(s string) (start, end string, err error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", s)
+	}
+	for _, part := range parts {
+		if _, err := time.Parse("15:04", part); err != nil {
+			return "", "", fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", s)
+		}
+	}
+	return parts[0], parts[1], nil
+}
+
+// parseWeekdays reads a list of days such as mon,tue,wed or mon-fri into
+// weekday numbers, 0 being Sunday
+func parseWeekdays(s string) ([]int, error) {
+	var days []int
+	for _, item := range strings.Split(strings.ToLower(s), ",") {
+		bounds := strings.SplitN(item, "-", 2)
+		first, ok := weekdays[bounds[0]]
+		last := first
+		if ok && len(bounds) == 2 {
+			last, ok = weekdays[bounds[1]]
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q, expected mon, tue, wed, thu, fri, sat or sun", item)
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+var weekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	database "done/lib/database/interface"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	est := fs.String("est", "", "")
+	due := fs.String("due", "", "")
+
+	positional, err := parseInterspersed(fs, []string{"Write", "--est", "1h", "report", "--due=2026-11-01", "--", "--draft"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"Write", "report", "--draft"}; !reflect.DeepEqual(positional, want) {
+		t.Errorf("positional = %q, want %q", positional, want)
+	}
+	if *est != "1h" || *due != "2026-11-01" {
+		t.Errorf("est = %q, due = %q", *est, *due)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	date, hasTime, err := parseDate("2026-11-01")
+	if err != nil || hasTime || date.Hour() != 0 || date.Day() != 1 {
+		t.Errorf("parseDate(date) = %v, %v, %v", date, hasTime, err)
+	}
+
+	date, hasTime, err = parseDate("2026-11-01T15:30")
+	if err != nil || !hasTime || date.Hour() != 15 || date.Minute() != 30 {
+		t.Errorf("parseDate(date and time) = %v, %v, %v", date, hasTime, err)
+	}
+
+	if _, _, err := parseDate("11/01/2026"); err == nil {
+		t.Error("parseDate should reject other formats")
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	days, err := parseWeekdays("mon-fri")
+	if want := []int{1, 2, 3, 4, 5}; err != nil || !reflect.DeepEqual(days, want) {
+		t.Errorf("range: got %v, %v", days, err)
+	}
+
+	days, err = parseWeekdays("Sat,sun")
+	if want := []int{6, 0}; err != nil || !reflect.DeepEqual(days, want) {
+		t.Errorf("list: got %v, %v", days, err)
+	}
+
+	days, err = parseWeekdays("fri-mon")
+	if want := []int{5, 6, 0, 1}; err != nil || !reflect.DeepEqual(days, want) {
+		t.Errorf("range over the weekend: got %v, %v", days, err)
+	}
+
+	if _, err := parseWeekdays("monday"); err == nil {
+		t.Error("parseWeekdays should reject full names")
+	}
+}
+
+func TestResolveTask(t *testing.T) {
+	tasks := []database.Task{
+		{UUID: "3f2a9c1e-0000-0000-0000-000000000000"},
+		{UUID: "3f2b0000-0000-0000-0000-000000000000"},
+		{UUID: "a1000000-0000-0000-0000-000000000000"},
+	}
+
+	if task, err := resolveTask(tasks, "2"); err != nil || task.UUID != tasks[1].UUID {
+		t.Errorf("position: got %v, %v", task, err)
+	}
+	if task, err := resolveTask(tasks, "3F2A"); err != nil || task.UUID != tasks[0].UUID {
+		t.Errorf("prefix: got %v, %v", task, err)
+	}
+	if _, err := resolveTask(tasks, "3f2"); err == nil {
+		t.Error("ambiguous prefix should fail")
+	}
+	if _, err := resolveTask(tasks, "ff"); err == nil {
+		t.Error("unknown prefix should fail")
+	}
+}
@@ -0,0 +1,1565 @@
+// Package cli implements the task management subcommands of the done binary,
+// e.g. "done add", "done ls" and "done complete".
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"done/lib/client"
+	handlers "done/lib/database"
+	database "done/lib/database/interface"
+	"done/lib/digest"
+	"done/lib/estimator"
+	"done/lib/planner"
+	"done/lib/reminder"
+	"done/lib/tui"
+	"done/lib/utils"
+)
+
+// command runs a subcommand with the arguments following its name
+type command struct {
+	usage string
+	run   func(c client.Client, args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"add":         {"add <text> [--est 1h30m] [--due 2026-11-01[T15:00]] [--plan 2026-10-30] [--priority high|medium|low]", runAdd},
+	"quick":       {"quick [--preview] <text ~est due <date> plan <date> #tag !priority>", runQuick},
+	"estimate":    {"estimate <text ~est #tag>", runEstimate},
+	"ls":          {"ls", runList},
+	"complete":    {"complete [--force] <task>", runComplete},
+	"block":       {"block <task> <blocking task>...", runBlock},
+	"unblock":     {"unblock <task>", runUnblock},
+	"rm":          {"rm <task>", runRemove},
+	"move":        {"move <task> <target task>", runMove},
+	"today":       {"today", runToday},
+	"history":     {"history [--from 2026-03-01] [--to 2026-03-31] [--oldest] [--limit 20] [--cursor <next>]", runHistory},
+	"search":      {"search [--limit 20] <words \"phrase\" #tag in:completed on:march>", runSearch},
+	"sort":        {"sort manual|smart", runSort},
+	"plan":        {"plan [--days 7] [--accept]", runPlan},
+	"hours":       {"hours [09:00-17:00 [mon-fri]]", runHours},
+	"busy":        {"busy [add <date> <HH:MM-HH:MM> [title] | clear]", runBusy},
+	"import":      {"import [--format taskwarrior|todotxt|todoist] [--dry-run] <file>", runImport},
+	"tui":         {"tui", runTUI},
+	"user":        {"user [ls | add <name> [--admin] | passwd <name> | disable <name> | enable <name>]", runUser},
+	"token":       {"token [ls | add <name> [--scope read|write] | revoke <id>]", runToken},
+	"leaderboard": {"leaderboard [--metric points|completed|streak] [--week 2026-10-19] | join | leave", runLeaderboard},
+	"challenge":   {"challenge [ls | add <title> --target 500 --deadline 2026-10-30 [--metric points|completed] | join <id> | leave <id> | rm <id>]", runChallenge},
+	"list":        {"list [ls | add <name> [<member>...] | members <name> <member>...]", runSharedList},
+	"assign":      {"assign <task> [<user>]", runAssign},
+	"webhook":     {"webhook [ls | add <url> [--events task.completed,level.up] | rm <id> | test <id>]", runWebhook},
+	"reminders":   {"reminders [on|off] [--before 1d,1h] [--overdue=true|false] [--via events,desktop,email] [--email <address>]", runReminders},
+	"snooze":      {"snooze <task> [1h | 2026-11-01T09:00]", runSnooze},
+	"reports":     {"reports [--daily on|off] [--weekly on|off] [--at 18:00] [--day fri] [--email <address>] | send daily|weekly", runReports},
+}
+
+// commandOrder is the order commands are listed in the usage
+var commandOrder = []string{"add", "quick", "estimate", "ls", "complete", "block", "unblock", "rm", "move", "today", "history", "search", "sort", "plan", "hours", "busy", "import", "tui", "user", "token", "leaderboard", "challenge", "list", "assign", "webhook", "reminders", "snooze", "reports"}
+
+// IsCommand reports whether name is a subcommand, so the binary does not
+// start the server
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok || name == "help"
+}
+
+// Run executes the subcommand named by args[0]. The client is only opened
+// for commands that need it.
+func Run(args []string, open func() (client.Client, error), out io.Writer) error {
+	if len(args) == 0 || args[0] == "help" {
+		Usage(out)
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	c, err := open()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return cmd.run(c, args[1:], out)
+}
+
+// Usage prints the list of subcommands
+func Usage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: done [flags] <command> [arguments]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, name := range commandOrder {
+		fmt.Fprintln(out, "  done "+commands[name].usage)
+	}
+	fmt.Fprintln(out, "\nA <task> is a UUID prefix or the task's position in \"done ls\".")
+	fmt.Fprintln(out, "Commands use the running server on -port, or open -dbpath directly when none is running.")
+	fmt.Fprintln(out, "On a shared server, -user or DONE_USER names the user whose tasks they work on, signed in")
+	fmt.Fprintln(out, "with -token or DONE_TOKEN, or with the password in DONE_PASSWORD. -list or DONE_LIST")
+	fmt.Fprintln(out, "works on a shared list instead.")
+}
+
+func runAdd(c client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	est := fs.String("est", "", "Estimated duration, e.g. 45m, 1h30m or 2d (8 hour days)")
+	due := fs.String("due", "", "Hard deadline, e.g. 2026-11-01 or 2026-11-01T15:00")
+	plan := fs.String("plan", "", "Planned date, e.g. 2026-10-30")
+	priority := fs.String("priority", "", "Priority: high, medium or low")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	body := strings.TrimSpace(strings.Join(positional, " "))
+	if body == "" {
+		return errors.New("task text is required")
+	}
+
+	if !database.ValidPriority(*priority) {
+		return fmt.Errorf("unknown priority %q", *priority)
+	}
+
+	task := database.Task{Body: body, Priority: *priority}
+
+	if *est != "" {
+		task.DurationExecutionEstimatedSeconds, err = utils.ParseEstimate(*est)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *due != "" {
+		task.TimeHardDeadline, task.DeadlineHasTime, err = parseDate(*due)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *plan != "" {
+		task.TimePlanned, _, err = parseDate(*plan)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := c.Add(&task); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Added: %s\n", body)
+	return nil
+}
+
+// runQuick adds a task from a quick-add line, e.g.
+// done quick Write RFC draft ~2h30m due fri #docs !high
+func runQuick(c client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("quick", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	preview := fs.Bool("preview", false, "Only show how the line is read")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	line := strings.TrimSpace(strings.Join(positional, " "))
+	parsed, err := c.ParseTask(line)
+	if err != nil {
+		return err
+	}
+	if parsed.Body == "" {
+		return errors.New("task text is required")
+	}
+
+	task := parsed.Task()
+	summary := parsed.Body
+	if task.DurationExecutionEstimatedSeconds > 0 {
+		summary += "  ~" + utils.FormatEstimate(task.DurationExecutionEstimatedSeconds)
+	}
+	if task.TimeHardDeadline != nil {
+		summary += "  due " + formatDeadline(&task)
+	}
+	if task.TimePlanned != nil {
+		summary += "  plan " + formatDay(task.TimePlanned)
+	}
+	if len(task.Tags) > 0 {
+		summary += "  #" + strings.Join(task.Tags, " #")
+	}
+	if task.Priority != "" {
+		summary += "  !" + task.Priority
+	}
+
+	if *preview {
+		fmt.Fprintf(out, "Preview: %s\n", summary)
+		return nil
+	}
+
+	if err := c.QuickAdd(line); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Added: %s\n", summary)
+	return nil
+}
+
+// runEstimate suggests how long a task will take from similar completed
+// tasks. The task is written as for "done quick"; an estimate in it is
+// corrected by how past estimates turned out.
+func runEstimate(c client.Client, args []string, out io.Writer) error {
+	line := strings.TrimSpace(strings.Join(args, " "))
+	parsed, err := c.ParseTask(line)
+	if err != nil {
+		return err
+	}
+	if parsed.Body == "" {
+		return errors.New("task text is required")
+	}
+
+	task := parsed.Task()
+	s, err := c.Estimate(estimator.Input{
+		Body:            task.Body,
+		Tags:            task.Tags,
+		EstimateSeconds: task.DurationExecutionEstimatedSeconds,
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.Confidence == estimator.ConfidenceNone {
+		fmt.Fprintln(out, "No similar completed tasks to learn from")
+	} else {
+		fmt.Fprintf(out, "Suggested: %s (%s to %s, %s confidence)\n",
+			utils.FormatEstimate(s.Seconds),
+			utils.FormatEstimate(s.LowSeconds),
+			utils.FormatEstimate(s.HighSeconds),
+			s.Confidence,
+		)
+	}
+	for _, match := range s.Similar {
+		fmt.Fprintf(out, "  took %s: %s\n", utils.FormatEstimate(match.RealSeconds), firstLine(match.Body))
+	}
+	for _, tag := range task.Tags {
+		if factor, ok := s.TagFactors[strings.ToLower(tag)]; ok {
+			fmt.Fprintf(out, "  #%s tasks take %.2fx their estimate\n", tag, factor)
+		}
+	}
+	if s.RatioSamples > 0 {
+		fmt.Fprintf(out, "Tasks take %.2fx their estimate overall (%d tasks)\n", s.Ratio, s.RatioSamples)
+	}
+	return nil
+}
+
+func runList(c client.Client, args []string, out io.Writer) error {
+	tasks, err := c.Tasks()
+	if err != nil {
+		return err
+	}
+
+	if len(tasks) == 0 {
+		fmt.Fprintln(out, "No tasks")
+		return nil
+	}
+
+	// Tasks on shared lists show who they are assigned to
+	assigned := false
+	for _, task := range tasks {
+		assigned = assigned || task.Assignee != ""
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := "#\tID\tPRI\tTASK\tEST\tDUE\tPLANNED"
+	if assigned {
+		header += "\tASSIGNEE"
+	}
+	fmt.Fprintln(tw, header)
+	for i, task := range tasks {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s",
+			i+1,
+			shortUUID(task.UUID),
+			formatPriority(&task),
+			firstLine(task.Body),
+			utils.FormatEstimate(task.DurationExecutionEstimatedSeconds),
+			formatDeadline(&task),
+			formatDay(task.TimePlanned),
+		)
+		if assigned {
+			fmt.Fprintf(tw, "\t%s", task.Assignee)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func runComplete(c client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("complete", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	force := fs.Bool("force", false, "Complete the task even if it is blocked")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	task, err := resolveArg(c, positional)
+	if err != nil {
+		return err
+	}
+
+	unblocked, err := c.Complete(task.UUID, *force)
+	if err != nil {
+		if task.Blocked {
+			return fmt.Errorf("%w (use --force to complete it anyway)", err)
+		}
+		return err
+	}
+
+	fmt.Fprintf(out, "Completed: %s\n", firstLine(task.Body))
+	for _, task := range unblocked {
+		fmt.Fprintf(out, "Unblocked: %s\n", firstLine(task.Body))
+	}
+	return nil
+}
+
+// runBlock makes a task wait for other tasks
+func runBlock(c client.Client, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return errors.New("usage: done block <task> <blocking task>...")
+	}
+
+	tasks, err := c.Tasks()
+	if err != nil {
+		return err
+	}
+
+	task, err := resolveTask(tasks, args[0])
+	if err != nil {
+		return err
+	}
+
+	blockers := append([]string(nil), task.BlockedBy...)
+	var names []string
+	for _, arg := range args[1:] {
+		blocker, err := resolveTask(tasks, arg)
+		if err != nil {
+			return err
+		}
+		blockers = append(blockers, blocker.UUID)
+		names = append(names, firstLine(blocker.Body))
+	}
+
+	if err := c.Block(task.UUID, blockers); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Blocked: %s\nWaiting for: %s\n", firstLine(task.Body), strings.Join(names, "; "))
+	return nil
+}
+
+// runUnblock removes all blockers of a task
+func runUnblock(c client.Client, args []string, out io.Writer) error {
+	task, err := resolveArg(c, args)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Block(task.UUID, nil); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Unblocked: %s\n", firstLine(task.Body))
+	return nil
+}
+
+func runRemove(c client.Client, args []string, out io.Writer) error {
+	task, err := resolveArg(c, args)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Remove(task.UUID); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Moved to trash: %s\n", firstLine(task.Body))
+	return nil
+}
+
+func runMove(c client.Client, args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("usage: done move <task> <target task>")
+	}
+
+	tasks, err := c.Tasks()
+	if err != nil {
+		return err
+	}
+
+	source, err := resolveTask(tasks, args[0])
+	if err != nil {
+		return err
+	}
+	destination, err := resolveTask(tasks, args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := c.Move(source.UUID, destination.UUID); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Moved: %s\n", firstLine(source.Body))
+	return nil
+}
+
+func runToday(c client.Client, args []string, out io.Writer) error {
+	tasks, err := c.CompletedToday()
+	if err != nil {
+		return err
+	}
+
+	if len(tasks) == 0 {
+		fmt.Fprintln(out, "Nothing completed today")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, task := range tasks {
+		fmt.Fprintf(tw, "%s\t%s\n", task.TimeCompleted.In(Location).Format("15:04"), firstLine(task.Body))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%d completed today\n", len(tasks))
+	return nil
+}
+
+// runHistory pages through completed tasks, newest first
+func runHistory(c client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	from := fs.String("from", "", "First day, e.g. 2026-03-01")
+	to := fs.String("to", "", "Last day, e.g. 2026-03-31")
+	oldest := fs.Bool("oldest", false, "Oldest first")
+	limit := fs.Int("limit", 20, "Tasks per page")
+	cursor := fs.String("cursor", "", "Continue after the previous page")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 || *limit < 1 {
+		return errors.New("usage: done history [--from 2026-03-01] [--to 2026-03-31] [--oldest] [--limit 20] [--cursor <next>]")
+	}
+
+	query := database.CompletedQuery{Oldest: *oldest, Limit: *limit, Cursor: *cursor}
+	if *from != "" {
+		day, _, err := parseDate(*from)
+		if err != nil {
+			return err
+		}
+		query.From = *day
+	}
+	if *to != "" {
+		day, _, err := parseDate(*to)
+		if err != nil {
+			return err
+		}
+		query.To = day.AddDate(0, 0, 1)
+	}
+
+	page, err := c.CompletedHistory(query)
+	if err != nil {
+		return err
+	}
+
+	if len(page.Tasks) == 0 {
+		fmt.Fprintln(out, "No completed tasks")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, task := range page.Tasks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			task.TimeCompleted.In(Location).Format("2006-01-02 15:04"),
+			shortUUID(task.UUID),
+			utils.FormatEstimate(task.DurationExecutionRealSeconds),
+			firstLine(task.Body),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if page.NextCursor != "" {
+		fmt.Fprintf(out, "More: --cursor %s\n", page.NextCursor)
+	}
+	return nil
+}
+
+// runSearch finds tasks in the active, completed and trashed lists
+func runSearch(c client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	limit := fs.Int("limit", 20, "Maximum number of results")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return errors.New("usage: done search <query>")
+	}
+
+	results, err := c.Search(strings.Join(positional, " "), *limit)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(out, "No tasks found")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, result := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			shortUUID(result.Task.UUID),
+			result.List,
+			result.Date.In(Location).Format("2006-01-02"),
+			firstLine(result.Task.Body),
+		)
+	}
+	return tw.Flush()
+}
+
+func runSort(c client.Client, args []string, out io.Writer) error {
+	if len(args) != 1 || (args[0] != database.SortManual && args[0] != database.SortSmart) {
+		return errors.New("usage: done sort manual|smart")
+	}
+
+	if err := c.SetSortMode(args[0]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Sort mode: %s\n", args[0])
+	return nil
+}
+
+// runPlan shows which tasks fit into the working hours of the coming days,
+// and records the plan with --accept
+func runPlan(c client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	days := fs.Int("days", 7, "Number of days to plan, starting today")
+	accept := fs.Bool("accept", false, "Record the plan, so reports compare it with what was done")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: done plan [--days 7] [--accept]")
+	}
+
+	var plan *planner.Plan
+	if *accept {
+		plan, err = c.AcceptPlan(*days)
+	} else {
+		plan, err = c.Plan(*days)
+	}
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, day := range plan.Days {
+		date, err := time.Parse("2006-01-02", day.Date)
+		if err != nil {
+			return err
+		}
+		if day.CapacitySeconds == 0 && len(day.Tasks) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s planned of %s free\t\n",
+			date.Format("Mon 2006-01-02"),
+			utils.FormatEstimate(day.PlannedSeconds),
+			utils.FormatEstimate(day.CapacitySeconds),
+		)
+		for _, task := range day.Tasks {
+			fmt.Fprintf(tw, "  %s-%s\t%s\t%s\n",
+				task.Start.Format("15:04"),
+				task.End.Format("15:04"),
+				firstLine(task.Body),
+				utils.FormatEstimate(task.Seconds),
+			)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, note := range plan.Unscheduled {
+		fmt.Fprintf(out, "Not planned: %s (%s)\n", firstLine(note.Body), note.Reason)
+	}
+	for _, note := range plan.Warnings {
+		fmt.Fprintf(out, "Warning: %s: %s\n", firstLine(note.Body), note.Reason)
+	}
+	if *accept {
+		fmt.Fprintln(out, "Plan accepted")
+	}
+	return nil
+}
+
+// runHours shows or sets the working hours plans are made for
+func runHours(c client.Client, args []string, out io.Writer) error {
+	if len(args) > 2 {
+		return errors.New("usage: done hours [09:00-17:00 [mon-fri]]")
+	}
+
+	settings, err := c.Settings()
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		hours := &settings.WorkingHours
+		if hours.Start, hours.End, err = parseClockRange(args[0]); err != nil {
+			return err
+		}
+		if len(args) == 2 {
+			if hours.Days, err = parseWeekdays(args[1]); err != nil {
+				return err
+			}
+		}
+		if err := c.UpdateSettings(settings); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, len(settings.WorkingHours.Days))
+	for i, day := range settings.WorkingHours.Days {
+		names[i] = time.Weekday(day).String()[:3]
+	}
+	fmt.Fprintf(out, "Working hours: %s-%s %s\n", settings.WorkingHours.Start, settings.WorkingHours.End, strings.Join(names, ","))
+	return nil
+}
+
+// runBusy lists, adds or clears meetings and other time not available for
+// tasks
+func runBusy(c client.Client, args []string, out io.Writer) error {
+	settings, err := c.Settings()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 0:
+		if len(settings.Busy) == 0 {
+			fmt.Fprintln(out, "No busy time")
+			return nil
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, block := range settings.Busy {
+			fmt.Fprintf(tw, "%s-%s\t%s\n", block.Start.Format("Mon 2006-01-02 15:04"), block.End.Format("15:04"), block.Title)
+		}
+		return tw.Flush()
+
+	case args[0] == "clear" && len(args) == 1:
+		settings.Busy = nil
+		if err := c.UpdateSettings(settings); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Busy time cleared")
+		return nil
+
+	case args[0] == "add" && len(args) >= 3:
+		date, _, err := parseDate(args[1])
+		if err != nil {
+			return err
+		}
+		start, end, err := parseClockRange(args[2])
+		if err != nil {
+			return err
+		}
+		block := database.BusyBlock{Title: strings.Join(args[3:], " ")}
+		if block.Start, err = atClock(*date, start); err != nil {
+			return err
+		}
+		if block.End, err = atClock(*date, end); err != nil {
+			return err
+		}
+		settings.Busy = append(settings.Busy, block)
+		if err := c.UpdateSettings(settings); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Busy: %s %s-%s %s\n", date.Format("Mon 2006-01-02"), start, end, block.Title)
+		return nil
+	}
+
+	return errors.New("usage: done busy [add <date> <HH:MM-HH:MM> [title] | clear]")
+}
+
+// runUser lists and manages the accounts of a shared server. The first user
+// added takes over the existing tasks.
+func runUser(c client.Client, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"ls"}
+	}
+
+	switch args[0] {
+	case "ls":
+		users, err := c.Users()
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			fmt.Fprintln(out, "No users")
+			return nil
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tROLE\tSTATUS\tCREATED")
+		for _, user := range users {
+			role := "user"
+			switch {
+			case user.Owner:
+				role = "owner"
+			case user.Admin:
+				role = "admin"
+			}
+			status := "active"
+			if user.Disabled {
+				status = "disabled"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", user.Name, role, status, user.Created.Format("2006-01-02"))
+		}
+		return tw.Flush()
+
+	case "add":
+		fs := flag.NewFlagSet("user add", flag.ContinueOnError)
+		fs.SetOutput(os.Stderr)
+		admin := fs.Bool("admin", false, "Allow the user to manage users")
+
+		positional, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(positional) != 1 {
+			return errors.New("usage: done user add <name> [--admin]")
+		}
+
+		password, err := tui.ReadPassword(fmt.Sprintf("Password for %s (empty for none): ", positional[0]))
+		if err != nil {
+			return err
+		}
+
+		user, err := c.AddUser(positional[0], *admin, password)
+		if err != nil {
+			return err
+		}
+		if user.Owner {
+			fmt.Fprintf(out, "Added %s, who owns the existing tasks\n", user.Name)
+		} else {
+			fmt.Fprintf(out, "Added %s\n", user.Name)
+		}
+		return nil
+
+	case "passwd":
+		if len(args) != 2 {
+			return errors.New("usage: done user passwd <name>")
+		}
+		password, err := tui.ReadPassword(fmt.Sprintf("New password for %s: ", args[1]))
+		if err != nil {
+			return err
+		}
+		again, err := tui.ReadPassword("Repeat it: ")
+		if err != nil {
+			return err
+		}
+		if password != again {
+			return errors.New("the passwords differ")
+		}
+		if err := c.SetUserPassword(args[1], password); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Changed the password of %s\n", args[1])
+		return nil
+
+	case "disable", "enable":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: done user %s <name>", args[0])
+		}
+		disable := args[0] == "disable"
+		if err := c.SetUserDisabled(args[1], disable); err != nil {
+			return err
+		}
+		if disable {
+			fmt.Fprintf(out, "Disabled %s\n", args[1])
+		} else {
+			fmt.Fprintf(out, "Enabled %s\n", args[1])
+		}
+		return nil
+	}
+
+	return errors.New("usage: done user [ls | add <name> [--admin] | passwd <name> | disable <name> | enable <name>]")
+}
+
+// runToken manages the personal API tokens of the user, for scripts and
+// integrations. A token is only shown when it is created.
+func runToken(c client.Client, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"ls"}
+	}
+
+	switch args[0] {
+	case "ls":
+		tokens, err := c.Tokens()
+		if err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			fmt.Fprintln(out, "No tokens")
+			return nil
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tSCOPE\tCREATED")
+		for _, token := range tokens {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", token.ID, token.Name, token.Scope, token.Created.Format("2006-01-02"))
+		}
+		return tw.Flush()
+
+	case "add":
+		fs := flag.NewFlagSet("token add", flag.ContinueOnError)
+		fs.SetOutput(os.Stderr)
+		scope := fs.String("scope", "write", "What the token allows: read or write")
+
+		positional, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(positional) != 1 {
+			return errors.New("usage: done token add <name> [--scope read|write]")
+		}
+
+		token, err := c.AddToken(positional[0], *scope)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s token %s. Copy it now, it is not shown again:\n%s\n", token.Scope, token.Name, token.Secret)
+		return nil
+
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("usage: done token revoke <id>")
+		}
+		if err := c.RevokeToken(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Revoked %s\n", args[1])
+		return nil
+	}
+
+	return errors.New("usage: done token [ls | add <name> [--scope read|write] | revoke <id>]")
+}
+
+// runLeaderboard ranks the users of a shared server, or adds the user to
+// the leaderboard or takes them off it
+func runLeaderboard(c client.Client, args []string, out io.Writer) error {
+	if len(args) == 1 && (args[0] == "join" || args[0] == "leave") {
+		settings, err := c.Settings()
+		if err != nil {
+			return err
+		}
+		settings.Leaderboard = args[0] == "join"
+		if err := c.UpdateSettings(settings); err != nil {
+			return err
+		}
+		if settings.Leaderboard {
+			fmt.Fprintln(out, "You are on the leaderboard")
+		} else {
+			fmt.Fprintln(out, "You are off the leaderboard")
+		}
+		return nil
+	}
+
+	fs := flag.NewFlagSet("leaderboard", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	metric := fs.String("metric", "points", "Rank by points, completed or streak")
+	week := fs.String("week", "", "Any day of the week to rank; this week by default")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("usage: done leaderboard [--metric points|completed|streak] [--week 2026-10-19] | join | leave")
+	}
+
+	var day time.Time
+	if *week != "" {
+		parsed, _, err := parseDate(*week)
+		if err != nil {
+			return err
+		}
+		day = *parsed
+	}
+
+	board, err := c.Leaderboard(*metric, day)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Week of %s by %s\n", board.Week.Format("Mon 2006-01-02"), board.Metric)
+	if len(board.Entries) == 0 {
+		fmt.Fprintln(out, "Nobody is on the leaderboard yet; join with \"done leaderboard join\"")
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tUSER\tPOINTS\tCOMPLETED\tSTREAK")
+	for _, entry := range board.Entries {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\n", entry.Rank, entry.User, entry.Points, entry.Completed, entry.Streak)
+	}
+	return tw.Flush()
+}
+
+// runChallenge lists, starts, joins, leaves and deletes team challenges
+func runChallenge(c client.Client, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"ls"}
+	}
+
+	switch args[0] {
+	case "ls":
+		challenges, err := c.Challenges()
+		if err != nil {
+			return err
+		}
+		if len(challenges) == 0 {
+			fmt.Fprintln(out, "No challenges")
+			return nil
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTITLE\tPROGRESS\tDEADLINE\tSTATE\tPARTICIPANTS")
+		for _, challenge := range challenges {
+			names := make([]string, len(challenge.Participants))
+			for i, p := range challenge.Participants {
+				names[i] = fmt.Sprintf("%s %d", p.User, p.Value)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d/%d %s\t%s\t%s\t%s\n",
+				shortUUID(challenge.ID),
+				challenge.Title,
+				challenge.Progress, challenge.Target, challenge.Metric,
+				formatChallengeDeadline(challenge.Deadline),
+				challenge.State,
+				strings.Join(names, ", "),
+			)
+		}
+		return tw.Flush()
+
+	case "add":
+		fs := flag.NewFlagSet("challenge add", flag.ContinueOnError)
+		fs.SetOutput(os.Stderr)
+		target := fs.Int("target", 0, "Points or completed tasks to reach together")
+		deadline := fs.String("deadline", "", "Last day, e.g. 2026-10-30, or time, e.g. 2026-10-30T17:00")
+		metric := fs.String("metric", "points", "What counts: points or completed")
+
+		positional, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		title := strings.TrimSpace(strings.Join(positional, " "))
+		if title == "" || *deadline == "" {
+			return errors.New("usage: done challenge add <title> --target 500 --deadline 2026-10-30 [--metric points|completed]")
+		}
+
+		day, hasTime, err := parseDate(*deadline)
+		if err != nil {
+			return err
+		}
+		end := *day
+		if !hasTime {
+			end = end.AddDate(0, 0, 1)
+		}
+
+		challenge, err := c.AddChallenge(title, *metric, *target, end)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Started %s: %s %d %s by %s\n", shortUUID(challenge.ID), challenge.Title, challenge.Target, challenge.Metric, formatChallengeDeadline(challenge.Deadline))
+		return nil
+
+	case "join", "leave", "rm":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: done challenge %s <id>", args[0])
+		}
+		challenges, err := c.Challenges()
+		if err != nil {
+			return err
+		}
+		challenge, err := resolveChallenge(challenges, args[1])
+		if err != nil {
+			return err
+		}
+
+		switch args[0] {
+		case "join":
+			_, err = c.JoinChallenge(challenge.ID)
+			if err == nil {
+				fmt.Fprintf(out, "Joined %s\n", challenge.Title)
+			}
+		case "leave":
+			_, err = c.LeaveChallenge(challenge.ID)
+			if err == nil {
+				fmt.Fprintf(out, "Left %s\n", challenge.Title)
+			}
+		default:
+			err = c.RemoveChallenge(challenge.ID)
+			if err == nil {
+				fmt.Fprintf(out, "Deleted %s\n", challenge.Title)
+			}
+		}
+		return err
+	}
+
+	return errors.New("usage: done challenge [ls | add <title> --target 500 --deadline 2026-10-30 [--metric points|completed] | join <id> | leave <id> | rm <id>]")
+}
+
+// runSharedList lists and creates shared lists and sets their members
+func runSharedList(c client.Client, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"ls"}
+	}
+
+	switch args[0] {
+	case "ls":
+		lists, err := c.Lists()
+		if err != nil {
+			return err
+		}
+		if len(lists) == 0 {
+			fmt.Fprintln(out, "No shared lists")
+			return nil
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tCREATED BY\tMEMBERS")
+		for _, list := range lists {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", list.Name, list.CreatedBy, strings.Join(list.Members, ", "))
+		}
+		return tw.Flush()
+
+	case "add":
+		if len(args) < 2 {
+			return errors.New("usage: done list add <name> [<member>...]")
+		}
+		list, err := c.AddList(args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s for %s; work on it with -list %s\n", list.Name, strings.Join(list.Members, ", "), list.Name)
+		return nil
+
+	case "members":
+		if len(args) < 2 {
+			return errors.New("usage: done list members <name> <member>...")
+		}
+		list, err := c.SetListMembers(args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Members of %s: %s\n", list.Name, strings.Join(list.Members, ", "))
+		return nil
+	}
+
+	return errors.New("usage: done list [ls | add <name> [<member>...] | members <name> <member>...]")
+}
+
+// runAssign assigns a task on the shared list chosen with -list, or
+// unassigns it when no user is given
+func runAssign(c client.Client, args []string, out io.Writer) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errors.New("usage: done -list <list> assign <task> [<user>]")
+	}
+
+	task, err := resolveArg(c, args[:1])
+	if err != nil {
+		return err
+	}
+
+	assignee := ""
+	if len(args) == 2 {
+		assignee = args[1]
+	}
+	if err := c.Assign(task.UUID, assignee); err != nil {
+		return err
+	}
+
+	if assignee == "" {
+		fmt.Fprintf(out, "Unassigned: %s\n", firstLine(task.Body))
+	} else {
+		fmt.Fprintf(out, "Assigned to %s: %s\n", assignee, firstLine(task.Body))
+	}
+	return nil
+}
+
+// runWebhook lists, adds, removes and tests the webhooks of the user, or of
+// the shared list chosen with -list
+func runWebhook(c client.Client, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"ls"}
+	}
+
+	switch args[0] {
+	case "ls":
+		hooks, err := c.Webhooks()
+		if err != nil {
+			return err
+		}
+		if len(hooks) == 0 {
+			fmt.Fprintln(out, "No webhooks")
+			return nil
+		}
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tURL\tEVENTS\tSTATUS")
+		for _, hook := range hooks {
+			events := "all"
+			if len(hook.Events) > 0 {
+				events = strings.Join(hook.Events, ",")
+			}
+			status := "-"
+			switch {
+			case hook.LastError != "":
+				status = "failing: " + hook.LastError
+			case hook.LastDelivered != nil:
+				status = "delivered " + hook.LastDelivered.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", hook.ID, hook.URL, events, status)
+		}
+		return tw.Flush()
+
+	case "add":
+		fs := flag.NewFlagSet("webhook add", flag.ContinueOnError)
+		fs.SetOutput(os.Stderr)
+		eventList := fs.String("events", "", "Comma-separated events to send; all when empty")
+
+		positional, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(positional) != 1 {
+			return errors.New("usage: done webhook add <url> [--events task.completed,level.up]")
+		}
+		var events []string
+		for _, event := range strings.Split(*eventList, ",") {
+			if event = strings.TrimSpace(event); event != "" {
+				events = append(events, event)
+			}
+		}
+
+		hook, err := c.AddWebhook(positional[0], events)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Added webhook %s. Deliveries are signed with this secret, which is not shown again:\n%s\n", hook.ID, hook.Secret)
+		return nil
+
+	case "rm", "test":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: done webhook %s <id>", args[0])
+		}
+		if args[0] == "rm" {
+			if err := c.RemoveWebhook(args[1]); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Removed %s\n", args[1])
+			return nil
+		}
+		if err := c.TestWebhook(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Queued a ping event; the server sends it")
+		return nil
+	}
+
+	return errors.New("usage: done webhook [ls | add <url> [--events task.completed,level.up] | rm <id> | test <id>]")
+}
+
+// runReminders shows or changes when and how the user is reminded of
+// deadlines
+func runReminders(c client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("reminders", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	before := fs.String("before", "", "Comma-separated times before a deadline to remind, e.g. 1d,1h")
+	overdue := fs.Bool("overdue", true, "Remind of overdue tasks once a day")
+	via := fs.String("via", "", "Comma-separated channels: events, desktop, email")
+	email := fs.String("email", "", "Address email reminders are sent to")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	usage := errors.New("usage: done reminders [on|off] [--before 1d,1h] [--overdue=true|false] [--via events,desktop,email] [--email <address>]")
+	if len(positional) > 1 {
+		return usage
+	}
+
+	settings, err := c.Settings()
+	if err != nil {
+		return err
+	}
+	reminders := &settings.Reminders
+
+	changed := len(positional) == 1
+	if changed {
+		switch positional[0] {
+		case "on":
+			reminders.Enabled = true
+		case "off":
+			reminders.Enabled = false
+		default:
+			return usage
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		changed = true
+		switch f.Name {
+		case "before":
+			reminders.Offsets = splitList(*before)
+		case "overdue":
+			reminders.Overdue = *overdue
+		case "via":
+			reminders.Channels = splitList(*via)
+		case "email":
+			settings.Email = *email
+		}
+	})
+	if changed {
+		if err := c.UpdateSettings(settings); err != nil {
+			return err
+		}
+	}
+
+	if !reminders.Enabled {
+		fmt.Fprintln(out, "Reminders: off")
+		return nil
+	}
+	fmt.Fprintf(out, "Reminders: %s before deadlines", strings.Join(reminders.Offsets, ", "))
+	if reminders.Overdue {
+		fmt.Fprint(out, ", daily when overdue")
+	}
+	fmt.Fprintf(out, ", by %s\n", strings.Join(reminders.Channels, ", "))
+	if settings.Email != "" {
+		fmt.Fprintf(out, "Email: %s\n", settings.Email)
+	}
+	return nil
+}
+
+// runSnooze holds off the deadline reminders of a task for a while, an hour
+// by default, or until a given time
+func runSnooze(c client.Client, args []string, out io.Writer) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errors.New("usage: done snooze <task> [1h | 2026-11-01T09:00]")
+	}
+
+	task, err := resolveArg(c, args[:1])
+	if err != nil {
+		return err
+	}
+
+	until := time.Now().Add(time.Hour)
+	if len(args) == 2 {
+		if d, err := reminder.ParseOffset(args[1]); err == nil {
+			until = time.Now().Add(d)
+		} else if date, _, err := parseDate(args[1]); err == nil {
+			until = *date
+		} else {
+			return errors.New("snooze for a duration like 1d, 2h or 30m, or until a date like 2026-11-01T09:00")
+		}
+	}
+
+	if err := c.Snooze(task.UUID, until); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Snoozed until %s: %s\n", until.Format("Mon 2006-01-02 15:04"), firstLine(task.Body))
+	return nil
+}
+
+// runReports shows or changes which reports are emailed to the user and
+// when, or emails one right away
+func runReports(c client.Client, args []string, out io.Writer) error {
+	usage := errors.New("usage: done reports [--daily on|off] [--weekly on|off] [--at 18:00] [--day fri] [--email <address>] | send daily|weekly")
+
+	if len(args) > 0 && args[0] == "send" {
+		if len(args) != 2 || (args[1] != digest.Daily && args[1] != digest.Weekly) {
+			return usage
+		}
+		to, err := c.SendReport(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Sent the %s report to %s\n", args[1], to)
+		return nil
+	}
+
+	fs := flag.NewFlagSet("reports", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	daily := fs.String("daily", "", "Email the daily summary: on or off")
+	weekly := fs.String("weekly", "", "Email the weekly report: on or off")
+	at := fs.String("at", "", "Time the reports are sent at, HH:MM")
+	day := fs.String("day", "", "Day the weekly report is sent on: mon, tue, ... sun")
+	email := fs.String("email", "", "Address the reports are sent to")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usage
+	}
+
+	settings, err := c.Settings()
+	if err != nil {
+		return err
+	}
+	reports := &settings.Reports
+
+	changed := false
+	var invalid []error
+	fs.Visit(func(f *flag.Flag) {
+		changed = true
+		var err error
+		switch f.Name {
+		case "daily":
+			reports.Daily, err = parseSwitch(f.Name, *daily)
+		case "weekly":
+			reports.Weekly, err = parseSwitch(f.Name, *weekly)
+		case "at":
+			reports.At = *at
+		case "day":
+			weekday, ok := weekdays[strings.ToLower(*day)]
+			if !ok {
+				err = fmt.Errorf("invalid day %q, expected mon, tue, wed, thu, fri, sat or sun", *day)
+			}
+			reports.WeekDay = weekday
+		case "email":
+			settings.Email = *email
+		}
+		if err != nil {
+			invalid = append(invalid, err)
+		}
+	})
+	if len(invalid) > 0 {
+		return errors.Join(invalid...)
+	}
+	if changed {
+		if err := c.UpdateSettings(settings); err != nil {
+			return err
+		}
+	}
+
+	state := func(on bool) string {
+		if on {
+			return "on"
+		}
+		return "off"
+	}
+	fmt.Fprintf(out, "Daily summary: %s, at %s\n", state(reports.Daily), reports.At)
+	fmt.Fprintf(out, "Weekly report: %s, on %s at %s\n", state(reports.Weekly), time.Weekday(reports.WeekDay), reports.At)
+	if settings.Email == "" {
+		fmt.Fprintln(out, "Email: not set; reports are only sent once it is (--email)")
+	} else {
+		fmt.Fprintf(out, "Email: %s\n", settings.Email)
+	}
+	return nil
+}
+
+// parseSwitch reads the on or off value of a flag
+func parseSwitch(name, value string) (bool, error) {
+	switch value {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("--%s must be on or off", name)
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func runImport(c client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	format := fs.String("format", "", "Export format; detected from the content when not set")
+	dryRun := fs.Bool("dry-run", false, "Only show what would be imported")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: done import [--format taskwarrior|todotxt|todoist] [--dry-run] <file>")
+	}
+
+	data, err := os.ReadFile(positional[0])
+	if err != nil {
+		return err
+	}
+
+	report, err := c.Import(*format, data, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Imported"
+	if report.DryRun {
+		verb = "Would import"
+	}
+	fmt.Fprintf(out, "%s %d tasks from %s\n", verb, len(report.Imported), report.Format)
+	for _, task := range report.Imported {
+		line := "  + " + firstLine(task.Body)
+		if len(task.Tags) > 0 {
+			line += "  #" + strings.Join(task.Tags, " #")
+		}
+		if task.TimeHardDeadline != nil {
+			line += "  due " + formatDeadline(&task)
+		}
+		fmt.Fprintln(out, line)
+	}
+	for _, task := range report.Duplicates {
+		fmt.Fprintf(out, "  = %s (duplicate)\n", firstLine(task.Body))
+	}
+	for _, rejected := range report.Rejected {
+		fmt.Fprintf(out, "  x %s (%s)\n", firstLine(rejected.Task.Body), rejected.Reason)
+	}
+	for _, notice := range report.Skipped {
+		fmt.Fprintf(out, "  - line %d skipped: %s %s\n", notice.Line, notice.Reason, notice.Text)
+	}
+	for _, notice := range report.Warnings {
+		fmt.Fprintf(out, "  ! line %d: %s\n", notice.Line, notice.Reason)
+	}
+	return nil
+}
+
+// runTUI opens the interactive terminal UI
+func runTUI(c client.Client, args []string, out io.Writer) error {
+	return tui.Run(c, os.Stdin, out)
+}
+
+// atClock returns the time of day HH:MM on the day of date
+func atClock(date time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location()), nil
+}
+
+// resolveArg resolves the single task argument of a command
+func resolveArg(c client.Client, args []string) (*database.Task, error) {
+	if len(args) != 1 {
+		return nil, errors.New("expected exactly one task")
+	}
+
+	tasks, err := c.Tasks()
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveTask(tasks, args[0])
+}
+
+// resolveChallenge finds the challenge with a unique ID prefix
+func resolveChallenge(challenges []handlers.ChallengeStatus, ref string) (*handlers.ChallengeStatus, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	var matches []*handlers.ChallengeStatus
+	for i := range challenges {
+		if ref != "" && strings.HasPrefix(challenges[i].ID, ref) {
+			matches = append(matches, &challenges[i])
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no challenge matches %q", ref)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("%q matches several challenges", ref)
+}
+
+// formatChallengeDeadline shows a deadline at midnight as the day before,
+// the last day of the challenge
+func formatChallengeDeadline(deadline time.Time) string {
+	deadline = deadline.In(Location)
+	if deadline.Hour() == 0 && deadline.Minute() == 0 {
+		return deadline.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	return deadline.Format("2006-01-02 15:04")
+}
+
+// shortUUID returns the prefix of a UUID shown by "done ls"
+func shortUUID(uuid string) string {
+	if len(uuid) > 8 {
+		return uuid[:8]
+	}
+	return uuid
+}
+
+func firstLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i] + " …"
+	}
+	return text
+}
+
+// formatPriority shows a priority as its first letter, pinned tasks with a *
+// and blocked tasks with ⛔
+func formatPriority(task *database.Task) string {
+	priority := "-"
+	if task.Priority != "" {
+		priority = strings.ToUpper(task.Priority[:1])
+	}
+	if task.Pinned {
+		priority += "*"
+	}
+	if task.Blocked {
+		priority += " ⛔"
+	}
+	return priority
+}
+
+// formatDeadline shows a deadline in the time zone it was set in
+func formatDeadline(task *database.Task) string {
+	if task.TimeHardDeadline == nil {
+		return "-"
+	}
+	if task.DeadlineHasTime {
+		return task.TimeHardDeadline.Format("2006-01-02 15:04")
+	}
+	return formatDay(task.TimeHardDeadline)
+}
+
+func formatDay(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"done/lib/client"
+)
+
+// localOpener returns how Run opens a client of a new database file, and
+// counts how often it is called
+func localOpener(t *testing.T) (func() (client.Client, error), *int) {
+	t.Helper()
+	// Completing a task writes the report into the home directory
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "done.db")
+	opened := 0
+	return func() (client.Client, error) {
+		opened++
+		return client.OpenLocal(path)
+	}, &opened
+}
+
+// run runs a command line and returns what it printed
+func run(t *testing.T, open func() (client.Client, error), line string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := Run(strings.Fields(line), open, &out); err != nil {
+		t.Fatalf("%s: %v", line, err)
+	}
+	return out.String()
+}
+
+func TestRunCommands(t *testing.T) {
+	open, _ := localOpener(t)
+	if out := run(t, open, "add Write report --est 1h30m --priority high"); out != "Added: Write report\n" {
+		t.Errorf("add printed %q", out)
+	}
+	run(t, open, "add Call Bob")
+
+	out := run(t, open, "ls")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "Call Bob") || !strings.Contains(lines[2], "Write report") || !strings.Contains(lines[2], "1h30m") {
+		t.Errorf("ls printed %q", out)
+	}
+
+	if out := run(t, open, "complete 2"); out != "Completed: Write report\n" {
+		t.Errorf("complete printed %q", out)
+	}
+	if out := run(t, open, "today"); !strings.Contains(out, "Write report") || !strings.HasSuffix(out, "1 completed today\n") {
+		t.Errorf("today printed %q", out)
+	}
+}
+
+func TestRunWithoutCommand(t *testing.T) {
+	open, opened := localOpener(t)
+	for _, line := range []string{"", "help"} {
+		if out := run(t, open, line); !strings.HasPrefix(out, "Usage: done") {
+			t.Errorf("%q printed %q", line, out)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Run([]string{"frobnicate"}, open, &out); err == nil || !strings.Contains(err.Error(), "frobnicate") {
+		t.Errorf("unknown command: %v", err)
+	}
+	if *opened != 0 {
+		t.Errorf("opened the database %d times without a command that needs it", *opened)
+	}
+	if IsCommand("frobnicate") || !IsCommand("help") || !IsCommand("ls") {
+		t.Error("IsCommand does not match the commands")
+	}
+}
+
+func TestCommandOrder(t *testing.T) {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	listed := append([]string(nil), commandOrder...)
+	sort.Strings(names)
+	sort.Strings(listed)
+	if strings.Join(names, " ") != strings.Join(listed, " ") {
+		t.Errorf("usage lists %v, commands are %v", listed, names)
+	}
+}
@@ -0,0 +1,129 @@
+// Package client manages tasks from outside the web UI, either through the
+// API of a running Done server or directly in the database file.
+package client
+
+import (
+	"errors"
+	"time"
+
+	handlers "done/lib/database"
+	database "done/lib/database/interface"
+	"done/lib/estimator"
+	"done/lib/planner"
+	"done/lib/quickadd"
+	"done/lib/search"
+)
+
+// errNoList is returned when assigning tasks outside a shared list
+var errNoList = errors.New("assigning tasks needs a shared list; choose one with -list")
+
+// Client is the set of task operations available to command-line tools
+type Client interface {
+	// Tasks returns the active tasks in the order they are shown
+	Tasks() ([]database.Task, error)
+	// CompletedToday returns the tasks completed since midnight
+	CompletedToday() ([]database.Task, error)
+	// CompletedHistory returns a page of completed tasks
+	CompletedHistory(query database.CompletedQuery) (*database.CompletedPage, error)
+	// Search finds tasks in all lists, best matches first
+	Search(query string, limit int) ([]search.Result, error)
+	// Add creates task at the top of the list
+	Add(task *database.Task) error
+	// ParseTask previews how a quick-add line like "Call Bob ~15m due fri"
+	// would be read, without creating a task
+	ParseTask(line string) (*quickadd.Parsed, error)
+	// QuickAdd creates a task from a quick-add line
+	QuickAdd(line string) error
+	// Estimate suggests how long a task will take, learned from completed
+	// tasks
+	Estimate(in estimator.Input) (*estimator.Suggestion, error)
+	// Complete marks a task as done and credits its points. A task blocked by
+	// open tasks is refused unless force is set. It returns the tasks that
+	// are no longer blocked.
+	Complete(uuid string, force bool) ([]database.Task, error)
+	// Block sets the tasks that must be done before a task can start; no
+	// blockers clears them
+	Block(uuid string, blockers []string) error
+	// Remove moves a task to the trash
+	Remove(uuid string) error
+	// Move puts a task at the position of another one
+	Move(sourceUUID, destinationUUID string) error
+	// SetRealSeconds stores the time spent on a task, as counted by a timer
+	SetRealSeconds(uuid string, seconds int) error
+	// Import adds the tasks of an export from another task manager, or only
+	// reports what it would add when dryRun is set
+	Import(format string, data []byte, dryRun bool) (*handlers.ImportReport, error)
+	// SetSortMode switches the list between manual and smart sort
+	SetSortMode(mode string) error
+	// Settings returns the settings, such as working hours and busy time
+	Settings() (*database.Settings, error)
+	// UpdateSettings stores the settings
+	UpdateSettings(settings *database.Settings) error
+	// Plan proposes which tasks fit into the working hours of the coming days
+	Plan(days int) (*planner.Plan, error)
+	// AcceptPlan records a fresh plan for the coming days and returns it
+	AcceptPlan(days int) (*planner.Plan, error)
+	// Gamification returns the points, level, streak and achievements
+	Gamification() (*database.Gamification, error)
+	// Users lists the accounts of a shared server
+	Users() ([]database.User, error)
+	// AddUser creates an account with its own empty lists and, unless it is
+	// empty, a password. The first user owns the tasks stored before there
+	// were users.
+	AddUser(name string, admin bool, password string) (*database.User, error)
+	// SetUserDisabled stops or allows a user signing in
+	SetUserDisabled(name string, disabled bool) error
+	// SetUserPassword changes a user's password and signs them out
+	// everywhere
+	SetUserPassword(name, password string) error
+	// Tokens lists the API tokens of the user
+	Tokens() ([]database.Token, error)
+	// AddToken creates an API token with read or write scope. The returned
+	// secret is not shown again.
+	AddToken(name, scope string) (*handlers.NewToken, error)
+	// RevokeToken deletes an API token of the user
+	RevokeToken(id string) error
+	// Leaderboard ranks the users who turned on the leaderboard setting by
+	// points, completed tasks or streak over the week of the given day; the
+	// zero time means this week
+	Leaderboard(metric string, week time.Time) (*handlers.Leaderboard, error)
+	// Challenges lists the team challenges with their progress
+	Challenges() ([]handlers.ChallengeStatus, error)
+	// AddChallenge starts a team challenge with a points or completed tasks
+	// target, which the user joins
+	AddChallenge(title, metric string, target int, deadline time.Time) (*handlers.ChallengeStatus, error)
+	// JoinChallenge makes what the user completes count towards a challenge
+	JoinChallenge(id string) (*handlers.ChallengeStatus, error)
+	// LeaveChallenge stops the user counting towards a challenge
+	LeaveChallenge(id string) (*handlers.ChallengeStatus, error)
+	// RemoveChallenge deletes a challenge the user created
+	RemoveChallenge(id string) error
+	// Lists returns the shared lists the user is a member of
+	Lists() ([]handlers.ListInfo, error)
+	// AddList creates a shared list with the user and the named members
+	AddList(name string, members []string) (*handlers.ListInfo, error)
+	// SetListMembers replaces the members of a shared list, given by ID or
+	// name
+	SetListMembers(list string, members []string) (*handlers.ListInfo, error)
+	// Assign gives a task on the shared list set with SetList to a member;
+	// an empty assignee unassigns it
+	Assign(uuid, assignee string) error
+	// Webhooks lists the user's webhooks, without their secrets
+	Webhooks() ([]database.Webhook, error)
+	// AddWebhook sends events of the user's tasks, or of the shared list set
+	// with SetList, to url; all events when events is empty. The returned
+	// secret is not shown again.
+	AddWebhook(url string, events []string) (*database.Webhook, error)
+	// RemoveWebhook deletes a webhook of the user and its queued deliveries
+	RemoveWebhook(id string) error
+	// TestWebhook queues a ping event to a webhook of the user
+	TestWebhook(id string) error
+	// Snooze holds off the deadline reminders of a task for the user until
+	// until, when they are reminded once more
+	Snooze(uuid string, until time.Time) error
+	// SendReport emails the daily or weekly report to the address in the
+	// settings now, and returns the address
+	SendReport(kind string) (string, error)
+	// Close releases the client's resources
+	Close() error
+}
@@ -0,0 +1,400 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"done/lib/database"
+	"done/lib/database/bolt"
+	dbinterface "done/lib/database/interface"
+	"done/lib/estimator"
+	"done/lib/hooks"
+	"done/lib/importer"
+	"done/lib/mail"
+	"done/lib/planner"
+	"done/lib/quickadd"
+	"done/lib/search"
+)
+
+// Local works on the database file directly. It is used when no server is
+// running, since BoltDB only allows one process to open the file. Whoever
+// can open the file may act as any user; without SetUser it works on the
+// owner's tasks.
+type Local struct {
+	db       *bolt.BoltDB
+	accounts *database.Accounts
+	handler  *database.Handler
+	user     *dbinterface.User // Set by SetUser
+	list     string            // Set by SetList
+}
+
+// OpenLocal opens the database file at dbPath
+func OpenLocal(dbPath string) (*Local, error) {
+	db := bolt.NewBoltDB(dbPath)
+	if err := db.Connect(); err != nil {
+		return nil, err
+	}
+	accounts := database.NewAccounts(db)
+	handler, err := accounts.Handler(nil)
+	if err != nil {
+		db.Disconnect()
+		return nil, err
+	}
+	return &Local{db: db, accounts: accounts, handler: handler}, nil
+}
+
+// SetLocation sets the time zone quick-add dates and plans are read in
+func (l *Local) SetLocation(loc *time.Location) {
+	l.accounts.Location = loc
+	l.handler.Location = loc
+}
+
+// SetHooks sets the hooks run when tasks are added and change. It must be
+// called before SetUser and SetList.
+func (l *Local) SetHooks(runner *hooks.Runner) {
+	l.accounts.Hooks = runner
+	l.handler.Hooks = runner
+}
+
+// SetMail sets the relay reports are emailed through. It must be called
+// before SetUser.
+func (l *Local) SetMail(sender *mail.Sender) {
+	l.accounts.Mail = sender
+	l.handler.Mail = sender
+}
+
+// SetUser switches to the tasks of the named user
+func (l *Local) SetUser(name string) error {
+	user, err := l.db.GetUserByName(name)
+	if err != nil {
+		return fmt.Errorf("user %q: %w", name, err)
+	}
+	handler, err := l.accounts.Handler(user)
+	if err != nil {
+		return err
+	}
+	handler.Location = l.handler.Location
+	l.handler = handler
+	l.user = user
+	return nil
+}
+
+// SetList switches to the tasks of a shared list, given by ID or name,
+// which the user set by SetUser, or else the owner, is a member of
+func (l *Local) SetList(list string) error {
+	user, err := l.actingUser()
+	if err != nil {
+		return err
+	}
+	handler, err := l.accounts.ListHandler(user, list)
+	if err != nil {
+		return fmt.Errorf("list %q: %w", list, err)
+	}
+	handler.Location = l.handler.Location
+	l.handler = handler
+	l.list = list
+	return nil
+}
+
+// actingUser returns the user whose tokens and challenges are managed: the
+// one set by SetUser, or else the owner
+func (l *Local) actingUser() (*dbinterface.User, error) {
+	if l.user != nil {
+		return l.user, nil
+	}
+	users, err := l.db.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if users[i].Owner {
+			return &users[i], nil
+		}
+	}
+	return nil, errors.New("no users yet; add a user first")
+}
+
+func (l *Local) Tasks() ([]dbinterface.Task, error) {
+	return l.handler.Tasks()
+}
+
+func (l *Local) CompletedToday() ([]dbinterface.Task, error) {
+	return l.handler.CompletedToday()
+}
+
+func (l *Local) CompletedHistory(query dbinterface.CompletedQuery) (*dbinterface.CompletedPage, error) {
+	return l.handler.DB.QueryCompletedTasks(query)
+}
+
+func (l *Local) Search(query string, limit int) ([]search.Result, error) {
+	return l.handler.Search(query, limit)
+}
+
+func (l *Local) Add(task *dbinterface.Task) error {
+	return l.handler.Create(task)
+}
+
+func (l *Local) ParseTask(line string) (*quickadd.Parsed, error) {
+	return l.handler.ParseLine(line), nil
+}
+
+func (l *Local) QuickAdd(line string) error {
+	_, err := l.handler.QuickAdd(line)
+	return err
+}
+
+func (l *Local) Estimate(in estimator.Input) (*estimator.Suggestion, error) {
+	return l.handler.Estimate(in)
+}
+
+func (l *Local) Complete(uuid string, force bool) ([]dbinterface.Task, error) {
+	name := ""
+	if l.list != "" {
+		user, err := l.actingUser()
+		if err != nil {
+			return nil, err
+		}
+		name = user.Name
+	}
+	_, unblocked, err := l.handler.CompleteChecked(uuid, name, force)
+	return unblocked, err
+}
+
+func (l *Local) Block(uuid string, blockers []string) error {
+	_, err := l.handler.Update(uuid, &database.TaskPatch{UUID: uuid, BlockedBy: &blockers})
+	return err
+}
+
+func (l *Local) Remove(uuid string) error {
+	_, err := l.handler.Remove(uuid)
+	return err
+}
+
+func (l *Local) Move(sourceUUID, destinationUUID string) error {
+	return l.handler.Move(sourceUUID, destinationUUID)
+}
+
+func (l *Local) SetRealSeconds(uuid string, seconds int) error {
+	return l.handler.SetRealSeconds(uuid, seconds)
+}
+
+func (l *Local) Import(format string, data []byte, dryRun bool) (*database.ImportReport, error) {
+	parsed, err := importer.Parse(format, data)
+	if err != nil {
+		return nil, err
+	}
+	return l.handler.Import(parsed, dryRun)
+}
+
+func (l *Local) SetSortMode(mode string) error {
+	return l.handler.SetSortMode(mode)
+}
+
+func (l *Local) Settings() (*dbinterface.Settings, error) {
+	return l.handler.DB.GetSettings()
+}
+
+func (l *Local) UpdateSettings(settings *dbinterface.Settings) error {
+	return l.handler.SaveSettings(settings)
+}
+
+func (l *Local) Plan(days int) (*planner.Plan, error) {
+	return l.handler.Plan(days)
+}
+
+func (l *Local) AcceptPlan(days int) (*planner.Plan, error) {
+	plan, err := l.handler.Plan(days)
+	if err != nil {
+		return nil, err
+	}
+	return plan, l.handler.SavePlan(plan)
+}
+
+func (l *Local) Gamification() (*dbinterface.Gamification, error) {
+	return l.handler.DB.GetGamification()
+}
+
+func (l *Local) Users() ([]dbinterface.User, error) {
+	return l.db.GetUsers()
+}
+
+func (l *Local) AddUser(name string, admin bool, password string) (*dbinterface.User, error) {
+	return l.accounts.AddUser(name, admin, password)
+}
+
+func (l *Local) SetUserDisabled(name string, disabled bool) error {
+	_, err := l.accounts.SetUserDisabled(name, disabled)
+	return err
+}
+
+func (l *Local) SetUserPassword(name, password string) error {
+	return l.accounts.SetPassword(name, password)
+}
+
+func (l *Local) Tokens() ([]dbinterface.Token, error) {
+	user, err := l.actingUser()
+	if err != nil {
+		return nil, err
+	}
+	return l.db.GetTokens(user.ID)
+}
+
+func (l *Local) AddToken(name, scope string) (*database.NewToken, error) {
+	user, err := l.actingUser()
+	if err != nil {
+		return nil, err
+	}
+	return l.accounts.CreateToken(user, name, scope)
+}
+
+func (l *Local) RevokeToken(id string) error {
+	user, err := l.actingUser()
+	if err != nil {
+		return err
+	}
+	return l.accounts.RevokeToken(user, id)
+}
+
+func (l *Local) Leaderboard(metric string, week time.Time) (*database.Leaderboard, error) {
+	if week.IsZero() {
+		week = time.Now()
+	}
+	return l.accounts.Leaderboard(metric, week)
+}
+
+func (l *Local) Challenges() ([]database.ChallengeStatus, error) {
+	return l.accounts.ChallengeStatuses()
+}
+
+func (l *Local) AddChallenge(title, metric string, target int, deadline time.Time) (*database.ChallengeStatus, error) {
+	user, err := l.actingUser()
+	if err != nil {
+		return nil, err
+	}
+	return l.accounts.CreateChallenge(user, title, metric, target, deadline)
+}
+
+func (l *Local) JoinChallenge(id string) (*database.ChallengeStatus, error) {
+	user, err := l.actingUser()
+	if err != nil {
+		return nil, err
+	}
+	return l.accounts.Join(user, id)
+}
+
+func (l *Local) LeaveChallenge(id string) (*database.ChallengeStatus, error) {
+	user, err := l.actingUser()
+	if err != nil {
+		return nil, err
+	}
+	return l.accounts.Leave(user, id)
+}
+
+func (l *Local) RemoveChallenge(id string) error {
+	user, err := l.actingUser()
+	if err != nil {
+		return err
+	}
+	return l.accounts.RemoveChallenge(user, id)
+}
+
+func (l *Local) Lists() ([]database.ListInfo, error) {
+	user, err := l.actingUser()
+	if err != nil {
+		return nil, err
+	}
+	return l.accounts.SharedLists(user)
+}
+
+func (l *Local) AddList(name string, members []string) (*database.ListInfo, error) {
+	user, err := l.actingUser()
+	if err != nil {
+		return nil, err
+	}
+	return l.accounts.CreateList(user, name, members)
+}
+
+func (l *Local) SetListMembers(list string, members []string) (*database.ListInfo, error) {
+	user, err := l.actingUser()
+	if err != nil {
+		return nil, err
+	}
+	return l.accounts.SetListMembers(user, list, members)
+}
+
+func (l *Local) Assign(uuid, assignee string) error {
+	if l.list == "" {
+		return errNoList
+	}
+	user, err := l.actingUser()
+	if err != nil {
+		return err
+	}
+	_, err = l.accounts.Assign(user, l.list, uuid, assignee)
+	return err
+}
+
+// userOrNone returns whose webhooks and reminders are managed: the user set
+// by SetUser, else the owner, or nil on a database without users
+func (l *Local) userOrNone() (*dbinterface.User, error) {
+	users, err := l.db.GetUsers()
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return l.actingUser()
+}
+
+func (l *Local) Webhooks() ([]dbinterface.Webhook, error) {
+	user, err := l.userOrNone()
+	if err != nil {
+		return nil, err
+	}
+	return l.accounts.UserWebhooks(user)
+}
+
+func (l *Local) AddWebhook(url string, events []string) (*dbinterface.Webhook, error) {
+	user, err := l.userOrNone()
+	if err != nil {
+		return nil, err
+	}
+	return l.accounts.CreateWebhook(user, l.list, url, events)
+}
+
+func (l *Local) RemoveWebhook(id string) error {
+	user, err := l.userOrNone()
+	if err != nil {
+		return err
+	}
+	return l.accounts.RemoveWebhook(user, id)
+}
+
+func (l *Local) TestWebhook(id string) error {
+	user, err := l.userOrNone()
+	if err != nil {
+		return err
+	}
+	return l.accounts.PingWebhook(user, id)
+}
+
+func (l *Local) Snooze(uuid string, until time.Time) error {
+	user, err := l.userOrNone()
+	if err != nil {
+		return err
+	}
+	return l.handler.Snooze(uuid, user, until)
+}
+
+func (l *Local) SendReport(kind string) (string, error) {
+	now := time.Now()
+	if l.handler.Location != nil {
+		now = now.In(l.handler.Location)
+	}
+	return l.handler.EmailReport(kind, now)
+}
+
+func (l *Local) Close() error {
+	// Queue the webhook deliveries of the changes made before closing
+	l.accounts.Close()
+	return l.db.Disconnect()
+}
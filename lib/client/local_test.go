@@ -0,0 +1,128 @@
+package client
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	dbinterface "done/lib/database/interface"
+)
+
+// openLocal returns a client of a new database file, closed when the test
+// ends
+func openLocal(t *testing.T) *Local {
+	t.Helper()
+	// Completing a task writes the report into the home directory
+	t.Setenv("HOME", t.TempDir())
+	l, err := OpenLocal(filepath.Join(t.TempDir(), "done.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestLocalTasks(t *testing.T) {
+	l := openLocal(t)
+	for _, line := range []string{"Write report ~1h", "Call Bob"} {
+		if err := l.QuickAdd(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tasks, err := l.Tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].Body != "Call Bob" || tasks[1].DurationExecutionEstimatedSeconds != 3600 {
+		t.Fatalf("tasks = %+v", tasks)
+	}
+
+	if _, err := l.Complete(tasks[0].UUID, false); err != nil {
+		t.Fatal(err)
+	}
+	done, err := l.CompletedToday()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].UUID != tasks[0].UUID {
+		t.Errorf("completed today = %+v", done)
+	}
+	if tasks, err := l.Tasks(); err != nil || len(tasks) != 1 {
+		t.Errorf("tasks left = %+v (%v)", tasks, err)
+	}
+}
+
+func TestLocalUsers(t *testing.T) {
+	l := openLocal(t)
+	if _, err := l.Lists(); err == nil {
+		t.Error("lists without users: no error")
+	}
+	if err := l.SetUser("ann"); err == nil {
+		t.Error("unknown user: no error")
+	}
+
+	if _, err := l.AddUser("ann", true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AddUser("bob", false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetUser("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Add(&dbinterface.Task{Body: "Bob's task"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Assign("", "bob"); !errors.Is(err, errNoList) {
+		t.Errorf("assigning outside a list: %v", err)
+	}
+
+	if err := l.SetUser("ann"); err != nil {
+		t.Fatal(err)
+	}
+	if tasks, err := l.Tasks(); err != nil || len(tasks) != 0 {
+		t.Errorf("ann sees %+v (%v)", tasks, err)
+	}
+}
+
+func TestLocalSharedList(t *testing.T) {
+	l := openLocal(t)
+	if _, err := l.AddUser("ann", true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AddUser("bob", false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AddList("home", []string{"bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetList("home"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Add(&dbinterface.Task{Body: "Do the dishes"}); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := l.Tasks()
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("list tasks = %+v (%v)", tasks, err)
+	}
+
+	if err := l.Assign(tasks[0].UUID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	// The owner acts on the list unless SetUser names someone else
+	if _, err := l.Complete(tasks[0].UUID, false); err == nil {
+		t.Error("ann completed bob's task")
+	}
+
+	if err := l.SetUser("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetList("home"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Complete(tasks[0].UUID, false); err != nil {
+		t.Errorf("bob completing the task assigned to bob: %v", err)
+	}
+}
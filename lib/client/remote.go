@@ -0,0 +1,564 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	handlers "done/lib/database"
+	database "done/lib/database/interface"
+	"done/lib/estimator"
+	"done/lib/planner"
+	"done/lib/quickadd"
+	"done/lib/search"
+	"done/lib/utils"
+)
+
+// remoteSession is the undo/redo session the server records command-line
+// changes under
+const remoteSession = "cli"
+
+// Remote talks to a running Done server over its API
+type Remote struct {
+	baseURL  string
+	http     *http.Client
+	user     string // Sent as X-Done-User; empty on a server without users
+	password string // Sent with user as basic auth, unless there is a token
+	token    string // Sent as a bearer token
+	list     string // Sent as X-Done-List; empty for the user's own tasks
+}
+
+// NewRemote returns a client for the server at baseURL, e.g.
+// "http://localhost:3001"
+func NewRemote(baseURL string) *Remote {
+	return &Remote{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r *Remote) Tasks() ([]database.Task, error) {
+	var tasks []database.Task
+	err := r.call(http.MethodGet, "/api/getTasks", "", &tasks)
+	return tasks, err
+}
+
+func (r *Remote) CompletedToday() ([]database.Task, error) {
+	var tasks []database.Task
+	err := r.call(http.MethodGet, "/api/getTodayResults", "", &tasks)
+	return tasks, err
+}
+
+func (r *Remote) CompletedHistory(query database.CompletedQuery) (*database.CompletedPage, error) {
+	params := url.Values{}
+	if !query.From.IsZero() {
+		params.Set("from", query.From.Format(time.RFC3339))
+	}
+	if !query.To.IsZero() {
+		params.Set("to", query.To.Format(time.RFC3339))
+	}
+	if query.Oldest {
+		params.Set("order", "oldest")
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Cursor != "" {
+		params.Set("cursor", query.Cursor)
+	}
+
+	var page database.CompletedPage
+	if err := r.call(http.MethodGet, "/api/getCompletedHistory?"+params.Encode(), "", &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *Remote) Search(query string, limit int) ([]search.Result, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("limit", strconv.Itoa(limit))
+
+	var results []search.Result
+	err := r.call(http.MethodGet, "/api/search?"+params.Encode(), "", &results)
+	return results, err
+}
+
+// Add sends task in the "$;" separated format of the web UI. The server
+// assigns the UUID, so task is not updated.
+func (r *Remote) Add(task *database.Task) error {
+	fields := make([]string, 10)
+	fields[0] = utils.EncodeTaskText(task.Body)
+	fields[1] = strconv.Itoa(task.DurationExecutionEstimatedSeconds)
+	if deadline := task.TimeHardDeadline; deadline != nil {
+		fields[2] = strconv.Itoa(int(deadline.Month()))
+		fields[3] = strconv.Itoa(deadline.Day())
+		fields[4] = strconv.Itoa(deadline.Year())
+		if task.DeadlineHasTime {
+			fields[5] = deadline.Format("15:04")
+		}
+	}
+	if planned := task.TimePlanned; planned != nil {
+		fields[6] = strconv.Itoa(int(planned.Month()))
+		fields[7] = strconv.Itoa(planned.Day())
+		fields[8] = strconv.Itoa(planned.Year())
+	}
+	fields[9] = task.Priority
+
+	return r.call(http.MethodPost, "/api/addTask", strings.Join(fields, "$;"), nil)
+}
+
+func (r *Remote) ParseTask(line string) (*quickadd.Parsed, error) {
+	var parsed quickadd.Parsed
+	if err := r.call(http.MethodPost, "/api/parseTask", line, &parsed); err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func (r *Remote) QuickAdd(line string) error {
+	return r.call(http.MethodPost, "/api/quickAdd", line, nil)
+}
+
+func (r *Remote) Estimate(in estimator.Input) (*estimator.Suggestion, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestion estimator.Suggestion
+	if err := r.call(http.MethodPost, "/api/estimate", string(data), &suggestion); err != nil {
+		return nil, err
+	}
+	return &suggestion, nil
+}
+
+// Complete returns the tasks the completion unblocked, as listed by the
+// X-Done-Unblocked response header
+func (r *Remote) Complete(uuid string, force bool) ([]database.Task, error) {
+	path := "/api/completeTask"
+	if force {
+		path += "?force=true"
+	}
+
+	var tasks []database.Task
+	header, err := r.send(http.MethodPost, path, uuid, &tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	var unblocked []database.Task
+	for _, id := range strings.Split(header.Get("X-Done-Unblocked"), ",") {
+		for _, task := range tasks {
+			if id != "" && task.UUID == id {
+				unblocked = append(unblocked, task)
+			}
+		}
+	}
+	return unblocked, nil
+}
+
+func (r *Remote) Block(uuid string, blockers []string) error {
+	if blockers == nil {
+		blockers = []string{}
+	}
+	patch, err := json.Marshal(map[string]interface{}{"uuid": uuid, "blocked_by": blockers})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPatch, "/api/updateTask", string(patch), nil)
+}
+
+func (r *Remote) Remove(uuid string) error {
+	return r.call(http.MethodPost, "/api/removeTask", uuid, nil)
+}
+
+func (r *Remote) Move(sourceUUID, destinationUUID string) error {
+	return r.call(http.MethodPost, "/api/rearrangeTasks", sourceUUID+","+destinationUUID, nil)
+}
+
+func (r *Remote) SetRealSeconds(uuid string, seconds int) error {
+	return r.call(http.MethodPost, "/api/updateTaskExecutionRealSeconds", uuid+"$;"+strconv.Itoa(seconds), nil)
+}
+
+func (r *Remote) Import(format string, data []byte, dryRun bool) (*handlers.ImportReport, error) {
+	query := url.Values{}
+	query.Set("format", format)
+	if dryRun {
+		query.Set("dryRun", "true")
+	}
+
+	var report handlers.ImportReport
+	if err := r.call(http.MethodPost, "/api/importTasks?"+query.Encode(), string(data), &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// SetSortMode only sends the sort mode, so the other settings are kept
+func (r *Remote) SetSortMode(mode string) error {
+	settings, err := json.Marshal(map[string]string{"sort_mode": mode})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/updateSettings", string(settings), nil)
+}
+
+func (r *Remote) Settings() (*database.Settings, error) {
+	var settings database.Settings
+	if err := r.call(http.MethodGet, "/api/getSettings", "", &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *Remote) UpdateSettings(settings *database.Settings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/updateSettings", string(data), nil)
+}
+
+func (r *Remote) Plan(days int) (*planner.Plan, error) {
+	var plan planner.Plan
+	if err := r.call(http.MethodGet, "/api/getPlan?days="+strconv.Itoa(days), "", &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r *Remote) AcceptPlan(days int) (*planner.Plan, error) {
+	var plan planner.Plan
+	if err := r.call(http.MethodPost, "/api/acceptPlan?days="+strconv.Itoa(days), "", &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r *Remote) Gamification() (*database.Gamification, error) {
+	var gamification database.Gamification
+	if err := r.call(http.MethodGet, "/api/getGamification", "", &gamification); err != nil {
+		return nil, err
+	}
+	return &gamification, nil
+}
+
+// SetUser makes requests as the named user. A server that checks passwords
+// also needs the user's password or an API token.
+func (r *Remote) SetUser(name string) {
+	r.user = name
+}
+
+// SetPassword signs requests in with the password of the user set by
+// SetUser
+func (r *Remote) SetPassword(password string) {
+	r.password = password
+}
+
+// SetList makes task requests work on the shared list with the given ID or
+// name instead of the user's own tasks
+func (r *Remote) SetList(list string) {
+	r.list = list
+}
+
+// SetToken signs requests in with a personal API token
+func (r *Remote) SetToken(token string) {
+	r.token = token
+}
+
+func (r *Remote) Users() ([]database.User, error) {
+	var users []database.User
+	if err := r.call(http.MethodGet, "/api/users", "", &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *Remote) AddUser(name string, admin bool, password string) (*database.User, error) {
+	body, err := json.Marshal(map[string]interface{}{"name": name, "admin": admin, "password": password})
+	if err != nil {
+		return nil, err
+	}
+	var user database.User
+	if err := r.call(http.MethodPost, "/api/users", string(body), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *Remote) SetUserDisabled(name string, disabled bool) error {
+	body, err := json.Marshal(map[string]interface{}{"name": name, "disabled": disabled})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/updateUser", string(body), nil)
+}
+
+func (r *Remote) SetUserPassword(name, password string) error {
+	body, err := json.Marshal(map[string]string{"name": name, "password": password})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/updatePassword", string(body), nil)
+}
+
+func (r *Remote) Tokens() ([]database.Token, error) {
+	var tokens []database.Token
+	if err := r.call(http.MethodGet, "/api/tokens", "", &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *Remote) AddToken(name, scope string) (*handlers.NewToken, error) {
+	body, err := json.Marshal(map[string]string{"name": name, "scope": scope})
+	if err != nil {
+		return nil, err
+	}
+	var token handlers.NewToken
+	if err := r.call(http.MethodPost, "/api/tokens", string(body), &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *Remote) RevokeToken(id string) error {
+	body, err := json.Marshal(map[string]string{"id": id})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/deleteToken", string(body), nil)
+}
+
+func (r *Remote) Leaderboard(metric string, week time.Time) (*handlers.Leaderboard, error) {
+	params := url.Values{}
+	if metric != "" {
+		params.Set("metric", metric)
+	}
+	if !week.IsZero() {
+		params.Set("week", week.Format(time.RFC3339))
+	}
+	var board handlers.Leaderboard
+	if err := r.call(http.MethodGet, "/api/leaderboard?"+params.Encode(), "", &board); err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+func (r *Remote) Challenges() ([]handlers.ChallengeStatus, error) {
+	var challenges []handlers.ChallengeStatus
+	if err := r.call(http.MethodGet, "/api/challenges", "", &challenges); err != nil {
+		return nil, err
+	}
+	return challenges, nil
+}
+
+func (r *Remote) AddChallenge(title, metric string, target int, deadline time.Time) (*handlers.ChallengeStatus, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    title,
+		"metric":   metric,
+		"target":   target,
+		"deadline": deadline.Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var challenge handlers.ChallengeStatus
+	if err := r.call(http.MethodPost, "/api/challenges", string(body), &challenge); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *Remote) JoinChallenge(id string) (*handlers.ChallengeStatus, error) {
+	return r.updateChallenge("/api/joinChallenge", id)
+}
+
+func (r *Remote) LeaveChallenge(id string) (*handlers.ChallengeStatus, error) {
+	return r.updateChallenge("/api/leaveChallenge", id)
+}
+
+func (r *Remote) updateChallenge(path, id string) (*handlers.ChallengeStatus, error) {
+	body, err := json.Marshal(map[string]string{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	var challenge handlers.ChallengeStatus
+	if err := r.call(http.MethodPost, path, string(body), &challenge); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *Remote) RemoveChallenge(id string) error {
+	body, err := json.Marshal(map[string]string{"id": id})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/deleteChallenge", string(body), nil)
+}
+
+func (r *Remote) Lists() ([]handlers.ListInfo, error) {
+	var lists []handlers.ListInfo
+	if err := r.call(http.MethodGet, "/api/lists", "", &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (r *Remote) AddList(name string, members []string) (*handlers.ListInfo, error) {
+	body, err := json.Marshal(map[string]interface{}{"name": name, "members": members})
+	if err != nil {
+		return nil, err
+	}
+	var list handlers.ListInfo
+	if err := r.call(http.MethodPost, "/api/lists", string(body), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *Remote) SetListMembers(list string, members []string) (*handlers.ListInfo, error) {
+	body, err := json.Marshal(map[string]interface{}{"list": list, "members": members})
+	if err != nil {
+		return nil, err
+	}
+	var info handlers.ListInfo
+	if err := r.call(http.MethodPost, "/api/updateListMembers", string(body), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (r *Remote) Assign(uuid, assignee string) error {
+	if r.list == "" {
+		return errNoList
+	}
+	body, err := json.Marshal(map[string]string{"uuid": uuid, "assignee": assignee})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/assignTask", string(body), nil)
+}
+
+func (r *Remote) Webhooks() ([]database.Webhook, error) {
+	var hooks []database.Webhook
+	if err := r.call(http.MethodGet, "/api/webhooks", "", &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (r *Remote) AddWebhook(url string, events []string) (*database.Webhook, error) {
+	body, err := json.Marshal(map[string]interface{}{"url": url, "events": events, "list": r.list})
+	if err != nil {
+		return nil, err
+	}
+	var hook database.Webhook
+	if err := r.call(http.MethodPost, "/api/webhooks", string(body), &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (r *Remote) RemoveWebhook(id string) error {
+	body, err := json.Marshal(map[string]string{"id": id})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/deleteWebhook", string(body), nil)
+}
+
+func (r *Remote) TestWebhook(id string) error {
+	body, err := json.Marshal(map[string]string{"id": id})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/testWebhook", string(body), nil)
+}
+
+func (r *Remote) Snooze(uuid string, until time.Time) error {
+	body, err := json.Marshal(map[string]interface{}{"uuid": uuid, "until": until})
+	if err != nil {
+		return err
+	}
+	return r.call(http.MethodPost, "/api/snoozeReminder", string(body), nil)
+}
+
+func (r *Remote) SendReport(kind string) (string, error) {
+	body, err := json.Marshal(map[string]string{"kind": kind})
+	if err != nil {
+		return "", err
+	}
+	var sent struct {
+		To string `json:"to"`
+	}
+	if err := r.call(http.MethodPost, "/api/sendReport", string(body), &sent); err != nil {
+		return "", err
+	}
+	return sent.To, nil
+}
+
+func (r *Remote) Close() error {
+	return nil
+}
+
+// call sends a plain text body to the API and decodes the JSON response into
+// result, if given
+func (r *Remote) call(method, path, body string, result interface{}) error {
+	_, err := r.send(method, path, body, result)
+	return err
+}
+
+// send works like call and also returns the response headers
+func (r *Remote) send(method, path, body string, result interface{}) (http.Header, error) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, r.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-Done-Session", remoteSession)
+	if r.user != "" {
+		req.Header.Set("X-Done-User", r.user)
+	}
+	if r.list != "" {
+		req.Header.Set("X-Done-List", r.list)
+	}
+	switch {
+	case r.token != "":
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	case r.user != "" && r.password != "":
+		req.SetBasicAuth(r.user, r.password)
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("server responded %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return resp.Header, nil
+	}
+	return resp.Header, json.Unmarshal(data, result)
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/utils"
+)
+
+// request is what the test server received
+type request struct {
+	method, path, body string
+	header             http.Header
+}
+
+// serve starts a server that records each request and answers with status
+// and body, and returns a client of it
+func serve(t *testing.T, status int, body string, header http.Header) (*Remote, *[]request) {
+	t.Helper()
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.RequestURI(), string(data), r.Header})
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return NewRemote(server.URL + "/"), &requests
+}
+
+func TestRemoteAdd(t *testing.T) {
+	r, requests := serve(t, http.StatusOK, "", nil)
+	deadline := time.Date(2026, 11, 1, 15, 0, 0, 0, time.Local)
+	task := &database.Task{Body: "Write report", DurationExecutionEstimatedSeconds: 5400, TimeHardDeadline: &deadline, DeadlineHasTime: true, Priority: "high"}
+	if err := r.Add(task); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(*requests))
+	}
+	got := (*requests)[0]
+	want := utils.EncodeTaskText("Write report") + "$;5400$;11$;1$;2026$;15:00$;$;$;$;high"
+	if got.method != http.MethodPost || got.path != "/api/addTask" || got.body != want {
+		t.Errorf("request = %s %s %q, want POST /api/addTask %q", got.method, got.path, got.body, want)
+	}
+}
+
+func TestRemoteHeaders(t *testing.T) {
+	r, requests := serve(t, http.StatusOK, "[]", nil)
+	r.SetUser("bob")
+	r.SetPassword("bob's password")
+	if _, err := r.Tasks(); err != nil {
+		t.Fatal(err)
+	}
+	r.SetList("home")
+	r.SetToken("secret")
+	if _, err := r.Tasks(); err != nil {
+		t.Fatal(err)
+	}
+
+	password := (*requests)[0]
+	if user, pass, ok := (&http.Request{Header: password.header}).BasicAuth(); !ok || user != "bob" || pass != "bob's password" {
+		t.Errorf("basic auth = %q %q %v", user, pass, ok)
+	}
+	if password.header.Get("X-Done-List") != "" {
+		t.Errorf("list sent before SetList: %q", password.header.Get("X-Done-List"))
+	}
+
+	token := (*requests)[1]
+	for key, want := range map[string]string{
+		"X-Done-User":    "bob",
+		"X-Done-List":    "home",
+		"X-Done-Session": remoteSession,
+		"Authorization":  "Bearer secret",
+	} {
+		if got := token.header.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestRemoteComplete(t *testing.T) {
+	tasks := `[{"uuid": "a", "body": "Call Bob"}, {"uuid": "b", "body": "Write report"}, {"uuid": "c", "body": "Send report"}]`
+	r, requests := serve(t, http.StatusOK, tasks, http.Header{"X-Done-Unblocked": {"c,a"}})
+	unblocked, err := r.Complete("d", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := (*requests)[0]; got.path != "/api/completeTask?force=true" || got.body != "d" {
+		t.Errorf("request = %s %q", got.path, got.body)
+	}
+	if len(unblocked) != 2 || unblocked[0].UUID != "c" || unblocked[1].UUID != "a" {
+		t.Errorf("unblocked = %+v, want c and a", unblocked)
+	}
+}
+
+func TestRemoteErrors(t *testing.T) {
+	r, requests := serve(t, http.StatusForbidden, "Task is assigned to someone else\n", nil)
+	_, err := r.Complete("a", false)
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.HasSuffix(err.Error(), "Task is assigned to someone else") {
+		t.Errorf("error = %v", err)
+	}
+
+	if err := r.Assign("a", "bob"); !errors.Is(err, errNoList) {
+		t.Errorf("assigning outside a list: %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("%d requests, want only the completion", len(*requests))
+	}
+}
@@ -0,0 +1,620 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"done/lib/auth"
+	database "done/lib/database/interface"
+	"done/lib/hooks"
+	"done/lib/mail"
+	uuid "github.com/satori/go.uuid"
+)
+
+// A server without users serves everyone from the one list, as before. Once
+// the first user is added, every request is made by a user, as told by the
+// Authenticator, and is served from that user's tasks, history,
+// gamification and settings. The first user owns the data from before there
+// were users and may add further users.
+
+const (
+	userHeader = "X-Done-User"
+	userCookie = "done_user"
+)
+
+// userName restricts names to what is safe as a report directory
+var userName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,31}$`)
+
+var (
+	errSignIn   = errors.New("sign in required")
+	errDisabled = errors.New("user is disabled")
+	errNotAdmin = errors.New("only admins may manage users")
+)
+
+// Accounts routes requests to the handler of the user who made them
+type Accounts struct {
+	Store database.Store
+	Auth  Authenticator
+
+	// Location is the time zone of every user's handler; nil means local
+	Location *time.Location
+
+	// Hooks runs the hooks of every user's handler and their events; nil
+	// runs none
+	Hooks *hooks.Runner
+
+	// Mail sends every user's reports by email; nil sends none
+	Mail *mail.Sender
+
+	// PublicWebhooks only lets webhooks reach public addresses, so users
+	// cannot make the server send requests into its own network
+	PublicWebhooks bool
+
+	// Notifiers send reminders, by channel. The events and desktop
+	// channels are there from the start.
+	Notifiers map[string]Notifier
+
+	mu       sync.Mutex
+	handlers map[string]*Handler // By user ID; "" is the owner's, or everyone's without users
+
+	challenges sync.Mutex // Serializes changes to the participants of challenges
+	webhooks   sync.Mutex // Serializes changes to webhooks
+
+	stop     chan struct{}  // Closed by Close
+	wake     chan struct{}  // Tells RunWebhooks a delivery was queued
+	watchers sync.WaitGroup // Goroutines queueing the handlers' events for webhooks
+}
+
+// NewAccounts returns the accounts of store, signing users in with a
+// password
+func NewAccounts(store database.Store) *Accounts {
+	return &Accounts{
+		Store:    store,
+		Auth:     NewPasswordAuth(store),
+		handlers: make(map[string]*Handler),
+		stop:     make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		Notifiers: map[string]Notifier{
+			database.NotifyEvents:  eventsNotifier,
+			database.NotifyDesktop: desktopNotifier,
+		},
+	}
+}
+
+// Handler returns the handler of user, creating it on first use. A nil user
+// or the owner gets the handler of the top-level data.
+func (a *Accounts) Handler(user *database.User) (*Handler, error) {
+	key := ""
+	if user != nil && !user.Owner {
+		key = user.ID
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if h, ok := a.handlers[key]; ok {
+		return h, nil
+	}
+
+	var db database.Database = a.Store
+	if key != "" {
+		var err error
+		if db, err = a.Store.ForUser(key); err != nil {
+			return nil, err
+		}
+	}
+
+	h := NewHandler(db)
+	h.Location = a.Location
+	h.Hooks = a.Hooks
+	h.Mail = a.Mail
+	if key != "" {
+		h.ReportName = user.Name
+	}
+	a.watch(h, key, "")
+	a.handlers[key] = h
+	return h, nil
+}
+
+// Serve adapts a handler method, such as (*Handler).GetTasks, to serve each
+// request from the handler of the user who made it, or of the shared list
+// the request is about
+func (a *Accounts) Serve(f func(*Handler, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := a.identity(w, r)
+		if !ok {
+			return
+		}
+
+		if ref := listRef(r); ref != "" {
+			h, ok := a.serveList(w, identity.User, ref)
+			if ok {
+				f(h, w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+			}
+			return
+		}
+
+		h, err := a.Handler(identity.User)
+		if err != nil {
+			log.Printf("Error opening tasks of user: %v", err)
+			http.Error(w, "Failed to open tasks", http.StatusInternalServerError)
+			return
+		}
+
+		f(h, w, r)
+	}
+}
+
+// RunTrashPurger purges the expired trash of every user once at startup and
+// then every interval, like Handler.RunTrashPurger. It never returns.
+func (a *Accounts) RunTrashPurger(retention, interval time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	for {
+		users, err := a.Store.GetUsers()
+		if err != nil {
+			log.Printf("Error getting users: %v", err)
+		}
+		if len(users) == 0 {
+			users = []database.User{{Owner: true}}
+		}
+
+		var handlers []*Handler
+		for i := range users {
+			h, err := a.Handler(&users[i])
+			if err != nil {
+				log.Printf("Error opening tasks of %s: %v", users[i].Name, err)
+				continue
+			}
+			handlers = append(handlers, h)
+		}
+		lists, err := a.Store.GetSharedLists()
+		if err != nil {
+			log.Printf("Error getting shared lists: %v", err)
+		}
+		for i := range lists {
+			h, err := a.listHandler(&lists[i])
+			if err != nil {
+				log.Printf("Error opening list %s: %v", lists[i].Name, err)
+				continue
+			}
+			handlers = append(handlers, h)
+		}
+
+		for _, h := range handlers {
+			purged, err := h.PurgeExpired(retention)
+			if err != nil {
+				log.Printf("Error purging trash: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d tasks deleted more than %v ago", purged, retention)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// AddUser creates a user with a new ID and, unless it is empty, a password.
+// The first user becomes the owner.
+func (a *Accounts) AddUser(name string, admin bool, password string) (*database.User, error) {
+	if !userName.MatchString(name) {
+		return nil, errors.New("user names are up to 32 letters, digits, dots, dashes and underscores")
+	}
+	var hash string
+	if password != "" {
+		if err := auth.CheckPasswordStrength(password); err != nil {
+			return nil, err
+		}
+		var err error
+		if hash, err = auth.HashPassword(password); err != nil {
+			return nil, err
+		}
+	}
+
+	user := &database.User{
+		ID:      uuid.NewV4().String(),
+		Name:    name,
+		Admin:   admin,
+		Created: time.Now(),
+	}
+	if err := a.Store.AddUser(user); err != nil {
+		return nil, err
+	}
+	if hash != "" {
+		if err := a.Store.SetPasswordHash(user.ID, hash); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// SetUserDisabled disables or enables the named user. The owner cannot be
+// disabled, so the server always has an admin.
+func (a *Accounts) SetUserDisabled(name string, disabled bool) (*database.User, error) {
+	user, err := a.Store.GetUserByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if user.Owner && disabled {
+		return nil, errors.New("the owner cannot be disabled")
+	}
+
+	user.Disabled = disabled
+	return user, a.Store.UpdateUser(user)
+}
+
+// SetPassword changes the named user's password and signs them out
+// everywhere
+func (a *Accounts) SetPassword(name, password string) error {
+	if err := auth.CheckPasswordStrength(password); err != nil {
+		return err
+	}
+	user, err := a.Store.GetUserByName(name)
+	if err != nil {
+		return err
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := a.Store.SetPasswordHash(user.ID, hash); err != nil {
+		return err
+	}
+	return a.Store.RemoveSessions(user.ID)
+}
+
+// NewToken is an API token as created, with the secret that is shown once
+type NewToken struct {
+	database.Token
+	Secret string `json:"token"`
+}
+
+// CreateToken gives user a new API token with the given scope
+func (a *Accounts) CreateToken(user *database.User, name, scope string) (*NewToken, error) {
+	if name == "" {
+		return nil, errors.New("token name is required")
+	}
+	if !auth.ValidScope(scope) {
+		return nil, errors.New("token scope must be read or write")
+	}
+
+	secret, err := auth.NewSecret(auth.TokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	token := database.Token{
+		ID:      uuid.NewV4().String(),
+		UserID:  user.ID,
+		Name:    name,
+		Scope:   scope,
+		Created: time.Now(),
+		Hash:    auth.HashSecret(secret),
+	}
+	if err := a.Store.AddToken(&token); err != nil {
+		return nil, err
+	}
+	return &NewToken{Token: token, Secret: secret}, nil
+}
+
+// RevokeToken deletes one of user's API tokens
+func (a *Accounts) RevokeToken(user *database.User, id string) error {
+	tokens, err := a.Store.GetTokens(user.ID)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.ID == id {
+			return a.Store.RemoveToken(id)
+		}
+	}
+	return database.ErrTokenNotFound
+}
+
+// Login signs in the user posted as JSON ({"name": "ann", "password":
+// "..."}), setting the cookie that identifies them in later requests
+func (a *Accounts) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var login struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
+		http.Error(w, "Invalid login", http.StatusBadRequest)
+		return
+	}
+
+	user, err := a.Auth.SignIn(w, login.Name, login.Password)
+	switch {
+	case errors.Is(err, errBadCredentials):
+		log.Printf("Failed sign-in as %q from %s", login.Name, r.RemoteAddr)
+		w.Header().Set(authHeader, a.Auth.Name())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, errDisabled):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	errHandler(err)
+
+	writeJSON(w, user)
+}
+
+// Logout forgets the browser's sign-in
+func (a *Accounts) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	errHandler(a.Auth.SignOut(w, r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me responds with the user who made the request, or null on a server
+// without users
+func (a *Accounts) Me(w http.ResponseWriter, r *http.Request) {
+	identity, ok := a.identity(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, identity.User)
+}
+
+// manager returns who made a request to manage users or tokens. Managing
+// needs a password, not an API token; managing users also needs an admin,
+// except for adding the first user.
+func (a *Accounts) manager(w http.ResponseWriter, r *http.Request, admin bool) (*Identity, bool) {
+	identity, ok := a.identity(w, r)
+	if !ok {
+		return nil, false
+	}
+	if identity.Token != nil {
+		http.Error(w, errTokenRefused.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	if admin && identity.User != nil && !identity.User.Admin {
+		http.Error(w, errNotAdmin.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return identity, true
+}
+
+// Users lists the users on GET, and adds the user posted as JSON
+// ({"name": "ann", "admin": false, "password": "..."}) on POST. Only admins
+// manage users, except for adding the first one.
+func (a *Accounts) Users(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identity, ok := a.manager(w, r, true)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		users, err := a.Store.GetUsers()
+		errHandler(err)
+		writeJSON(w, users)
+		return
+	}
+
+	var add struct {
+		Name     string `json:"name"`
+		Admin    bool   `json:"admin"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&add); err != nil {
+		http.Error(w, "Invalid user", http.StatusBadRequest)
+		return
+	}
+
+	// Without a password the first user could not sign in again
+	if identity.User == nil && add.Password == "" && a.Auth.Name() == "password" {
+		http.Error(w, "the first user needs a password", http.StatusBadRequest)
+		return
+	}
+
+	added, err := a.AddUser(add.Name, add.Admin, add.Password)
+	if errors.Is(err, database.ErrUserExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Added user %s", added.Name)
+	writeJSON(w, added)
+}
+
+// UpdateUser disables or enables a user, posted as JSON
+// ({"name": "ann", "disabled": true}). Only admins may change users.
+func (a *Accounts) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identity, ok := a.manager(w, r, true)
+	if !ok {
+		return
+	}
+	if identity.User == nil {
+		http.Error(w, errNotAdmin.Error(), http.StatusForbidden)
+		return
+	}
+
+	var update struct {
+		Name     string `json:"name"`
+		Disabled bool   `json:"disabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid user", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := a.SetUserDisabled(update.Name, update.Disabled)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s disabled: %v", updated.Name, updated.Disabled)
+	writeJSON(w, updated)
+}
+
+// UpdatePassword changes a password posted as JSON ({"name": "ann",
+// "password": "..."}). Users change their own password, and admins anyone's.
+func (a *Accounts) UpdatePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identity, ok := a.manager(w, r, false)
+	if !ok {
+		return
+	}
+
+	var change struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		http.Error(w, "Invalid password", http.StatusBadRequest)
+		return
+	}
+
+	if identity.User == nil {
+		http.Error(w, errSignIn.Error(), http.StatusForbidden)
+		return
+	}
+	if change.Name == "" {
+		change.Name = identity.User.Name
+	}
+	if !identity.User.Admin && change.Name != identity.User.Name {
+		http.Error(w, "only admins may change the passwords of others", http.StatusForbidden)
+		return
+	}
+
+	err := a.SetPassword(change.Name, change.Password)
+	if errors.Is(err, database.ErrUserNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Password of %s changed by %s", change.Name, identity.User.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Tokens lists the API tokens of the user on GET, and creates one posted as
+// JSON ({"name": "laptop", "scope": "read"}) on POST. The response to POST
+// holds the token itself, which is not shown again.
+func (a *Accounts) Tokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identity, ok := a.manager(w, r, false)
+	if !ok {
+		return
+	}
+	if identity.User == nil {
+		http.Error(w, "tokens belong to users; add a user first", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		tokens, err := a.Store.GetTokens(identity.User.ID)
+		errHandler(err)
+		writeJSON(w, tokens)
+		return
+	}
+
+	var create struct {
+		Name  string `json:"name"`
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		http.Error(w, "Invalid token", http.StatusBadRequest)
+		return
+	}
+	if create.Scope == "" {
+		create.Scope = auth.ScopeWrite
+	}
+
+	token, err := a.CreateToken(identity.User, create.Name, create.Scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s created %s token %q", identity.User.Name, token.Scope, token.Name)
+	writeJSON(w, token)
+}
+
+// DeleteToken revokes the API token whose ID is posted as JSON
+// ({"id": "..."})
+func (a *Accounts) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identity, ok := a.manager(w, r, false)
+	if !ok {
+		return
+	}
+	if identity.User == nil {
+		http.Error(w, database.ErrTokenNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	var revoke struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&revoke); err != nil {
+		http.Error(w, "Invalid token", http.StatusBadRequest)
+		return
+	}
+
+	err := a.RevokeToken(identity.User, revoke.ID)
+	if errors.Is(err, database.ErrTokenNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	errHandler(err)
+
+	log.Printf("User %s revoked token %s", identity.User.Name, revoke.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON responds with v encoded as JSON
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
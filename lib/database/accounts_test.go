@@ -0,0 +1,140 @@
+package database
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"done/lib/auth"
+	database "done/lib/database/interface"
+)
+
+// apiRequest sends a request through Protect to a handler method served from
+// the handler of whoever the token belongs to
+func apiRequest(a *Accounts, f func(*Handler, http.ResponseWriter, *http.Request), method, target, body, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.Protect(a.Serve(f)).ServeHTTP(w, r)
+	return w
+}
+
+func TestUsersDoNotSeeEachOthersData(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // Completions are added to the report
+	a := NewAccounts(openStore(t))
+	defer a.Close()
+	owner, err := a.AddUser("ann", true, "ann's password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := a.AddUser("bob", false, "bob's password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid, err := a.AddUser("cid", false, "cid's password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := a.Handler(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uuid := range []string{"open", "done"} {
+		if err := h.DB.AddTask(&database.Task{UUID: uuid, Body: "Bob's " + uuid + " task"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.Complete("done"); err != nil {
+		t.Fatal(err)
+	}
+	settings := database.DefaultSettings()
+	settings.SortMode = database.SortSmart
+	if err := h.DB.UpdateSettings(&settings); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := a.CreateToken(bob, "laptop", auth.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := apiRequest(a, (*Handler).GetTasks, http.MethodGet, "/api/getTasks", "", token.Secret); !strings.Contains(w.Body.String(), "Bob's open task") {
+		t.Fatalf("bob's tasks: %d %s", w.Code, w.Body)
+	}
+
+	for _, user := range []*database.User{owner, cid} {
+		other, err := a.Handler(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tasks, err := other.DB.GetTasks(); err != nil || len(tasks) != 0 {
+			t.Errorf("%s sees tasks %v (%v)", user.Name, tasks, err)
+		}
+		if completed, err := other.DB.GetCompletedTasks(); err != nil || len(completed) != 0 {
+			t.Errorf("%s sees completed tasks %v (%v)", user.Name, completed, err)
+		}
+		if gamification, err := other.DB.GetGamification(); err != nil || gamification.CompletedTasks != 0 || gamification.TotalPoints != 0 {
+			t.Errorf("%s sees gamification %+v (%v)", user.Name, gamification, err)
+		}
+		if settings, err := other.DB.GetSettings(); err != nil || settings.SortMode == database.SortSmart {
+			t.Errorf("%s sees settings %+v (%v)", user.Name, settings, err)
+		}
+
+		token, err := a.CreateToken(user, "laptop", auth.ScopeWrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := apiRequest(a, (*Handler).GetTasks, http.MethodGet, "/api/getTasks", "", token.Secret); strings.Contains(w.Body.String(), "Bob's") {
+			t.Errorf("%s got bob's tasks: %s", user.Name, w.Body)
+		}
+	}
+}
+
+func TestProtect(t *testing.T) {
+	a := NewAccounts(openStore(t))
+	defer a.Close()
+	request := func(remote, token string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/getTasks", nil)
+		r.RemoteAddr = remote
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		a.Protect(a.Serve((*Handler).GetTasks)).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Before the first user, only this machine may use the server
+	if code := request("127.0.0.1:40000", ""); code != http.StatusOK {
+		t.Errorf("loopback without users: %d, want 200", code)
+	}
+	if code := request("[::1]:40000", ""); code != http.StatusOK {
+		t.Errorf("IPv6 loopback without users: %d, want 200", code)
+	}
+	if code := request("192.0.2.1:40000", ""); code != http.StatusUnauthorized {
+		t.Errorf("remote without users: %d, want 401", code)
+	}
+
+	user, err := a.AddUser("ann", true, "ann's password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, remote := range []string{"127.0.0.1:40000", "192.0.2.1:40000"} {
+		if code := request(remote, ""); code != http.StatusUnauthorized {
+			t.Errorf("unauthenticated from %s: %d, want 401", remote, code)
+		}
+		if code := request(remote, "done_wrong"); code != http.StatusUnauthorized {
+			t.Errorf("unknown token from %s: %d, want 401", remote, code)
+		}
+	}
+	token, err := a.CreateToken(user, "laptop", auth.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := request("192.0.2.1:40000", token.Secret); code != http.StatusOK {
+		t.Errorf("with a token: %d, want 200", code)
+	}
+}
@@ -0,0 +1,433 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"done/lib/auth"
+	database "done/lib/database/interface"
+)
+
+// Every /api route but the public ones goes through Accounts.Protect, which
+// asks the Authenticator who made the request. PasswordAuth, the default,
+// signs browsers in with a password and a session cookie, and takes personal
+// API tokens from the command line and integrations. TrustedAuth takes the
+// user's name on trust, for servers only a trusted network can reach.
+//
+// Routes need write access unless the router marks them with Reads or
+// ReadsOnGet, so a read-scoped API token only reaches routes known to
+// change nothing, whatever the method of the request.
+
+const (
+	signInCookie = "done_signin" // Holds the secret of a PasswordAuth session
+	authHeader   = "X-Done-Auth" // Tells a client that is refused how to sign in
+
+	sessionLength = 30 * 24 * time.Hour
+)
+
+// publicPaths may be requested without signing in
+var publicPaths = map[string]bool{
+	"/api/version": true,
+	"/api/login":   true,
+	"/api/logout":  true,
+}
+
+var (
+	errBadCredentials = errors.New("wrong name or password")
+	errLocalOnly      = errors.New("add a user with a password to use Done from other machines")
+	errTokenRefused   = errors.New("sign in with a password to manage users and tokens")
+	errReadOnly       = errors.New("the token only allows reading")
+)
+
+// Identity is who made a request and what they may do
+type Identity struct {
+	User  *database.User  // nil on a server without users
+	Scope string          // auth.ScopeRead or auth.ScopeWrite
+	Token *database.Token // The API token the request was made with, if any
+}
+
+// Authenticator identifies the user behind requests
+type Authenticator interface {
+	// Name is sent in the X-Done-Auth header of refused requests: "password"
+	// or "trusted"
+	Name() string
+	// Authenticate returns who made the request
+	Authenticate(r *http.Request) (*Identity, error)
+	// SignIn checks a browser's credentials and sets the cookie that
+	// identifies the user in later requests
+	SignIn(w http.ResponseWriter, name, password string) (*database.User, error)
+	// SignOut forgets the browser's sign-in
+	SignOut(w http.ResponseWriter, r *http.Request) error
+}
+
+type identityKey struct{}
+
+type accessKey struct{}
+
+// Reads marks a route that changes nothing, which read-scoped API tokens
+// may use
+func Reads(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(context.WithValue(r.Context(), accessKey{}, auth.ScopeRead)))
+	}
+}
+
+// ReadsOnGet marks a route that lists on GET and changes something on other
+// methods, such as /api/lists
+func ReadsOnGet(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next(w, r)
+			return
+		}
+		Reads(next)(w, r)
+	}
+}
+
+// access returns the scope the route of a request needs
+func access(r *http.Request) string {
+	if scope, ok := r.Context().Value(accessKey{}).(string); ok {
+		return scope
+	}
+	return auth.ScopeWrite
+}
+
+// identityOf returns the identity Protect found for the request
+func identityOf(r *http.Request) (*Identity, bool) {
+	identity, ok := r.Context().Value(identityKey{}).(*Identity)
+	return identity, ok
+}
+
+// Protect refuses requests to every route but the public ones unless the
+// authenticator knows who made them. Whether their scope allows the route
+// is checked when the route asks who made the request.
+func (a *Accounts) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity, ok := a.authenticate(w, r)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	})
+}
+
+// identity returns who made the request, responding with an error when they
+// may not make it. Requests that came through Protect are not authenticated
+// again.
+func (a *Accounts) identity(w http.ResponseWriter, r *http.Request) (*Identity, bool) {
+	identity, ok := identityOf(r)
+	if !ok {
+		if identity, ok = a.authenticate(w, r); !ok {
+			return nil, false
+		}
+	}
+
+	if !auth.Allows(identity.Scope, access(r)) {
+		http.Error(w, errReadOnly.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return identity, true
+}
+
+func (a *Accounts) authenticate(w http.ResponseWriter, r *http.Request) (*Identity, bool) {
+	identity, err := a.Auth.Authenticate(r)
+	switch {
+	case errors.Is(err, errSignIn), errors.Is(err, errBadCredentials), errors.Is(err, errLocalOnly):
+		w.Header().Set(authHeader, a.Auth.Name())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	case errors.Is(err, errDisabled):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, false
+	case err != nil:
+		log.Printf("Error authenticating request: %v", err)
+		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+		return nil, false
+	}
+	return identity, true
+}
+
+// hasUsers reports whether any user has been added
+func hasUsers(store database.Store) (bool, error) {
+	users, err := store.GetUsers()
+	return len(users) > 0, err
+}
+
+// enabled returns an error for a disabled user
+func enabled(user *database.User) error {
+	if user.Disabled {
+		return errDisabled
+	}
+	return nil
+}
+
+// PasswordAuth signs users in with a password. Browsers get a session
+// cookie, and the command line and integrations send an API token as
+// "Authorization: Bearer done_..." or the name and password as basic auth.
+// Until the first user is added, only this machine may use the server.
+type PasswordAuth struct {
+	Store database.Store
+}
+
+// NewPasswordAuth returns the password authenticator of store
+func NewPasswordAuth(store database.Store) *PasswordAuth {
+	return &PasswordAuth{Store: store}
+}
+
+func (p *PasswordAuth) Name() string {
+	return "password"
+}
+
+func (p *PasswordAuth) Authenticate(r *http.Request) (*Identity, error) {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return p.token(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+	}
+
+	if name, password, ok := r.BasicAuth(); ok {
+		user, err := p.checkPassword(name, password)
+		if err != nil {
+			if errors.Is(err, errBadCredentials) {
+				log.Printf("Failed sign-in as %q from %s", name, r.RemoteAddr)
+			}
+			return nil, err
+		}
+		return &Identity{User: user, Scope: auth.ScopeWrite}, nil
+	}
+
+	if cookie, err := r.Cookie(signInCookie); err == nil && cookie.Value != "" {
+		return p.session(cookie.Value)
+	}
+
+	exist, err := hasUsers(p.Store)
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		return nil, errSignIn
+	}
+	if !fromLoopback(r) {
+		return nil, errLocalOnly
+	}
+	return &Identity{Scope: auth.ScopeWrite}, nil
+}
+
+func (p *PasswordAuth) token(secret string) (*Identity, error) {
+	token, err := p.Store.GetTokenByHash(auth.HashSecret(secret))
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return nil, errSignIn
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := p.Store.GetUser(token.UserID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return nil, errSignIn
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := enabled(user); err != nil {
+		return nil, err
+	}
+	return &Identity{User: user, Scope: token.Scope, Token: token}, nil
+}
+
+func (p *PasswordAuth) session(secret string) (*Identity, error) {
+	hash := auth.HashSecret(secret)
+	session, err := p.Store.GetSession(hash)
+	if errors.Is(err, database.ErrSessionNotFound) {
+		return nil, errSignIn
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(session.Expires) {
+		p.Store.RemoveSession(hash)
+		return nil, errSignIn
+	}
+
+	user, err := p.Store.GetUser(session.UserID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return nil, errSignIn
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := enabled(user); err != nil {
+		return nil, err
+	}
+	return &Identity{User: user, Scope: auth.ScopeWrite}, nil
+}
+
+// checkPassword returns the enabled user with the name and password
+func (p *PasswordAuth) checkPassword(name, password string) (*database.User, error) {
+	user, err := p.Store.GetUserByName(name)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return nil, errBadCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := p.Store.PasswordHash(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		return nil, errBadCredentials
+	}
+	ok, err := auth.CheckPassword(hash, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errBadCredentials
+	}
+
+	if err := enabled(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (p *PasswordAuth) SignIn(w http.ResponseWriter, name, password string) (*database.User, error) {
+	user, err := p.checkPassword(name, password)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := auth.NewSecret("")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &database.Session{UserID: user.ID, Created: now, Expires: now.Add(sessionLength)}
+	if err := p.Store.AddSession(auth.HashSecret(secret), session); err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     signInCookie,
+		Value:    secret,
+		Path:     "/",
+		Expires:  session.Expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return user, nil
+}
+
+func (p *PasswordAuth) SignOut(w http.ResponseWriter, r *http.Request) error {
+	clearCookie(w, signInCookie)
+	if cookie, err := r.Cookie(signInCookie); err == nil && cookie.Value != "" {
+		return p.Store.RemoveSession(auth.HashSecret(cookie.Value))
+	}
+	return nil
+}
+
+// TrustedAuth takes the user's name from the X-Done-User header or the
+// cookie set by SignIn, without a password. Anyone who can reach the server
+// may act as any user, so it is only for trusted networks.
+type TrustedAuth struct {
+	Store database.Store
+}
+
+// NewTrustedAuth returns the trusting authenticator of store
+func NewTrustedAuth(store database.Store) *TrustedAuth {
+	return &TrustedAuth{Store: store}
+}
+
+func (t *TrustedAuth) Name() string {
+	return "trusted"
+}
+
+func (t *TrustedAuth) Authenticate(r *http.Request) (*Identity, error) {
+	exist, err := hasUsers(t.Store)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return &Identity{Scope: auth.ScopeWrite}, nil
+	}
+
+	name := r.Header.Get(userHeader)
+	if name == "" {
+		if cookie, err := r.Cookie(userCookie); err == nil {
+			name = cookie.Value
+		}
+	}
+	if name == "" {
+		return nil, errSignIn
+	}
+
+	user, err := t.Store.GetUserByName(name)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return nil, errSignIn
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := enabled(user); err != nil {
+		return nil, err
+	}
+	return &Identity{User: user, Scope: auth.ScopeWrite}, nil
+}
+
+func (t *TrustedAuth) SignIn(w http.ResponseWriter, name, password string) (*database.User, error) {
+	user, err := t.Store.GetUserByName(name)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return nil, errBadCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := enabled(user); err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     userCookie,
+		Value:    user.Name,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return user, nil
+}
+
+func (t *TrustedAuth) SignOut(w http.ResponseWriter, r *http.Request) error {
+	clearCookie(w, userCookie)
+	return nil
+}
+
+// clearCookie tells the browser to forget a cookie
+func clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+// fromLoopback reports whether the request was made from this machine
+func fromLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -14,13 +14,59 @@ import (
 const (
 	tasksBucket          = "tasks"
 	completedTasksBucket = "tasks_completed"
+	trashBucket          = "tasks_trash"
+	taskHistoryBucket    = "task_history"
 	gamificationBucket   = "gamification"
 	gamificationKey      = "stats"
+	settingsBucket       = "settings"
+	settingsKey          = "settings"
+	plansBucket          = "plans"
+
+	// completedIndexBucket orders completed tasks by completion time
+	completedIndexBucket = "tasks_completed_by_time"
+
+	// usersBucket holds the accounts, and userDataBucket a bucket for each
+	// user but the owner with the buckets above
+	usersBucket    = "users"
+	userDataBucket = "user_data"
+
+	// passwordsBucket maps user IDs to password hashes, and sessionsBucket
+	// and tokensBucket map secret hashes to sessions and API tokens
+	passwordsBucket = "passwords"
+	sessionsBucket  = "sessions"
+	tokensBucket    = "tokens"
+
+	// challengesBucket holds the team challenges by ID
+	challengesBucket = "challenges"
+
+	// sharedListsBucket holds the shared lists by ID, and listDataBucket a
+	// bucket for each with the buckets of a user
+	sharedListsBucket = "shared_lists"
+	listDataBucket    = "list_data"
+
+	// webhooksBucket holds the webhooks by ID, and deliveriesBucket their
+	// queued deliveries by number
+	webhooksBucket   = "webhooks"
+	deliveriesBucket = "webhook_deliveries"
+
+	// remindersBucket holds the reminder state of the tasks of every user
+	// and shared list, under keys starting with their scope
+	remindersBucket = "reminders"
+
+	// reportsBucket holds when the emailed reports of every user were last
+	// sent, under keys starting with their scope
+	reportsBucket = "reports_sent"
+
+	// legacyNoDeadlineYear marks "no deadline" in tasks written before
+	// deadlines became nullable
+	legacyNoDeadlineYear = 9999
 )
 
 type BoltDB struct {
 	db     *bolt.DB
 	dbPath string
+	user   string // ID of the user the database is scoped to; empty for the owner
+	list   string // ID of the shared list the database is scoped to, if any
 }
 
 func NewBoltDB(dbPath string) *BoltDB {
@@ -39,19 +85,20 @@ func (b *BoltDB) Connect() error {
 
 	// Create buckets if they don't exist
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(tasksBucket))
-		if err != nil {
-			return fmt.Errorf("failed to create tasks bucket: %w", err)
+		if err := createBuckets(tx); err != nil {
+			return err
 		}
 
-		_, err = tx.CreateBucketIfNotExists([]byte(completedTasksBucket))
-		if err != nil {
-			return fmt.Errorf("failed to create completed tasks bucket: %w", err)
+		if _, err := tx.CreateBucketIfNotExists([]byte(usersBucket)); err != nil {
+			return fmt.Errorf("failed to create users bucket: %w", err)
 		}
-
-		_, err = tx.CreateBucketIfNotExists([]byte(gamificationBucket))
-		if err != nil {
-			return fmt.Errorf("failed to create gamification bucket: %w", err)
+		if _, err := tx.CreateBucketIfNotExists([]byte(userDataBucket)); err != nil {
+			return fmt.Errorf("failed to create user data bucket: %w", err)
+		}
+		for _, name := range []string{passwordsBucket, sessionsBucket, tokensBucket, challengesBucket, sharedListsBucket, listDataBucket, webhooksBucket, deliveriesBucket, remindersBucket, reportsBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("failed to create %s bucket: %w", name, err)
+			}
 		}
 
 		return nil
@@ -60,6 +107,37 @@ func (b *BoltDB) Connect() error {
 	return err
 }
 
+// createBuckets creates the buckets of one user, in the transaction itself
+// for the owner or in the user's bucket
+func createBuckets(c container) error {
+	buckets := []struct {
+		name, description string
+	}{
+		{tasksBucket, "tasks"},
+		{completedTasksBucket, "completed tasks"},
+		{gamificationBucket, "gamification"},
+		{trashBucket, "trash"},
+		{taskHistoryBucket, "task history"},
+		{settingsBucket, "settings"},
+		{plansBucket, "plans"},
+	}
+	for _, bucket := range buckets {
+		if _, err := c.CreateBucketIfNotExists([]byte(bucket.name)); err != nil {
+			return fmt.Errorf("failed to create %s bucket: %w", bucket.description, err)
+		}
+	}
+
+	// Databases from before the index have their completed tasks indexed
+	// once
+	if c.Bucket([]byte(completedIndexBucket)) == nil {
+		if err := buildCompletedIndex(c); err != nil {
+			return fmt.Errorf("failed to index completed tasks: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func (b *BoltDB) Disconnect() error {
 	if b.db != nil {
 		return b.db.Close()
@@ -71,19 +149,17 @@ func (b *BoltDB) GetTasks() ([]database.Task, error) {
 	var tasks []database.Task
 
 	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(tasksBucket))
+		bucket := b.root(tx).Bucket([]byte(tasksBucket))
 		if bucket == nil {
 			return errors.New("tasks bucket not found")
 		}
 
 		return bucket.ForEach(func(k, v []byte) error {
-			var task database.Task
-			if err := json.Unmarshal(v, &task); err != nil {
+			task, err := decodeTask(v)
+			if err != nil {
 				return err
 			}
-			// Clean task body to remove any encoding artifacts
-			task.Body = utils.CleanTaskText(task.Body)
-			tasks = append(tasks, task)
+			tasks = append(tasks, *task)
 			return nil
 		})
 	})
@@ -104,59 +180,103 @@ func (b *BoltDB) GetTasks() ([]database.Task, error) {
 	return tasks, nil
 }
 
+// TasksRevision counts the changes to the active list, apart from the time
+// spent on tasks. It is kept as the sequence of the tasks bucket.
+func (b *BoltDB) TasksRevision() (uint64, error) {
+	var revision uint64
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(tasksBucket))
+		if bucket == nil {
+			return errors.New("tasks bucket not found")
+		}
+		revision = bucket.Sequence()
+		return nil
+	})
+
+	return revision, err
+}
+
 func (b *BoltDB) GetTaskByUUID(uuid string) (*database.Task, error) {
 	var task database.Task
 
 	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(tasksBucket))
+		bucket := b.root(tx).Bucket([]byte(tasksBucket))
 		if bucket == nil {
 			return errors.New("tasks bucket not found")
 		}
 
 		data := bucket.Get([]byte(uuid))
 		if data == nil {
-			return errors.New("task not found")
+			return database.ErrTaskNotFound
 		}
 
-		return json.Unmarshal(data, &task)
+		decoded, err := decodeTask(data)
+		if err != nil {
+			return err
+		}
+		task = *decoded
+		return nil
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Clean task body to remove any encoding artifacts
-	task.Body = utils.CleanTaskText(task.Body)
-
 	return &task, nil
 }
 
 func (b *BoltDB) AddTask(task *database.Task) error {
 	return b.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(tasksBucket))
+		bucket := b.root(tx).Bucket([]byte(tasksBucket))
 		if bucket == nil {
 			return errors.New("tasks bucket not found")
 		}
 
+		// A task coming back from the completed tasks or the trash keeps
+		// counting from its old revision
+		task.Revision++
+
 		data, err := json.Marshal(task)
 		if err != nil {
 			return err
 		}
 
+		if _, err := bucket.NextSequence(); err != nil {
+			return err
+		}
 		return bucket.Put([]byte(task.UUID), data)
 	})
 }
 
+// UpdateTask stores task, bumping its revision when its content changed and
+// the revision of the list when it changed or moved
 func (b *BoltDB) UpdateTask(task *database.Task) error {
 	return b.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(tasksBucket))
+		bucket := b.root(tx).Bucket([]byte(tasksBucket))
 		if bucket == nil {
 			return errors.New("tasks bucket not found")
 		}
 
 		// Check if task exists
-		if bucket.Get([]byte(task.UUID)) == nil {
-			return errors.New("task not found")
+		stored := bucket.Get([]byte(task.UUID))
+		if stored == nil {
+			return database.ErrTaskNotFound
+		}
+		old, err := unmarshalTask(stored)
+		if err != nil {
+			return err
+		}
+
+		content, placement := task.Changes(old)
+		task.Revision = old.Revision
+		if content {
+			task.Revision++
+		}
+		if content || placement {
+			if _, err := bucket.NextSequence(); err != nil {
+				return err
+			}
 		}
 
 		data, err := json.Marshal(task)
@@ -170,11 +290,17 @@ func (b *BoltDB) UpdateTask(task *database.Task) error {
 
 func (b *BoltDB) RemoveTask(uuid string) error {
 	return b.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(tasksBucket))
+		bucket := b.root(tx).Bucket([]byte(tasksBucket))
 		if bucket == nil {
 			return errors.New("tasks bucket not found")
 		}
 
+		if bucket.Get([]byte(uuid)) == nil {
+			return nil
+		}
+		if _, err := bucket.NextSequence(); err != nil {
+			return err
+		}
 		return bucket.Delete([]byte(uuid))
 	})
 }
@@ -183,19 +309,17 @@ func (b *BoltDB) GetCompletedTasks() ([]database.Task, error) {
 	var tasks []database.Task
 
 	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(completedTasksBucket))
+		bucket := b.root(tx).Bucket([]byte(completedTasksBucket))
 		if bucket == nil {
 			return errors.New("completed tasks bucket not found")
 		}
 
 		return bucket.ForEach(func(k, v []byte) error {
-			var task database.Task
-			if err := json.Unmarshal(v, &task); err != nil {
+			task, err := decodeTask(v)
+			if err != nil {
 				return err
 			}
-			// Clean task body to remove any encoding artifacts
-			task.Body = utils.CleanTaskText(task.Body)
-			tasks = append(tasks, task)
+			tasks = append(tasks, *task)
 			return nil
 		})
 	})
@@ -207,9 +331,28 @@ func (b *BoltDB) GetCompletedTasks() ([]database.Task, error) {
 	return tasks, nil
 }
 
+func (b *BoltDB) GetCompletedTaskByUUID(uuid string) (*database.Task, error) {
+	return b.getTask(completedTasksBucket, uuid)
+}
+
+func (b *BoltDB) RemoveCompletedTask(uuid string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(completedTasksBucket))
+		if bucket == nil {
+			return errors.New("completed tasks bucket not found")
+		}
+
+		if err := unindexCompleted(b.root(tx), uuid); err != nil {
+			return err
+		}
+
+		return bucket.Delete([]byte(uuid))
+	})
+}
+
 func (b *BoltDB) AddCompletedTask(task *database.Task) error {
 	return b.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(completedTasksBucket))
+		bucket := b.root(tx).Bucket([]byte(completedTasksBucket))
 		if bucket == nil {
 			return errors.New("completed tasks bucket not found")
 		}
@@ -219,15 +362,183 @@ func (b *BoltDB) AddCompletedTask(task *database.Task) error {
 			return err
 		}
 
+		// A task stored again may have a new completion time
+		if err := unindexCompleted(b.root(tx), task.UUID); err != nil {
+			return err
+		}
+		if err := indexCompleted(b.root(tx), task); err != nil {
+			return err
+		}
+
 		return bucket.Put([]byte(task.UUID), data)
 	})
 }
 
+func (b *BoltDB) GetTrashedTasks() ([]database.Task, error) {
+	return b.listTasks(trashBucket)
+}
+
+func (b *BoltDB) GetTrashedTaskByUUID(uuid string) (*database.Task, error) {
+	return b.getTask(trashBucket, uuid)
+}
+
+func (b *BoltDB) AddTrashedTask(task *database.Task) error {
+	return b.putTask(trashBucket, task)
+}
+
+func (b *BoltDB) RemoveTrashedTask(uuid string) error {
+	return b.deleteTask(trashBucket, uuid)
+}
+
+// listTasks returns all tasks stored in the named bucket
+func (b *BoltDB) listTasks(name string) ([]database.Task, error) {
+	var tasks []database.Task
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(name))
+		if bucket == nil {
+			return fmt.Errorf("%s bucket not found", name)
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			task, err := decodeTask(v)
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, *task)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// getTask returns the task with the given UUID from the named bucket
+func (b *BoltDB) getTask(name, uuid string) (*database.Task, error) {
+	var task *database.Task
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(name))
+		if bucket == nil {
+			return fmt.Errorf("%s bucket not found", name)
+		}
+
+		data := bucket.Get([]byte(uuid))
+		if data == nil {
+			return database.ErrTaskNotFound
+		}
+
+		var err error
+		task, err = decodeTask(data)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// putTask stores a task in the named bucket under its UUID
+func (b *BoltDB) putTask(name string, task *database.Task) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(name))
+		if bucket == nil {
+			return fmt.Errorf("%s bucket not found", name)
+		}
+
+		data, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(task.UUID), data)
+	})
+}
+
+// deleteTask removes the task with the given UUID from the named bucket
+func (b *BoltDB) deleteTask(name, uuid string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(name))
+		if bucket == nil {
+			return fmt.Errorf("%s bucket not found", name)
+		}
+
+		return bucket.Delete([]byte(uuid))
+	})
+}
+
+func (b *BoltDB) GetTaskHistory(uuid string) ([]database.TaskEdit, error) {
+	var history []database.TaskEdit
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(taskHistoryBucket))
+		if bucket == nil {
+			return errors.New("task history bucket not found")
+		}
+
+		data := bucket.Get([]byte(uuid))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &history)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// AddTaskEdit appends an edit to the history of its task
+func (b *BoltDB) AddTaskEdit(edit *database.TaskEdit) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(taskHistoryBucket))
+		if bucket == nil {
+			return errors.New("task history bucket not found")
+		}
+
+		var history []database.TaskEdit
+		if data := bucket.Get([]byte(edit.TaskUUID)); data != nil {
+			if err := json.Unmarshal(data, &history); err != nil {
+				return err
+			}
+		}
+		history = append(history, *edit)
+
+		data, err := json.Marshal(history)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(edit.TaskUUID), data)
+	})
+}
+
+// RemoveTaskHistory deletes the edit history of a task
+func (b *BoltDB) RemoveTaskHistory(uuid string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(taskHistoryBucket))
+		if bucket == nil {
+			return errors.New("task history bucket not found")
+		}
+
+		return bucket.Delete([]byte(uuid))
+	})
+}
+
 func (b *BoltDB) GetGamification() (*database.Gamification, error) {
 	var gamification database.Gamification
 
 	err := b.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(gamificationBucket))
+		bucket := b.root(tx).Bucket([]byte(gamificationBucket))
 		if bucket == nil {
 			return errors.New("gamification bucket not found")
 		}
@@ -255,7 +566,7 @@ func (b *BoltDB) GetGamification() (*database.Gamification, error) {
 
 func (b *BoltDB) UpdateGamification(gamification *database.Gamification) error {
 	return b.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(gamificationBucket))
+		bucket := b.root(tx).Bucket([]byte(gamificationBucket))
 		if bucket == nil {
 			return errors.New("gamification bucket not found")
 		}
@@ -269,7 +580,171 @@ func (b *BoltDB) UpdateGamification(gamification *database.Gamification) error {
 	})
 }
 
+// GetSettings returns the stored settings, or the defaults when none are
+// stored yet
+func (b *BoltDB) GetSettings() (*database.Settings, error) {
+	settings := database.DefaultSettings()
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(settingsBucket))
+		if bucket == nil {
+			return errors.New("settings bucket not found")
+		}
+
+		data := bucket.Get([]byte(settingsKey))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &settings)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (b *BoltDB) UpdateSettings(settings *database.Settings) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(settingsBucket))
+		if bucket == nil {
+			return errors.New("settings bucket not found")
+		}
+
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(settingsKey), data)
+	})
+}
+
+// GetDayPlan returns the plan accepted for a day, given as 2006-01-02
+func (b *BoltDB) GetDayPlan(date string) (*database.DayPlan, error) {
+	var plan database.DayPlan
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(plansBucket))
+		if bucket == nil {
+			return errors.New("plans bucket not found")
+		}
+
+		data := bucket.Get([]byte(date))
+		if data == nil {
+			return database.ErrPlanNotFound
+		}
+
+		return json.Unmarshal(data, &plan)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// SaveDayPlan stores the plan of a day, replacing any plan accepted before
+func (b *BoltDB) SaveDayPlan(plan *database.DayPlan) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := b.root(tx).Bucket([]byte(plansBucket))
+		if bucket == nil {
+			return errors.New("plans bucket not found")
+		}
+
+		data, err := json.Marshal(plan)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(plan.Date), data)
+	})
+}
+
+// decodeTask unmarshals a stored task and cleans its body
+func decodeTask(data []byte) (*database.Task, error) {
+	task, err := unmarshalTask(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clean task body to remove any encoding artifacts
+	task.Body = utils.CleanTaskText(task.Body)
+
+	return task, nil
+}
+
+// unmarshalTask unmarshals a stored task, converting the legacy year 9999
+// "no deadline" marker into a nil deadline
+func unmarshalTask(data []byte) (*database.Task, error) {
+	var task database.Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, err
+	}
+
+	if task.TimeHardDeadline != nil && task.TimeHardDeadline.Year() == legacyNoDeadlineYear {
+		task.TimeHardDeadline = nil
+		task.DeadlineHasTime = false
+	}
+
+	return &task, nil
+}
+
+// DBUpgrade rewrites tasks stored with the legacy year 9999 deadline marker so
+// that they carry a null deadline instead
 func (b *BoltDB) DBUpgrade() string {
-	return "DBUpgrade not required for BoltDB"
+	upgraded := 0
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{tasksBucket, completedTasksBucket} {
+			bucket := b.root(tx).Bucket([]byte(name))
+			if bucket == nil {
+				return fmt.Errorf("%s bucket not found", name)
+			}
+
+			updates := make(map[string][]byte)
+			err := bucket.ForEach(func(k, v []byte) error {
+				var raw struct {
+					TimeHardDeadline *time.Time `json:"time_hard_dead_line"`
+				}
+				if err := json.Unmarshal(v, &raw); err != nil {
+					return err
+				}
+				if raw.TimeHardDeadline == nil || raw.TimeHardDeadline.Year() != legacyNoDeadlineYear {
+					return nil
+				}
+
+				task, err := unmarshalTask(v)
+				if err != nil {
+					return err
+				}
+				data, err := json.Marshal(task)
+				if err != nil {
+					return err
+				}
+				updates[string(k)] = data
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for k, v := range updates {
+				if err := bucket.Put([]byte(k), v); err != nil {
+					return err
+				}
+			}
+			upgraded += len(updates)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Sprintf("DBUpgrade failed: %v", err)
+	}
+	return fmt.Sprintf("DBUpgrade complete: %d tasks converted to nullable deadlines", upgraded)
 }
 
@@ -0,0 +1,66 @@
+package bolt
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	database "done/lib/database/interface"
+)
+
+func openTestDB(t *testing.T) *BoltDB {
+	t.Helper()
+	db := NewBoltDB(filepath.Join(t.TempDir(), "done.db"))
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Disconnect() })
+	return db
+}
+
+func TestDBUpgradeLegacyDeadline(t *testing.T) {
+	db := openTestDB(t)
+	legacy := time.Date(legacyNoDeadlineYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	deadline := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	tasks := []*database.Task{
+		{UUID: "legacy", Body: "No deadline", TimeHardDeadline: &legacy, DeadlineHasTime: true},
+		{UUID: "due", Body: "Due in May", TimeHardDeadline: &deadline, Order: 1},
+	}
+	for _, task := range tasks {
+		if err := db.AddTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if msg := db.DBUpgrade(); !strings.Contains(msg, "1 tasks converted") {
+		t.Fatalf("DBUpgrade: %s", msg)
+	}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket([]byte(tasksBucket)).Get([]byte("legacy")); bytes.Contains(data, []byte("9999")) {
+			t.Errorf("legacy marker still stored: %s", data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := db.GetTaskByUUID("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.TimeHardDeadline != nil || task.DeadlineHasTime {
+		t.Errorf("legacy deadline = %v, has time %v; want none", task.TimeHardDeadline, task.DeadlineHasTime)
+	}
+	if task, err := db.GetTaskByUUID("due"); err != nil || task.TimeHardDeadline == nil || !task.TimeHardDeadline.Equal(deadline) {
+		t.Errorf("real deadline changed: %v (%v)", task, err)
+	}
+
+	if msg := db.DBUpgrade(); !strings.Contains(msg, "0 tasks converted") {
+		t.Errorf("second DBUpgrade: %s", msg)
+	}
+}
@@ -0,0 +1,72 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/boltdb/bolt"
+
+	database "done/lib/database/interface"
+)
+
+func (b *BoltDB) GetChallenges() ([]database.Challenge, error) {
+	challenges := []database.Challenge{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(challengesBucket))
+		if bucket == nil {
+			return errors.New("challenges bucket not found")
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var challenge database.Challenge
+			if err := json.Unmarshal(v, &challenge); err != nil {
+				return err
+			}
+			challenges = append(challenges, challenge)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(challenges); i++ {
+		for j := i + 1; j < len(challenges); j++ {
+			if challenges[j].Deadline.Before(challenges[i].Deadline) {
+				challenges[i], challenges[j] = challenges[j], challenges[i]
+			}
+		}
+	}
+
+	return challenges, nil
+}
+
+func (b *BoltDB) GetChallenge(id string) (*database.Challenge, error) {
+	var challenge database.Challenge
+	if err := b.getJSON(challengesBucket, id, &challenge, database.ErrChallengeNotFound); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (b *BoltDB) SaveChallenge(challenge *database.Challenge) error {
+	if challenge.ID == "" {
+		return errors.New("challenge has no ID")
+	}
+	return b.putJSON(challengesBucket, challenge.ID, challenge)
+}
+
+func (b *BoltDB) RemoveChallenge(id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(challengesBucket))
+		if bucket == nil {
+			return errors.New("challenges bucket not found")
+		}
+		if bucket.Get([]byte(id)) == nil {
+			return database.ErrChallengeNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
@@ -0,0 +1,207 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+
+	database "done/lib/database/interface"
+)
+
+func (b *BoltDB) PasswordHash(userID string) (string, error) {
+	var hash string
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(passwordsBucket))
+		if bucket == nil {
+			return errors.New("passwords bucket not found")
+		}
+		hash = string(bucket.Get([]byte(userID)))
+		return nil
+	})
+
+	return hash, err
+}
+
+func (b *BoltDB) SetPasswordHash(userID, hash string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if users := tx.Bucket([]byte(usersBucket)); users == nil || users.Get([]byte(userID)) == nil {
+			return database.ErrUserNotFound
+		}
+
+		bucket := tx.Bucket([]byte(passwordsBucket))
+		if bucket == nil {
+			return errors.New("passwords bucket not found")
+		}
+		return bucket.Put([]byte(userID), []byte(hash))
+	})
+}
+
+func (b *BoltDB) AddSession(hash string, session *database.Session) error {
+	return b.putJSON(sessionsBucket, hash, session)
+}
+
+func (b *BoltDB) GetSession(hash string) (*database.Session, error) {
+	var session database.Session
+	if err := b.getJSON(sessionsBucket, hash, &session, database.ErrSessionNotFound); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (b *BoltDB) RemoveSession(hash string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(sessionsBucket))
+		if bucket == nil {
+			return errors.New("sessions bucket not found")
+		}
+		return bucket.Delete([]byte(hash))
+	})
+}
+
+func (b *BoltDB) RemoveSessions(userID string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(sessionsBucket))
+		if bucket == nil {
+			return errors.New("sessions bucket not found")
+		}
+
+		var keys [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var session database.Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+			if session.UserID == userID {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BoltDB) AddToken(token *database.Token) error {
+	if token.Hash == "" {
+		return errors.New("token has no hash")
+	}
+	return b.putJSON(tokensBucket, token.Hash, token)
+}
+
+func (b *BoltDB) GetTokens(userID string) ([]database.Token, error) {
+	tokens := []database.Token{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(tokensBucket))
+		if bucket == nil {
+			return errors.New("tokens bucket not found")
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var token database.Token
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			if token.UserID != userID {
+				return nil
+			}
+			token.Hash = string(k)
+			tokens = append(tokens, token)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		for j := i + 1; j < len(tokens); j++ {
+			if tokens[j].Created.Before(tokens[i].Created) {
+				tokens[i], tokens[j] = tokens[j], tokens[i]
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+func (b *BoltDB) GetTokenByHash(hash string) (*database.Token, error) {
+	var token database.Token
+	if err := b.getJSON(tokensBucket, hash, &token, database.ErrTokenNotFound); err != nil {
+		return nil, err
+	}
+	token.Hash = hash
+	return &token, nil
+}
+
+func (b *BoltDB) RemoveToken(id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(tokensBucket))
+		if bucket == nil {
+			return errors.New("tokens bucket not found")
+		}
+
+		var key []byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var token database.Token
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			if token.ID == id {
+				key = append([]byte(nil), k...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return database.ErrTokenNotFound
+		}
+		return bucket.Delete(key)
+	})
+}
+
+// putJSON stores v as JSON under key in a top-level bucket
+func (b *BoltDB) putJSON(name, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			return fmt.Errorf("%s bucket not found", name)
+		}
+		return bucket.Put([]byte(key), data)
+	})
+}
+
+// getJSON decodes the JSON stored under key in a top-level bucket into v,
+// returning notFound when there is none
+func (b *BoltDB) getJSON(name, key string, v interface{}, notFound error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			return fmt.Errorf("%s bucket not found", name)
+		}
+		data := bucket.Get([]byte(key))
+		if data == nil {
+			return notFound
+		}
+		return json.Unmarshal(data, v)
+	})
+}
@@ -0,0 +1,162 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	database "done/lib/database/interface"
+)
+
+// completedKeyLayout formats completion times so that index keys sort by
+// time. The UUID follows, so tasks completed at the same moment keep
+// distinct keys.
+const completedKeyLayout = "20060102150405.000000000"
+
+// completedKey is the index key of a completed task
+func completedKey(task *database.Task) []byte {
+	return []byte(task.TimeCompleted.UTC().Format(completedKeyLayout) + task.UUID)
+}
+
+// timeKey is the smallest index key of tasks completed at or after t
+func timeKey(t time.Time) []byte {
+	return []byte(t.UTC().Format(completedKeyLayout))
+}
+
+// buildCompletedIndex creates the completion time index of the completed
+// tasks
+func buildCompletedIndex(c container) error {
+	if _, err := c.CreateBucket([]byte(completedIndexBucket)); err != nil {
+		return err
+	}
+
+	return c.Bucket([]byte(completedTasksBucket)).ForEach(func(k, v []byte) error {
+		task, err := unmarshalTask(v)
+		if err != nil {
+			return err
+		}
+		return indexCompleted(c, task)
+	})
+}
+
+// indexCompleted adds a completed task to the index
+func indexCompleted(c container, task *database.Task) error {
+	index := c.Bucket([]byte(completedIndexBucket))
+	if index == nil {
+		return errors.New("completed tasks index not found")
+	}
+	return index.Put(completedKey(task), []byte(task.UUID))
+}
+
+// unindexCompleted removes a stored completed task from the index, if it is
+// stored
+func unindexCompleted(c container, uuid string) error {
+	index := c.Bucket([]byte(completedIndexBucket))
+	if index == nil {
+		return errors.New("completed tasks index not found")
+	}
+
+	data := c.Bucket([]byte(completedTasksBucket)).Get([]byte(uuid))
+	if data == nil {
+		return nil
+	}
+	task, err := unmarshalTask(data)
+	if err != nil {
+		return err
+	}
+	return index.Delete(completedKey(task))
+}
+
+// QueryCompletedTasks returns a page of completed tasks in order of
+// completion, reading only the part of the index the page covers
+func (b *BoltDB) QueryCompletedTasks(query database.CompletedQuery) (*database.CompletedPage, error) {
+	var cursor []byte
+	if query.Cursor != "" {
+		var err error
+		if cursor, err = base64.RawURLEncoding.DecodeString(query.Cursor); err != nil || len(cursor) == 0 {
+			return nil, database.ErrInvalidCursor
+		}
+	}
+
+	page := &database.CompletedPage{Tasks: []database.Task{}}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		index := b.root(tx).Bucket([]byte(completedIndexBucket))
+		bucket := b.root(tx).Bucket([]byte(completedTasksBucket))
+		if index == nil || bucket == nil {
+			return errors.New("completed tasks bucket not found")
+		}
+
+		var from, to []byte
+		if !query.From.IsZero() {
+			from = timeKey(query.From)
+		}
+		if !query.To.IsZero() {
+			to = timeKey(query.To)
+		}
+
+		c := index.Cursor()
+		var k, v []byte
+		var next func() ([]byte, []byte)
+		var inRange func([]byte) bool
+
+		if query.Oldest {
+			next = c.Next
+			inRange = func(k []byte) bool { return to == nil || bytes.Compare(k, to) < 0 }
+			switch {
+			case cursor != nil:
+				if k, v = c.Seek(cursor); bytes.Equal(k, cursor) {
+					k, v = c.Next()
+				}
+			case from != nil:
+				k, v = c.Seek(from)
+			default:
+				k, v = c.First()
+			}
+		} else {
+			next = c.Prev
+			inRange = func(k []byte) bool { return from == nil || bytes.Compare(k, from) >= 0 }
+			// Start before the first key at or after the cursor or upper bound
+			start := cursor
+			if start == nil {
+				start = to
+			}
+			if start == nil {
+				k, v = c.Last()
+			} else if k, _ = c.Seek(start); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+
+		var last []byte
+		for ; k != nil && inRange(k); k, v = next() {
+			if query.Limit > 0 && len(page.Tasks) == query.Limit {
+				page.NextCursor = base64.RawURLEncoding.EncodeToString(last)
+				break
+			}
+			last = k
+
+			data := bucket.Get(v)
+			if data == nil {
+				continue
+			}
+			task, err := decodeTask(data)
+			if err != nil {
+				return err
+			}
+			page.Tasks = append(page.Tasks, *task)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,113 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/boltdb/bolt"
+
+	database "done/lib/database/interface"
+)
+
+func (b *BoltDB) GetSharedLists() ([]database.SharedList, error) {
+	lists := []database.SharedList{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(sharedListsBucket))
+		if bucket == nil {
+			return errors.New("shared lists bucket not found")
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var list database.SharedList
+			if err := json.Unmarshal(v, &list); err != nil {
+				return err
+			}
+			lists = append(lists, list)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(lists); i++ {
+		for j := i + 1; j < len(lists); j++ {
+			if lists[j].Created.Before(lists[i].Created) {
+				lists[i], lists[j] = lists[j], lists[i]
+			}
+		}
+	}
+
+	return lists, nil
+}
+
+func (b *BoltDB) GetSharedList(id string) (*database.SharedList, error) {
+	var list database.SharedList
+	if err := b.getJSON(sharedListsBucket, id, &list, database.ErrListNotFound); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (b *BoltDB) AddSharedList(list *database.SharedList) error {
+	if list.ID == "" || list.Name == "" {
+		return errors.New("list needs an ID and a name")
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(sharedListsBucket))
+		if bucket == nil {
+			return errors.New("shared lists bucket not found")
+		}
+
+		err := bucket.ForEach(func(k, v []byte) error {
+			var other database.SharedList
+			if err := json.Unmarshal(v, &other); err != nil {
+				return err
+			}
+			if string(k) == list.ID || strings.EqualFold(other.Name, list.Name) {
+				return database.ErrListExists
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		data := tx.Bucket([]byte(listDataBucket))
+		if data == nil {
+			return errors.New("list data bucket not found")
+		}
+		own, err := data.CreateBucket([]byte(list.ID))
+		if err != nil {
+			return fmt.Errorf("failed to create list data: %w", err)
+		}
+		if err := createBuckets(own); err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(list.ID), encoded)
+	})
+}
+
+func (b *BoltDB) UpdateSharedList(list *database.SharedList) error {
+	if _, err := b.GetSharedList(list.ID); err != nil {
+		return err
+	}
+	return b.putJSON(sharedListsBucket, list.ID, list)
+}
+
+func (b *BoltDB) ForSharedList(id string) (database.Database, error) {
+	if _, err := b.GetSharedList(id); err != nil {
+		return nil, err
+	}
+	return &BoltDB{db: b.db, dbPath: b.dbPath, list: id}, nil
+}
@@ -0,0 +1,70 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/boltdb/bolt"
+
+	database "done/lib/database/interface"
+)
+
+// scope starts the keys of the database's user or shared list in buckets
+// shared by all of them
+func (b *BoltDB) scope() string {
+	if b.list != "" {
+		return "list/" + b.list + "/"
+	}
+	return "user/" + b.user + "/"
+}
+
+func (b *BoltDB) GetReminderStates() (map[string]database.ReminderState, error) {
+	states := make(map[string]database.ReminderState)
+	scope := b.scope()
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(remindersBucket))
+		if bucket == nil {
+			return errors.New("reminders bucket not found")
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek([]byte(scope)); k != nil && strings.HasPrefix(string(k), scope); k, v = c.Next() {
+			var state database.ReminderState
+			if err := json.Unmarshal(v, &state); err != nil {
+				return err
+			}
+			states[strings.TrimPrefix(string(k), scope)] = state
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func (b *BoltDB) GetReminderState(key string) (*database.ReminderState, error) {
+	var state database.ReminderState
+	err := b.getJSON(remindersBucket, b.scope()+key, &state, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (b *BoltDB) SaveReminderState(key string, state *database.ReminderState) error {
+	return b.putJSON(remindersBucket, b.scope()+key, state)
+}
+
+func (b *BoltDB) RemoveReminderState(key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(remindersBucket))
+		if bucket == nil {
+			return errors.New("reminders bucket not found")
+		}
+		return bucket.Delete([]byte(b.scope() + key))
+	})
+}
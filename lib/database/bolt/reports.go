@@ -0,0 +1,13 @@
+package bolt
+
+import "time"
+
+func (b *BoltDB) GetReportSent(kind string) (time.Time, error) {
+	var sent time.Time
+	err := b.getJSON(reportsBucket, b.scope()+kind, &sent, nil)
+	return sent, err
+}
+
+func (b *BoltDB) SetReportSent(kind string, sent time.Time) error {
+	return b.putJSON(reportsBucket, b.scope()+kind, sent)
+}
@@ -0,0 +1,219 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/boltdb/bolt"
+
+	database "done/lib/database/interface"
+)
+
+// container holds the buckets of one user: the transaction itself for the
+// owner, whose data predates users, or the user's bucket for anyone else.
+// Shared lists have a bucket like the ones of users.
+type container interface {
+	Bucket(name []byte) *bolt.Bucket
+	CreateBucket(name []byte) (*bolt.Bucket, error)
+	CreateBucketIfNotExists(name []byte) (*bolt.Bucket, error)
+}
+
+// root returns the buckets of the user or shared list the database is
+// scoped to
+func (b *BoltDB) root(tx *bolt.Tx) container {
+	parent, key := userDataBucket, b.user
+	if b.list != "" {
+		parent, key = listDataBucket, b.list
+	}
+	if key == "" {
+		return tx
+	}
+	if data := tx.Bucket([]byte(parent)); data != nil {
+		if bucket := data.Bucket([]byte(key)); bucket != nil {
+			return bucket
+		}
+	}
+	return noBuckets{}
+}
+
+// noBuckets is the container of a user or list whose buckets are missing,
+// so that reads report a missing bucket instead of panicking
+type noBuckets struct{}
+
+func (noBuckets) Bucket([]byte) *bolt.Bucket { return nil }
+
+func (noBuckets) CreateBucket([]byte) (*bolt.Bucket, error) {
+	return nil, errors.New("data bucket not found")
+}
+
+func (noBuckets) CreateBucketIfNotExists([]byte) (*bolt.Bucket, error) {
+	return nil, errors.New("data bucket not found")
+}
+
+func (b *BoltDB) GetUsers() ([]database.User, error) {
+	users := []database.User{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(usersBucket))
+		if bucket == nil {
+			return errors.New("users bucket not found")
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var user database.User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return err
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Oldest first, so the owner leads the list
+	for i := 0; i < len(users); i++ {
+		for j := i + 1; j < len(users); j++ {
+			if users[j].Created.Before(users[i].Created) {
+				users[i], users[j] = users[j], users[i]
+			}
+		}
+	}
+
+	return users, nil
+}
+
+func (b *BoltDB) GetUser(id string) (*database.User, error) {
+	var user database.User
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(usersBucket))
+		if bucket == nil {
+			return errors.New("users bucket not found")
+		}
+
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return database.ErrUserNotFound
+		}
+		return json.Unmarshal(data, &user)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByName finds a user by name, ignoring case
+func (b *BoltDB) GetUserByName(name string) (*database.User, error) {
+	users, err := b.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range users {
+		if strings.EqualFold(users[i].Name, name) {
+			return &users[i], nil
+		}
+	}
+	return nil, database.ErrUserNotFound
+}
+
+func (b *BoltDB) AddUser(user *database.User) error {
+	if user.ID == "" || user.Name == "" {
+		return errors.New("user needs an ID and a name")
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(usersBucket))
+		if bucket == nil {
+			return errors.New("users bucket not found")
+		}
+
+		empty := true
+		err := bucket.ForEach(func(k, v []byte) error {
+			empty = false
+			var other database.User
+			if err := json.Unmarshal(v, &other); err != nil {
+				return err
+			}
+			if string(k) == user.ID || strings.EqualFold(other.Name, user.Name) {
+				return database.ErrUserExists
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// The first user takes over the existing lists and runs the server
+		user.Owner = empty
+		if user.Owner {
+			user.Admin = true
+		} else {
+			data := tx.Bucket([]byte(userDataBucket))
+			if data == nil {
+				return errors.New("user data bucket not found")
+			}
+			own, err := data.CreateBucket([]byte(user.ID))
+			if err != nil {
+				return fmt.Errorf("failed to create user data: %w", err)
+			}
+			if err := createBuckets(own); err != nil {
+				return err
+			}
+		}
+
+		encoded, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(user.ID), encoded)
+	})
+}
+
+// UpdateUser stores the name, admin and disabled flags of an existing user.
+// Who owns the server does not change.
+func (b *BoltDB) UpdateUser(user *database.User) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(usersBucket))
+		if bucket == nil {
+			return errors.New("users bucket not found")
+		}
+
+		data := bucket.Get([]byte(user.ID))
+		if data == nil {
+			return database.ErrUserNotFound
+		}
+		var stored database.User
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+		user.Owner = stored.Owner
+		user.Created = stored.Created
+
+		encoded, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(user.ID), encoded)
+	})
+}
+
+// ForUser returns the database of one user. The owner's is the top level of
+// the file; everyone else's is a bucket of their own.
+func (b *BoltDB) ForUser(id string) (database.Database, error) {
+	user, err := b.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Owner {
+		return &BoltDB{db: b.db, dbPath: b.dbPath}, nil
+	}
+	return &BoltDB{db: b.db, dbPath: b.dbPath, user: user.ID}, nil
+}
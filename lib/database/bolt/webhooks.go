@@ -0,0 +1,184 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	database "done/lib/database/interface"
+)
+
+func (b *BoltDB) GetWebhooks() ([]database.Webhook, error) {
+	webhooks := []database.Webhook{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(webhooksBucket))
+		if bucket == nil {
+			return errors.New("webhooks bucket not found")
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var webhook database.Webhook
+			if err := json.Unmarshal(v, &webhook); err != nil {
+				return err
+			}
+			webhooks = append(webhooks, webhook)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(webhooks); i++ {
+		for j := i + 1; j < len(webhooks); j++ {
+			if webhooks[j].Created.Before(webhooks[i].Created) {
+				webhooks[i], webhooks[j] = webhooks[j], webhooks[i]
+			}
+		}
+	}
+
+	return webhooks, nil
+}
+
+func (b *BoltDB) GetWebhook(id string) (*database.Webhook, error) {
+	var webhook database.Webhook
+	if err := b.getJSON(webhooksBucket, id, &webhook, database.ErrWebhookNotFound); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (b *BoltDB) SaveWebhook(webhook *database.Webhook) error {
+	if webhook.ID == "" {
+		return errors.New("webhook has no ID")
+	}
+	return b.putJSON(webhooksBucket, webhook.ID, webhook)
+}
+
+func (b *BoltDB) RemoveWebhook(id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(webhooksBucket))
+		if bucket == nil {
+			return errors.New("webhooks bucket not found")
+		}
+		if bucket.Get([]byte(id)) == nil {
+			return database.ErrWebhookNotFound
+		}
+		if err := bucket.Delete([]byte(id)); err != nil {
+			return err
+		}
+
+		queue := tx.Bucket([]byte(deliveriesBucket))
+		if queue == nil {
+			return errors.New("webhook deliveries bucket not found")
+		}
+		var keys [][]byte
+		err := queue.ForEach(func(k, v []byte) error {
+			var delivery database.Delivery
+			if err := json.Unmarshal(v, &delivery); err != nil {
+				return err
+			}
+			if delivery.WebhookID == id {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := queue.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deliveryKey orders deliveries by number
+func deliveryKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+func (b *BoltDB) EnqueueDelivery(delivery *database.Delivery) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		queue := tx.Bucket([]byte(deliveriesBucket))
+		if queue == nil {
+			return errors.New("webhook deliveries bucket not found")
+		}
+
+		id, err := queue.NextSequence()
+		if err != nil {
+			return err
+		}
+		delivery.ID = id
+
+		encoded, err := json.Marshal(delivery)
+		if err != nil {
+			return err
+		}
+		return queue.Put(deliveryKey(id), encoded)
+	})
+}
+
+func (b *BoltDB) DueDeliveries(now time.Time, limit int) ([]database.Delivery, error) {
+	due := []database.Delivery{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		queue := tx.Bucket([]byte(deliveriesBucket))
+		if queue == nil {
+			return errors.New("webhook deliveries bucket not found")
+		}
+
+		perWebhook := make(map[string]int)
+		c := queue.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var delivery database.Delivery
+			if err := json.Unmarshal(v, &delivery); err != nil {
+				return err
+			}
+			if !delivery.NextAttempt.After(now) && perWebhook[delivery.WebhookID] < limit {
+				perWebhook[delivery.WebhookID]++
+				due = append(due, delivery)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+func (b *BoltDB) UpdateDelivery(delivery *database.Delivery) error {
+	encoded, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		queue := tx.Bucket([]byte(deliveriesBucket))
+		if queue == nil {
+			return errors.New("webhook deliveries bucket not found")
+		}
+		return queue.Put(deliveryKey(delivery.ID), encoded)
+	})
+}
+
+func (b *BoltDB) RemoveDelivery(id uint64) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		queue := tx.Bucket([]byte(deliveriesBucket))
+		if queue == nil {
+			return errors.New("webhook deliveries bucket not found")
+		}
+		return queue.Delete(deliveryKey(id))
+	})
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// Page sizes of the completed task history
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// parseHistoryTime reads a from or to parameter: a day such as 2026-03-15,
+// in the configured time zone, or an RFC 3339 time. A day given as to
+// includes the whole day.
+func (h *Handler) parseHistoryTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, h.now().Location())
+	if err != nil {
+		return time.Time{}, errors.New("invalid date " + strconv.Quote(value) + ", expected YYYY-MM-DD or RFC 3339")
+	}
+	if end {
+		return day.AddDate(0, 0, 1), nil
+	}
+	return day, nil
+}
+
+// GetCompletedHistory responds with a page of completed tasks. Parameters:
+// from and to limit the completion time, order is newest (default) or
+// oldest, limit is the page size and cursor is the next_cursor of the
+// previous page.
+func (h *Handler) GetCompletedHistory(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := database.CompletedQuery{Limit: defaultHistoryLimit, Cursor: params.Get("cursor")}
+
+	var err error
+	if from := params.Get("from"); from != "" {
+		if query.From, err = h.parseHistoryTime(from, false); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		if query.To, err = h.parseHistoryTime(to, true); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch params.Get("order") {
+	case "", "newest":
+	case "oldest":
+		query.Oldest = true
+	default:
+		http.Error(w, "order must be newest or oldest", http.StatusBadRequest)
+		return
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > maxHistoryLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxHistoryLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.DB.QueryCompletedTasks(query)
+	if err == database.ErrInvalidCursor {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error querying completed tasks: %v", err)
+		http.Error(w, "Failed to get completed tasks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	database "done/lib/database/interface"
+)
+
+// BlockedError is returned when completing a task whose blockers are still
+// open
+type BlockedError struct {
+	Blockers []database.Task
+}
+
+func (e *BlockedError) Error() string {
+	names := make([]string, len(e.Blockers))
+	for i, blocker := range e.Blockers {
+		names[i] = fmt.Sprintf("%q", firstLine(blocker.Body))
+	}
+	return "task is blocked by " + strings.Join(names, ", ")
+}
+
+// markBlocked sets the Blocked flag of tasks with a blocker among the active
+// tasks. Completed, trashed and unknown blockers do not block.
+func markBlocked(tasks []database.Task) {
+	active := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		active[task.UUID] = true
+	}
+
+	for i := range tasks {
+		tasks[i].Blocked = false
+		for _, blocker := range tasks[i].BlockedBy {
+			if active[blocker] {
+				tasks[i].Blocked = true
+				break
+			}
+		}
+	}
+}
+
+// openBlockers returns the active tasks that block task
+func (h *Handler) openBlockers(task *database.Task) ([]database.Task, error) {
+	var blockers []database.Task
+	for _, uuid := range task.BlockedBy {
+		blocker, err := h.DB.GetTaskByUUID(uuid)
+		if errors.Is(err, database.ErrTaskNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		blockers = append(blockers, *blocker)
+	}
+	return blockers, nil
+}
+
+// checkBlockers validates new blockers of the task with the given UUID. Each
+// blocker must be an active or completed task, and blocking must not come
+// full circle back to the task.
+func (h *Handler) checkBlockers(uuid string, blockers []string) error {
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		return err
+	}
+
+	edges := make(map[string][]string, len(tasks))
+	for _, task := range tasks {
+		edges[task.UUID] = task.BlockedBy
+	}
+	edges[uuid] = blockers
+
+	for _, blocker := range blockers {
+		if blocker == uuid {
+			return errors.New("a task cannot block itself")
+		}
+		if _, active := edges[blocker]; active {
+			continue
+		}
+		if _, err := h.DB.GetCompletedTaskByUUID(blocker); err != nil {
+			if errors.Is(err, database.ErrTaskNotFound) {
+				return fmt.Errorf("blocker %s not found", blocker)
+			}
+			return err
+		}
+	}
+
+	// Walk the blockers of the blockers; reaching uuid again is a cycle
+	visited := make(map[string]bool)
+	stack := append([]string(nil), blockers...)
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == uuid {
+			return errors.New("blockers would form a cycle")
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, edges[current]...)
+	}
+
+	return nil
+}
+
+// CompleteChecked completes a task unless it is blocked by an open task, in
+// which case it returns a *BlockedError; force completes it anyway. On a
+// shared list the task is claimed by the named user completing it, and it
+// is refused when assigned to someone else. It also returns the tasks the
+// completion unblocked.
+func (h *Handler) CompleteChecked(uuid, name string, force bool) (*database.Task, []database.Task, error) {
+	task, err := h.DB.GetTaskByUUID(uuid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := h.claim(task, name); err != nil {
+		return nil, nil, err
+	}
+
+	if !force {
+		blockers, err := h.openBlockers(task)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(blockers) > 0 {
+			return nil, nil, &BlockedError{Blockers: blockers}
+		}
+	}
+
+	completed, err := h.complete(task)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unblocked, err := h.unblockedBy(uuid)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, task := range unblocked {
+		log.Printf("Task %s unblocked by completing %s", task.UUID, uuid)
+		h.bus.Publish(EventTaskUnblocked, published(&unblocked[i]))
+	}
+
+	return completed, unblocked, nil
+}
+
+// unblockedBy returns the active tasks that were waiting on the completed
+// task and have no open blockers left
+func (h *Handler) unblockedBy(uuid string) ([]database.Task, error) {
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		return nil, err
+	}
+	markBlocked(tasks)
+
+	var unblocked []database.Task
+	for _, task := range tasks {
+		if task.Blocked {
+			continue
+		}
+		for _, blocker := range task.BlockedBy {
+			if blocker == uuid {
+				unblocked = append(unblocked, task)
+				break
+			}
+		}
+	}
+	return unblocked, nil
+}
+
+// CompleteTask completes the task whose UUID is posted as the body and
+// responds with the task list. A task with open blockers is refused with
+// 409 Conflict unless the force query parameter is true. The
+// X-Done-Unblocked header lists the UUIDs of tasks the completion unblocked.
+func (h *Handler) CompleteTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	uuid, err := ioutil.ReadAll(r.Body)
+	errHandler(err)
+
+	if !h.checkTask(w, r, string(uuid)) {
+		return
+	}
+
+	force := r.URL.Query().Get("force") == "true"
+
+	name := ""
+	if identity, ok := identityOf(r); ok && identity.User != nil {
+		name = identity.User.Name
+	}
+
+	_, unblocked, err := h.CompleteChecked(string(uuid), name, force)
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		http.Error(w, blocked.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errAssignedOther) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	errHandler(err)
+
+	h.record(w, r, &completeOperation{uuid: string(uuid)})
+
+	if len(unblocked) > 0 {
+		uuids := make([]string, len(unblocked))
+		for i, task := range unblocked {
+			uuids[i] = task.UUID
+		}
+		w.Header().Set("X-Done-Unblocked", strings.Join(uuids, ","))
+	}
+
+	h.writeTasks(w)
+}
+
+// firstLine returns the first line of a task body
+func firstLine(body string) string {
+	if i := strings.IndexByte(body, '\n'); i >= 0 {
+		return body[:i]
+	}
+	return body
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/digest"
+	"done/lib/mail"
+)
+
+// Users who opt in get their daily summary and weekly report by email as
+// well, at the time in their settings, sent to their address through the
+// SMTP relay of Mail. A summary the server was down for is sent when it
+// starts, unless it is over a day late.
+
+var (
+	errNoMail  = errors.New("no SMTP relay is configured; start the server with -smtp")
+	errNoEmail = errors.New("no email address in the settings")
+)
+
+// checkReportSettings returns an error unless the reports can be sent as
+// the settings say
+func checkReportSettings(settings *database.ReportSettings) error {
+	if _, err := digest.Slot(digest.Daily, time.Now(), settings.At, 0); err != nil {
+		return err
+	}
+	if settings.WeekDay < 0 || settings.WeekDay > 6 {
+		return fmt.Errorf("invalid report weekday %d: use 0 (Sunday) to 6", settings.WeekDay)
+	}
+	return nil
+}
+
+// Digest returns the summary of the given kind due at to: the tasks
+// completed in the day or week before, and for the daily summary how the
+// day went against its plan
+func (h *Handler) Digest(kind string, to time.Time) (*digest.Summary, error) {
+	from := digest.Since(kind, to)
+	page, err := h.DB.QueryCompletedTasks(database.CompletedQuery{From: from, To: to, Oldest: true})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &digest.Summary{Kind: kind, From: from, To: to, Tasks: page.Tasks}
+	if kind != digest.Daily {
+		return summary, nil
+	}
+
+	comparison, err := h.ComparePlan(to.Format("2006-01-02"))
+	if errors.Is(err, database.ErrPlanNotFound) {
+		return summary, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	summary.Plan = &digest.Plan{PlannedSeconds: comparison.PlannedSeconds, ActualSeconds: comparison.ActualSeconds}
+	for _, outcome := range comparison.Tasks {
+		summary.Plan.Tasks = append(summary.Plan.Tasks, digest.PlanTask{
+			Status:         planLabel(outcome.Status),
+			Body:           outcome.Body,
+			PlannedSeconds: outcome.PlannedSeconds,
+			ActualSeconds:  outcome.ActualSeconds,
+		})
+	}
+	return summary, nil
+}
+
+// EmailReport emails the summary of the given kind due at to the address
+// in the settings, and returns the address
+func (h *Handler) EmailReport(kind string, to time.Time) (string, error) {
+	if h.Mail == nil {
+		return "", errNoMail
+	}
+	settings, err := h.DB.GetSettings()
+	if err != nil {
+		return "", err
+	}
+	if settings.Email == "" {
+		return "", errNoEmail
+	}
+
+	summary, err := h.Digest(kind, to)
+	if err != nil {
+		return "", err
+	}
+	return settings.Email, h.Mail.Send(&mail.Message{
+		To:      settings.Email,
+		Subject: summary.Subject(),
+		Text:    summary.Text(),
+		HTML:    summary.HTML(),
+	})
+}
+
+// reportRetry is a due summary that could not be sent
+type reportRetry struct {
+	slot     time.Time // When the summary was due
+	failures int
+	next     time.Time // When to try sending it again
+}
+
+// emailDueReports emails the summaries the user opted in to that are due
+// at now. A summary that fails does not hold up the other one, and is only
+// tried again once digest.Retry has passed.
+func (h *Handler) emailDueReports(now time.Time) error {
+	settings, err := h.DB.GetSettings()
+	if err != nil {
+		return err
+	}
+	reports := settings.Reports
+	if settings.Email == "" {
+		return nil
+	}
+
+	enabled := map[string]bool{digest.Daily: reports.Daily, digest.Weekly: reports.Weekly}
+	var errs []error
+	for _, kind := range []string{digest.Daily, digest.Weekly} {
+		if !enabled[kind] {
+			continue
+		}
+		if err := h.emailDueReport(kind, settings, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// emailDueReport emails the summary of the given kind if it is due at now
+func (h *Handler) emailDueReport(kind string, settings *database.Settings, now time.Time) error {
+	reports := settings.Reports
+	slot, err := digest.Slot(kind, now, reports.At, time.Weekday(reports.WeekDay))
+	if err != nil {
+		return err
+	}
+	last, err := h.DB.GetReportSent(kind)
+	if err != nil {
+		return err
+	}
+	if !digest.Due(slot, last, now) {
+		return nil
+	}
+
+	retry := h.reportRetries[kind]
+	if retry != nil && retry.slot.Equal(slot) && now.Before(retry.next) {
+		return nil
+	}
+	if _, err := h.EmailReport(kind, slot); err != nil {
+		if retry == nil || !retry.slot.Equal(slot) {
+			retry = &reportRetry{slot: slot}
+		}
+		retry.failures++
+		retry.next = now.Add(digest.Retry(retry.failures))
+		if h.reportRetries == nil {
+			h.reportRetries = make(map[string]*reportRetry)
+		}
+		h.reportRetries[kind] = retry
+		return fmt.Errorf("emailing %s report to %s, trying again at %s: %w", kind, settings.Email, retry.next.Format("15:04"), err)
+	}
+	delete(h.reportRetries, kind)
+	return h.DB.SetReportSent(kind, now)
+}
+
+// RunReports emails the reports that are due every interval until Close is
+// called. A report that fails is tried again after a while, backing off
+// each time it fails again.
+func (a *Accounts) RunReports(interval time.Duration) {
+	for {
+		users, err := a.Store.GetUsers()
+		if err != nil {
+			log.Printf("Error getting users: %v", err)
+		}
+		if len(users) == 0 {
+			users = []database.User{{Owner: true}}
+		}
+
+		for i := range users {
+			if users[i].Disabled {
+				continue
+			}
+			h, err := a.Handler(&users[i])
+			if err != nil {
+				log.Printf("Error opening tasks of user %s: %v", users[i].Name, err)
+				continue
+			}
+			if err := h.emailDueReports(a.now()); err != nil {
+				log.Printf("Error sending reports: %v", err)
+			}
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// SendReport emails the report of the kind posted as JSON ({"kind":
+// "daily"} or "weekly") right away, to try the settings out
+func (h *Handler) SendReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var send struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&send); err != nil {
+		http.Error(w, "Invalid report", http.StatusBadRequest)
+		return
+	}
+	if send.Kind != digest.Daily && send.Kind != digest.Weekly {
+		http.Error(w, "kind must be daily or weekly", http.StatusBadRequest)
+		return
+	}
+
+	to, err := h.EmailReport(send.Kind, h.now())
+	switch {
+	case errors.Is(err, errNoMail), errors.Is(err, errNoEmail):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("Error emailing %s report: %v", send.Kind, err)
+		http.Error(w, "Failed to send email: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, map[string]string{"to": to})
+}
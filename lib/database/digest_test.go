@@ -0,0 +1,40 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/mail"
+)
+
+func TestFailedReportsBackOff(t *testing.T) {
+	h := NewHandler(openStore(t))
+	h.Mail = &mail.Sender{} // No relay, so every send fails
+	settings := database.DefaultSettings()
+	settings.Email = "ann@example.com"
+	settings.Reports = database.ReportSettings{Daily: true, Weekly: true, At: "18:00", WeekDay: int(time.Monday)}
+	if err := h.DB.UpdateSettings(&settings); err != nil {
+		t.Fatal(err)
+	}
+	monday := time.Date(2026, 10, 19, 18, 30, 0, 0, time.UTC)
+
+	// The failed daily summary does not keep the weekly report from being tried
+	err := h.emailDueReports(monday)
+	if err == nil || !strings.Contains(err.Error(), "daily") || !strings.Contains(err.Error(), "weekly") {
+		t.Fatalf("first attempt: %v", err)
+	}
+	if err := h.emailDueReports(monday.Add(30 * time.Second)); err != nil {
+		t.Errorf("tried again before backing off: %v", err)
+	}
+	if err := h.emailDueReports(monday.Add(time.Minute)); err == nil {
+		t.Error("not tried again after a minute")
+	}
+	if err := h.emailDueReports(monday.Add(2 * time.Minute)); err != nil {
+		t.Errorf("tried again before backing off longer: %v", err)
+	}
+	if retry := h.reportRetries["weekly"]; retry == nil || retry.failures != 2 {
+		t.Errorf("weekly retry = %+v, want 2 failures", retry)
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"done/lib/estimator"
+)
+
+// Estimate suggests a duration for a task from the completed tasks
+func (h *Handler) Estimate(in estimator.Input) (*estimator.Suggestion, error) {
+	completed, err := h.DB.GetCompletedTasks()
+	if err != nil {
+		return nil, err
+	}
+	return estimator.New(completed).Suggest(in), nil
+}
+
+// EstimateTask responds with a suggested duration and range for a task
+// posted as JSON: {"body": ..., "tags": [...], "estimate_seconds": ...}
+func (h *Handler) EstimateTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var in estimator.Input
+	if err := json.Unmarshal(body, &in); err != nil {
+		http.Error(w, "Invalid task", http.StatusBadRequest)
+		return
+	}
+
+	suggestion, err := h.Estimate(in)
+	if err != nil {
+		log.Printf("Error estimating: %v", err)
+		http.Error(w, "Failed to estimate", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(suggestion)
+}
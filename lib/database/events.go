@@ -0,0 +1,241 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/events"
+	"done/lib/utils"
+)
+
+// Types of the events published on the handler's bus
+const (
+	EventTaskAdded           = "task.added"           // Data is the task
+	EventTaskUpdated         = "task.updated"         // Data is the task
+	EventTaskRemoved         = "task.removed"         // Data is {"uuid"}; the task left the active list
+	EventTaskCompleted       = "task.completed"       // Data is the task
+	EventTaskUnblocked       = "task.unblocked"       // Data is the task, whose last open blocker was completed
+	EventCompletedRemoved    = "completed.removed"    // Data is {"uuid"}; the task was reopened
+	EventTasksReordered      = "tasks.reordered"      // Tasks were moved or unpinned
+	EventGamificationUpdated = "gamification.updated" // Data is the gamification stats
+	EventSettingsUpdated     = "settings.updated"     // Data is the settings
+	EventLevelUp             = "level.up"             // Data is {"level", "previous_level", "total_points"}
+	EventAchievement         = "achievement.unlocked" // Data is {"achievement"}, one event for each
+	EventReminder            = "reminder.due"         // Data is the reminder
+
+	// EventResync tells a reconnecting client that it missed events that are
+	// no longer kept, so it should reload everything
+	EventResync = "resync"
+)
+
+const (
+	// eventHistory is how many events are kept for clients that reconnect
+	eventHistory = 256
+	// eventsRetry is how long a disconnected client waits to reconnect
+	eventsRetry = 3 * time.Second
+	// eventsPing keeps idle connections from being closed by proxies
+	eventsPing = 30 * time.Second
+)
+
+// removal is the data of events about a task that left a list
+type removal struct {
+	UUID string `json:"uuid"`
+}
+
+// levelUp is the data of a level-up event
+type levelUp struct {
+	Level         int `json:"level"`
+	PreviousLevel int `json:"previous_level"`
+	TotalPoints   int `json:"total_points"`
+}
+
+// achievement is the data of an achievement event
+type achievement struct {
+	Achievement string `json:"achievement"`
+}
+
+// publishingDB publishes an event for every change made to the active and
+// completed tasks, the gamification stats and the settings
+type publishingDB struct {
+	database.Database
+	bus *events.Bus
+}
+
+// published returns the task as it is read back from the database
+func published(task *database.Task) *database.Task {
+	clean := *task
+	clean.Body = utils.CleanTaskText(task.Body)
+	return &clean
+}
+
+func (db *publishingDB) AddTask(task *database.Task) error {
+	if err := db.Database.AddTask(task); err != nil {
+		return err
+	}
+	db.bus.Publish(EventTaskAdded, published(task))
+	return nil
+}
+
+func (db *publishingDB) UpdateTask(task *database.Task) error {
+	old, err := db.Database.GetTaskByUUID(task.UUID)
+	if err != nil {
+		return err
+	}
+	if err := db.Database.UpdateTask(task); err != nil {
+		return err
+	}
+	// Adding or moving a task shifts the others, which is announced once by
+	// the added or reordered event rather than for every task, and a running
+	// timer stores the time spent every second
+	if content, _ := task.Changes(old); content {
+		db.bus.Publish(EventTaskUpdated, published(task))
+	}
+	return nil
+}
+
+func (db *publishingDB) RemoveTask(uuid string) error {
+	if err := db.Database.RemoveTask(uuid); err != nil {
+		return err
+	}
+	db.bus.Publish(EventTaskRemoved, removal{UUID: uuid})
+	return nil
+}
+
+func (db *publishingDB) AddCompletedTask(task *database.Task) error {
+	if err := db.Database.AddCompletedTask(task); err != nil {
+		return err
+	}
+	db.bus.Publish(EventTaskCompleted, published(task))
+	return nil
+}
+
+func (db *publishingDB) RemoveCompletedTask(uuid string) error {
+	if err := db.Database.RemoveCompletedTask(uuid); err != nil {
+		return err
+	}
+	db.bus.Publish(EventCompletedRemoved, removal{UUID: uuid})
+	return nil
+}
+
+func (db *publishingDB) UpdateGamification(gamification *database.Gamification) error {
+	old, err := db.Database.GetGamification()
+	if err != nil {
+		return err
+	}
+	if err := db.Database.UpdateGamification(gamification); err != nil {
+		return err
+	}
+	db.bus.Publish(EventGamificationUpdated, gamification)
+
+	if gamification.Level > old.Level {
+		db.bus.Publish(EventLevelUp, levelUp{
+			Level:         gamification.Level,
+			PreviousLevel: old.Level,
+			TotalPoints:   gamification.TotalPoints,
+		})
+	}
+	unlocked := make(map[string]bool, len(old.Achievements))
+	for _, name := range old.Achievements {
+		unlocked[name] = true
+	}
+	for _, name := range gamification.Achievements {
+		if !unlocked[name] {
+			db.bus.Publish(EventAchievement, achievement{Achievement: name})
+		}
+	}
+	return nil
+}
+
+func (db *publishingDB) UpdateSettings(settings *database.Settings) error {
+	if err := db.Database.UpdateSettings(settings); err != nil {
+		return err
+	}
+	db.bus.Publish(EventSettingsUpdated, settings)
+	return nil
+}
+
+// StreamEvents sends the handler's events as Server-Sent Events until the
+// client disconnects. Each event is a JSON object with id, type, time and
+// data. A client that reconnects with the Last-Event-ID header, or the
+// last_event_id parameter, first gets the events it missed, or a resync
+// event when they are no longer kept or the ID is from before the server
+// restarted.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	param := r.Header.Get("Last-Event-ID")
+	if param == "" {
+		param = r.URL.Query().Get("last_event_id")
+	}
+	var lastID uint64
+	valid := true
+	if param != "" {
+		lastID, valid = h.bus.ParseCursor(param)
+	}
+
+	subscription, missed, complete := h.bus.Subscribe(lastID)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds())
+
+	if !complete || !valid {
+		missed = []events.Event{{ID: h.bus.LastID(), Type: EventResync, Time: time.Now()}}
+	}
+	for _, event := range missed {
+		if err := h.writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ping := time.NewTicker(eventsPing)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.C:
+			if !ok {
+				// Too far behind; the client reconnects and catches up
+				return
+			}
+			if err := h.writeEvent(w, event); err != nil {
+				return
+			}
+		case <-ping.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent sends an event with its ID as a cursor of the bus, so a client
+// that reconnects after a restart is told to resync
+func (h *Handler) writeEvent(w io.Writer, event events.Event) error {
+	cursor := h.bus.Cursor(event.ID)
+	data, err := json.Marshal(struct {
+		events.Event
+		ID string `json:"id"`
+	}{event, cursor})
+	if err != nil {
+		log.Printf("Error encoding event %s: %v", cursor, err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %s\ndata: %s\n\n", cursor, data)
+	return err
+}
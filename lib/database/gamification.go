@@ -0,0 +1,175 @@
+package database
+
+import (
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// Achievement IDs, matching the ones shown by the web UI
+const (
+	achievementFirstTask  = "firstTask"
+	achievementStreak3    = "streak3"
+	achievementStreak7    = "streak7"
+	achievementStreak30   = "streak30"
+	achievementPoints1000 = "points1000"
+	achievementPoints5000 = "points5000"
+	achievementSpeedDemon = "speedDemon"
+	achievementEarlyBird  = "earlyBird"
+)
+
+// taskPoints calculates the points a completed task is worth
+func taskPoints(task *database.Task) int {
+	// Calculate points based on task complexity
+	points := 10 // Base points
+	if task.DurationExecutionEstimatedSeconds > 3600 { // More than 1 hour
+		points = 25
+	}
+	if task.DurationExecutionEstimatedSeconds > 7200 { // More than 2 hours
+		points = 50
+	}
+
+	// Bonus points for finishing before the planned date or the hard deadline
+	switch task.DeadlineOutcome {
+	case database.OutcomeBeforePlanned:
+		points += 20
+	case database.OutcomeOnTime:
+		points += 10
+	}
+
+	return points
+}
+
+// applyCompletion credits a task completed at task.TimeCompleted to the
+// gamification stats. completedToday is the number of tasks completed today,
+// including this one. The returned award records everything that changed.
+func applyCompletion(gamification *database.Gamification, task *database.Task, completedToday int) *database.CompletionAward {
+	now := task.TimeCompleted
+
+	award := &database.CompletionAward{
+		Points:                     taskPoints(task),
+		PreviousStreak:             gamification.CurrentStreak,
+		PreviousLongestStreak:      gamification.LongestStreak,
+		PreviousLastCompletionDate: gamification.LastCompletionDate,
+		PreviousFirstTaskDate:      gamification.FirstTaskDate,
+	}
+
+	// Update gamification stats
+	gamification.TotalPoints += award.Points
+	gamification.CompletedTasks++
+
+	// Update first task date if not set
+	if gamification.FirstTaskDate == nil {
+		gamification.FirstTaskDate = &now
+	}
+
+	// Calculate level (100 points per level)
+	gamification.Level = (gamification.TotalPoints / 100) + 1
+
+	// Update streak
+	today := now.Truncate(24 * time.Hour)
+	if gamification.LastCompletionDate != nil {
+		lastDate := gamification.LastCompletionDate.Truncate(24 * time.Hour)
+		daysSince := int(today.Sub(lastDate).Hours() / 24)
+
+		if daysSince == 0 {
+			// Same day, streak continues
+		} else if daysSince == 1 {
+			// Next day, increment streak
+			gamification.CurrentStreak++
+		} else {
+			// Streak broken
+			gamification.CurrentStreak = 1
+		}
+	} else {
+		// First task
+		gamification.CurrentStreak = 1
+	}
+
+	// Update longest streak
+	if gamification.CurrentStreak > gamification.LongestStreak {
+		gamification.LongestStreak = gamification.CurrentStreak
+	}
+
+	// Update last completion date
+	gamification.LastCompletionDate = &now
+
+	// Unlock achievements
+	earned := earnedByTotals(gamification)
+	earned[achievementSpeedDemon] = completedToday >= 5
+	earned[achievementEarlyBird] = task.DeadlineOutcome == database.OutcomeBeforePlanned || task.DeadlineOutcome == database.OutcomeOnTime
+	for _, id := range []string{
+		achievementFirstTask, achievementStreak3, achievementStreak7, achievementStreak30,
+		achievementPoints1000, achievementPoints5000, achievementSpeedDemon, achievementEarlyBird,
+	} {
+		if earned[id] && !hasAchievement(gamification, id) {
+			gamification.Achievements = append(gamification.Achievements, id)
+			award.Achievements = append(award.Achievements, id)
+		}
+	}
+
+	return award
+}
+
+// earnedByTotals tells which of the achievements that depend only on the
+// stats the stats earn. The others depend on a single completion.
+func earnedByTotals(gamification *database.Gamification) map[string]bool {
+	return map[string]bool{
+		achievementFirstTask:  gamification.CompletedTasks >= 1,
+		achievementStreak3:    gamification.LongestStreak >= 3,
+		achievementStreak7:    gamification.LongestStreak >= 7,
+		achievementStreak30:   gamification.LongestStreak >= 30,
+		achievementPoints1000: gamification.TotalPoints >= 1000,
+		achievementPoints5000: gamification.TotalPoints >= 5000,
+	}
+}
+
+// revertCompletion takes back what completing task granted. The streak is
+// only restored when the task is still the most recent completion; otherwise
+// later completions already account for the streak. Tasks completed before
+// awards were recorded give back the points they would have earned.
+func revertCompletion(gamification *database.Gamification, task *database.Task) {
+	gamification.TotalPoints -= completionPoints(task)
+	if gamification.TotalPoints < 0 {
+		gamification.TotalPoints = 0
+	}
+	if gamification.CompletedTasks > 0 {
+		gamification.CompletedTasks--
+	}
+	gamification.Level = (gamification.TotalPoints / 100) + 1
+
+	award := task.Award
+	if award == nil {
+		return
+	}
+
+	if gamification.LastCompletionDate != nil && gamification.LastCompletionDate.Equal(task.TimeCompleted) {
+		gamification.CurrentStreak = award.PreviousStreak
+		gamification.LongestStreak = award.PreviousLongestStreak
+		gamification.LastCompletionDate = award.PreviousLastCompletionDate
+		gamification.FirstTaskDate = award.PreviousFirstTaskDate
+	}
+
+	// Achievements the stats still earn are kept
+	earned := earnedByTotals(gamification)
+	for _, id := range award.Achievements {
+		if earned[id] {
+			continue
+		}
+		for i, achievement := range gamification.Achievements {
+			if achievement == id {
+				gamification.Achievements = append(gamification.Achievements[:i], gamification.Achievements[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func hasAchievement(gamification *database.Gamification, id string) bool {
+	for _, achievement := range gamification.Achievements {
+		if achievement == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+func TestReopenTakesBackCompletion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // Completions are added to the report
+	h := NewHandler(openStore(t))
+	for i, uuid := range []string{"first", "second"} {
+		if err := h.DB.AddTask(&database.Task{UUID: uuid, Body: "Task " + uuid, Order: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.Complete("first"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := h.DB.GetGamification()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Complete("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := h.Reopen("second"); err != nil {
+		t.Fatal(err)
+	}
+	after, err := h.DB.GetGamification()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.TotalPoints != before.TotalPoints || after.CompletedTasks != before.CompletedTasks || after.CurrentStreak != before.CurrentStreak {
+		t.Errorf("after reopening: %+v, want %+v", after, before)
+	}
+	if !hasAchievement(after, achievementFirstTask) {
+		t.Errorf("took back the achievement of the first task: %v", after.Achievements)
+	}
+
+	task, err := h.DB.GetTaskByUUID("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Order != 1 || task.Award != nil || !task.TimeCompleted.IsZero() {
+		t.Errorf("reopened task: %+v", task)
+	}
+	if _, err := h.DB.GetCompletedTaskByUUID("second"); err == nil {
+		t.Error("reopened task is still completed")
+	}
+}
+
+func TestRevertCompletionWithoutAward(t *testing.T) {
+	// Completed before awards were recorded
+	task := &database.Task{UUID: "old", TimeCompleted: time.Now().Add(-48 * time.Hour)}
+	gamification := &database.Gamification{TotalPoints: 130, CompletedTasks: 4, Level: 2}
+
+	revertCompletion(gamification, task)
+	if gamification.TotalPoints != 120 || gamification.CompletedTasks != 3 || gamification.Level != 2 {
+		t.Fatalf("after reopening: %+v", gamification)
+	}
+
+	// Completing it again only earns back what reopening took
+	task.TimeCompleted = time.Now()
+	applyCompletion(gamification, task, 1)
+	if gamification.TotalPoints != 130 || gamification.CompletedTasks != 4 {
+		t.Errorf("after completing again: %+v", gamification)
+	}
+}
+
+func TestRevertCompletionKeepsAchievementsStillEarned(t *testing.T) {
+	gamification := &database.Gamification{TotalPoints: 995, CompletedTasks: 60, LongestStreak: 4}
+	task := &database.Task{UUID: "t", TimeCompleted: time.Now(), DeadlineOutcome: database.OutcomeOnTime}
+	task.Award = applyCompletion(gamification, task, 1)
+
+	// The streak of 3 was reached long before, but the points only now
+	if !hasAchievement(gamification, achievementPoints1000) || !hasAchievement(gamification, achievementStreak3) {
+		t.Fatalf("achievements = %v", gamification.Achievements)
+	}
+	if !hasAchievement(gamification, achievementEarlyBird) {
+		t.Fatalf("achievements = %v", gamification.Achievements)
+	}
+
+	revertCompletion(gamification, task)
+	if hasAchievement(gamification, achievementPoints1000) || hasAchievement(gamification, achievementEarlyBird) {
+		t.Errorf("kept achievements the task earned: %v", gamification.Achievements)
+	}
+	if !hasAchievement(gamification, achievementFirstTask) || !hasAchievement(gamification, achievementStreak3) {
+		t.Errorf("removed achievements the stats still earn: %v", gamification.Achievements)
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,19 +12,65 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	database "done/lib/database/interface"
+	"done/lib/events"
+	"done/lib/hooks"
+	"done/lib/mail"
 	"done/lib/utils"
 	uuid "github.com/satori/go.uuid"
 )
 
 type Handler struct {
 	DB database.Database
+
+	// Location is the time zone task dates are read in and reports are dated
+	// in; nil means local
+	Location *time.Location
+
+	// ReportName is the directory in ~/tasksReport the user's reports are
+	// written to; empty writes them to ~/tasksReport itself
+	ReportName string
+
+	// Hooks runs the pre-add hooks of the tasks added through the handler;
+	// nil runs none
+	Hooks *hooks.Runner
+
+	// Mail sends the user's reports by email; nil sends none
+	Mail *mail.Sender
+
+	// credit is set on the handlers of shared lists. It returns the handler
+	// of the named user, whose gamification and report completing a task
+	// is credited to.
+	credit func(name string) (*Handler, error)
+
+	journals *journals    // Undo/redo history per client session
+	search   *searchIndex // Full-text index of the tasks in all lists
+	bus      *events.Bus  // Changes, streamed to clients by StreamEvents
+
+	// reportRetries holds the emailed summaries that failed, by kind, so
+	// RunReports backs off before sending them again
+	reportRetries map[string]*reportRetry
+
+	// writes is held by HTTP handlers while they change the active list, so
+	// conditional changes are checked against the list they change and
+	// concurrent moves do not interleave
+	writes sync.Mutex
 }
 
+// NewHandler returns a handler for db. Changes made through the handler
+// keep its search index up to date and are published on its event bus.
 func NewHandler(db database.Database) *Handler {
-	return &Handler{DB: db}
+	index := &searchIndex{}
+	bus := events.NewBus(eventHistory)
+	return &Handler{
+		DB:       &publishingDB{Database: &indexedDB{Database: db, search: index}, bus: bus},
+		journals: newJournals(),
+		search:   index,
+		bus:      bus,
+	}
 }
 
 func errHandler(err error) {
@@ -32,17 +79,42 @@ func errHandler(err error) {
 	}
 }
 
-func (h *Handler) AddTask(w http.ResponseWriter, r *http.Request) {
+// Create adds task at the top of the list, assigning it a new UUID and
+// creation time, once the pre-add hooks have accepted it
+func (h *Handler) Create(task *database.Task) error {
+	task.UUID = uuid.NewV4().String()
+	task.TimeCreated = time.Now()
+	task.Order = 0
+	if err := h.preAdd(task); err != nil {
+		return err
+	}
+
 	tasks, err := h.DB.GetTasks()
-	errHandler(err)
+	if err != nil {
+		return err
+	}
 
 	// Increment order for all existing tasks
 	for i := 0; i < len(tasks); i++ {
 		tasks[i].Order++
 		err = h.DB.UpdateTask(&tasks[i])
-		errHandler(err)
+		if err != nil {
+			return err
+		}
 	}
 
+	return h.DB.AddTask(task)
+}
+
+func (h *Handler) AddTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
 	newTaskJSON, err := ioutil.ReadAll(r.Body)
 	errHandler(err)
 
@@ -52,136 +124,176 @@ func (h *Handler) AddTask(w http.ResponseWriter, r *http.Request) {
 	durationExecutionEstimatedSeconds, err := strconv.Atoi(newTaskSplitted[1])
 	errHandler(err)
 	
-	// Parse deadline fields with validation
-	deadlineMonth := 0
-	if newTaskSplitted[2] != "" && newTaskSplitted[2] != "MM" {
-		deadlineMonth, err = strconv.Atoi(newTaskSplitted[2])
-		if err != nil {
-			log.Printf("Invalid deadline month: %s, using 0\n", newTaskSplitted[2])
-			deadlineMonth = 0
-		}
-	}
-	
-	deadlineDay := 0
-	if newTaskSplitted[3] != "" && newTaskSplitted[3] != "DD" {
-		deadlineDay, err = strconv.Atoi(newTaskSplitted[3])
-		if err != nil {
-			log.Printf("Invalid deadline day: %s, using 0\n", newTaskSplitted[3])
-			deadlineDay = 0
-		}
-	}
-	
-	// Handle year parameter if provided
-	var deadlineYear int
-	if len(newTaskSplitted) > 4 && newTaskSplitted[4] != "" && newTaskSplitted[4] != "YYYY" {
-		deadlineYear, err = strconv.Atoi(newTaskSplitted[4])
-		if err != nil {
-			log.Printf("Invalid deadline year: %s, using 0\n", newTaskSplitted[4])
-			deadlineYear = 0
-		}
-	} else {
-		deadlineYear = 0
-	}
-
 	var task database.Task
-	task.UUID = uuid.NewV4().String()
 	task.Body = body
 	task.DurationExecutionEstimatedSeconds = durationExecutionEstimatedSeconds
 
-	currentYear, currentMonth, _ := time.Now().Date()
-	var taskDeadlineYear int
-
-	if (deadlineMonth == 0) && (deadlineDay == 0) && (deadlineYear == 0) {
-		// No deadline set
-		taskDeadlineYear = 9999
-		deadlineMonth = 1
-		deadlineDay = 1
-	} else {
-		// If year is explicitly provided, use it
-		if deadlineYear > 0 {
-			taskDeadlineYear = deadlineYear
-		} else {
-			// Legacy behavior: guess the year based on month
-			if deadlineMonth < int(currentMonth) {
-				taskDeadlineYear = currentYear + 1
-			} else {
-				taskDeadlineYear = currentYear
-			}
-		}
-		
-		// Set defaults for missing values
-		if deadlineMonth == 0 {
-			deadlineMonth = int(time.Now().Month())
-		}
-		if deadlineDay == 0 {
-			deadlineDay = 1
-		}
+	// Hard deadline: month, day, year and an optional HH:MM time of day
+	task.TimeHardDeadline = parseDateFields(h.now(), payloadField(newTaskSplitted, 2), payloadField(newTaskSplitted, 3), payloadField(newTaskSplitted, 4))
+	if task.TimeHardDeadline != nil {
+		task.DeadlineHasTime = applyClock(task.TimeHardDeadline, payloadField(newTaskSplitted, 5))
 	}
 
-	task.TimeHardDeadline = time.Date(taskDeadlineYear, time.Month(deadlineMonth), deadlineDay, 0, 0, 0, 0, time.UTC)
+	// Soft deadline: the date the task is planned for
+	task.TimePlanned = parseDateFields(h.now(), payloadField(newTaskSplitted, 6), payloadField(newTaskSplitted, 7), payloadField(newTaskSplitted, 8))
 
-	timeNow := time.Now()
-	task.TimeCreated = timeNow
-	task.Order = 0
+	if priority := payloadField(newTaskSplitted, 9); database.ValidPriority(priority) {
+		task.Priority = priority
+	}
 
-	err = h.DB.AddTask(&task)
+	err = h.Create(&task)
+	if errors.Is(err, hooks.ErrRejected) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	errHandler(err)
 
-	tasks, err = h.DB.GetTasks()
-	errHandler(err)
+	h.record(w, r, &addOperation{task: task})
 
-	tasksJSON, err := json.Marshal(tasks)
-	errHandler(err)
+	h.writeTasks(w)
+}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Write(tasksJSON)
+// payloadField returns the i-th field of a "$;" separated payload, or an empty
+// string for fields older clients do not send
+func payloadField(fields []string, i int) string {
+	if i < len(fields) {
+		return strings.TrimSpace(fields[i])
+	}
+	return ""
 }
 
-func (h *Handler) GetTasks(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.DB.GetTasks()
-	errHandler(err)
+// parseDateFields builds a date in the time zone of now from month, day and
+// year form fields. Empty or placeholder fields count as unset; it returns
+// nil when all three are unset.
+func parseDateFields(now time.Time, monthField, dayField, yearField string) *time.Time {
+	month := parseDateField(monthField, "MM")
+	day := parseDateField(dayField, "DD")
+	year := parseDateField(yearField, "YYYY")
 
-	tasksJSON, err := json.Marshal(tasks)
-	errHandler(err)
+	if month == 0 && day == 0 && year == 0 {
+		return nil
+	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Write(tasksJSON)
-}
+	currentYear, currentMonth, _ := now.Date()
 
-func (h *Handler) RemoveTask(w http.ResponseWriter, r *http.Request) {
-	uuid, err := ioutil.ReadAll(r.Body)
-	errHandler(err)
+	// If year is not provided, guess it based on month (legacy behavior)
+	if year == 0 {
+		if month != 0 && month < int(currentMonth) {
+			year = currentYear + 1
+		} else {
+			year = currentYear
+		}
+	}
 
-	err = h.DB.RemoveTask(string(uuid))
-	errHandler(err)
+	// Set defaults for missing values
+	if month == 0 {
+		month = int(currentMonth)
+	}
+	if day == 0 {
+		day = 1
+	}
 
-	tasks, err := h.DB.GetTasks()
-	errHandler(err)
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, now.Location())
+	return &date
+}
 
-	tasksJSON, err := json.Marshal(tasks)
-	errHandler(err)
+// onDate returns midnight, in the handler's time zone, of the date t falls
+// on where it was given, so a date without a time of day means the same day
+// however the task was added or edited
+func (h *Handler) onDate(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	year, month, day := t.Date()
+	date := time.Date(year, month, day, 0, 0, 0, 0, h.now().Location())
+	return &date
+}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Write(tasksJSON)
+func parseDateField(field, placeholder string) int {
+	if field == "" || field == placeholder {
+		return 0
+	}
+	value, err := strconv.Atoi(field)
+	if err != nil {
+		log.Printf("Invalid date field: %s, using 0\n", field)
+		return 0
+	}
+	return value
 }
 
-func (h *Handler) CompleteTask(w http.ResponseWriter, r *http.Request) {
+// applyClock sets the time of day of date from an "HH:MM" field. It reports
+// whether a valid time was applied.
+func applyClock(date *time.Time, clock string) bool {
+	if clock == "" || clock == "HH:MM" {
+		return false
+	}
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		log.Printf("Invalid deadline time: %s, ignoring\n", clock)
+		return false
+	}
+	year, month, day := date.Date()
+	*date = time.Date(year, month, day, parsed.Hour(), parsed.Minute(), 0, 0, date.Location())
+	return true
+}
+
+func (h *Handler) GetTasks(w http.ResponseWriter, r *http.Request) {
+	h.writeTasks(w)
+}
+
+func (h *Handler) RemoveTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
 	uuid, err := ioutil.ReadAll(r.Body)
 	errHandler(err)
 
-	task, err := h.DB.GetTaskByUUID(string(uuid))
-	errHandler(err)
+	if !h.checkTask(w, r, string(uuid)) {
+		return
+	}
 
-	err = h.DB.RemoveTask(string(uuid))
+	_, err = h.Remove(string(uuid))
 	errHandler(err)
 
+	h.record(w, r, &removeOperation{uuid: string(uuid)})
+
+	h.writeTasks(w)
+}
+
+// Complete moves an active task to the completed tasks, credits it to the
+// gamification stats and appends it to today's report. On a shared list the
+// assignee is credited, and the task goes to their report as well as the
+// list's.
+func (h *Handler) Complete(uuid string) (*database.Task, error) {
+	task, err := h.DB.GetTaskByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+	return h.complete(task)
+}
+
+// complete completes the active task as read, with any changes made to it
+// before, such as claiming it
+func (h *Handler) complete(task *database.Task) (*database.Task, error) {
+	uuid := task.UUID
 	task.TimeCompleted = time.Now()
+	task.DeadlineOutcome = task.Outcome(task.TimeCompleted)
 
-	err = h.DB.AddCompletedTask(task)
-	errHandler(err)
+	credited, err := h.credited(task.Assignee)
+	if err != nil {
+		return nil, err
+	}
+	if credited != h {
+		task.CompletedBy = task.Assignee
+	}
 
 	// Update gamification data
-	gamification, err := h.DB.GetGamification()
+	gamification, err := credited.DB.GetGamification()
 	if err != nil {
 		log.Printf("Error getting gamification data: %v", err)
 		// Continue with the rest of the handler even if gamification fails
@@ -192,235 +304,167 @@ func (h *Handler) CompleteTask(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Calculate points based on task complexity
-	points := 10 // Base points
-	if task.DurationExecutionEstimatedSeconds > 3600 { // More than 1 hour
-		points = 25
-	}
-	if task.DurationExecutionEstimatedSeconds > 7200 { // More than 2 hours
-		points = 50
-	}
-	
-	// Bonus points for completing on time
-	if task.TimeHardDeadline.Year() != 9999 && task.TimeCompleted.Before(task.TimeHardDeadline) {
-		points += 10
-	}
-
-	// Update gamification stats
-	gamification.TotalPoints += points
-	gamification.CompletedTasks++
-	
-	// Update first task date if not set
-	if gamification.FirstTaskDate == nil {
-		now := time.Now()
-		gamification.FirstTaskDate = &now
+	completedToday, err := credited.CompletedToday()
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate level (100 points per level)
-	gamification.Level = (gamification.TotalPoints / 100) + 1
+	task.Award = applyCompletion(gamification, task, len(completedToday)+1)
 
-	// Update streak
-	today := time.Now().Truncate(24 * time.Hour)
-	if gamification.LastCompletionDate != nil {
-		lastDate := gamification.LastCompletionDate.Truncate(24 * time.Hour)
-		daysSince := int(today.Sub(lastDate).Hours() / 24)
-		
-		if daysSince == 0 {
-			// Same day, streak continues
-		} else if daysSince == 1 {
-			// Next day, increment streak
-			gamification.CurrentStreak++
-		} else {
-			// Streak broken
-			gamification.CurrentStreak = 1
-		}
-	} else {
-		// First task
-		gamification.CurrentStreak = 1
+	err = h.DB.RemoveTask(uuid)
+	if err != nil {
+		return nil, err
 	}
 
-	// Update longest streak
-	if gamification.CurrentStreak > gamification.LongestStreak {
-		gamification.LongestStreak = gamification.CurrentStreak
+	err = h.DB.AddCompletedTask(task)
+	if err != nil {
+		return nil, err
 	}
 
-	// Update last completion date
-	now := time.Now()
-	gamification.LastCompletionDate = &now
-
 	// Save gamification data
-	err = h.DB.UpdateGamification(gamification)
+	err = credited.DB.UpdateGamification(gamification)
 	if err != nil {
 		log.Printf("Error updating gamification data: %v", err)
 		// Continue even if gamification update fails
 	}
 
 	// Save to file in ~/tasksReport/
-	homeDir, err := os.UserHomeDir()
+	h.appendTaskReport(task)
+	if credited != h {
+		credited.appendTaskReport(task)
+	}
+
+	return task, nil
+}
+
+// credited returns the handler a task completed by the named user is
+// credited to: the user's own on a shared list, and h itself otherwise
+func (h *Handler) credited(name string) (*Handler, error) {
+	if h.credit == nil || name == "" {
+		return h, nil
+	}
+	return h.credit(name)
+}
+
+// Reopen moves a completed task back to the active list at the position it
+// was completed from, taking back the points, streak and achievements its
+// completion granted and removing it from the per-day report
+func (h *Handler) Reopen(uuid string) (*database.Task, error) {
+	task, err := h.DB.GetCompletedTaskByUUID(uuid)
 	if err != nil {
-		log.Printf("Error getting home directory: %v", err)
-		homeDir = "."
+		return nil, err
 	}
-	
-	reportDir := filepath.Join(homeDir, "tasksReport")
-	err = os.MkdirAll(reportDir, 0755)
+
+	credited, err := h.credited(task.CompletedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	gamification, err := credited.DB.GetGamification()
 	if err != nil {
-		log.Printf("Error creating report directory: %v", err)
+		return nil, err
 	}
+	revertCompletion(gamification, task)
 
-	day := task.TimeCompleted.Format("02")
-	month := task.TimeCompleted.Format("Jan")
-	year := task.TimeCompleted.Format("2006")
+	h.removeTaskReport(task)
+	if credited != h {
+		credited.removeTaskReport(task)
+	}
 
-	filenameComplete := filepath.Join(reportDir, year+"-"+month+"-"+day+".html")
+	task.TimeCompleted = time.Time{}
+	task.DeadlineOutcome = ""
+	task.Award = nil
+	task.CompletedBy = ""
 
-	f, err := os.OpenFile(filenameComplete, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0660)
+	err = h.insertAtOrder(task)
 	if err != nil {
-		log.Printf("Error opening report file: %v", err)
-		// Continue even if file save fails
-	} else {
-		defer f.Close()
-
-		finfo, err := f.Stat()
-		if err == nil && finfo.Size() == 0 {
-			// Write HTML header with modern styling
-			header := `<!DOCTYPE html>
-<html>
-<head>
-<meta charset="utf-8">
-<meta name="viewport" content="width=device-width, initial-scale=1">
-<title>Task Report - ` + year + `-` + month + `-` + day + `</title>
-<style>
-body {
-    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Helvetica, Arial, sans-serif;
-    margin: 0;
-    padding: 20px;
-    background: #0a0e27;
-    color: #ffffff;
-    line-height: 1.6;
-}
-.container {
-    max-width: 800px;
-    margin: 0 auto;
-}
-h1 {
-    color: #00ff41;
-    font-size: 28px;
-    margin-bottom: 30px;
-    padding-bottom: 10px;
-    border-bottom: 2px solid #00ff41;
-}
-.task-item {
-    background: rgba(255, 255, 255, 0.05);
-    border: 1px solid rgba(255, 255, 255, 0.1);
-    border-radius: 8px;
-    padding: 15px 20px;
-    margin-bottom: 10px;
-    transition: all 0.3s ease;
-}
-.task-item:hover {
-    background: rgba(255, 255, 255, 0.08);
-    border-color: #00ff41;
-}
-.task-time {
-    color: #00ff41;
-    font-size: 12px;
-    font-weight: 600;
-    margin-bottom: 5px;
-}
-.task-body {
-    color: #ffffff;
-    font-size: 16px;
-}
-.task-duration {
-    color: #888;
-    font-size: 14px;
-    margin-top: 8px;
-}
-.summary {
-    background: rgba(0, 255, 65, 0.1);
-    border: 2px solid #00ff41;
-    border-radius: 8px;
-    padding: 20px;
-    margin-top: 30px;
-}
-.summary h2 {
-    color: #00ff41;
-    margin-top: 0;
+		return nil, err
+	}
+
+	err = h.DB.RemoveCompletedTask(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = credited.DB.UpdateGamification(gamification)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
 }
-</style>
-</head>
-<body>
-<div class="container">
-<h1>📋 Task Report - ` + year + `-` + month + `-` + day + `</h1>
-`
-			io.WriteString(f, header)
-		}
 
-		// Format task completion time and duration
-		hours := task.DurationExecutionRealSeconds / 3600
-		minutes := (task.DurationExecutionRealSeconds % 3600) / 60
-		seconds := task.DurationExecutionRealSeconds % 60
-		
-		durationStr := ""
-		if hours > 0 {
-			durationStr = fmt.Sprintf("%dh %dm %ds", hours, minutes, seconds)
-		} else if minutes > 0 {
-			durationStr = fmt.Sprintf("%dm %ds", minutes, seconds)
-		} else {
-			durationStr = fmt.Sprintf("%ds", seconds)
+// insertAtOrder adds task to the active list at task.Order, moving the tasks
+// at that position and below down by one
+func (h *Handler) insertAtOrder(task *database.Task) error {
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(tasks); i++ {
+		if tasks[i].Order >= task.Order {
+			tasks[i].Order++
+			err = h.DB.UpdateTask(&tasks[i])
+			if err != nil {
+				return err
+			}
 		}
+	}
 
-		// Clean task body to remove any encoding artifacts
-		cleanBody := utils.CleanTaskText(task.Body)
-		
-		taskHTML := fmt.Sprintf(`<div class="task-item">
-    <div class="task-time">✅ Completed at %s</div>
-    <div class="task-body">%s</div>
-    <div class="task-duration">⏱️ Time spent: %s</div>
-</div>
-`, task.TimeCompleted.Format("15:04:05"), cleanBody, durationStr)
+	return h.DB.AddTask(task)
+}
 
-		io.WriteString(f, taskHTML)
+func (h *Handler) ReopenTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	tasks, err := h.DB.GetTasks()
-	errHandler(err)
+	h.writes.Lock()
+	defer h.writes.Unlock()
 
-	tasksJSON, err := json.Marshal(tasks)
+	uuid, err := ioutil.ReadAll(r.Body)
 	errHandler(err)
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Write(tasksJSON)
-}
-
-func (h *Handler) RearrangeTasks(w http.ResponseWriter, r *http.Request) {
-	body, err := ioutil.ReadAll(r.Body)
+	_, err = h.Reopen(string(uuid))
+	if errors.Is(err, database.ErrTaskNotFound) {
+		http.Error(w, "Completed task not found", http.StatusNotFound)
+		return
+	}
 	errHandler(err)
 
-	strBody := string(body)
-	sourceTaskUUID := ""
-	destinationTaskPosition := 0
+	h.record(w, r, &reopenOperation{uuid: string(uuid)})
 
-	for i := 0; i < len(strBody); i++ {
-		if strBody[i] != ',' {
-			sourceTaskUUID += string(strBody[i])
-		} else {
-			destinationTaskPosition = i + 1
-			break
-		}
-	}
+	h.writeTasks(w)
+}
 
-	destinationTaskUUID := strBody[destinationTaskPosition:]
+// Move puts the source task at the position of the destination task, shifting
+// the tasks in between by one. In smart sort mode, positions are those of the
+// sorted list and the moved task is pinned there.
+func (h *Handler) Move(sourceTaskUUID, destinationTaskUUID string) error {
+	smart, err := h.smartSort()
+	if err != nil {
+		return err
+	}
+	if smart {
+		return h.moveSmart(sourceTaskUUID, destinationTaskUUID)
+	}
 
 	sourceTask, err := h.DB.GetTaskByUUID(sourceTaskUUID)
-	errHandler(err)
+	if err != nil {
+		return err
+	}
 
 	destinationTask, err := h.DB.GetTaskByUUID(destinationTaskUUID)
-	errHandler(err)
+	if err != nil {
+		return err
+	}
 
 	tasks, err := h.DB.GetTasks()
-	errHandler(err)
+	if err != nil {
+		return err
+	}
 
 	sourceOrder := sourceTask.Order
 	destinationOrder := destinationTask.Order
@@ -456,10 +500,103 @@ func (h *Handler) RearrangeTasks(w http.ResponseWriter, r *http.Request) {
 
 	for i := 0; i < len(tasks); i++ {
 		err = h.DB.UpdateTask(&tasks[i])
-		errHandler(err)
+		if err != nil {
+			return err
+		}
+	}
+
+	h.bus.Publish(EventTasksReordered, nil)
+	return nil
+}
+
+// placement is the stored position of a task in the list
+type placement struct {
+	order  int
+	pinned bool
+}
+
+// orders returns the placement of every active task by UUID
+func (h *Handler) orders() (map[string]placement, error) {
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make(map[string]placement, len(tasks))
+	for _, task := range tasks {
+		orders[task.UUID] = placement{order: task.Order, pinned: task.Pinned}
+	}
+	return orders, nil
+}
+
+// setOrders restores the given placement of active tasks. Tasks missing from
+// orders keep their current placement.
+func (h *Handler) setOrders(orders map[string]placement) error {
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(tasks); i++ {
+		p, ok := orders[tasks[i].UUID]
+		if !ok || (p.order == tasks[i].Order && p.pinned == tasks[i].Pinned) {
+			continue
+		}
+		tasks[i].Order = p.order
+		tasks[i].Pinned = p.pinned
+		err = h.DB.UpdateTask(&tasks[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	h.bus.Publish(EventTasksReordered, nil)
+	return nil
+}
+
+func (h *Handler) RearrangeTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	body, err := ioutil.ReadAll(r.Body)
+	errHandler(err)
+
+	strBody := string(body)
+	sourceTaskUUID := ""
+	destinationTaskPosition := 0
+
+	for i := 0; i < len(strBody); i++ {
+		if strBody[i] != ',' {
+			sourceTaskUUID += string(strBody[i])
+		} else {
+			destinationTaskPosition = i + 1
+			break
+		}
+	}
+
+	destinationTaskUUID := strBody[destinationTaskPosition:]
+
+	if !h.checkList(w, r) {
+		return
 	}
 
-	tasks, err = h.DB.GetTasks()
+	before, err := h.orders()
+	errHandler(err)
+
+	err = h.Move(sourceTaskUUID, destinationTaskUUID)
+	errHandler(err)
+
+	after, err := h.orders()
+	errHandler(err)
+
+	h.record(w, r, &moveOperation{before: before, after: after})
+
+	tasks, err := h.Tasks()
 	errHandler(err)
 	
 	// Log final task order
@@ -468,14 +605,31 @@ func (h *Handler) RearrangeTasks(w http.ResponseWriter, r *http.Request) {
 		log.Printf("  - %s: order %d\n", task.UUID, task.Order)
 	}
 
-	tasksJSON, err := json.Marshal(tasks)
-	errHandler(err)
+	h.writeTasks(w)
+}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Write(tasksJSON)
+// SetRealSeconds stores the time spent on a task so far, as counted by a
+// running timer
+func (h *Handler) SetRealSeconds(uuid string, seconds int) error {
+	task, err := h.DB.GetTaskByUUID(uuid)
+	if err != nil {
+		return err
+	}
+
+	task.DurationExecutionRealSeconds = seconds
+
+	return h.DB.UpdateTask(task)
 }
 
 func (h *Handler) UpdateTaskExecutionRealSeconds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
 	updateTaskJSON, err := ioutil.ReadAll(r.Body)
 	errHandler(err)
 
@@ -486,31 +640,38 @@ func (h *Handler) UpdateTaskExecutionRealSeconds(w http.ResponseWriter, r *http.
 	seconds, err := strconv.Atoi(updateTaskSplitted[1])
 	errHandler(err)
 
-	task, err := h.DB.GetTaskByUUID(uuid)
+	err = h.SetRealSeconds(uuid, seconds)
 	errHandler(err)
+}
 
-	task.DurationExecutionRealSeconds = seconds
+// CompletedToday returns the tasks completed since midnight, in the order
+// they were completed
+func (h *Handler) CompletedToday() ([]database.Task, error) {
+	now := h.now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
-	err = h.DB.UpdateTask(task)
-	errHandler(err)
+	page, err := h.DB.QueryCompletedTasks(database.CompletedQuery{
+		From:   midnight,
+		To:     midnight.AddDate(0, 0, 1),
+		Oldest: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Tasks, nil
 }
 
 func (h *Handler) GetTodayResults(w http.ResponseWriter, r *http.Request) {
-	tasksCompleted, err := h.DB.GetCompletedTasks()
+	tasksCompletedToday, err := h.CompletedToday()
 	errHandler(err)
 
-	var tasksCompletedToday []database.Task
-
-	var todayYear, todayMonth, todayDay = time.Now().Date()
-
-	for i := 0; i < len(tasksCompleted); i++ {
-		year, month, day := tasksCompleted[i].TimeCompleted.Date()
-		if (year == todayYear) && (month == todayMonth) && (day == todayDay) {
-			tasksCompletedToday = append(tasksCompletedToday, tasksCompleted[i])
-		}
+	comparison, err := h.ComparePlan(h.now().Format("2006-01-02"))
+	if err != nil && err != database.ErrPlanNotFound {
+		log.Printf("Error comparing plan: %v", err)
 	}
 
-	saveReport(tasksCompletedToday)
+	h.saveReport(tasksCompletedToday, comparison)
 
 	tasksJSON, err := json.Marshal(tasksCompletedToday)
 	errHandler(err)
@@ -519,21 +680,12 @@ func (h *Handler) GetTodayResults(w http.ResponseWriter, r *http.Request) {
 	w.Write(tasksJSON)
 }
 
-func saveReport(completedTasks []database.Task) {
-	// Get home directory for report path
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Printf("Error getting home directory: %v", err)
-		homeDir = "."
-	}
-	
-	reportDir := filepath.Join(homeDir, "tasksReport")
-	err = os.MkdirAll(reportDir, 0755)
-	if err != nil {
-		log.Printf("Error creating report directory: %v", err)
-	}
+// saveReport writes the daily summary, with the comparison of the accepted
+// plan and what was done when a plan was accepted for today
+func (h *Handler) saveReport(completedTasks []database.Task, comparison *PlanComparison) {
+	reportDir := h.reportDirectory()
 
-	t := time.Now()
+	t := h.now()
 	day := t.Format("02")
 	month := t.Format("Jan")
 	year := t.Format("2006")
@@ -554,6 +706,21 @@ func saveReport(completedTasks []database.Task) {
 		totalSeconds += task.DurationExecutionRealSeconds
 	}
 
+	// Count how tasks with a planned date or deadline were finished
+	beforePlanned := 0
+	deadlinesMet := 0
+	for _, task := range completedTasks {
+		switch task.DeadlineOutcome {
+		case database.OutcomeBeforePlanned:
+			beforePlanned++
+			if task.TimeHardDeadline != nil {
+				deadlinesMet++
+			}
+		case database.OutcomeOnTime:
+			deadlinesMet++
+		}
+	}
+
 	totalHours := totalSeconds / 3600
 	totalMinutes := (totalSeconds % 3600) / 60
 
@@ -655,6 +822,11 @@ h1 {
     font-size: 14px;
     margin-top: 8px;
 }
+.task-deadline {
+    color: #ffd700;
+    font-size: 14px;
+    margin-top: 4px;
+}
 .footer {
     text-align: center;
     margin-top: 50px;
@@ -679,6 +851,14 @@ h1 {
         <div class="stat-value">` + fmt.Sprintf("%dh %dm", totalHours, totalMinutes) + `</div>
         <div class="stat-label">Total Time</div>
     </div>
+    <div class="stat-box">
+        <div class="stat-value">` + strconv.Itoa(beforePlanned) + `</div>
+        <div class="stat-label">Before Planned Date</div>
+    </div>
+    <div class="stat-box">
+        <div class="stat-value">` + strconv.Itoa(deadlinesMet) + `</div>
+        <div class="stat-label">Deadlines Met</div>
+    </div>
     <div class="stat-box">
         <div class="stat-value">🔥</div>
         <div class="stat-label">Great Work!</div>
@@ -712,14 +892,16 @@ h1 {
     <div class="task-time">✅ Completed at %s</div>
     <div class="task-body">%s</div>
     <div class="task-duration">⏱️ Time spent: %s</div>
-</div>
-`, task.TimeCompleted.Format("15:04:05"), cleanBody, durationStr)
+%s</div>
+`, task.TimeCompleted.Format("15:04:05"), cleanBody, durationStr, outcomeHTML(task.DeadlineOutcome))
 		io.WriteString(f, taskHTML)
 	}
 
+	io.WriteString(f, "</div>\n")
+	io.WriteString(f, planHTML(comparison))
+
 	// Write footer
-	footer := `</div>
-<div class="footer">
+	footer := `<div class="footer">
     Generated by Done Task Manager
 </div>
 </div>
@@ -728,44 +910,78 @@ h1 {
 	io.WriteString(f, footer)
 }
 
-func (h *Handler) GetGamification(w http.ResponseWriter, r *http.Request) {
-	gamification, err := h.DB.GetGamification()
-	if err != nil {
-		log.Printf("Error getting gamification: %v", err)
-		http.Error(w, "Failed to get gamification data", http.StatusInternalServerError)
-		return
+// planHTML renders the plan versus actual section of the report
+func planHTML(comparison *PlanComparison) string {
+	if comparison == nil {
+		return ""
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	json.NewEncoder(w).Encode(gamification)
+	html := `<div class="task-list">
+<h2>Plan vs Actual</h2>
+<div class="task-duration">Planned ` + reportDuration(comparison.PlannedSeconds) +
+		`, spent ` + reportDuration(comparison.ActualSeconds) + ` on completed tasks</div>
+`
+	for _, outcome := range comparison.Tasks {
+		html += fmt.Sprintf(`<div class="task-item">
+    <div class="task-time">%s</div>
+    <div class="task-body">%s</div>
+    <div class="task-duration">Planned %s, spent %s</div>
+</div>
+`, planLabel(outcome.Status), utils.CleanTaskText(outcome.Body), reportDuration(outcome.PlannedSeconds), reportDuration(outcome.ActualSeconds))
+	}
+	return html + "</div>\n"
+}
+
+// planLabel describes what became of a task of the plan
+func planLabel(status string) string {
+	switch status {
+	case PlanDone:
+		return "✅ Done as planned"
+	case PlanOpen:
+		return "⏳ Planned, still open"
+	case PlanDropped:
+		return "🗑️ Planned, then dropped"
+	case PlanUnplanned:
+		return "➕ Done without a plan"
+	}
+	return ""
 }
 
-func (h *Handler) UpdateGamification(w http.ResponseWriter, r *http.Request) {
-	var gamification database.Gamification
-	
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Error reading body: %v", err)
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
+// reportDuration formats a duration of the plan section, 0m when none
+func reportDuration(seconds int) string {
+	if seconds <= 0 {
+		return "0m"
 	}
-	defer r.Body.Close()
-
-	err = json.Unmarshal(body, &gamification)
-	if err != nil {
-		log.Printf("Error unmarshaling gamification: %v", err)
-		http.Error(w, "Invalid gamification data", http.StatusBadRequest)
-		return
+	return utils.FormatEstimate(seconds)
+}
+
+// outcomeHTML renders the deadline outcome line of a report entry
+func outcomeHTML(outcome string) string {
+	label := ""
+	switch outcome {
+	case database.OutcomeBeforePlanned:
+		label = "🎯 Finished before the planned date"
+	case database.OutcomeOnTime:
+		label = "⏰ Made the hard deadline"
+	case database.OutcomeLate:
+		label = "⚠️ Missed the hard deadline"
+	case database.OutcomeSlipped:
+		label = "🐢 Finished after the planned date"
+	default:
+		return ""
 	}
+	return `    <div class="task-deadline">` + label + `</div>
+`
+}
 
-	err = h.DB.UpdateGamification(&gamification)
+func (h *Handler) GetGamification(w http.ResponseWriter, r *http.Request) {
+	gamification, err := h.DB.GetGamification()
 	if err != nil {
-		log.Printf("Error updating gamification: %v", err)
-		http.Error(w, "Failed to update gamification", http.StatusInternalServerError)
+		log.Printf("Error getting gamification: %v", err)
+		http.Error(w, "Failed to get gamification data", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(gamification)
 }
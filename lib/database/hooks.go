@@ -0,0 +1,92 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	database "done/lib/database/interface"
+	"done/lib/events"
+)
+
+// Hooks are executables in the hooks directory, run on the machine Done
+// runs on. A pre-add hook gets each task about to be added as JSON and may
+// print it changed, or exit with a non-zero status to reject it. The on-
+// hooks get the events of tasks as they happen, as webhooks do, and their
+// output is ignored.
+
+// hookPreAdd is the kind of the hooks that filter added tasks
+const hookPreAdd = "pre-add"
+
+// hookKinds are the kinds of the hooks run on events, by event type
+var hookKinds = map[string]string{
+	EventTaskAdded:     "on-add",
+	EventTaskUpdated:   "on-update",
+	EventTaskCompleted: "on-complete",
+	EventTaskRemoved:   "on-remove",
+	EventLevelUp:       "on-level-up",
+	EventAchievement:   "on-achievement",
+	EventReminder:      "on-reminder",
+}
+
+// preAdd lets the pre-add hooks change or reject a task about to be added.
+// They cannot change its UUID, creation time or position.
+func (h *Handler) preAdd(task *database.Task) error {
+	if h.Hooks == nil {
+		return nil
+	}
+
+	input, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	output, err := h.Hooks.Filter(hookPreAdd, input)
+	if err != nil {
+		return err
+	}
+
+	var changed database.Task
+	if err := json.Unmarshal(output, &changed); err != nil {
+		return fmt.Errorf("%s hook printed an invalid task: %w", hookPreAdd, err)
+	}
+	if strings.TrimSpace(changed.Body) == "" {
+		return fmt.Errorf("%s hook printed a task without text", hookPreAdd)
+	}
+	changed.UUID = task.UUID
+	changed.TimeCreated = task.TimeCreated
+	changed.Order = task.Order
+	*task = changed
+	return nil
+}
+
+// runHooks runs the hooks of event, which happened to the tasks of the user
+// with the given handler key or of the shared list
+func (a *Accounts) runHooks(event events.Event, key, list string) {
+	kind, ok := hookKinds[event.Type]
+	if !ok || a.Hooks == nil {
+		return
+	}
+
+	payload := EventPayload{Event: event.Type, Time: event.Time, Data: event.Data}
+	if list != "" {
+		if shared, err := a.Store.GetSharedList(list); err == nil {
+			payload.List = shared.Name
+		}
+	} else if users, err := a.Store.GetUsers(); err == nil {
+		for _, user := range users {
+			if user.ID == key || (key == "" && user.Owner) {
+				payload.User = user.Name
+			}
+		}
+	}
+
+	input, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding %s for hooks: %v", event.Type, err)
+		return
+	}
+	if err := a.Hooks.Run(kind, input); err != nil {
+		log.Printf("Error running %s hooks: %v", kind, err)
+	}
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/hooks"
+	"done/lib/importer"
+	uuid "github.com/satori/go.uuid"
+)
+
+// maxImportBytes bounds the size of an uploaded export
+const maxImportBytes = 10 << 20
+
+// ImportReport lists what an import added, or would add on a dry run
+type ImportReport struct {
+	Format     string            `json:"format"`
+	DryRun     bool              `json:"dry_run"`
+	Imported   []database.Task   `json:"imported"`
+	Duplicates []database.Task   `json:"duplicates"`
+	Rejected   []RejectedTask    `json:"rejected"`
+	Skipped    []importer.Notice `json:"skipped"`
+	Warnings   []importer.Notice `json:"warnings"`
+}
+
+// RejectedTask is a task a pre-add hook refused to import
+type RejectedTask struct {
+	Task   database.Task `json:"task"`
+	Reason string        `json:"reason"`
+}
+
+// Import adds parsed tasks below the existing ones, keeping their order.
+// Tasks whose text matches an active task, or an earlier task of the same
+// import, are reported as duplicates and left out. Every other task goes
+// through the pre-add hooks, which may change or reject it, also on a dry
+// run, which only reports.
+func (h *Handler) Import(parsed *importer.Result, dryRun bool) (*ImportReport, error) {
+	report := &ImportReport{
+		Format:     parsed.Format,
+		DryRun:     dryRun,
+		Imported:   []database.Task{},
+		Duplicates: []database.Task{},
+		Rejected:   []RejectedTask{},
+		Skipped:    append([]importer.Notice{}, parsed.Skipped...),
+		Warnings:   append([]importer.Notice{}, parsed.Warnings...),
+	}
+
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	order := 0
+	for _, task := range tasks {
+		seen[duplicateKey(task.Body)] = true
+		if task.Order >= order {
+			order = task.Order + 1
+		}
+	}
+
+	for _, task := range parsed.Tasks {
+		key := duplicateKey(task.Body)
+		if seen[key] {
+			report.Duplicates = append(report.Duplicates, task)
+			continue
+		}
+		seen[key] = true
+
+		task.UUID = uuid.NewV4().String()
+		if task.TimeCreated.IsZero() {
+			task.TimeCreated = time.Now()
+		}
+		task.Order = order
+		err := h.preAdd(&task)
+		if errors.Is(err, hooks.ErrRejected) {
+			report.Rejected = append(report.Rejected, RejectedTask{Task: task, Reason: err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		order++
+
+		report.Imported = append(report.Imported, task)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	for i := range report.Imported {
+		if err := h.DB.AddTask(&report.Imported[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// duplicateKey normalizes task text for duplicate detection: case and
+// whitespace do not matter
+func duplicateKey(body string) string {
+	return strings.ToLower(strings.Join(strings.Fields(body), " "))
+}
+
+// ImportTasks imports an export from another task manager posted as the
+// request body. The format query parameter is "taskwarrior", "todotxt",
+// "todoist" or empty to detect it, and dryRun=true previews the import.
+func (h *Handler) ImportTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		log.Printf("Error reading import: %v", err)
+		http.Error(w, "Failed to read import", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	parsed, err := importer.Parse(r.URL.Query().Get("format"), data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+
+	report, err := h.Import(parsed, dryRun)
+	if err != nil {
+		log.Printf("Error importing tasks: %v", err)
+		http.Error(w, "Failed to import tasks", http.StatusInternalServerError)
+		return
+	}
+
+	if !dryRun && len(report.Imported) > 0 {
+		h.record(w, r, &importOperation{tasks: report.Imported})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(report)
+}
+
+// importOperation records an import so it can be undone as a whole
+type importOperation struct {
+	tasks []database.Task
+}
+
+func (op *importOperation) name() string { return "import" }
+
+func (op *importOperation) undo(h *Handler) error {
+	for _, task := range op.tasks {
+		err := h.DB.RemoveTask(task.UUID)
+		if err != nil && !errors.Is(err, database.ErrTaskNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (op *importOperation) redo(h *Handler) error {
+	for i := range op.tasks {
+		if err := h.DB.AddTask(&op.tasks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	database "done/lib/database/interface"
+	"done/lib/hooks"
+	"done/lib/importer"
+)
+
+func TestImportRunsPreAddHooks(t *testing.T) {
+	dir := t.TempDir()
+	// Rejects tasks about secrets and tags the others
+	hook := `#!/bin/sh
+task=$(cat)
+case "$task" in
+*secret*) echo "No secrets"; exit 1 ;;
+esac
+echo "$task" | sed 's/"body":"/"body":"Imported: /'
+`
+	if err := os.WriteFile(filepath.Join(dir, "pre-add"), []byte(hook), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(openStore(t))
+	h.Hooks = hooks.New(dir, 0)
+	parsed := &importer.Result{Format: "todotxt", Tasks: []database.Task{
+		{Body: "Write the report"},
+		{Body: "Share the secret plan"},
+		{Body: "Call the bank"},
+	}}
+
+	for _, dryRun := range []bool{true, false} {
+		report, err := h.Import(parsed, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Imported) != 2 || report.Imported[0].Body != "Imported: Write the report" || report.Imported[1].Order != report.Imported[0].Order+1 {
+			t.Errorf("dry run %v: imported %+v", dryRun, report.Imported)
+		}
+		if len(report.Rejected) != 1 || report.Rejected[0].Task.Body != "Share the secret plan" {
+			t.Errorf("dry run %v: rejected %+v", dryRun, report.Rejected)
+		}
+	}
+
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("stored %d tasks, want 2", len(tasks))
+	}
+}
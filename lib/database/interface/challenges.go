@@ -0,0 +1,34 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrChallengeNotFound is returned when no challenge with the requested ID
+// exists
+var ErrChallengeNotFound = errors.New("challenge not found")
+
+// Challenge is a target the users of a shared server reach together, such
+// as 500 points by Friday. What participants complete between Start and
+// Deadline counts towards it.
+type Challenge struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Metric       string    `json:"metric"` // points or completed
+	Target       int       `json:"target"`
+	Start        time.Time `json:"start"`
+	Deadline     time.Time `json:"deadline"`
+	CreatedBy    string    `json:"created_by"`   // User ID
+	Participants []string  `json:"participants"` // User IDs
+}
+
+// Challenges stores the team challenges of a shared server
+type Challenges interface {
+	// GetChallenges returns every challenge, soonest deadline first
+	GetChallenges() ([]Challenge, error)
+	GetChallenge(id string) (*Challenge, error)
+	// SaveChallenge adds or replaces the challenge with the same ID
+	SaveChallenge(challenge *Challenge) error
+	RemoveChallenge(id string) error
+}
@@ -1,19 +1,155 @@
 package database
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
+// ErrTaskNotFound is returned when no task with the requested UUID exists
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrPlanNotFound is returned when no plan was accepted for the requested day
+var ErrPlanNotFound = errors.New("plan not found")
+
+// ErrInvalidCursor is returned for a page cursor that was not returned by a
+// previous query
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Deadline outcomes recorded on completed tasks
+const (
+	OutcomeBeforePlanned = "before_planned" // Finished on or before the planned date
+	OutcomeOnTime        = "on_time"        // Missed or had no planned date but made the hard deadline
+	OutcomeLate          = "late"           // Finished after the hard deadline
+	OutcomeSlipped       = "slipped"        // Finished after the planned date, no hard deadline set
+)
+
+// Task priorities; tasks without a priority leave it empty
+const (
+	PriorityHigh   = "high"
+	PriorityMedium = "medium"
+	PriorityLow    = "low"
+)
+
+// ValidPriority reports whether p is a task priority or empty
+func ValidPriority(p string) bool {
+	switch p {
+	case "", PriorityHigh, PriorityMedium, PriorityLow:
+		return true
+	}
+	return false
+}
+
 type Task struct {
-	UUID                              string    `json:"uuid"`
-	Body                              string    `json:"body"`
-	TimeCreated                       time.Time `json:"timecreated"`
-	TimeCompleted                     time.Time `json:"timecompleted"`
-	DurationExecutionEstimatedSeconds int       `json:"duration_execution_estimated_seconds"`
-	DurationExecutionRealSeconds      int       `json:"duration_execution_real_seconds"`
-	TimeHardDeadline                  time.Time `json:"time_hard_dead_line"`
-	Order                             int       `json:"order"`
-	Child                             []Task    `json:"-"`
+	UUID                              string           `json:"uuid"`
+	Body                              string           `json:"body"`
+	TimeCreated                       time.Time        `json:"timecreated"`
+	TimeCompleted                     time.Time        `json:"timecompleted"`
+	DurationExecutionEstimatedSeconds int              `json:"duration_execution_estimated_seconds"`
+	DurationExecutionRealSeconds      int              `json:"duration_execution_real_seconds"`
+	TimeHardDeadline                  *time.Time       `json:"time_hard_dead_line"`
+	DeadlineHasTime                   bool             `json:"deadline_has_time"`
+	TimePlanned                       *time.Time       `json:"time_planned"`
+	Tags                              []string         `json:"tags,omitempty"`
+	Priority                          string           `json:"priority,omitempty"`
+	Pinned                            bool             `json:"pinned,omitempty"` // Keeps its manual position in smart sort
+	BlockedBy                         []string         `json:"blocked_by,omitempty"`
+	Blocked                           bool             `json:"blocked,omitempty"` // Computed: a task in BlockedBy is still active
+	DeadlineOutcome                   string           `json:"deadline_outcome,omitempty"`
+	TimeDeleted                       *time.Time       `json:"time_deleted,omitempty"`
+	Award                             *CompletionAward `json:"award,omitempty"`
+	Assignee                          string           `json:"assignee,omitempty"`     // Name of the user a task on a shared list is assigned to
+	CompletedBy                       string           `json:"completed_by,omitempty"` // Name of the user credited for completing it
+	Order                             int              `json:"order"`
+	Revision                          uint64           `json:"revision"` // Counts changes to the content, see Changes
+	Child                             []Task           `json:"-"`
+}
+
+// Changes reports what updating old to t changes: the content of the task,
+// which bumps its revision, or only its place in the list. The time spent,
+// stored every second by a running timer, is neither.
+func (t *Task) Changes(old *Task) (content, placement bool) {
+	placement = t.Order != old.Order || t.Pinned != old.Pinned
+
+	same := *old
+	same.Order, same.Pinned = t.Order, t.Pinned
+	same.DurationExecutionRealSeconds = t.DurationExecutionRealSeconds
+	same.Revision, same.Blocked = t.Revision, t.Blocked
+	a, errA := json.Marshal(&same)
+	b, errB := json.Marshal(t)
+	content = errA != nil || errB != nil || !bytes.Equal(a, b)
+	return content, placement
+}
+
+// CompletionAward records what completing a task granted, so that reopening
+// the task can take exactly that back
+type CompletionAward struct {
+	Points                     int        `json:"points"`
+	Achievements               []string   `json:"achievements,omitempty"`
+	PreviousStreak             int        `json:"previous_streak"`
+	PreviousLongestStreak      int        `json:"previous_longest_streak"`
+	PreviousLastCompletionDate *time.Time `json:"previous_last_completion_date"`
+	PreviousFirstTaskDate      *time.Time `json:"previous_first_task_date"`
+}
+
+// HardDeadline returns the moment the hard deadline expires. Deadlines without
+// a time of day last until the end of their day.
+func (t *Task) HardDeadline() (time.Time, bool) {
+	if t.TimeHardDeadline == nil {
+		return time.Time{}, false
+	}
+	if t.DeadlineHasTime {
+		return *t.TimeHardDeadline, true
+	}
+	return endOfDay(*t.TimeHardDeadline), true
+}
+
+// PlannedBy returns the end of the day the task is planned for
+func (t *Task) PlannedBy() (time.Time, bool) {
+	if t.TimePlanned == nil {
+		return time.Time{}, false
+	}
+	return endOfDay(*t.TimePlanned), true
+}
+
+// Outcome classifies a completion time against the planned date and the hard
+// deadline. It returns an empty string when the task has neither.
+func (t *Task) Outcome(completed time.Time) string {
+	deadline, hasDeadline := t.HardDeadline()
+	planned, hasPlanned := t.PlannedBy()
+
+	if hasDeadline && completed.After(deadline) {
+		return OutcomeLate
+	}
+	if hasPlanned && !completed.After(planned) {
+		return OutcomeBeforePlanned
+	}
+	if hasDeadline {
+		return OutcomeOnTime
+	}
+	if hasPlanned {
+		return OutcomeSlipped
+	}
+	return ""
+}
+
+func endOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 23, 59, 59, int(time.Second-time.Nanosecond), t.Location())
+}
+
+// TaskEdit records the fields changed by one edit of a task
+type TaskEdit struct {
+	TaskUUID string        `json:"task_uuid"`
+	Time     time.Time     `json:"time"`
+	Changes  []FieldChange `json:"changes"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
 }
 
 type Gamification struct {
@@ -27,22 +163,133 @@ type Gamification struct {
 	Achievements     []string  `json:"achievements"`
 }
 
+// Sort modes of the task list
+const (
+	SortManual = "manual" // Order set by dragging tasks
+	SortSmart  = "smart"  // Ordered by priority, deadline and estimate
+)
+
+// Settings are the user's preferences stored with the tasks
+type Settings struct {
+	SortMode     string       `json:"sort_mode"`
+	WorkingHours WorkingHours `json:"working_hours"`
+	Busy         []BusyBlock  `json:"busy"`        // Meetings and other time not available for tasks
+	Leaderboard  bool         `json:"leaderboard"` // Show the user on the server's leaderboard
+
+	Reminders ReminderSettings `json:"reminders"`
+	Reports   ReportSettings   `json:"reports"`
+	Email     string           `json:"email"` // Where reminders and reports are emailed
+}
+
+// WorkingHours is the part of the day and the week available for tasks
+type WorkingHours struct {
+	Start string `json:"start"` // "09:00"
+	End   string `json:"end"`   // "17:00"
+	Days  []int  `json:"days"`  // Working weekdays, 0 is Sunday
+}
+
+// BusyBlock is time taken by a meeting or another commitment
+type BusyBlock struct {
+	Title string    `json:"title"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// DefaultSettings are used until the user changes them: manual order, 8
+// hour working days from Monday to Friday, reminders a day and an hour
+// before deadlines and of overdue tasks in the web UI, and no emailed
+// reports until the user asks for them, at 18:00 and on Fridays
+func DefaultSettings() Settings {
+	return Settings{
+		SortMode: SortManual,
+		WorkingHours: WorkingHours{
+			Start: "09:00",
+			End:   "17:00",
+			Days:  []int{1, 2, 3, 4, 5},
+		},
+		Busy: []BusyBlock{},
+		Reminders: ReminderSettings{
+			Enabled:  true,
+			Offsets:  []string{"1d", "1h"},
+			Overdue:  true,
+			Channels: []string{NotifyEvents},
+		},
+		Reports: ReportSettings{
+			At:      "18:00",
+			WeekDay: int(time.Friday),
+		},
+	}
+}
+
+// DayPlan is the plan accepted for one day
+type DayPlan struct {
+	Date            string        `json:"date"` // 2006-01-02
+	Accepted        time.Time     `json:"accepted"`
+	CapacitySeconds int           `json:"capacity_seconds"`
+	Tasks           []PlannedTask `json:"tasks"`
+}
+
+// PlannedTask is the time set aside for a task on one day
+type PlannedTask struct {
+	UUID    string    `json:"uuid"`
+	Body    string    `json:"body"`
+	Seconds int       `json:"seconds"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// CompletedQuery selects a page of completed tasks by completion time
+type CompletedQuery struct {
+	From   time.Time // Completed at or after this time, when set
+	To     time.Time // Completed before this time, when set
+	Oldest bool      // Oldest first; newest first otherwise
+	Limit  int       // Tasks per page; 0 returns all
+	Cursor string    // NextCursor of the previous page
+}
+
+// CompletedPage is a page of completed tasks
+type CompletedPage struct {
+	Tasks      []Task `json:"tasks"`
+	NextCursor string `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
 type Database interface {
 	Connect() error
 	Disconnect() error
 
 	GetTasks() ([]Task, error)
+	TasksRevision() (uint64, error)
 	GetTaskByUUID(uuid string) (*Task, error)
 	AddTask(task *Task) error
 	UpdateTask(task *Task) error
 	RemoveTask(uuid string) error
 
 	GetCompletedTasks() ([]Task, error)
+	GetCompletedTaskByUUID(uuid string) (*Task, error)
 	AddCompletedTask(task *Task) error
+	RemoveCompletedTask(uuid string) error
+	QueryCompletedTasks(query CompletedQuery) (*CompletedPage, error)
+
+	GetTrashedTasks() ([]Task, error)
+	GetTrashedTaskByUUID(uuid string) (*Task, error)
+	AddTrashedTask(task *Task) error
+	RemoveTrashedTask(uuid string) error
+
+	GetTaskHistory(uuid string) ([]TaskEdit, error)
+	AddTaskEdit(edit *TaskEdit) error
+	RemoveTaskHistory(uuid string) error
 
 	GetGamification() (*Gamification, error)
 	UpdateGamification(gamification *Gamification) error
 
+	GetSettings() (*Settings, error)
+	UpdateSettings(settings *Settings) error
+
+	GetDayPlan(date string) (*DayPlan, error)
+	SaveDayPlan(plan *DayPlan) error
+
+	Reminders
+	Reports
+
 	DBUpgrade() string
 }
-
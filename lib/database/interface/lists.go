@@ -0,0 +1,37 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrListNotFound is returned when no shared list with the requested ID
+// exists
+var ErrListNotFound = errors.New("list not found")
+
+// ErrListExists is returned when adding a shared list whose name is taken
+var ErrListExists = errors.New("list already exists")
+
+// SharedList is a task list several users work on together. Its tasks,
+// order, history and reports are its own; the points for completing its
+// tasks go to whoever completes them.
+type SharedList struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"` // User ID
+	Members   []string  `json:"members"`    // User IDs
+	Created   time.Time `json:"created"`
+}
+
+// SharedLists stores the shared lists of a server
+type SharedLists interface {
+	// GetSharedLists returns every shared list, oldest first
+	GetSharedLists() ([]SharedList, error)
+	GetSharedList(id string) (*SharedList, error)
+	// AddSharedList creates the list and its empty buckets
+	AddSharedList(list *SharedList) error
+	UpdateSharedList(list *SharedList) error
+
+	// ForSharedList returns the database of the list's tasks
+	ForSharedList(id string) (Database, error)
+}
@@ -0,0 +1,37 @@
+package database
+
+import "time"
+
+// Channels reminders are sent through
+const (
+	NotifyEvents  = "events"  // Live updates, which reach the web UI and webhooks
+	NotifyDesktop = "desktop" // Desktop notification on the machine Done runs on
+	NotifyEmail   = "email"   // Email to the address in the settings
+)
+
+// ReminderSettings are when and how the user is reminded of deadlines
+type ReminderSettings struct {
+	Enabled  bool     `json:"enabled"`
+	Offsets  []string `json:"offsets"`  // How long before a deadline to remind, e.g. "1d" or "2h"
+	Overdue  bool     `json:"overdue"`  // Remind of overdue tasks once a day
+	Channels []string `json:"channels"` // NotifyEvents, NotifyDesktop or NotifyEmail
+}
+
+// ReminderState records the reminders sent about a task's deadline
+type ReminderState struct {
+	Deadline time.Time     `json:"deadline"`          // The deadline they were sent for; a new deadline starts over
+	Before   time.Duration `json:"before,omitempty"`  // The shortest offset reminded of
+	Overdue  *time.Time    `json:"overdue,omitempty"` // When the task was last reminded of as overdue
+	Snoozed  *time.Time    `json:"snoozed,omitempty"` // No reminders until then, then one
+}
+
+// Reminders stores the reminder state of a user's or a shared list's tasks,
+// by a key made of the task's UUID
+type Reminders interface {
+	// GetReminderStates returns the state of every task reminded of, by key
+	GetReminderStates() (map[string]ReminderState, error)
+	// GetReminderState returns the state of a task, empty if there is none
+	GetReminderState(key string) (*ReminderState, error)
+	SaveReminderState(key string, state *ReminderState) error
+	RemoveReminderState(key string) error
+}
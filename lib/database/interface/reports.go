@@ -0,0 +1,20 @@
+package database
+
+import "time"
+
+// ReportSettings are which summaries of completed tasks are emailed to the
+// user, and when. Both are off until the user opts in.
+type ReportSettings struct {
+	Daily   bool   `json:"daily"`    // Email the daily summary
+	Weekly  bool   `json:"weekly"`   // Email the weekly report
+	At      string `json:"at"`       // Clock time they are sent at, "18:00"
+	WeekDay int    `json:"week_day"` // Weekday the weekly report is sent on, 0 is Sunday
+}
+
+// Reports records when the user's emailed summaries were last sent
+type Reports interface {
+	// GetReportSent returns when the summary of the given kind was last
+	// sent, the zero time if never
+	GetReportSent(kind string) (time.Time, error)
+	SetReportSent(kind string, sent time.Time) error
+}
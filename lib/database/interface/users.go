@@ -0,0 +1,94 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUserNotFound is returned when no user with the requested ID or name
+// exists
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUserExists is returned when adding a user whose name is taken
+var ErrUserExists = errors.New("user already exists")
+
+// User is an account on a server shared by several people. Each user has
+// their own tasks, completed history, gamification record and settings.
+type User struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Admin    bool      `json:"admin,omitempty"`    // May add, enable and disable users
+	Disabled bool      `json:"disabled,omitempty"` // May not sign in
+	Owner    bool      `json:"owner,omitempty"`    // Has the data from before there were users
+	Created  time.Time `json:"created"`
+}
+
+// Users stores the accounts of a shared server
+type Users interface {
+	GetUsers() ([]User, error)
+	GetUser(id string) (*User, error)
+	GetUserByName(name string) (*User, error)
+	// AddUser creates the user and their empty lists. The first user is
+	// made the owner of the data stored before there were users.
+	AddUser(user *User) error
+	UpdateUser(user *User) error
+
+	// ForUser returns the database of one user
+	ForUser(id string) (Database, error)
+}
+
+// ErrSessionNotFound is returned for an unknown or signed out session
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrTokenNotFound is returned for an unknown or revoked API token
+var ErrTokenNotFound = errors.New("token not found")
+
+// Session is a signed in browser. The cookie holds a secret; only its hash
+// is stored, as the key of the session.
+type Session struct {
+	UserID  string    `json:"user_id"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// Token is a personal API token for the command line and integrations.
+// Like sessions, tokens are stored by the hash of their secret.
+type Token struct {
+	ID      string    `json:"id"`
+	UserID  string    `json:"user_id"`
+	Name    string    `json:"name"`
+	Scope   string    `json:"scope"` // read or write
+	Created time.Time `json:"created"`
+	Hash    string    `json:"-"`
+}
+
+// Credentials stores what users sign in with
+type Credentials interface {
+	// PasswordHash returns the hash of the user's password, or "" when the
+	// user has none
+	PasswordHash(userID string) (string, error)
+	SetPasswordHash(userID, hash string) error
+
+	AddSession(hash string, session *Session) error
+	GetSession(hash string) (*Session, error)
+	RemoveSession(hash string) error
+	// RemoveSessions signs the user out everywhere
+	RemoveSessions(userID string) error
+
+	AddToken(token *Token) error
+	// GetTokens returns the tokens of a user, oldest first
+	GetTokens(userID string) ([]Token, error)
+	GetTokenByHash(hash string) (*Token, error)
+	RemoveToken(id string) error
+}
+
+// Store is a database that can hold several users. Used as a Database, it
+// holds the data from before there were users, which belongs to the owner.
+type Store interface {
+	Database
+	Users
+	Credentials
+	Challenges
+	SharedLists
+	Webhooks
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrWebhookNotFound is returned when no webhook with the requested ID
+// exists
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Webhook sends the events of a user's tasks, or of a shared list, to a URL
+type Webhook struct {
+	ID      string    `json:"id"`
+	UserID  string    `json:"user_id"`        // Empty on a server without users
+	List    string    `json:"list,omitempty"` // ID of the shared list whose events are sent
+	URL     string    `json:"url"`
+	Events  []string  `json:"events,omitempty"` // Event types sent; all when empty
+	Secret  string    `json:"secret,omitempty"` // Signs the deliveries
+	Created time.Time `json:"created"`
+
+	LastDelivered *time.Time `json:"last_delivered,omitempty"`
+	LastError     string     `json:"last_error,omitempty"` // Why the latest attempt failed
+}
+
+// Delivery is an event waiting to be sent to a webhook. Deliveries are
+// queued in the database, so they survive restarts until they are sent or
+// given up.
+type Delivery struct {
+	ID          uint64          `json:"id"`
+	WebhookID   string          `json:"webhook_id"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+}
+
+// Webhooks stores the webhooks and their delivery queue
+type Webhooks interface {
+	// GetWebhooks returns every webhook, oldest first
+	GetWebhooks() ([]Webhook, error)
+	GetWebhook(id string) (*Webhook, error)
+	// SaveWebhook adds or replaces the webhook with the same ID
+	SaveWebhook(webhook *Webhook) error
+	// RemoveWebhook deletes the webhook and its queued deliveries
+	RemoveWebhook(id string) error
+
+	// EnqueueDelivery queues a delivery, numbering it
+	EnqueueDelivery(delivery *Delivery) error
+	// DueDeliveries returns the deliveries whose next attempt is due at
+	// now, oldest first, up to limit of each webhook
+	DueDeliveries(now time.Time, limit int) ([]Delivery, error)
+	UpdateDelivery(delivery *Delivery) error
+	RemoveDelivery(id uint64) error
+}
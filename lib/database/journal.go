@@ -0,0 +1,313 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	database "done/lib/database/interface"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// maxJournalOperations bounds the undo history of a session
+	maxJournalOperations = 50
+	// maxJournalSessions bounds the number of sessions with a journal; the
+	// least recently used journal is dropped first
+	maxJournalSessions = 64
+
+	sessionCookie = "done_session"
+	sessionHeader = "X-Done-Session"
+)
+
+// operation is a recorded task mutation that can be reverted and applied again
+type operation interface {
+	name() string
+	undo(h *Handler) error
+	redo(h *Handler) error
+}
+
+// journal holds the undo and redo stacks of one session
+type journal struct {
+	undone   []operation
+	done     []operation
+	lastUsed time.Time
+}
+
+// journals keeps one bounded journal per session
+type journals struct {
+	mu       sync.Mutex
+	sessions map[string]*journal
+}
+
+func newJournals() *journals {
+	return &journals{sessions: make(map[string]*journal)}
+}
+
+// get returns the journal of a session, creating it if needed. Callers must
+// hold j.mu.
+func (j *journals) get(session string) *journal {
+	entry, ok := j.sessions[session]
+	if !ok {
+		if len(j.sessions) >= maxJournalSessions {
+			j.evictOldest()
+		}
+		entry = &journal{}
+		j.sessions[session] = entry
+	}
+	entry.lastUsed = time.Now()
+	return entry
+}
+
+func (j *journals) evictOldest() {
+	oldest := ""
+	for session, entry := range j.sessions {
+		if oldest == "" || entry.lastUsed.Before(j.sessions[oldest].lastUsed) {
+			oldest = session
+		}
+	}
+	delete(j.sessions, oldest)
+}
+
+// sessionID identifies the client session of a request by the X-Done-Session
+// header or the session cookie, setting a new cookie when there is neither.
+// It must be called before the response body is written.
+func sessionID(w http.ResponseWriter, r *http.Request) string {
+	if session := r.Header.Get(sessionHeader); session != "" {
+		return session
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	session := uuid.NewV4().String()
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return session
+}
+
+// record adds a completed mutation to the journal of the request's session
+// and clears what could be redone
+func (h *Handler) record(w http.ResponseWriter, r *http.Request, op operation) {
+	session := sessionID(w, r)
+
+	h.journals.mu.Lock()
+	defer h.journals.mu.Unlock()
+
+	entry := h.journals.get(session)
+	entry.done = append(entry.done, op)
+	if len(entry.done) > maxJournalOperations {
+		entry.done = entry.done[len(entry.done)-maxJournalOperations:]
+	}
+	entry.undone = nil
+}
+
+var errNothingToReplay = errors.New("nothing to replay")
+
+// replay applies the latest operation of one stack of the session's journal
+// and moves it onto the other stack. An operation whose task is gone, e.g.
+// because it was purged meanwhile, can never be applied and is dropped, so
+// the operations before it can still be replayed; any other failure leaves
+// it where it was to be tried again.
+func (h *Handler) replay(session string, undo bool) (operation, error) {
+	h.journals.mu.Lock()
+	defer h.journals.mu.Unlock()
+
+	entry := h.journals.get(session)
+	from, to := &entry.undone, &entry.done
+	if undo {
+		from, to = &entry.done, &entry.undone
+	}
+
+	if len(*from) == 0 {
+		return nil, errNothingToReplay
+	}
+	op := (*from)[len(*from)-1]
+
+	var err error
+	if undo {
+		err = op.undo(h)
+	} else {
+		err = op.redo(h)
+	}
+	if errors.Is(err, database.ErrTaskNotFound) {
+		*from = (*from)[:len(*from)-1]
+	}
+	if err != nil {
+		return op, err
+	}
+
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, op)
+	return op, nil
+}
+
+func (h *Handler) writeReplay(w http.ResponseWriter, r *http.Request, undo bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	action := "redo"
+	if undo {
+		action = "undo"
+	}
+
+	op, err := h.replay(sessionID(w, r), undo)
+	if errors.Is(err, errNothingToReplay) {
+		http.Error(w, "Nothing to "+action, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error trying to %s %s: %v", action, op.name(), err)
+		http.Error(w, "Cannot "+action+" "+op.name()+": "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("X-Done-Operation", op.name())
+	h.writeTasks(w)
+}
+
+// Undo reverts the latest mutation of the session and responds with the task
+// list. The X-Done-Operation header names the reverted operation.
+func (h *Handler) Undo(w http.ResponseWriter, r *http.Request) {
+	h.writeReplay(w, r, true)
+}
+
+// Redo applies the latest undone mutation of the session again
+func (h *Handler) Redo(w http.ResponseWriter, r *http.Request) {
+	h.writeReplay(w, r, false)
+}
+
+// addOperation records a task creation
+type addOperation struct {
+	task database.Task
+}
+
+func (op *addOperation) name() string { return "add" }
+
+func (op *addOperation) undo(h *Handler) error {
+	current, err := h.DB.GetTaskByUUID(op.task.UUID)
+	if err != nil {
+		return err
+	}
+	op.task = *current
+	return h.DB.RemoveTask(op.task.UUID)
+}
+
+func (op *addOperation) redo(h *Handler) error {
+	task := op.task
+	return h.insertAtOrder(&task)
+}
+
+// updateOperation records an edit of a task's fields
+type updateOperation struct {
+	before *database.Task
+	after  *database.Task
+}
+
+func (op *updateOperation) name() string { return "update" }
+
+func (op *updateOperation) undo(h *Handler) error {
+	_, err := h.Update(op.before.UUID, patchFrom(op.before))
+	return err
+}
+
+func (op *updateOperation) redo(h *Handler) error {
+	_, err := h.Update(op.after.UUID, patchFrom(op.after))
+	return err
+}
+
+// removeOperation records moving a task to the trash
+type removeOperation struct {
+	uuid string
+}
+
+func (op *removeOperation) name() string { return "remove" }
+
+func (op *removeOperation) undo(h *Handler) error {
+	_, err := h.Restore(op.uuid)
+	return err
+}
+
+func (op *removeOperation) redo(h *Handler) error {
+	_, err := h.Remove(op.uuid)
+	return err
+}
+
+// restoreOperation records restoring a task from the trash
+type restoreOperation struct {
+	uuid string
+}
+
+func (op *restoreOperation) name() string { return "restore" }
+
+func (op *restoreOperation) undo(h *Handler) error {
+	_, err := h.Remove(op.uuid)
+	return err
+}
+
+func (op *restoreOperation) redo(h *Handler) error {
+	_, err := h.Restore(op.uuid)
+	return err
+}
+
+// completeOperation records a task completion
+type completeOperation struct {
+	uuid string
+}
+
+func (op *completeOperation) name() string { return "complete" }
+
+func (op *completeOperation) undo(h *Handler) error {
+	_, err := h.Reopen(op.uuid)
+	return err
+}
+
+func (op *completeOperation) redo(h *Handler) error {
+	_, err := h.Complete(op.uuid)
+	return err
+}
+
+// reopenOperation records reopening a completed task
+type reopenOperation struct {
+	uuid string
+}
+
+func (op *reopenOperation) name() string { return "reopen" }
+
+func (op *reopenOperation) undo(h *Handler) error {
+	_, err := h.Complete(op.uuid)
+	return err
+}
+
+func (op *reopenOperation) redo(h *Handler) error {
+	_, err := h.Reopen(op.uuid)
+	return err
+}
+
+// moveOperation records a reordering of the task list
+type moveOperation struct {
+	before map[string]placement
+	after  map[string]placement
+}
+
+func (op *moveOperation) name() string { return "move" }
+
+func (op *moveOperation) undo(h *Handler) error {
+	return h.setOrders(op.before)
+}
+
+func (op *moveOperation) redo(h *Handler) error {
+	return h.setOrders(op.after)
+}
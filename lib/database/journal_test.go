@@ -0,0 +1,121 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	database "done/lib/database/interface"
+)
+
+func TestUndoRedo(t *testing.T) {
+	h := NewHandler(openStore(t))
+	task := database.Task{UUID: "t", Body: "Call the bank"}
+	if err := h.DB.AddTask(&task); err != nil {
+		t.Fatal(err)
+	}
+	h.journals.get("s").done = []operation{&addOperation{task: task}}
+
+	if _, err := h.replay("s", true); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if _, err := h.DB.GetTaskByUUID("t"); !errors.Is(err, database.ErrTaskNotFound) {
+		t.Errorf("undone add left the task: %v", err)
+	}
+	if _, err := h.replay("s", true); !errors.Is(err, errNothingToReplay) {
+		t.Errorf("undo with nothing left: %v", err)
+	}
+
+	op, err := h.replay("s", false)
+	if err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	if op.name() != "add" {
+		t.Errorf("redid %s, want add", op.name())
+	}
+	if _, err := h.DB.GetTaskByUUID("t"); err != nil {
+		t.Errorf("redone add: %v", err)
+	}
+
+	// Another session has a journal of its own
+	if _, err := h.replay("other", true); !errors.Is(err, errNothingToReplay) {
+		t.Errorf("undo in another session: %v", err)
+	}
+}
+
+// flakyOperation fails to undo until it is told to succeed
+type flakyOperation struct {
+	fail   error
+	undone int
+}
+
+func (op *flakyOperation) name() string { return "flaky" }
+
+func (op *flakyOperation) undo(h *Handler) error {
+	if op.fail != nil {
+		return op.fail
+	}
+	op.undone++
+	return nil
+}
+
+func (op *flakyOperation) redo(h *Handler) error { return nil }
+
+func TestFailedReplayKeepsOperation(t *testing.T) {
+	h := NewHandler(openStore(t))
+	op := &flakyOperation{fail: errors.New("task is busy")}
+	h.journals.get("s").done = []operation{op}
+
+	if _, err := h.replay("s", true); !errors.Is(err, op.fail) {
+		t.Fatalf("undo: %v", err)
+	}
+	if entry := h.journals.get("s"); len(entry.done) != 1 || len(entry.undone) != 0 {
+		t.Fatalf("after failed undo: done %d, undone %d", len(entry.done), len(entry.undone))
+	}
+
+	op.fail = nil
+	if _, err := h.replay("s", true); err != nil {
+		t.Fatalf("undo again: %v", err)
+	}
+	if entry := h.journals.get("s"); op.undone != 1 || len(entry.done) != 0 || len(entry.undone) != 1 {
+		t.Errorf("after undo: undone %d times, done %d, undone %d", op.undone, len(entry.done), len(entry.undone))
+	}
+}
+
+func TestUndoPastPurgedTask(t *testing.T) {
+	h := NewHandler(openStore(t))
+	for i, uuid := range []string{"kept", "purged"} {
+		task := database.Task{UUID: uuid, Body: "Task " + uuid, Order: i}
+		if err := h.DB.AddTask(&task); err != nil {
+			t.Fatal(err)
+		}
+		entry := h.journals.get("s")
+		entry.done = append(entry.done, &addOperation{task: task})
+	}
+	if _, err := h.Remove("purged"); err != nil {
+		t.Fatal(err)
+	}
+	entry := h.journals.get("s")
+	entry.done = append(entry.done, &removeOperation{uuid: "purged"})
+	if err := h.Purge("purged"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restoring and then removing the purged task both fail for good
+	for _, want := range []string{"remove", "add"} {
+		op, err := h.replay("s", true)
+		if !errors.Is(err, database.ErrTaskNotFound) || op.name() != want {
+			t.Fatalf("undo: %v, %v; want %s failing", op, err, want)
+		}
+	}
+
+	op, err := h.replay("s", true)
+	if err != nil || op.name() != "add" {
+		t.Fatalf("undo of the older add: %v, %v", op, err)
+	}
+	if _, err := h.DB.GetTaskByUUID("kept"); !errors.Is(err, database.ErrTaskNotFound) {
+		t.Errorf("undone add left the task: %v", err)
+	}
+	if _, err := h.replay("s", true); !errors.Is(err, errNothingToReplay) {
+		t.Errorf("undo with nothing left: %v", err)
+	}
+}
@@ -0,0 +1,521 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/leaderboard"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The leaderboard and challenges compare users by numbers worked out from
+// their completed history and gamification record. Only names and numbers
+// leave a user's data; the tasks behind them are never shown. Users appear
+// on the leaderboard once they turn on the leaderboard setting, and in a
+// challenge once they join it.
+
+var (
+	errNoUsers         = errors.New("leaderboards and challenges are for servers with users; add a user first")
+	errNotChallengeOwn = errors.New("only the creator of a challenge or an admin may delete it")
+)
+
+// Leaderboard is the ranking of one week
+type Leaderboard struct {
+	Metric  string              `json:"metric"`
+	Week    time.Time           `json:"week"` // Monday the week starts
+	Entries []leaderboard.Entry `json:"entries"`
+}
+
+// ChallengeStatus is a challenge with the progress made towards it. Users
+// are named rather than identified.
+type ChallengeStatus struct {
+	ID           string         `json:"id"`
+	Title        string         `json:"title"`
+	Metric       string         `json:"metric"`
+	Target       int            `json:"target"`
+	Start        time.Time      `json:"start"`
+	Deadline     time.Time      `json:"deadline"`
+	CreatedBy    string         `json:"created_by"`
+	Progress     int            `json:"progress"`
+	State        string         `json:"state"` // active, completed or failed
+	Participants []Contribution `json:"participants"`
+}
+
+// Contribution is what one participant added to a challenge
+type Contribution struct {
+	User  string `json:"user"`
+	Value int    `json:"value"`
+}
+
+// now returns the current time in the accounts' time zone
+func (a *Accounts) now() time.Time {
+	if a.Location != nil {
+		return time.Now().In(a.Location)
+	}
+	return time.Now()
+}
+
+// completionPoints returns the points a completed task earned
+func completionPoints(task *database.Task) int {
+	if task.Award != nil {
+		return task.Award.Points
+	}
+	return taskPoints(task)
+}
+
+// totals adds up the points and completed tasks of user between from and
+// to, on their own list and the shared lists
+func (a *Accounts) totals(user *database.User, from, to time.Time) (points, completed int, err error) {
+	h, err := a.Handler(user)
+	if err != nil {
+		return 0, 0, err
+	}
+	handlers := []*Handler{h}
+
+	lists, err := a.Store.GetSharedLists()
+	if err != nil {
+		return 0, 0, err
+	}
+	for i := range lists {
+		list, err := a.listHandler(&lists[i])
+		if err != nil {
+			return 0, 0, err
+		}
+		handlers = append(handlers, list)
+	}
+
+	for _, h := range handlers {
+		page, err := h.DB.QueryCompletedTasks(database.CompletedQuery{From: from, To: to})
+		if err != nil {
+			return 0, 0, err
+		}
+		for i := range page.Tasks {
+			task := &page.Tasks[i]
+			if h.credit != nil && !strings.EqualFold(task.CompletedBy, user.Name) {
+				continue
+			}
+			points += completionPoints(task)
+			completed++
+		}
+	}
+	return points, completed, nil
+}
+
+// Leaderboard ranks the users who turned on the leaderboard setting by
+// metric over the week of the given day. Streaks are as of now.
+func (a *Accounts) Leaderboard(metric string, week time.Time) (*Leaderboard, error) {
+	if metric == "" {
+		metric = leaderboard.MetricPoints
+	}
+	if !leaderboard.ValidMetric(metric) {
+		return nil, errors.New("metric must be points, completed or streak")
+	}
+
+	users, err := a.Store.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errNoUsers
+	}
+
+	now := a.now()
+	from := leaderboard.WeekStart(week.In(now.Location()))
+	to := from.AddDate(0, 0, 7)
+
+	entries := []leaderboard.Entry{}
+	for i := range users {
+		user := &users[i]
+		if user.Disabled {
+			continue
+		}
+		h, err := a.Handler(user)
+		if err != nil {
+			return nil, err
+		}
+		settings, err := h.DB.GetSettings()
+		if err != nil {
+			return nil, err
+		}
+		if !settings.Leaderboard {
+			continue
+		}
+
+		points, completed, err := a.totals(user, from, to)
+		if err != nil {
+			return nil, err
+		}
+		gamification, err := h.DB.GetGamification()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, leaderboard.Entry{
+			User:      user.Name,
+			Points:    points,
+			Completed: completed,
+			Streak:    leaderboard.ActiveStreak(gamification.CurrentStreak, gamification.LastCompletionDate, now),
+		})
+	}
+
+	return &Leaderboard{Metric: metric, Week: from, Entries: leaderboard.Rank(entries, metric)}, nil
+}
+
+// status works out the progress of a challenge. users maps IDs to users.
+func (a *Accounts) status(challenge *database.Challenge, users map[string]*database.User) (*ChallengeStatus, error) {
+	status := &ChallengeStatus{
+		ID:           challenge.ID,
+		Title:        challenge.Title,
+		Metric:       challenge.Metric,
+		Target:       challenge.Target,
+		Start:        challenge.Start,
+		Deadline:     challenge.Deadline,
+		Participants: []Contribution{},
+	}
+	if creator, ok := users[challenge.CreatedBy]; ok {
+		status.CreatedBy = creator.Name
+	}
+
+	for _, id := range challenge.Participants {
+		user, ok := users[id]
+		if !ok {
+			continue
+		}
+		points, completed, err := a.totals(user, challenge.Start, challenge.Deadline)
+		if err != nil {
+			return nil, err
+		}
+		value := points
+		if challenge.Metric == leaderboard.MetricCompleted {
+			value = completed
+		}
+		status.Progress += value
+		status.Participants = append(status.Participants, Contribution{User: user.Name, Value: value})
+	}
+
+	status.State = leaderboard.State(status.Progress, challenge.Target, challenge.Deadline, a.now())
+	return status, nil
+}
+
+// usersByID returns every user by ID
+func (a *Accounts) usersByID() (map[string]*database.User, error) {
+	users, err := a.Store.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*database.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	return byID, nil
+}
+
+// ChallengeStatuses returns every challenge with its progress, soonest
+// deadline first
+func (a *Accounts) ChallengeStatuses() ([]ChallengeStatus, error) {
+	challenges, err := a.Store.GetChallenges()
+	if err != nil {
+		return nil, err
+	}
+	users, err := a.usersByID()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []ChallengeStatus{}
+	for i := range challenges {
+		status, err := a.status(&challenges[i], users)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// challengeStatus returns one challenge with its progress
+func (a *Accounts) challengeStatus(challenge *database.Challenge) (*ChallengeStatus, error) {
+	users, err := a.usersByID()
+	if err != nil {
+		return nil, err
+	}
+	return a.status(challenge, users)
+}
+
+// CreateChallenge starts a challenge that runs from now until deadline. The
+// user who creates it joins it.
+func (a *Accounts) CreateChallenge(user *database.User, title, metric string, target int, deadline time.Time) (*ChallengeStatus, error) {
+	title = strings.TrimSpace(title)
+	if metric == "" {
+		metric = leaderboard.MetricPoints
+	}
+	switch {
+	case title == "":
+		return nil, errors.New("challenge title is required")
+	case !leaderboard.ValidChallengeMetric(metric):
+		return nil, errors.New("challenge metric must be points or completed")
+	case target <= 0:
+		return nil, errors.New("challenge target must be positive")
+	}
+
+	now := a.now()
+	if !deadline.After(now) {
+		return nil, errors.New("challenge deadline must be in the future")
+	}
+
+	challenge := &database.Challenge{
+		ID:           uuid.NewV4().String(),
+		Title:        title,
+		Metric:       metric,
+		Target:       target,
+		Start:        now,
+		Deadline:     deadline,
+		CreatedBy:    user.ID,
+		Participants: []string{user.ID},
+	}
+	if err := a.Store.SaveChallenge(challenge); err != nil {
+		return nil, err
+	}
+	return a.challengeStatus(challenge)
+}
+
+// Join adds user to the participants of a challenge
+func (a *Accounts) Join(user *database.User, id string) (*ChallengeStatus, error) {
+	return a.updateParticipants(id, func(participants []string) []string {
+		for _, participant := range participants {
+			if participant == user.ID {
+				return participants
+			}
+		}
+		return append(participants, user.ID)
+	})
+}
+
+// Leave removes user from the participants of a challenge. What they
+// completed no longer counts towards it.
+func (a *Accounts) Leave(user *database.User, id string) (*ChallengeStatus, error) {
+	return a.updateParticipants(id, func(participants []string) []string {
+		kept := []string{}
+		for _, participant := range participants {
+			if participant != user.ID {
+				kept = append(kept, participant)
+			}
+		}
+		return kept
+	})
+}
+
+func (a *Accounts) updateParticipants(id string, update func([]string) []string) (*ChallengeStatus, error) {
+	a.challenges.Lock()
+	defer a.challenges.Unlock()
+
+	challenge, err := a.Store.GetChallenge(id)
+	if err != nil {
+		return nil, err
+	}
+	challenge.Participants = update(challenge.Participants)
+	if err := a.Store.SaveChallenge(challenge); err != nil {
+		return nil, err
+	}
+	return a.challengeStatus(challenge)
+}
+
+// RemoveChallenge deletes a challenge created by user, or any challenge
+// when user is an admin
+func (a *Accounts) RemoveChallenge(user *database.User, id string) error {
+	a.challenges.Lock()
+	defer a.challenges.Unlock()
+
+	challenge, err := a.Store.GetChallenge(id)
+	if err != nil {
+		return err
+	}
+	if challenge.CreatedBy != user.ID && !user.Admin {
+		return errNotChallengeOwn
+	}
+	return a.Store.RemoveChallenge(id)
+}
+
+// member returns the user who made a request about the leaderboard or
+// challenges, responding with an error on a server without users
+func (a *Accounts) member(w http.ResponseWriter, r *http.Request) (*database.User, bool) {
+	identity, ok := a.identity(w, r)
+	if !ok {
+		return nil, false
+	}
+	if identity.User == nil {
+		http.Error(w, errNoUsers.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return identity.User, true
+}
+
+// GetLeaderboard responds with the ranking of the users who turned on the
+// leaderboard setting. Parameters: metric is points (default), completed or
+// streak, and week is any day of the week to rank, this week by default.
+func (a *Accounts) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := a.member(w, r)
+	if !ok {
+		return
+	}
+
+	week := a.now()
+	if value := r.URL.Query().Get("week"); value != "" {
+		h, err := a.Handler(user)
+		errHandler(err)
+		if week, err = h.parseHistoryTime(value, false); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	board, err := a.Leaderboard(r.URL.Query().Get("metric"), week)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, board)
+}
+
+// Challenges lists the challenges with their progress on GET, and creates
+// the challenge posted as JSON ({"title": "Ship it", "metric": "points",
+// "target": 500, "deadline": "2026-10-30"}) on POST. A deadline given as a
+// day lasts until the end of it.
+func (a *Accounts) Challenges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := a.member(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		statuses, err := a.ChallengeStatuses()
+		errHandler(err)
+		writeJSON(w, statuses)
+		return
+	}
+
+	var create struct {
+		Title    string `json:"title"`
+		Metric   string `json:"metric"`
+		Target   int    `json:"target"`
+		Deadline string `json:"deadline"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		http.Error(w, "Invalid challenge", http.StatusBadRequest)
+		return
+	}
+
+	if create.Deadline == "" {
+		http.Error(w, "challenge deadline is required", http.StatusBadRequest)
+		return
+	}
+	h, err := a.Handler(user)
+	errHandler(err)
+	deadline, err := h.parseHistoryTime(create.Deadline, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status, err := a.CreateChallenge(user, create.Title, create.Metric, create.Target, deadline)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s created challenge %q", user.Name, status.Title)
+	writeJSON(w, status)
+}
+
+// challengeRequest decodes the challenge ID posted as JSON ({"id": "..."})
+// for a user's request
+func (a *Accounts) challengeRequest(w http.ResponseWriter, r *http.Request) (*database.User, string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, "", false
+	}
+
+	user, ok := a.member(w, r)
+	if !ok {
+		return nil, "", false
+	}
+
+	var request struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid challenge", http.StatusBadRequest)
+		return nil, "", false
+	}
+	return user, request.ID, true
+}
+
+// writeChallenge responds with a challenge updated by a request, or the
+// error that stopped the update
+func writeChallenge(w http.ResponseWriter, status *ChallengeStatus, err error) {
+	if errors.Is(err, database.ErrChallengeNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	errHandler(err)
+	writeJSON(w, status)
+}
+
+// JoinChallenge adds the user to the challenge whose ID is posted as
+// JSON ({"id": "..."})
+func (a *Accounts) JoinChallenge(w http.ResponseWriter, r *http.Request) {
+	user, id, ok := a.challengeRequest(w, r)
+	if !ok {
+		return
+	}
+	status, err := a.Join(user, id)
+	writeChallenge(w, status, err)
+}
+
+// LeaveChallenge removes the user from the challenge whose ID is
+// posted as JSON ({"id": "..."})
+func (a *Accounts) LeaveChallenge(w http.ResponseWriter, r *http.Request) {
+	user, id, ok := a.challengeRequest(w, r)
+	if !ok {
+		return
+	}
+	status, err := a.Leave(user, id)
+	writeChallenge(w, status, err)
+}
+
+// DeleteChallenge removes the challenge whose ID is posted as JSON
+// ({"id": "..."}). Only its creator or an admin may remove it.
+func (a *Accounts) DeleteChallenge(w http.ResponseWriter, r *http.Request) {
+	user, id, ok := a.challengeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.RemoveChallenge(user, id)
+	switch {
+	case errors.Is(err, database.ErrChallengeNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, errNotChallengeOwn):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	errHandler(err)
+
+	log.Printf("User %s deleted challenge %s", user.Name, id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,440 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+	uuid "github.com/satori/go.uuid"
+)
+
+// A shared list is worked on by its members. Requests with the list's ID
+// or name in the list parameter or the X-Done-List header are served from
+// the list's handler instead of the user's, so every task route works on
+// shared lists, each with its own order. Tasks can be assigned to a member;
+// only the assignee completes an assigned task, and completing an
+// unassigned one assigns it to whoever completes it. The points go to the
+// assignee, and the task appears in both their report and the list's, in
+// ~/tasksReport/lists/<name>/.
+
+const listHeader = "X-Done-List"
+
+var (
+	errNotMember     = errors.New("you are not a member of this list")
+	errNotListOwner  = errors.New("only the creator of a list or an admin may change its members")
+	errAssignedOther = errors.New("the task is assigned to someone else")
+)
+
+// ListInfo is a shared list with its members named rather than identified
+type ListInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	Members   []string  `json:"members"`
+	Created   time.Time `json:"created"`
+}
+
+// listRef returns the shared list a request is about, if any
+func listRef(r *http.Request) string {
+	if ref := r.Header.Get(listHeader); ref != "" {
+		return ref
+	}
+	return r.URL.Query().Get("list")
+}
+
+// FindList returns the shared list with the given ID or name
+func (a *Accounts) FindList(ref string) (*database.SharedList, error) {
+	lists, err := a.Store.GetSharedLists()
+	if err != nil {
+		return nil, err
+	}
+	for i := range lists {
+		if lists[i].ID == ref || strings.EqualFold(lists[i].Name, ref) {
+			return &lists[i], nil
+		}
+	}
+	return nil, database.ErrListNotFound
+}
+
+// isMember reports whether user may work on list
+func isMember(list *database.SharedList, user *database.User) bool {
+	for _, id := range list.Members {
+		if id == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// ListHandler returns the handler of a shared list, creating it on first
+// use, after checking that user is one of its members
+func (a *Accounts) ListHandler(user *database.User, ref string) (*Handler, error) {
+	if user == nil {
+		return nil, errNoUsers
+	}
+	list, err := a.FindList(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember(list, user) {
+		return nil, errNotMember
+	}
+	return a.listHandler(list)
+}
+
+func (a *Accounts) listHandler(list *database.SharedList) (*Handler, error) {
+	key := "list/" + list.ID
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if h, ok := a.handlers[key]; ok {
+		return h, nil
+	}
+
+	db, err := a.Store.ForSharedList(list.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	h := NewHandler(db)
+	h.Location = a.Location
+	h.Hooks = a.Hooks
+	h.Mail = a.Mail
+	h.ReportName = filepath.Join("lists", list.Name)
+	h.credit = func(name string) (*Handler, error) {
+		user, err := a.Store.GetUserByName(name)
+		if err != nil {
+			return nil, err
+		}
+		return a.Handler(user)
+	}
+	a.watch(h, "", list.ID)
+	a.handlers[key] = h
+	return h, nil
+}
+
+// serveList returns the handler of the shared list a request is about,
+// responding with an error when the user may not work on it
+func (a *Accounts) serveList(w http.ResponseWriter, user *database.User, ref string) (*Handler, bool) {
+	h, err := a.ListHandler(user, ref)
+	switch {
+	case errors.Is(err, errNoUsers):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	case errors.Is(err, database.ErrListNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	case errors.Is(err, errNotMember):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, false
+	case err != nil:
+		log.Printf("Error opening shared list: %v", err)
+		http.Error(w, "Failed to open list", http.StatusInternalServerError)
+		return nil, false
+	}
+	return h, true
+}
+
+// claim checks that the named user may complete a task on a shared list.
+// An unassigned task is assigned to them, so they are credited once it is
+// completed; the assignment is saved with the completion.
+func (h *Handler) claim(task *database.Task, name string) error {
+	if h.credit == nil || name == "" {
+		return nil
+	}
+
+	switch {
+	case task.Assignee == "":
+		task.Assignee = name
+	case !strings.EqualFold(task.Assignee, name):
+		return fmt.Errorf("%w: %s", errAssignedOther, task.Assignee)
+	}
+	return nil
+}
+
+// Assign gives a task to the named user; an empty name unassigns it
+func (h *Handler) Assign(uuid, name string) (*database.Task, error) {
+	task, err := h.DB.GetTaskByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+	task.Assignee = name
+	if err := h.DB.UpdateTask(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// listInfo names the creator and members of list. users maps IDs to users.
+func listInfo(list *database.SharedList, users map[string]*database.User) *ListInfo {
+	info := &ListInfo{ID: list.ID, Name: list.Name, Members: []string{}, Created: list.Created}
+	if creator, ok := users[list.CreatedBy]; ok {
+		info.CreatedBy = creator.Name
+	}
+	for _, id := range list.Members {
+		if member, ok := users[id]; ok {
+			info.Members = append(info.Members, member.Name)
+		}
+	}
+	return info
+}
+
+// memberIDs resolves the names of members to user IDs, with creator first
+func (a *Accounts) memberIDs(creator string, names []string) ([]string, error) {
+	ids := []string{creator}
+	for _, name := range names {
+		user, err := a.Store.GetUserByName(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("user %q: %w", name, err)
+		}
+		if !contains(ids, user.ID) {
+			ids = append(ids, user.ID)
+		}
+	}
+	return ids, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SharedLists returns the shared lists user is a member of, or every list
+// for an admin
+func (a *Accounts) SharedLists(user *database.User) ([]ListInfo, error) {
+	lists, err := a.Store.GetSharedLists()
+	if err != nil {
+		return nil, err
+	}
+	users, err := a.usersByID()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []ListInfo{}
+	for i := range lists {
+		if user.Admin || isMember(&lists[i], user) {
+			infos = append(infos, *listInfo(&lists[i], users))
+		}
+	}
+	return infos, nil
+}
+
+// CreateList adds a shared list with user and the named members
+func (a *Accounts) CreateList(user *database.User, name string, members []string) (*ListInfo, error) {
+	if !userName.MatchString(name) {
+		return nil, errors.New("list names are up to 32 letters, digits, dots, dashes and underscores")
+	}
+	ids, err := a.memberIDs(user.ID, members)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &database.SharedList{
+		ID:        uuid.NewV4().String(),
+		Name:      name,
+		CreatedBy: user.ID,
+		Members:   ids,
+		Created:   time.Now(),
+	}
+	if err := a.Store.AddSharedList(list); err != nil {
+		return nil, err
+	}
+
+	users, err := a.usersByID()
+	if err != nil {
+		return nil, err
+	}
+	return listInfo(list, users), nil
+}
+
+// SetListMembers replaces the members of a shared list. Only its creator,
+// who always stays a member, or an admin may change them. Tasks assigned to
+// a member who is removed stay assigned until they are reassigned.
+func (a *Accounts) SetListMembers(user *database.User, ref string, members []string) (*ListInfo, error) {
+	list, err := a.FindList(ref)
+	if err != nil {
+		return nil, err
+	}
+	if list.CreatedBy != user.ID && !user.Admin {
+		return nil, errNotListOwner
+	}
+
+	ids, err := a.memberIDs(list.CreatedBy, members)
+	if err != nil {
+		return nil, err
+	}
+	list.Members = ids
+	if err := a.Store.UpdateSharedList(list); err != nil {
+		return nil, err
+	}
+
+	users, err := a.usersByID()
+	if err != nil {
+		return nil, err
+	}
+	return listInfo(list, users), nil
+}
+
+// Assign assigns a task on a shared list to one of its members, or
+// unassigns it when assignee is empty. Any member may assign tasks.
+func (a *Accounts) Assign(user *database.User, ref, uuid, assignee string) (*database.Task, error) {
+	h, err := a.ListHandler(user, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if assignee != "" {
+		list, err := a.FindList(ref)
+		if err != nil {
+			return nil, err
+		}
+		member, err := a.Store.GetUserByName(assignee)
+		if err != nil {
+			return nil, fmt.Errorf("user %q: %w", assignee, err)
+		}
+		if !isMember(list, member) {
+			return nil, fmt.Errorf("%s is not a member of %s", member.Name, list.Name)
+		}
+		assignee = member.Name
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+	return h.Assign(uuid, assignee)
+}
+
+// Lists lists the shared lists of the user on GET, and creates the list
+// posted as JSON ({"name": "release", "members": ["bob", "carol"]}) on
+// POST, with the user as a member
+func (a *Accounts) Lists(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := a.member(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		lists, err := a.SharedLists(user)
+		errHandler(err)
+		writeJSON(w, lists)
+		return
+	}
+
+	var create struct {
+		Name    string   `json:"name"`
+		Members []string `json:"members"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		http.Error(w, "Invalid list", http.StatusBadRequest)
+		return
+	}
+
+	list, err := a.CreateList(user, create.Name, create.Members)
+	if errors.Is(err, database.ErrListExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s created list %s", user.Name, list.Name)
+	writeJSON(w, list)
+}
+
+// UpdateListMembers replaces the members of the list posted as JSON
+// ({"list": "release", "members": ["bob"]})
+func (a *Accounts) UpdateListMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := a.member(w, r)
+	if !ok {
+		return
+	}
+
+	var update struct {
+		List    string   `json:"list"`
+		Members []string `json:"members"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid list", http.StatusBadRequest)
+		return
+	}
+
+	list, err := a.SetListMembers(user, update.List, update.Members)
+	switch {
+	case errors.Is(err, database.ErrListNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, errNotListOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("User %s set the members of list %s", user.Name, list.Name)
+	writeJSON(w, list)
+}
+
+// AssignTask assigns a task on the shared list given by the list
+// parameter to the member posted as JSON ({"uuid": "...", "assignee":
+// "bob"}), or unassigns it when assignee is empty. It responds with the
+// list's tasks.
+func (a *Accounts) AssignTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := a.member(w, r)
+	if !ok {
+		return
+	}
+	h, ok := a.serveList(w, user, listRef(r))
+	if !ok {
+		return
+	}
+
+	var assign struct {
+		UUID     string `json:"uuid"`
+		Assignee string `json:"assignee"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&assign); err != nil {
+		http.Error(w, "Invalid assignment", http.StatusBadRequest)
+		return
+	}
+
+	_, err := a.Assign(user, listRef(r), assign.UUID, assign.Assignee)
+	switch {
+	case errors.Is(err, database.ErrTaskNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.writeTasks(w)
+}
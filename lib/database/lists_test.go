@@ -0,0 +1,224 @@
+package database
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"done/lib/auth"
+	database "done/lib/database/interface"
+	"done/lib/mail"
+)
+
+// sharedList returns the accounts of a new store, closed when the test
+// ends, with the users ann, the owner, bob and cid, and the handler of ann
+// and bob's shared list "home"
+func sharedList(t *testing.T) (*Accounts, *Handler) {
+	t.Helper()
+	a := NewAccounts(openStore(t))
+	t.Cleanup(a.Close)
+	ann, err := a.AddUser("ann", true, "ann's password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bob", "cid"} {
+		if _, err := a.AddUser(name, false, name+"'s password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.CreateList(ann, "home", []string{"bob"}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := a.ListHandler(ann, "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, h
+}
+
+// user returns the named user of a
+func user(t *testing.T, a *Accounts, name string) *database.User {
+	t.Helper()
+	u, err := a.Store.GetUserByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestListMembers(t *testing.T) {
+	a, h := sharedList(t)
+	if err := h.DB.AddTask(&database.Task{UUID: "dishes", Body: "Do the dishes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	bobs, err := a.ListHandler(user(t, a, "bob"), "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bobs != h {
+		t.Error("members got different handlers of the list")
+	}
+	if _, err := a.ListHandler(user(t, a, "cid"), "home"); !errors.Is(err, errNotMember) {
+		t.Errorf("non-member: %v", err)
+	}
+
+	// The list's tasks are not in its members' own lists
+	own, err := a.Handler(user(t, a, "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks, err := own.DB.GetTasks(); err != nil || len(tasks) != 0 {
+		t.Errorf("bob's own tasks: %v (%v)", tasks, err)
+	}
+}
+
+func TestAssign(t *testing.T) {
+	a, h := sharedList(t)
+	if err := h.DB.AddTask(&database.Task{UUID: "dishes", Body: "Do the dishes"}); err != nil {
+		t.Fatal(err)
+	}
+	ann := user(t, a, "ann")
+
+	task, err := a.Assign(ann, "home", "dishes", "BOB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Assignee != "bob" {
+		t.Errorf("assignee = %q, want bob", task.Assignee)
+	}
+	if _, err := a.Assign(ann, "home", "dishes", "cid"); err == nil {
+		t.Error("assigned the task to a non-member")
+	}
+	if _, err := a.Assign(user(t, a, "cid"), "home", "dishes", "cid"); !errors.Is(err, errNotMember) {
+		t.Errorf("non-member assigning: %v", err)
+	}
+
+	if task, err = a.Assign(ann, "home", "dishes", ""); err != nil || task.Assignee != "" {
+		t.Errorf("unassigning: %+v (%v)", task, err)
+	}
+}
+
+func TestCompletionCreditsAssignee(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	a, h := sharedList(t)
+	if err := h.DB.AddTask(&database.Task{UUID: "dishes", Body: "Do the dishes", Assignee: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := h.Complete("dishes"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]int{"ann": 0, "bob": 1} {
+		own, err := a.Handler(user(t, a, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gamification, err := own.DB.GetGamification()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gamification.CompletedTasks != want {
+			t.Errorf("%s completed %d tasks, want %d", name, gamification.CompletedTasks, want)
+		}
+	}
+
+	for _, dir := range []string{"bob", filepath.Join("lists", "home")} {
+		reports, _ := filepath.Glob(filepath.Join(home, "tasksReport", dir, "*"))
+		found := false
+		for _, report := range reports {
+			data, err := os.ReadFile(report)
+			if err == nil && strings.Contains(string(data), "Do the dishes") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no report in %s lists the task", dir)
+		}
+	}
+}
+
+func TestClaimRefusesOthers(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // Completions are added to the report
+	a, h := sharedList(t)
+	if err := h.DB.AddTask(&database.Task{UUID: "dishes", Body: "Do the dishes", Assignee: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.AddTask(&database.Task{UUID: "bins", Body: "Take out the bins", Order: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := a.CreateToken(user(t, a, "ann"), "laptop", auth.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := apiRequest(a, (*Handler).CompleteTask, http.MethodPost, "/api/completeTask?list=home", "dishes", token.Secret); w.Code != http.StatusForbidden {
+		t.Errorf("completing bob's task: %d, want 403", w.Code)
+	}
+	if _, err := h.DB.GetTaskByUUID("dishes"); err != nil {
+		t.Errorf("bob's task is no longer open: %v", err)
+	}
+
+	// An unassigned task is claimed by whoever completes it
+	if w := apiRequest(a, (*Handler).CompleteTask, http.MethodPost, "/api/completeTask?list=home", "bins", token.Secret); w.Code != http.StatusOK {
+		t.Fatalf("completing an unassigned task: %d %s", w.Code, w.Body)
+	}
+	task, err := h.DB.GetCompletedTaskByUUID("bins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Assignee != "ann" {
+		t.Errorf("assignee = %q, want ann", task.Assignee)
+	}
+}
+
+func TestBlockedCompletionDoesNotClaim(t *testing.T) {
+	a, h := sharedList(t)
+	if err := h.DB.AddTask(&database.Task{UUID: "shop", Body: "Buy soap"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DB.AddTask(&database.Task{UUID: "dishes", Body: "Do the dishes", Order: 1, BlockedBy: []string{"shop"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := a.CreateToken(user(t, a, "ann"), "laptop", auth.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := apiRequest(a, (*Handler).CompleteTask, http.MethodPost, "/api/completeTask?list=home", "dishes", token.Secret); w.Code != http.StatusConflict {
+		t.Fatalf("completing a blocked task: %d, want 409", w.Code)
+	}
+	task, err := h.DB.GetTaskByUUID("dishes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Assignee != "" {
+		t.Errorf("refused completion claimed the task for %q", task.Assignee)
+	}
+}
+
+func TestListHandlerMails(t *testing.T) {
+	a := NewAccounts(openStore(t))
+	t.Cleanup(a.Close)
+	a.Mail = &mail.Sender{Addr: "localhost:25", From: "done@example.com"}
+	ann, err := a.AddUser("ann", true, "ann's password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.CreateList(ann, "home", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := a.ListHandler(ann, "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Mail != a.Mail {
+		t.Error("the list's reports cannot be emailed")
+	}
+}
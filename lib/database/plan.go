@@ -0,0 +1,259 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/planner"
+)
+
+// defaultPlanDays is how many days a plan covers unless asked otherwise
+const defaultPlanDays = 7
+
+// Status of a task in the comparison of a plan with what was done
+const (
+	PlanDone      = "done"      // Planned and completed
+	PlanOpen      = "open"      // Planned and still active
+	PlanDropped   = "dropped"   // Planned and moved to the trash
+	PlanUnplanned = "unplanned" // Completed without being planned
+)
+
+// PlanComparison compares the plan accepted for a day with the tasks that
+// were completed that day
+type PlanComparison struct {
+	Date           string        `json:"date"`
+	Accepted       time.Time     `json:"accepted"`
+	PlannedSeconds int           `json:"planned_seconds"`
+	ActualSeconds  int           `json:"actual_seconds"` // Time spent on the completed tasks
+	Tasks          []PlanOutcome `json:"tasks"`
+}
+
+// PlanOutcome is what became of one task of the plan
+type PlanOutcome struct {
+	UUID           string `json:"uuid"`
+	Body           string `json:"body"`
+	Status         string `json:"status"`
+	PlannedSeconds int    `json:"planned_seconds"`
+	ActualSeconds  int    `json:"actual_seconds"`
+}
+
+// Plan proposes which active tasks, in the order they are shown, fit into
+// the working hours of the coming days
+func (h *Handler) Plan(days int) (*planner.Plan, error) {
+	if days < 1 {
+		return nil, errors.New("a plan covers at least one day")
+	}
+
+	settings, err := h.DB.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := planner.NewConfig(settings.WorkingHours, settings.Busy)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := h.Tasks()
+	if err != nil {
+		return nil, err
+	}
+
+	return planner.Propose(tasks, cfg, h.now(), days), nil
+}
+
+// SavePlan accepts a plan by storing each of its days, replacing the plans
+// accepted before for those days
+func (h *Handler) SavePlan(plan *planner.Plan) error {
+	accepted := h.now()
+	for _, day := range plan.Days {
+		if _, err := time.Parse("2006-01-02", day.Date); err != nil {
+			return errors.New("invalid plan date " + strconv.Quote(day.Date))
+		}
+		dayPlan := database.DayPlan{
+			Date:            day.Date,
+			Accepted:        accepted,
+			CapacitySeconds: day.CapacitySeconds,
+			Tasks:           day.Tasks,
+		}
+		if err := h.DB.SaveDayPlan(&dayPlan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ComparePlan compares the plan accepted for a day, given as 2006-01-02,
+// with the tasks completed that day. It returns ErrPlanNotFound when no plan
+// was accepted.
+func (h *Handler) ComparePlan(date string) (*PlanComparison, error) {
+	plan, err := h.DB.GetDayPlan(date)
+	if err != nil {
+		return nil, err
+	}
+
+	completed, err := h.DB.GetCompletedTasks()
+	if err != nil {
+		return nil, err
+	}
+	active, err := h.DB.GetTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	completedByUUID := make(map[string]*database.Task, len(completed))
+	for i := range completed {
+		completedByUUID[completed[i].UUID] = &completed[i]
+	}
+	activeByUUID := make(map[string]*database.Task, len(active))
+	for i := range active {
+		activeByUUID[active[i].UUID] = &active[i]
+	}
+
+	comparison := &PlanComparison{Date: plan.Date, Accepted: plan.Accepted, Tasks: []PlanOutcome{}}
+
+	// A task split around busy time is planned in several parts
+	index := make(map[string]int)
+	for _, part := range plan.Tasks {
+		comparison.PlannedSeconds += part.Seconds
+		if i, ok := index[part.UUID]; ok {
+			comparison.Tasks[i].PlannedSeconds += part.Seconds
+			continue
+		}
+		index[part.UUID] = len(comparison.Tasks)
+
+		outcome := PlanOutcome{UUID: part.UUID, Body: part.Body, Status: PlanDropped, PlannedSeconds: part.Seconds}
+		if task, ok := completedByUUID[part.UUID]; ok {
+			outcome.Status = PlanDone
+			outcome.ActualSeconds = task.DurationExecutionRealSeconds
+			comparison.ActualSeconds += task.DurationExecutionRealSeconds
+		} else if task, ok := activeByUUID[part.UUID]; ok {
+			outcome.Status = PlanOpen
+			outcome.ActualSeconds = task.DurationExecutionRealSeconds
+		}
+		comparison.Tasks = append(comparison.Tasks, outcome)
+	}
+
+	for _, task := range completed {
+		if _, planned := index[task.UUID]; planned || task.TimeCompleted.In(h.now().Location()).Format("2006-01-02") != date {
+			continue
+		}
+		comparison.Tasks = append(comparison.Tasks, PlanOutcome{
+			UUID:          task.UUID,
+			Body:          task.Body,
+			Status:        PlanUnplanned,
+			ActualSeconds: task.DurationExecutionRealSeconds,
+		})
+		comparison.ActualSeconds += task.DurationExecutionRealSeconds
+	}
+
+	return comparison, nil
+}
+
+// planDays reads the days query parameter of plan requests
+func planDays(r *http.Request) (int, error) {
+	param := r.URL.Query().Get("days")
+	if param == "" {
+		return defaultPlanDays, nil
+	}
+	days, err := strconv.Atoi(param)
+	if err != nil || days < 1 || days > 31 {
+		return 0, errors.New("days must be between 1 and 31")
+	}
+	return days, nil
+}
+
+// GetPlan responds with a proposed plan for the number of days in the days
+// query parameter, 7 by default
+func (h *Handler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	days, err := planDays(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.Plan(days)
+	if err != nil {
+		log.Printf("Error planning: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// AcceptPlan stores a posted plan, as returned by GetPlan, or a fresh
+// proposal when the body is empty, and responds with the stored plan
+func (h *Handler) AcceptPlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var plan *planner.Plan
+	if len(body) == 0 {
+		days, err := planDays(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if plan, err = h.Plan(days); err != nil {
+			log.Printf("Error planning: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if err := json.Unmarshal(body, &plan); err != nil || plan == nil {
+		http.Error(w, "Invalid plan", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.SavePlan(plan); err != nil {
+		log.Printf("Error accepting plan: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// GetPlanReport responds with the comparison of the plan accepted for the
+// day in the date query parameter, today by default, with what was done
+func (h *Handler) GetPlanReport(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = h.now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	comparison, err := h.ComparePlan(date)
+	if err == database.ErrPlanNotFound {
+		http.Error(w, "No plan accepted for "+date, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error comparing plan: %v", err)
+		http.Error(w, "Failed to compare plan", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(comparison)
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/quickadd"
+)
+
+// now returns the current time in the configured time zone
+func (h *Handler) now() time.Time {
+	if h.Location != nil {
+		return time.Now().In(h.Location)
+	}
+	return time.Now()
+}
+
+// ParseLine reads a quick-add line in the configured time zone
+func (h *Handler) ParseLine(line string) *quickadd.Parsed {
+	return quickadd.Parse(line, h.now())
+}
+
+// QuickAdd creates a task from a quick-add line like
+// "Write RFC draft ~2h30m due fri #docs !high"
+func (h *Handler) QuickAdd(line string) (*database.Task, error) {
+	task := h.ParseLine(line).Task()
+	if task.Body == "" {
+		return nil, errors.New("task text is required")
+	}
+
+	if err := h.Create(&task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// readLine reads a plain text quick-add line from the request body
+func readLine(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return "", false
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return "", false
+	}
+	defer r.Body.Close()
+
+	return strings.TrimSpace(string(body)), true
+}
+
+// ParseTask previews a quick-add line posted as plain text and responds with
+// its parsed fields without creating a task
+func (h *Handler) ParseTask(w http.ResponseWriter, r *http.Request) {
+	line, ok := readLine(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(h.ParseLine(line))
+}
+
+// QuickAddTask creates a task from a quick-add line posted as plain text and
+// responds with the task list
+func (h *Handler) QuickAddTask(w http.ResponseWriter, r *http.Request) {
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	line, ok := readLine(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := h.QuickAdd(line)
+	if err != nil {
+		log.Printf("Error adding task: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.record(w, r, &addOperation{task: *task})
+
+	h.writeTasks(w)
+}
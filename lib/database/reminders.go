@@ -0,0 +1,396 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/mail"
+	"done/lib/notify"
+	"done/lib/reminder"
+)
+
+// Reminders warn of hard deadlines. RunReminders checks the tasks of every
+// user and shared list, and sends the reminders that are due through the
+// channels in each user's settings, each by the Notifier of the channel.
+// A task on a shared list reminds its assignee, or every member when it is
+// unassigned, and each of them may snooze it.
+
+// defaultSnooze is how long a reminder is snoozed for when no time is given
+const defaultSnooze = time.Hour
+
+var errNoDeadline = errors.New("the task has no deadline")
+
+// Reminder is a reminder of a task's deadline sent to a user
+type Reminder struct {
+	Kind     string         `json:"kind"` // upcoming or overdue
+	Text     string         `json:"text"`
+	Deadline time.Time      `json:"deadline"`
+	Task     *database.Task `json:"task"`
+	User     string         `json:"user,omitempty"` // Name of the user reminded
+	List     string         `json:"list,omitempty"` // Name of the task's shared list
+	Email    string         `json:"-"`              // Address in the user's settings
+
+	handler *Handler // The user's handler
+}
+
+// Notifier sends reminders through one channel
+type Notifier interface {
+	Notify(reminder *Reminder) error
+}
+
+// NotifierFunc adapts a function to a Notifier
+type NotifierFunc func(reminder *Reminder) error
+
+func (f NotifierFunc) Notify(reminder *Reminder) error {
+	return f(reminder)
+}
+
+// eventsNotifier publishes reminders on the user's live updates, which the
+// web UI shows and webhooks and hooks are sent
+var eventsNotifier = NotifierFunc(func(reminder *Reminder) error {
+	reminder.handler.bus.Publish(EventReminder, reminder)
+	return nil
+})
+
+// desktopNotifier shows reminders on the desktop of the machine Done runs on
+var desktopNotifier = NotifierFunc(func(reminder *Reminder) error {
+	return notify.Desktop("Done", reminder.Text)
+})
+
+// EmailNotifier emails reminders to the address in the user's settings
+func EmailNotifier(sender *mail.Sender) Notifier {
+	return NotifierFunc(func(reminder *Reminder) error {
+		if reminder.Email == "" {
+			return errors.New("no email address in the settings")
+		}
+		text := reminder.Text + "\n\nDeadline: " + reminder.Deadline.Format("Mon, 2 Jan 2006 15:04")
+		if reminder.List != "" {
+			text += "\nList: " + reminder.List
+		}
+		return sender.Send(&mail.Message{
+			To:      reminder.Email,
+			Subject: reminder.Text,
+			Text:    text + "\n",
+		})
+	})
+}
+
+// checkReminderSettings returns an error unless the reminders can be sent
+// as the settings say
+func checkReminderSettings(settings *database.ReminderSettings) error {
+	if _, err := reminder.ParseOffsets(settings.Offsets); err != nil {
+		return err
+	}
+	for _, channel := range settings.Channels {
+		switch channel {
+		case database.NotifyEvents, database.NotifyDesktop, database.NotifyEmail:
+		default:
+			return fmt.Errorf("unknown reminder channel %q: use events, desktop or email", channel)
+		}
+	}
+	return nil
+}
+
+// RunReminders sends the reminders that are due every interval until Close
+// is called
+func (a *Accounts) RunReminders(interval time.Duration) {
+	for {
+		if err := a.SendReminders(a.now()); err != nil {
+			log.Printf("Error sending reminders: %v", err)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// SendReminders sends the reminders due at now to every user. A user or
+// shared list whose reminders fail is logged and skipped, so the others are
+// still reminded.
+func (a *Accounts) SendReminders(now time.Time) error {
+	users, err := a.Store.GetUsers()
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		// Everyone shares the one list
+		h, err := a.Handler(nil)
+		if err != nil {
+			return err
+		}
+		return a.remind(h, nil, func(*database.Task) []*database.User { return []*database.User{nil} }, now)
+	}
+
+	byID := make(map[string]*database.User)
+	for i := range users {
+		user := &users[i]
+		byID[user.ID] = user
+		if user.Disabled {
+			continue
+		}
+		h, err := a.Handler(user)
+		if err != nil {
+			log.Printf("Error opening tasks of %s: %v", user.Name, err)
+			continue
+		}
+		if err := a.remind(h, nil, func(*database.Task) []*database.User { return []*database.User{user} }, now); err != nil {
+			log.Printf("Error reminding %s: %v", user.Name, err)
+		}
+	}
+
+	lists, err := a.Store.GetSharedLists()
+	if err != nil {
+		return err
+	}
+	for i := range lists {
+		list := &lists[i]
+		h, err := a.listHandler(list)
+		if err != nil {
+			log.Printf("Error opening list %s: %v", list.Name, err)
+			continue
+		}
+		recipients := func(task *database.Task) []*database.User {
+			var members []*database.User
+			for _, id := range list.Members {
+				user := byID[id]
+				if user == nil || user.Disabled {
+					continue
+				}
+				if task.Assignee == "" || strings.EqualFold(task.Assignee, user.Name) {
+					members = append(members, user)
+				}
+			}
+			return members
+		}
+		if err := a.remind(h, list, recipients, now); err != nil {
+			log.Printf("Error reminding members of list %s: %v", list.Name, err)
+		}
+	}
+	return nil
+}
+
+// reminderKey is the key of the reminder state of a task for a user. Each
+// member of a shared list is reminded, and snoozes, on their own.
+func reminderKey(uuid string, list *database.SharedList, user *database.User) string {
+	if list == nil || user == nil {
+		return uuid
+	}
+	return uuid + "/" + user.ID
+}
+
+// remind sends the reminders due at now of the tasks of h, the handler of
+// a user or of a shared list, to the users recipients returns for each
+// task. It forgets the state of tasks that no longer have a deadline. A
+// member of a shared list whose settings cannot be read, or a reminder whose
+// state cannot be saved, is logged and skipped.
+func (a *Accounts) remind(h *Handler, list *database.SharedList, recipients func(*database.Task) []*database.User, now time.Time) error {
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		return err
+	}
+	states, err := h.DB.GetReminderStates()
+	if err != nil {
+		return err
+	}
+
+	// The handler and settings of each user reminded
+	type inbox struct {
+		h        *Handler
+		settings *database.Settings
+		offsets  []time.Duration
+	}
+	inboxes := make(map[*database.User]*inbox)
+	inboxOf := func(user *database.User) (*inbox, error) {
+		if in, ok := inboxes[user]; ok {
+			// nil when it could not be read, which was reported the first time
+			return in, nil
+		}
+		inboxes[user] = nil
+		uh := h
+		if list != nil {
+			var err error
+			if uh, err = a.Handler(user); err != nil {
+				return nil, err
+			}
+		}
+		settings, err := uh.DB.GetSettings()
+		if err != nil {
+			return nil, err
+		}
+		offsets, err := reminder.ParseOffsets(settings.Reminders.Offsets)
+		if err != nil {
+			return nil, err
+		}
+		in := &inbox{h: uh, settings: settings, offsets: offsets}
+		inboxes[user] = in
+		return in, nil
+	}
+
+	current := make(map[string]bool)
+	for i := range tasks {
+		task := &tasks[i]
+		deadline, ok := task.HardDeadline()
+		if !ok {
+			continue
+		}
+
+		for _, user := range recipients(task) {
+			key := reminderKey(task.UUID, list, user)
+			current[key] = true
+
+			in, err := inboxOf(user)
+			if err != nil {
+				log.Printf("Error reading reminder settings of %s: %v", userLabel(user), err)
+			}
+			if in == nil || !in.settings.Reminders.Enabled {
+				continue
+			}
+
+			state := states[key]
+			kind, due := reminder.Check(deadline, in.offsets, in.settings.Reminders.Overdue, &state, now)
+			if !due {
+				continue
+			}
+
+			r := &Reminder{
+				Kind:     kind,
+				Text:     reminder.Text(kind, firstLine(published(task).Body), deadline, now),
+				Deadline: deadline,
+				Task:     published(task),
+				Email:    in.settings.Email,
+				handler:  in.h,
+			}
+			if user != nil {
+				r.User = user.Name
+			}
+			if list != nil {
+				r.List = list.Name
+			}
+			a.notify(r, in.settings.Reminders.Channels)
+
+			if err := h.DB.SaveReminderState(key, &state); err != nil {
+				log.Printf("Error saving reminder state of %q: %v", firstLine(task.Body), err)
+			}
+		}
+	}
+
+	for key := range states {
+		if !current[key] {
+			if err := h.DB.RemoveReminderState(key); err != nil {
+				log.Printf("Error forgetting reminder state %s: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// userLabel names a user in the log; nil is everyone on a server without
+// users
+func userLabel(user *database.User) string {
+	if user == nil {
+		return "the server"
+	}
+	return user.Name
+}
+
+// notify sends a reminder through each of the channels
+func (a *Accounts) notify(r *Reminder, channels []string) {
+	for _, channel := range channels {
+		notifier, ok := a.Notifiers[channel]
+		if !ok {
+			log.Printf("Cannot send reminder of %q: no %s notifier is configured", firstLine(r.Task.Body), channel)
+			continue
+		}
+		if err := notifier.Notify(r); err != nil {
+			log.Printf("Error sending reminder of %q by %s: %v", firstLine(r.Task.Body), channel, err)
+		}
+	}
+}
+
+// Snooze holds off the reminders of a task for user until until, when they
+// are reminded once more. On a handler without users, user is nil.
+func (h *Handler) Snooze(uuid string, user *database.User, until time.Time) error {
+	task, err := h.DB.GetTaskByUUID(uuid)
+	if err != nil {
+		return err
+	}
+	deadline, ok := task.HardDeadline()
+	if !ok {
+		return errNoDeadline
+	}
+
+	key := uuid
+	if h.credit != nil {
+		if user == nil {
+			return errNoUsers
+		}
+		key = uuid + "/" + user.ID
+	}
+
+	state, err := h.DB.GetReminderState(key)
+	if err != nil {
+		return err
+	}
+	reminder.Snooze(state, deadline, until)
+	return h.DB.SaveReminderState(key, state)
+}
+
+// SnoozeReminder snoozes the reminders of the task posted as JSON
+// ({"uuid": "...", "for": "2h"} or {"uuid": "...", "until": "RFC 3339"}),
+// for an hour when neither is given
+func (h *Handler) SnoozeReminder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var snooze struct {
+		UUID  string     `json:"uuid"`
+		For   string     `json:"for"`
+		Until *time.Time `json:"until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&snooze); err != nil {
+		http.Error(w, "Invalid snooze", http.StatusBadRequest)
+		return
+	}
+
+	until := time.Now().Add(defaultSnooze)
+	switch {
+	case snooze.Until != nil:
+		until = *snooze.Until
+	case snooze.For != "":
+		d, err := reminder.ParseOffset(snooze.For)
+		if err != nil {
+			http.Error(w, "snooze for a duration like 1d, 2h or 30m", http.StatusBadRequest)
+			return
+		}
+		until = time.Now().Add(d)
+	}
+
+	var user *database.User
+	if identity, ok := identityOf(r); ok {
+		user = identity.User
+	}
+
+	err := h.Snooze(snooze.UUID, user, until)
+	switch {
+	case errors.Is(err, database.ErrTaskNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, errNoDeadline), errors.Is(err, errNoUsers):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	errHandler(err)
+
+	writeJSON(w, map[string]time.Time{"until": until})
+}
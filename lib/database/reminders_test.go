@@ -0,0 +1,110 @@
+package database
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// recordReminders makes the events channel of a record who is reminded of
+// which task, as "user/uuid", and returns a function that takes the reminders
+// sent since it was last called
+func recordReminders(a *Accounts) func() []string {
+	var sent []string
+	a.Notifiers[database.NotifyEvents] = NotifierFunc(func(r *Reminder) error {
+		sent = append(sent, r.User+"/"+r.Task.UUID)
+		return nil
+	})
+	return func() []string {
+		taken := sent
+		sent = nil
+		sort.Strings(taken)
+		return taken
+	}
+}
+
+// remindAt sends the reminders due at now and returns those sent
+func remindAt(t *testing.T, a *Accounts, sent func() []string, now time.Time) []string {
+	t.Helper()
+	if err := a.SendReminders(now); err != nil {
+		t.Fatal(err)
+	}
+	return sent()
+}
+
+func TestListReminders(t *testing.T) {
+	a, h := sharedList(t)
+	sent := recordReminders(a)
+	now := time.Now()
+	deadline := now.Add(2 * time.Hour)
+	if err := h.DB.AddTask(&database.Task{UUID: "dishes", Body: "Do the dishes", TimeHardDeadline: &deadline, DeadlineHasTime: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := remindAt(t, a, sent, now), []string{"ann/dishes", "bob/dishes"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("a day before: %v, want %v", got, want)
+	}
+	states, err := h.DB.GetReminderStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ann", "bob"} {
+		if _, ok := states["dishes/"+user(t, a, name).ID]; !ok {
+			t.Errorf("no reminder state of %s in %v", name, states)
+		}
+	}
+
+	if err := h.Snooze("dishes", user(t, a, "bob"), now.Add(90*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := remindAt(t, a, sent, now.Add(70*time.Minute)), []string{"ann/dishes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("an hour before, bob snoozed: %v, want %v", got, want)
+	}
+	if got, want := remindAt(t, a, sent, now.Add(90*time.Minute)), []string{"bob/dishes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bob's snooze over: %v, want %v", got, want)
+	}
+
+	if _, err := h.Update("dishes", &TaskPatch{TimeHardDeadline: NullableTime{Set: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := remindAt(t, a, sent, now.Add(3*time.Hour)); len(got) != 0 {
+		t.Errorf("reminded without a deadline: %v", got)
+	}
+	if states, err := h.DB.GetReminderStates(); err != nil || len(states) != 0 {
+		t.Errorf("states left without a deadline: %v (%v)", states, err)
+	}
+}
+
+func TestRemindersGoOnPastFailingUser(t *testing.T) {
+	a, h := sharedList(t)
+	sent := recordReminders(a)
+	now := time.Now()
+	deadline := now.Add(30 * time.Minute)
+	if err := h.DB.AddTask(&database.Task{UUID: "dishes", Body: "Do the dishes", TimeHardDeadline: &deadline, DeadlineHasTime: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	bobs, err := a.Handler(user(t, a, "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := database.DefaultSettings()
+	settings.Reminders.Offsets = []string{"soon"}
+	if err := bobs.DB.UpdateSettings(&settings); err != nil {
+		t.Fatal(err)
+	}
+	cids, err := a.Handler(user(t, a, "cid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cids.DB.AddTask(&database.Task{UUID: "taxes", Body: "File the taxes", TimeHardDeadline: &deadline, DeadlineHasTime: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := remindAt(t, a, sent, now), []string{"ann/dishes", "cid/taxes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bob's settings unreadable: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	database "done/lib/database/interface"
+	"done/lib/utils"
+)
+
+// reportDirectory returns ~/tasksReport, or the user's directory in it,
+// creating it if needed
+func (h *Handler) reportDirectory() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Printf("Error getting home directory: %v", err)
+		homeDir = "."
+	}
+
+	reportDir := filepath.Join(homeDir, "tasksReport", h.ReportName)
+	err = os.MkdirAll(reportDir, 0755)
+	if err != nil {
+		log.Printf("Error creating report directory: %v", err)
+	}
+
+	return reportDir
+}
+
+// dayReportPath returns the per-day report file for the day task was completed
+func (h *Handler) dayReportPath(task *database.Task) string {
+	day := task.TimeCompleted.Format("02")
+	month := task.TimeCompleted.Format("Jan")
+	year := task.TimeCompleted.Format("2006")
+
+	return filepath.Join(h.reportDirectory(), year+"-"+month+"-"+day+".html")
+}
+
+// appendTaskReport adds a completed task to its per-day report file in
+// ~/tasksReport/
+func (h *Handler) appendTaskReport(task *database.Task) {
+	day := task.TimeCompleted.Format("02")
+	month := task.TimeCompleted.Format("Jan")
+	year := task.TimeCompleted.Format("2006")
+
+	filenameComplete := h.dayReportPath(task)
+
+	f, err := os.OpenFile(filenameComplete, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0660)
+	if err != nil {
+		log.Printf("Error opening report file: %v", err)
+		// Continue even if file save fails
+		return
+	}
+	defer f.Close()
+
+	finfo, err := f.Stat()
+	if err == nil && finfo.Size() == 0 {
+		// Write HTML header with modern styling
+		header := `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Task Report - ` + year + `-` + month + `-` + day + `</title>
+<style>
+body {
+    font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Helvetica, Arial, sans-serif;
+    margin: 0;
+    padding: 20px;
+    background: #0a0e27;
+    color: #ffffff;
+    line-height: 1.6;
+}
+.container {
+    max-width: 800px;
+    margin: 0 auto;
+}
+h1 {
+    color: #00ff41;
+    font-size: 28px;
+    margin-bottom: 30px;
+    padding-bottom: 10px;
+    border-bottom: 2px solid #00ff41;
+}
+.task-item {
+    background: rgba(255, 255, 255, 0.05);
+    border: 1px solid rgba(255, 255, 255, 0.1);
+    border-radius: 8px;
+    padding: 15px 20px;
+    margin-bottom: 10px;
+    transition: all 0.3s ease;
+}
+.task-item:hover {
+    background: rgba(255, 255, 255, 0.08);
+    border-color: #00ff41;
+}
+.task-time {
+    color: #00ff41;
+    font-size: 12px;
+    font-weight: 600;
+    margin-bottom: 5px;
+}
+.task-body {
+    color: #ffffff;
+    font-size: 16px;
+}
+.task-duration {
+    color: #888;
+    font-size: 14px;
+    margin-top: 8px;
+}
+.task-deadline {
+    color: #ffd700;
+    font-size: 14px;
+    margin-top: 4px;
+}
+.summary {
+    background: rgba(0, 255, 65, 0.1);
+    border: 2px solid #00ff41;
+    border-radius: 8px;
+    padding: 20px;
+    margin-top: 30px;
+}
+.summary h2 {
+    color: #00ff41;
+    margin-top: 0;
+}
+</style>
+</head>
+<body>
+<div class="container">
+<h1>📋 Task Report - ` + year + `-` + month + `-` + day + `</h1>
+`
+		io.WriteString(f, header)
+	}
+
+	// Format task completion time and duration
+	hours := task.DurationExecutionRealSeconds / 3600
+	minutes := (task.DurationExecutionRealSeconds % 3600) / 60
+	seconds := task.DurationExecutionRealSeconds % 60
+	
+	durationStr := ""
+	if hours > 0 {
+		durationStr = fmt.Sprintf("%dh %dm %ds", hours, minutes, seconds)
+	} else if minutes > 0 {
+		durationStr = fmt.Sprintf("%dm %ds", minutes, seconds)
+	} else {
+		durationStr = fmt.Sprintf("%ds", seconds)
+	}
+
+	// Clean task body to remove any encoding artifacts
+	cleanBody := utils.CleanTaskText(task.Body)
+	
+	taskHTML := fmt.Sprintf(`<div class="task-item" data-uuid="%s">
+    <div class="task-time">✅ Completed at %s</div>
+    <div class="task-body">%s</div>
+    <div class="task-duration">⏱️ Time spent: %s</div>
+%s</div>
+`, task.UUID, task.TimeCompleted.Format("15:04:05"), cleanBody, durationStr, outcomeHTML(task.DeadlineOutcome))
+
+	io.WriteString(f, taskHTML)
+}
+
+// removeTaskReport deletes the entry of a completed task from its per-day
+// report file
+func (h *Handler) removeTaskReport(task *database.Task) {
+	filename := h.dayReportPath(task)
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Printf("Error reading report file: %v", err)
+		return
+	}
+
+	start := bytes.Index(content, []byte(`<div class="task-item" data-uuid="`+task.UUID+`">`))
+	if start < 0 {
+		log.Printf("Task %s not found in report %s", task.UUID, filename)
+		return
+	}
+
+	entryEnd := []byte("\n</div>\n")
+	end := bytes.Index(content[start:], entryEnd)
+	if end < 0 {
+		log.Printf("Malformed report entry for task %s in %s", task.UUID, filename)
+		return
+	}
+	end += start + len(entryEnd)
+
+	content = append(content[:start], content[end:]...)
+	err = ioutil.WriteFile(filename, content, 0660)
+	if err != nil {
+		log.Printf("Error writing report file: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	database "done/lib/database/interface"
+)
+
+// Responses with the task list carry the revision of the list as their ETag,
+// and every task carries its own revision. A change sent with an If-Match
+// header is refused with 409 Conflict and the current task list unless the
+// ETag is still current, so a client cannot overwrite a change it has not
+// seen. Moving tasks is checked against the list; changing, completing or
+// removing a task against the task, or the list.
+
+// listETag returns the ETag of the task list at a revision
+func listETag(revision uint64) string {
+	return fmt.Sprintf(`"tasks-%d"`, revision)
+}
+
+// taskETag returns the ETag of a task at its revision
+func taskETag(task *database.Task) string {
+	return fmt.Sprintf(`"task-%d"`, task.Revision)
+}
+
+// ifMatch reports whether the If-Match header of r names one of the current
+// ETags. A request without the header is unconditional.
+func ifMatch(r *http.Request, current ...string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	for _, etag := range strings.Split(header, ",") {
+		etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+		if etag == "*" {
+			return true
+		}
+		for _, c := range current {
+			if etag == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkList checks a conditional change to the order of the list. When the
+// client is out of date it responds with 409 Conflict and the current list,
+// and returns false.
+func (h *Handler) checkList(w http.ResponseWriter, r *http.Request) bool {
+	revision, err := h.DB.TasksRevision()
+	errHandler(err)
+
+	if ifMatch(r, listETag(revision)) {
+		return true
+	}
+	h.writeTasksStatus(w, http.StatusConflict)
+	return false
+}
+
+// checkTask checks a conditional change to a task, like checkList
+func (h *Handler) checkTask(w http.ResponseWriter, r *http.Request, uuid string) bool {
+	if r.Header.Get("If-Match") == "" {
+		return true
+	}
+
+	revision, err := h.DB.TasksRevision()
+	errHandler(err)
+
+	task, err := h.DB.GetTaskByUUID(uuid)
+	if errors.Is(err, database.ErrTaskNotFound) {
+		// Left to the change, which reports the missing task
+		return true
+	}
+	errHandler(err)
+
+	if ifMatch(r, listETag(revision), taskETag(task)) {
+		return true
+	}
+	h.writeTasksStatus(w, http.StatusConflict)
+	return false
+}
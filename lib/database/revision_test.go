@@ -0,0 +1,127 @@
+package database
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	database "done/lib/database/interface"
+)
+
+// serve sends a request to a handler function with an optional If-Match
+// header
+func serve(handler http.HandlerFunc, method, body, etag string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	if etag != "" {
+		r.Header.Set("If-Match", etag)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// revisionHandler returns a handler of a new store with two tasks, a and b
+func revisionHandler(t *testing.T) *Handler {
+	t.Helper()
+	h := NewHandler(openStore(t))
+	for i, uuid := range []string{"a", "b"} {
+		if err := h.DB.AddTask(&database.Task{UUID: uuid, Body: "Task " + uuid, Order: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return h
+}
+
+func TestStaleTaskETag(t *testing.T) {
+	h := revisionHandler(t)
+	task, err := h.DB.GetTaskByUUID("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := taskETag(task)
+
+	if w := serve(h.UpdateTask, http.MethodPatch, `{"uuid":"a","body":"First"}`, stale); w.Code != http.StatusOK {
+		t.Fatalf("current ETag: got %d", w.Code)
+	}
+	w := serve(h.UpdateTask, http.MethodPatch, `{"uuid":"a","body":"Second"}`, stale)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("stale ETag: got %d, want 409", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"First"`) || w.Header().Get("ETag") == "" {
+		t.Errorf("conflict did not send the current list: %s", w.Body)
+	}
+	if task, _ := h.DB.GetTaskByUUID("a"); task.Body != "First" {
+		t.Errorf("body = %q, want the first change kept", task.Body)
+	}
+
+	// Changing another task leaves the task's own ETag current
+	current := serve(h.GetTasks, http.MethodGet, "", "").Header().Get("ETag")
+	task, _ = h.DB.GetTaskByUUID("a")
+	if w := serve(h.UpdateTask, http.MethodPatch, `{"uuid":"b","body":"Other"}`, current); w.Code != http.StatusOK {
+		t.Fatalf("list ETag: got %d", w.Code)
+	}
+	if w := serve(h.RemoveTask, http.MethodPost, "a", taskETag(task)); w.Code != http.StatusOK {
+		t.Errorf("task ETag after another task changed: got %d", w.Code)
+	}
+}
+
+func TestStaleListETagOnRearrange(t *testing.T) {
+	h := revisionHandler(t)
+	stale := serve(h.GetTasks, http.MethodGet, "", "").Header().Get("ETag")
+
+	if w := serve(h.UpdateTask, http.MethodPatch, `{"uuid":"b","body":"Changed"}`, ""); w.Code != http.StatusOK {
+		t.Fatalf("update: got %d", w.Code)
+	}
+	if w := serve(h.RearrangeTasks, http.MethodPost, "b,a", stale); w.Code != http.StatusConflict {
+		t.Fatalf("stale list ETag: got %d, want 409", w.Code)
+	}
+	tasks, err := h.Tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks[0].UUID != "a" {
+		t.Errorf("refused move was applied: first task is %s", tasks[0].UUID)
+	}
+
+	current := serve(h.GetTasks, http.MethodGet, "", "").Header().Get("ETag")
+	if w := serve(h.RearrangeTasks, http.MethodPost, "b,a", current); w.Code != http.StatusOK {
+		t.Errorf("current list ETag: got %d", w.Code)
+	}
+}
+
+func TestTimerKeepsETag(t *testing.T) {
+	h := revisionHandler(t)
+	before := serve(h.GetTasks, http.MethodGet, "", "").Header().Get("ETag")
+	task, err := h.DB.GetTaskByUUID("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(h.UpdateTaskExecutionRealSeconds, http.MethodPost, "a$;90", ""); w.Code != http.StatusOK {
+		t.Fatalf("timer update: got %d", w.Code)
+	}
+	if after := serve(h.GetTasks, http.MethodGet, "", "").Header().Get("ETag"); after != before {
+		t.Errorf("list ETag changed from %s to %s", before, after)
+	}
+	if w := serve(h.UpdateTask, http.MethodPatch, `{"uuid":"a","body":"Changed"}`, taskETag(task)); w.Code != http.StatusOK {
+		t.Errorf("task ETag after a timer update: got %d", w.Code)
+	}
+}
+
+func TestIfMatchAny(t *testing.T) {
+	h := revisionHandler(t)
+	if w := serve(h.UpdateTask, http.MethodPatch, `{"uuid":"a","body":"Changed"}`, ""); w.Code != http.StatusOK {
+		t.Fatalf("update: got %d", w.Code)
+	}
+
+	if w := serve(h.UpdateTask, http.MethodPatch, `{"uuid":"a","body":"Again"}`, "*"); w.Code != http.StatusOK {
+		t.Errorf("update with *: got %d", w.Code)
+	}
+	if w := serve(h.RearrangeTasks, http.MethodPost, "b,a", "*"); w.Code != http.StatusOK {
+		t.Errorf("rearrange with *: got %d", w.Code)
+	}
+	if w := serve(h.RearrangeTasks, http.MethodPost, "a,b", `"tasks-0", *`); w.Code != http.StatusOK {
+		t.Errorf("rearrange with * in a list: got %d", w.Code)
+	}
+}
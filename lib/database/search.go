@@ -0,0 +1,190 @@
+package database
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	database "done/lib/database/interface"
+	"done/lib/search"
+	"done/lib/utils"
+)
+
+// defaultSearchLimit is how many results a search returns unless asked
+// otherwise
+const defaultSearchLimit = 50
+
+// searchIndex is built from the database on the first search and kept up to
+// date by indexedDB from then on
+type searchIndex struct {
+	mu    sync.Mutex // Held while building, so no change is missed
+	index *search.Index
+}
+
+// get returns the index, building it first if needed
+func (s *searchIndex) get(db database.Database) (*search.Index, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index != nil {
+		return s.index, nil
+	}
+
+	index := search.New()
+	lists := []struct {
+		name string
+		get  func() ([]database.Task, error)
+	}{
+		{search.ListActive, db.GetTasks},
+		{search.ListCompleted, db.GetCompletedTasks},
+		{search.ListTrashed, db.GetTrashedTasks},
+	}
+	for _, list := range lists {
+		tasks, err := list.get()
+		if err != nil {
+			return nil, err
+		}
+		for i := range tasks {
+			index.Put(list.name, &tasks[i])
+		}
+	}
+
+	s.index = index
+	return index, nil
+}
+
+// put indexes a task stored in a list, once the index is built
+func (s *searchIndex) put(list string, task *database.Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index != nil {
+		// Index the body as it is read back from the database
+		indexed := *task
+		indexed.Body = utils.CleanTaskText(task.Body)
+		s.index.Put(list, &indexed)
+	}
+}
+
+// delete removes a task from a list's part of the index, once it is built
+func (s *searchIndex) delete(list, uuid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index != nil {
+		s.index.Delete(list, uuid)
+	}
+}
+
+// indexedDB updates the search index with every change made to the tasks
+type indexedDB struct {
+	database.Database
+	search *searchIndex
+}
+
+func (db *indexedDB) AddTask(task *database.Task) error {
+	if err := db.Database.AddTask(task); err != nil {
+		return err
+	}
+	db.search.put(search.ListActive, task)
+	return nil
+}
+
+func (db *indexedDB) UpdateTask(task *database.Task) error {
+	if err := db.Database.UpdateTask(task); err != nil {
+		return err
+	}
+	db.search.put(search.ListActive, task)
+	return nil
+}
+
+func (db *indexedDB) RemoveTask(uuid string) error {
+	if err := db.Database.RemoveTask(uuid); err != nil {
+		return err
+	}
+	db.search.delete(search.ListActive, uuid)
+	return nil
+}
+
+func (db *indexedDB) AddCompletedTask(task *database.Task) error {
+	if err := db.Database.AddCompletedTask(task); err != nil {
+		return err
+	}
+	db.search.put(search.ListCompleted, task)
+	return nil
+}
+
+func (db *indexedDB) RemoveCompletedTask(uuid string) error {
+	if err := db.Database.RemoveCompletedTask(uuid); err != nil {
+		return err
+	}
+	db.search.delete(search.ListCompleted, uuid)
+	return nil
+}
+
+func (db *indexedDB) AddTrashedTask(task *database.Task) error {
+	if err := db.Database.AddTrashedTask(task); err != nil {
+		return err
+	}
+	db.search.put(search.ListTrashed, task)
+	return nil
+}
+
+func (db *indexedDB) RemoveTrashedTask(uuid string) error {
+	if err := db.Database.RemoveTrashedTask(uuid); err != nil {
+		return err
+	}
+	db.search.delete(search.ListTrashed, uuid)
+	return nil
+}
+
+// Search finds tasks in all lists, best matches first. See search.ParseQuery
+// for the query syntax.
+func (h *Handler) Search(query string, limit int) ([]search.Result, error) {
+	q, err := search.ParseQuery(query, h.now())
+	if err != nil {
+		return nil, err
+	}
+	return h.find(q, limit)
+}
+
+func (h *Handler) find(q *search.Query, limit int) ([]search.Result, error) {
+	index, err := h.search.get(h.DB)
+	if err != nil {
+		return nil, err
+	}
+	return index.Search(q, limit), nil
+}
+
+// SearchTasks responds with the tasks matching the q query parameter, at
+// most limit of them
+func (h *Handler) SearchTasks(w http.ResponseWriter, r *http.Request) {
+	limit := defaultSearchLimit
+	if param := r.URL.Query().Get("limit"); param != "" {
+		var err error
+		if limit, err = strconv.Atoi(param); err != nil || limit < 1 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	q, err := search.ParseQuery(r.URL.Query().Get("q"), h.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.find(q, limit)
+	if err != nil {
+		log.Printf("Error searching: %v", err)
+		http.Error(w, "Failed to search", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		log.Printf("Error writing search results: %v", err)
+	}
+}
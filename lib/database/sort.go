@@ -0,0 +1,248 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/mail"
+
+	database "done/lib/database/interface"
+	"done/lib/planner"
+	"done/lib/ranking"
+)
+
+// smartSort reports whether the task list is in smart sort mode
+func (h *Handler) smartSort() (bool, error) {
+	settings, err := h.DB.GetSettings()
+	if err != nil {
+		return false, err
+	}
+	return settings.SortMode == database.SortSmart, nil
+}
+
+// Tasks returns the active tasks in the order they are shown, with their
+// Blocked flag set. In smart sort mode, each task's Order is its position in
+// the sorted list.
+func (h *Handler) Tasks() ([]database.Task, error) {
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	markBlocked(tasks)
+
+	smart, err := h.smartSort()
+	if err != nil {
+		return nil, err
+	}
+	if !smart {
+		return tasks, nil
+	}
+
+	return ranking.Sort(tasks, h.now()), nil
+}
+
+// moveSmart moves a task within the smart sorted list and pins it there. The
+// stored order of every task becomes its position in the list, so that pinned
+// tasks stay where they were dropped.
+func (h *Handler) moveSmart(sourceTaskUUID, destinationTaskUUID string) error {
+	tasks, err := h.Tasks()
+	if err != nil {
+		return err
+	}
+
+	source, destination := -1, -1
+	for i, task := range tasks {
+		switch task.UUID {
+		case sourceTaskUUID:
+			source = i
+		case destinationTaskUUID:
+			destination = i
+		}
+	}
+	if source < 0 || (destination < 0 && destinationTaskUUID != sourceTaskUUID) {
+		return database.ErrTaskNotFound
+	}
+	if destination < 0 {
+		destination = source
+	}
+
+	moved := tasks[source]
+	moved.Pinned = true
+	tasks = append(tasks[:source], tasks[source+1:]...)
+	tasks = append(tasks[:destination], append([]database.Task{moved}, tasks[destination:]...)...)
+
+	stored, err := h.orders()
+	if err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		tasks[i].Order = i
+		if p := stored[tasks[i].UUID]; p.order == i && p.pinned == tasks[i].Pinned {
+			continue
+		}
+		if err := h.DB.UpdateTask(&tasks[i]); err != nil {
+			return err
+		}
+	}
+	h.bus.Publish(EventTasksReordered, nil)
+	return nil
+}
+
+// SetSortMode switches the task list between manual and smart sort
+func (h *Handler) SetSortMode(mode string) error {
+	settings, err := h.DB.GetSettings()
+	if err != nil {
+		return err
+	}
+	settings.SortMode = mode
+	return h.SaveSettings(settings)
+}
+
+// SaveSettings validates and stores the settings
+func (h *Handler) SaveSettings(settings *database.Settings) error {
+	if settings.SortMode != database.SortManual && settings.SortMode != database.SortSmart {
+		return fmt.Errorf("unknown sort mode %q", settings.SortMode)
+	}
+	if _, err := planner.NewConfig(settings.WorkingHours, settings.Busy); err != nil {
+		return err
+	}
+	for _, block := range settings.Busy {
+		if !block.End.After(block.Start) {
+			return fmt.Errorf("busy time %q ends before it starts", block.Title)
+		}
+	}
+	if err := checkReminderSettings(&settings.Reminders); err != nil {
+		return err
+	}
+	if err := checkReportSettings(&settings.Reports); err != nil {
+		return err
+	}
+	if settings.Email != "" {
+		if _, err := mail.ParseAddress(settings.Email); err != nil {
+			return fmt.Errorf("invalid email address %q", settings.Email)
+		}
+	}
+	if settings.Busy == nil {
+		settings.Busy = []database.BusyBlock{}
+	}
+	return h.DB.UpdateSettings(settings)
+}
+
+// Unpin releases every pinned task back to smart sort
+func (h *Handler) Unpin() error {
+	tasks, err := h.DB.GetTasks()
+	if err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		if !tasks[i].Pinned {
+			continue
+		}
+		tasks[i].Pinned = false
+		if err := h.DB.UpdateTask(&tasks[i]); err != nil {
+			return err
+		}
+	}
+	h.bus.Publish(EventTasksReordered, nil)
+	return nil
+}
+
+// GetSettings responds with the stored settings
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.DB.GetSettings()
+	if err != nil {
+		log.Printf("Error getting settings: %v", err)
+		http.Error(w, "Failed to get settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateSettings stores posted JSON settings and responds with the task list,
+// which the sort mode may have reordered. Settings missing from the JSON keep
+// their stored values.
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	settings, err := h.DB.GetSettings()
+	errHandler(err)
+
+	if err := json.Unmarshal(body, settings); err != nil {
+		log.Printf("Error unmarshaling settings: %v", err)
+		http.Error(w, "Invalid settings", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.SaveSettings(settings); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.writeTasks(w)
+}
+
+// UnpinTasks releases all tasks pinned by dragging in smart sort mode and
+// responds with the task list
+func (h *Handler) UnpinTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	before, err := h.orders()
+	errHandler(err)
+
+	err = h.Unpin()
+	errHandler(err)
+
+	after, err := h.orders()
+	errHandler(err)
+
+	h.record(w, r, &moveOperation{before: before, after: after})
+
+	h.writeTasks(w)
+}
+
+// writeTasks responds with the active tasks in the order they are shown,
+// tagged with the revision of the list
+func (h *Handler) writeTasks(w http.ResponseWriter) {
+	h.writeTasksStatus(w, http.StatusOK)
+}
+
+func (h *Handler) writeTasksStatus(w http.ResponseWriter, status int) {
+	// Read the revision first, so the ETag is never newer than the list
+	revision, err := h.DB.TasksRevision()
+	errHandler(err)
+
+	tasks, err := h.Tasks()
+	errHandler(err)
+
+	tasksJSON, err := json.Marshal(tasks)
+	errHandler(err)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("ETag", listETag(revision))
+	w.WriteHeader(status)
+	w.Write(tasksJSON)
+}
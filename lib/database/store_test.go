@@ -0,0 +1,20 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"done/lib/database/bolt"
+)
+
+// openStore returns a store in a temporary bolt file, disconnected when the
+// test ends
+func openStore(t *testing.T) *bolt.BoltDB {
+	t.Helper()
+	db := bolt.NewBoltDB(filepath.Join(t.TempDir(), "done.db"))
+	if err := db.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Disconnect() })
+	return db
+}
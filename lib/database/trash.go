@@ -0,0 +1,230 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// Remove moves an active task to the trash, stamping it with the deletion time
+func (h *Handler) Remove(uuid string) (*database.Task, error) {
+	task, err := h.DB.GetTaskByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	task.TimeDeleted = &now
+
+	err = h.DB.AddTrashedTask(task)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.DB.RemoveTask(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// Restore moves a trashed task back to the active list at its previous position
+func (h *Handler) Restore(uuid string) (*database.Task, error) {
+	task, err := h.DB.GetTrashedTaskByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	task.TimeDeleted = nil
+
+	err = h.insertAtOrder(task)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.DB.RemoveTrashedTask(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// Purge permanently deletes a task from the trash, with its edit history
+func (h *Handler) Purge(uuid string) error {
+	_, err := h.DB.GetTrashedTaskByUUID(uuid)
+	if err != nil {
+		return err
+	}
+
+	err = h.DB.RemoveTrashedTask(uuid)
+	if err != nil {
+		return err
+	}
+
+	return h.DB.RemoveTaskHistory(uuid)
+}
+
+// PurgeExpired permanently deletes tasks that have been in the trash longer
+// than retention. It returns the number of purged tasks. It holds the write
+// lock, as it also runs in the background.
+func (h *Handler) PurgeExpired(retention time.Duration) (int, error) {
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	tasks, err := h.DB.GetTrashedTasks()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-retention)
+	purged := 0
+	for _, task := range tasks {
+		if task.TimeDeleted != nil && task.TimeDeleted.After(cutoff) {
+			continue
+		}
+		err = h.Purge(task.UUID)
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// RunTrashPurger purges expired trash once at startup and then every interval.
+// A zero retention keeps trashed tasks forever. It never returns.
+func (h *Handler) RunTrashPurger(retention, interval time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	for {
+		purged, err := h.PurgeExpired(retention)
+		if err != nil {
+			log.Printf("Error purging trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d tasks deleted more than %v ago", purged, retention)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// trashed returns the trashed tasks, most recently deleted first
+func (h *Handler) trashed() ([]database.Task, error) {
+	tasks, err := h.DB.GetTrashedTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(tasks); i++ {
+		for j := i + 1; j < len(tasks); j++ {
+			if deletedBefore(&tasks[i], &tasks[j]) {
+				tasks[i], tasks[j] = tasks[j], tasks[i]
+			}
+		}
+	}
+
+	if tasks == nil {
+		tasks = []database.Task{}
+	}
+	return tasks, nil
+}
+
+func deletedBefore(a, b *database.Task) bool {
+	if a.TimeDeleted == nil || b.TimeDeleted == nil {
+		return a.TimeDeleted == nil && b.TimeDeleted != nil
+	}
+	return a.TimeDeleted.Before(*b.TimeDeleted)
+}
+
+func (h *Handler) writeTrash(w http.ResponseWriter) {
+	tasks, err := h.trashed()
+	if err != nil {
+		log.Printf("Error getting trash: %v", err)
+		http.Error(w, "Failed to get trash", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(tasks)
+}
+
+// GetTrash returns the trashed tasks, most recently deleted first
+func (h *Handler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	h.writeTrash(w)
+}
+
+// RestoreTask moves the trashed task whose UUID is the request body back to
+// the task list and responds with the task list
+func (h *Handler) RestoreTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	uuid, err := ioutil.ReadAll(r.Body)
+	errHandler(err)
+
+	_, err = h.Restore(string(uuid))
+	if errors.Is(err, database.ErrTaskNotFound) {
+		http.Error(w, "Trashed task not found", http.StatusNotFound)
+		return
+	}
+	errHandler(err)
+
+	h.record(w, r, &restoreOperation{uuid: string(uuid)})
+
+	h.writeTasks(w)
+}
+
+// PurgeTask permanently deletes the trashed task whose UUID is the request
+// body and responds with the remaining trash
+func (h *Handler) PurgeTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	uuid, err := ioutil.ReadAll(r.Body)
+	errHandler(err)
+
+	err = h.Purge(string(uuid))
+	if errors.Is(err, database.ErrTaskNotFound) {
+		http.Error(w, "Trashed task not found", http.StatusNotFound)
+		return
+	}
+	errHandler(err)
+
+	h.writeTrash(w)
+}
+
+// EmptyTrash permanently deletes every trashed task
+func (h *Handler) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, err := h.PurgeExpired(0)
+	if err != nil {
+		log.Printf("Error emptying trash: %v", err)
+		http.Error(w, "Failed to empty trash", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeTrash(w)
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+func TestPurgeExpired(t *testing.T) {
+	h := NewHandler(openStore(t))
+	for days, uuid := range map[int]string{1: "recent", 40: "old"} {
+		deleted := time.Now().AddDate(0, 0, -days)
+		if err := h.DB.AddTrashedTask(&database.Task{UUID: uuid, Body: "Deleted " + uuid, TimeDeleted: &deleted}); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.DB.AddTaskEdit(&database.TaskEdit{TaskUUID: uuid, Time: deleted}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := h.PurgeExpired(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d tasks, want 1", purged)
+	}
+	if _, err := h.DB.GetTrashedTaskByUUID("old"); err == nil {
+		t.Error("expired task is still in the trash")
+	}
+	if history, err := h.DB.GetTaskHistory("old"); err != nil || len(history) != 0 {
+		t.Errorf("history of the purged task: %v (%v)", history, err)
+	}
+	if _, err := h.DB.GetTrashedTaskByUUID("recent"); err != nil {
+		t.Errorf("task deleted a day ago was purged: %v", err)
+	}
+	if history, err := h.DB.GetTaskHistory("recent"); err != nil || len(history) != 1 {
+		t.Errorf("history of the kept task: %v (%v)", history, err)
+	}
+}
+
+func TestPurgeWaitsForOtherWrites(t *testing.T) {
+	h := NewHandler(openStore(t))
+	deleted := time.Now()
+	if err := h.DB.AddTrashedTask(&database.Task{UUID: "t", Body: "Deleted", TimeDeleted: &deleted}); err != nil {
+		t.Fatal(err)
+	}
+
+	// As while a restore or an update of the task is under way
+	h.writes.Lock()
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		h.PurgeTask(w, httptest.NewRequest(http.MethodPost, "/api/purgeTask", strings.NewReader("t")))
+		done <- w.Code
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("purged while another change held the list")
+	case <-time.After(50 * time.Millisecond):
+	}
+	h.writes.Unlock()
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("purge: %d", code)
+	}
+}
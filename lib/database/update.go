@@ -0,0 +1,297 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/utils"
+)
+
+// TaskPatch lists the fields of a task update. Fields left out of the JSON
+// request are not changed.
+type TaskPatch struct {
+	UUID                              string       `json:"uuid"`
+	Body                              *string      `json:"body"`
+	DurationExecutionEstimatedSeconds *int         `json:"duration_execution_estimated_seconds"`
+	TimeHardDeadline                  NullableTime `json:"time_hard_dead_line"`
+	DeadlineHasTime                   *bool        `json:"deadline_has_time"`
+	TimePlanned                       NullableTime `json:"time_planned"`
+	Priority                          *string      `json:"priority"`
+	Pinned                            *bool        `json:"pinned"`
+	BlockedBy                         *[]string    `json:"blocked_by"`
+}
+
+// NullableTime tells a time explicitly set to null apart from one not sent
+type NullableTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (n *NullableTime) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	return json.Unmarshal(data, &n.Value)
+}
+
+// Update applies patch to the active task with the given UUID. The task keeps
+// its UUID, creation time, order and timer data, and every changed field is
+// recorded in the task's edit history.
+func (h *Handler) Update(uuid string, patch *TaskPatch) (*database.Task, error) {
+	task, err := h.DB.GetTaskByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	edit := database.TaskEdit{TaskUUID: task.UUID, Time: time.Now()}
+
+	if patch.Body != nil {
+		body := utils.CleanTaskText(*patch.Body)
+		if body == "" {
+			return nil, errors.New("task body cannot be empty")
+		}
+		if body != task.Body {
+			edit.Changes = append(edit.Changes, database.FieldChange{Field: "body", Old: task.Body, New: body})
+			task.Body = body
+		}
+	}
+
+	if patch.DurationExecutionEstimatedSeconds != nil {
+		estimate := *patch.DurationExecutionEstimatedSeconds
+		if estimate < 0 {
+			return nil, errors.New("estimate cannot be negative")
+		}
+		if estimate != task.DurationExecutionEstimatedSeconds {
+			edit.Changes = append(edit.Changes, database.FieldChange{
+				Field: "duration_execution_estimated_seconds",
+				Old:   strconv.Itoa(task.DurationExecutionEstimatedSeconds),
+				New:   strconv.Itoa(estimate),
+			})
+			task.DurationExecutionEstimatedSeconds = estimate
+		}
+	}
+
+	// Dates without a time of day are days in the handler's time zone, as
+	// for new tasks
+	hasTime := task.DeadlineHasTime
+	if patch.DeadlineHasTime != nil {
+		hasTime = *patch.DeadlineHasTime
+	}
+	if patch.TimeHardDeadline.Set && !hasTime {
+		patch.TimeHardDeadline.Value = h.onDate(patch.TimeHardDeadline.Value)
+	}
+	if patch.TimePlanned.Set {
+		patch.TimePlanned.Value = h.onDate(patch.TimePlanned.Value)
+	}
+
+	if patch.TimeHardDeadline.Set && !sameTime(patch.TimeHardDeadline.Value, task.TimeHardDeadline) {
+		edit.Changes = append(edit.Changes, database.FieldChange{
+			Field: "time_hard_dead_line",
+			Old:   formatOptionalTime(task.TimeHardDeadline),
+			New:   formatOptionalTime(patch.TimeHardDeadline.Value),
+		})
+		task.TimeHardDeadline = patch.TimeHardDeadline.Value
+	}
+
+	if patch.DeadlineHasTime != nil && *patch.DeadlineHasTime != task.DeadlineHasTime {
+		edit.Changes = append(edit.Changes, database.FieldChange{
+			Field: "deadline_has_time",
+			Old:   strconv.FormatBool(task.DeadlineHasTime),
+			New:   strconv.FormatBool(*patch.DeadlineHasTime),
+		})
+		task.DeadlineHasTime = *patch.DeadlineHasTime
+	}
+	if task.TimeHardDeadline == nil {
+		task.DeadlineHasTime = false
+	}
+
+	if patch.TimePlanned.Set && !sameTime(patch.TimePlanned.Value, task.TimePlanned) {
+		edit.Changes = append(edit.Changes, database.FieldChange{
+			Field: "time_planned",
+			Old:   formatOptionalTime(task.TimePlanned),
+			New:   formatOptionalTime(patch.TimePlanned.Value),
+		})
+		task.TimePlanned = patch.TimePlanned.Value
+	}
+
+	if patch.Priority != nil && *patch.Priority != task.Priority {
+		if !database.ValidPriority(*patch.Priority) {
+			return nil, fmt.Errorf("unknown priority %q", *patch.Priority)
+		}
+		edit.Changes = append(edit.Changes, database.FieldChange{Field: "priority", Old: task.Priority, New: *patch.Priority})
+		task.Priority = *patch.Priority
+	}
+
+	if patch.Pinned != nil && *patch.Pinned != task.Pinned {
+		edit.Changes = append(edit.Changes, database.FieldChange{
+			Field: "pinned",
+			Old:   strconv.FormatBool(task.Pinned),
+			New:   strconv.FormatBool(*patch.Pinned),
+		})
+		task.Pinned = *patch.Pinned
+	}
+
+	if patch.BlockedBy != nil {
+		blockers := uniqueStrings(*patch.BlockedBy)
+		if strings.Join(blockers, ",") != strings.Join(task.BlockedBy, ",") {
+			if err := h.checkBlockers(task.UUID, blockers); err != nil {
+				return nil, err
+			}
+			edit.Changes = append(edit.Changes, database.FieldChange{
+				Field: "blocked_by",
+				Old:   strings.Join(task.BlockedBy, ","),
+				New:   strings.Join(blockers, ","),
+			})
+			task.BlockedBy = blockers
+		}
+	}
+
+	if len(edit.Changes) == 0 {
+		return task, nil
+	}
+
+	if err := h.DB.UpdateTask(task); err != nil {
+		return nil, err
+	}
+
+	if err := h.DB.AddTaskEdit(&edit); err != nil {
+		log.Printf("Error recording task history: %v", err)
+	}
+
+	return task, nil
+}
+
+// patchFrom builds a patch that sets every editable field to its value in task
+func patchFrom(task *database.Task) *TaskPatch {
+	body := task.Body
+	estimate := task.DurationExecutionEstimatedSeconds
+	hasTime := task.DeadlineHasTime
+	priority := task.Priority
+	pinned := task.Pinned
+	blockers := append([]string(nil), task.BlockedBy...)
+
+	return &TaskPatch{
+		UUID:                              task.UUID,
+		Body:                              &body,
+		DurationExecutionEstimatedSeconds: &estimate,
+		TimeHardDeadline:                  NullableTime{Set: true, Value: task.TimeHardDeadline},
+		DeadlineHasTime:                   &hasTime,
+		TimePlanned:                       NullableTime{Set: true, Value: task.TimePlanned},
+		Priority:                          &priority,
+		Pinned:                            &pinned,
+		BlockedBy:                         &blockers,
+	}
+}
+
+// uniqueStrings returns the non-empty values in order, without repeats. It
+// returns nil when there are none.
+func uniqueStrings(values []string) []string {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		unique = append(unique, value)
+	}
+	return unique
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// UpdateTask edits a task in place. It accepts PATCH (or POST) with a JSON
+// TaskPatch and responds with the updated task list.
+func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var patch TaskPatch
+	err = json.Unmarshal(body, &patch)
+	if err != nil {
+		log.Printf("Error unmarshaling task update: %v", err)
+		http.Error(w, "Invalid task update", http.StatusBadRequest)
+		return
+	}
+
+	h.writes.Lock()
+	defer h.writes.Unlock()
+
+	if !h.checkTask(w, r, patch.UUID) {
+		return
+	}
+
+	before, err := h.DB.GetTaskByUUID(patch.UUID)
+	if errors.Is(err, database.ErrTaskNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	errHandler(err)
+
+	after, err := h.Update(patch.UUID, &patch)
+	if errors.Is(err, database.ErrTaskNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating task: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to update task: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	h.record(w, r, &updateOperation{before: before, after: after})
+
+	h.writeTasks(w)
+}
+
+// GetTaskHistory returns the edit history of the task given by the uuid
+// query parameter, oldest edit first
+func (h *Handler) GetTaskHistory(w http.ResponseWriter, r *http.Request) {
+	uuid := r.URL.Query().Get("uuid")
+	if uuid == "" {
+		http.Error(w, "Missing uuid parameter", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.DB.GetTaskHistory(uuid)
+	if err != nil {
+		log.Printf("Error getting task history: %v", err)
+		http.Error(w, "Failed to get task history", http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []database.TaskEdit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(history)
+}
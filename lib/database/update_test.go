@@ -0,0 +1,85 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+func TestDatesInHandlerZone(t *testing.T) {
+	h := NewHandler(openStore(t))
+	h.Location = time.FixedZone("UTC+10", 10*60*60)
+
+	deadline := parseDateFields(h.now(), "3", "4", "2030")
+	if want := time.Date(2030, 3, 4, 0, 0, 0, 0, h.Location); deadline == nil || !deadline.Equal(want) {
+		t.Errorf("form date = %v, want %v", deadline, want)
+	}
+
+	if err := h.DB.AddTask(&database.Task{UUID: "t", Body: "Pay the rent"}); err != nil {
+		t.Fatal(err)
+	}
+	// A date picked in the browser, sent as midnight UTC
+	var patch TaskPatch
+	if err := json.Unmarshal([]byte(`{"time_hard_dead_line":"2030-03-04T00:00:00Z","deadline_has_time":false,"time_planned":"2030-03-02T00:00:00Z"}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	task, err := h.Update("t", &patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2030, 3, 4, 0, 0, 0, 0, h.Location); !task.TimeHardDeadline.Equal(want) {
+		t.Errorf("deadline = %v, want %v", task.TimeHardDeadline, want)
+	}
+	if want := time.Date(2030, 3, 2, 0, 0, 0, 0, h.Location); !task.TimePlanned.Equal(want) {
+		t.Errorf("planned = %v, want %v", task.TimePlanned, want)
+	}
+}
+
+func TestPatchClearsNullFields(t *testing.T) {
+	h := NewHandler(openStore(t))
+	deadline := time.Date(2030, 3, 4, 15, 0, 0, 0, time.UTC)
+	planned := time.Date(2030, 3, 2, 0, 0, 0, 0, time.UTC)
+	task := &database.Task{UUID: "t", Body: "Pay the rent", TimeHardDeadline: &deadline, DeadlineHasTime: true, TimePlanned: &planned}
+	if err := h.DB.AddTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leaving a field out keeps it
+	var patch TaskPatch
+	if err := json.Unmarshal([]byte(`{"body":"Pay the rent today"}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	task, err := h.Update("t", &patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.TimeHardDeadline == nil || task.TimePlanned == nil {
+		t.Fatalf("fields left out were cleared: %+v", task)
+	}
+
+	// null clears it
+	patch = TaskPatch{}
+	if err := json.Unmarshal([]byte(`{"time_hard_dead_line":null,"time_planned":null}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Update("t", &patch); err != nil {
+		t.Fatal(err)
+	}
+	task, err = h.DB.GetTaskByUUID("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.TimeHardDeadline != nil || task.TimePlanned != nil || task.Body != "Pay the rent today" {
+		t.Errorf("after clearing: deadline %v, planned %v, body %q", task.TimeHardDeadline, task.TimePlanned, task.Body)
+	}
+
+	history, err := h.DB.GetTaskHistory("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || len(history[1].Changes) != 2 {
+		t.Errorf("history = %+v, want the clearing recorded", history)
+	}
+}
@@ -0,0 +1,712 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"done/lib/auth"
+	database "done/lib/database/interface"
+	"done/lib/events"
+	"done/lib/webhook"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Webhooks send events of a user's tasks, or of a shared list, as signed
+// JSON POSTs. Every handler's events are watched from when it is created;
+// those a webhook subscribes to are queued in the database as deliveries,
+// which RunWebhooks sends, trying failed ones again with exponential backoff
+// until they succeed or MaxAttempts is reached. Each webhook's deliveries are
+// sent in order, and several webhooks at once, so a slow receiver only holds
+// up its own. Deliveries queued while no server runs, such as by the CLI,
+// are sent when it starts.
+
+// EventPing is sent by TestWebhook, whatever events the webhook subscribes to
+const EventPing = "ping"
+
+const (
+	// webhookTimeout is how long a receiver has to respond
+	webhookTimeout = 10 * time.Second
+	// webhookBatch is how many due deliveries of a webhook are sent at a
+	// time
+	webhookBatch = 50
+	// webhookWorkers is how many webhooks are sent to at once
+	webhookWorkers = 8
+)
+
+// webhookEvents are the event types webhooks may subscribe to
+var webhookEvents = []string{
+	EventTaskAdded,
+	EventTaskUpdated,
+	EventTaskCompleted,
+	EventTaskRemoved,
+	EventLevelUp,
+	EventAchievement,
+	EventReminder,
+}
+
+var (
+	errNotWebhookOwner = errors.New("only the creator of a webhook may change it")
+	errPrivateWebhook  = errors.New("webhooks may only be sent to public addresses on this server")
+)
+
+// newWebhookClient returns the client deliveries are sent with. It does not
+// follow redirects, so receivers cannot send deliveries elsewhere. With
+// publicOnly it refuses to connect to addresses that are not public, as
+// the URL's host resolves when connecting, and to proxies, which would
+// connect for it.
+func newWebhookClient(publicOnly bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if publicOnly {
+		dialer := &net.Dialer{
+			Timeout: webhookTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !webhook.Public(ip) {
+					return errPrivateWebhook
+				}
+				return nil
+			},
+		}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// EventPayload is the body of a delivery, and the input of hooks
+type EventPayload struct {
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	User  string      `json:"user,omitempty"` // Name of the webhook's user, or whose tasks changed
+	List  string      `json:"list,omitempty"` // Name of the shared list
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// validWebhookEvent reports whether webhooks may subscribe to typ
+func validWebhookEvent(typ string) bool {
+	for _, valid := range webhookEvents {
+		if typ == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribed reports whether hook sends events of type typ
+func subscribed(hook *database.Webhook, typ string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, event := range hook.Events {
+		if event == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// ownsWebhook reports whether hook belongs to user. Webhooks made before
+// there were users belong to the owner.
+func ownsWebhook(user *database.User, hook *database.Webhook) bool {
+	if hook.UserID == "" {
+		return user == nil || user.Owner
+	}
+	return user != nil && hook.UserID == user.ID
+}
+
+// watch queues the events of h for the webhooks of its user, given by the
+// key of a.handlers, or of the shared list with ID list. The subscription
+// is made before watch returns, so no change made through h is missed.
+func (a *Accounts) watch(h *Handler, key, list string) {
+	subscription, _, _ := h.bus.Subscribe(0)
+
+	a.watchers.Add(1)
+	go func() {
+		defer a.watchers.Done()
+
+		var lastID uint64
+		for {
+			select {
+			case event, ok := <-subscription.C:
+				if ok {
+					a.forward(event, key, list)
+					lastID = event.ID
+					continue
+				}
+				// Too far behind; catch up on what the bus still keeps
+				var missed []events.Event
+				var complete bool
+				subscription, missed, complete = h.bus.Subscribe(lastID)
+				if !complete {
+					log.Printf("Webhooks missed events after event %d", lastID)
+				}
+				for _, event := range missed {
+					a.forward(event, key, list)
+					lastID = event.ID
+				}
+			case <-a.stop:
+				for {
+					select {
+					case event, ok := <-subscription.C:
+						if !ok {
+							return
+						}
+						a.forward(event, key, list)
+					default:
+						subscription.Close()
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// forward runs the hooks of event and queues a delivery of it for every
+// webhook of the user with the given handler key, or of the shared list,
+// that subscribes to it
+func (a *Accounts) forward(event events.Event, key, list string) {
+	a.runHooks(event, key, list)
+
+	if !validWebhookEvent(event.Type) {
+		return
+	}
+
+	hooks, err := a.Store.GetWebhooks()
+	if err != nil {
+		log.Printf("Error getting webhooks: %v", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	users, err := a.usersByID()
+	if err != nil {
+		log.Printf("Error getting users: %v", err)
+		return
+	}
+
+	var shared *database.SharedList
+	if list != "" {
+		if shared, err = a.Store.GetSharedList(list); err != nil {
+			log.Printf("Error getting shared list: %v", err)
+			return
+		}
+	}
+
+	for i := range hooks {
+		hook := &hooks[i]
+		if hook.List != list || !subscribed(hook, event.Type) {
+			continue
+		}
+
+		user := users[hook.UserID]
+		if hook.UserID != "" && (user == nil || user.Disabled) {
+			continue
+		}
+		payload := EventPayload{Event: event.Type, Time: event.Time, Data: event.Data}
+		if user != nil {
+			payload.User = user.Name
+		}
+
+		if shared != nil {
+			// Only members keep getting a list's events
+			if user == nil || !isMember(shared, user) {
+				continue
+			}
+			payload.List = shared.Name
+		} else {
+			hookKey := hook.UserID
+			if user != nil && user.Owner {
+				hookKey = ""
+			}
+			if hookKey != key {
+				continue
+			}
+		}
+
+		if err := a.enqueue(hook, &payload); err != nil {
+			log.Printf("Error queueing %s for webhook %s: %v", event.Type, hook.ID, err)
+		}
+	}
+}
+
+// enqueue queues a delivery of payload to hook and wakes RunWebhooks
+func (a *Accounts) enqueue(hook *database.Webhook, payload *EventPayload) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	delivery := database.Delivery{
+		WebhookID:   hook.ID,
+		Event:       payload.Event,
+		Payload:     encoded,
+		NextAttempt: time.Now(),
+	}
+	if err := a.Store.EnqueueDelivery(&delivery); err != nil {
+		return err
+	}
+	a.wakeWebhooks()
+	return nil
+}
+
+// Close stops watching the handlers' events once those already published
+// are queued for the webhooks. The accounts must not be used afterwards.
+func (a *Accounts) Close() {
+	close(a.stop)
+	a.watchers.Wait()
+}
+
+// RunWebhooks sends the queued deliveries as they fall due until Close is
+// called. With PublicWebhooks set, deliveries to addresses that are not
+// public fail.
+func (a *Accounts) RunWebhooks() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	client := newWebhookClient(a.PublicWebhooks)
+	workers := make(chan struct{}, webhookWorkers)
+	var mu sync.Mutex
+	sending := make(map[string]bool) // Webhooks whose deliveries are being sent
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		due, err := a.Store.DueDeliveries(time.Now(), webhookBatch)
+		if err != nil {
+			log.Printf("Error getting webhook deliveries: %v", err)
+		}
+
+		var order []string
+		byWebhook := make(map[string][]database.Delivery)
+		for _, delivery := range due {
+			if _, ok := byWebhook[delivery.WebhookID]; !ok {
+				order = append(order, delivery.WebhookID)
+			}
+			byWebhook[delivery.WebhookID] = append(byWebhook[delivery.WebhookID], delivery)
+		}
+
+		for _, id := range order {
+			mu.Lock()
+			busy := sending[id]
+			sending[id] = true
+			mu.Unlock()
+			if busy {
+				continue
+			}
+
+			wg.Add(1)
+			go func(id string, deliveries []database.Delivery) {
+				defer wg.Done()
+				workers <- struct{}{}
+				a.deliverAll(client, deliveries)
+				<-workers
+
+				mu.Lock()
+				delete(sending, id)
+				mu.Unlock()
+				a.wakeWebhooks()
+			}(id, byWebhook[id])
+		}
+
+		select {
+		case <-ticker.C:
+		case <-a.wake:
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// wakeWebhooks tells RunWebhooks to look for due deliveries
+func (a *Accounts) wakeWebhooks() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverAll sends the deliveries of a webhook in order until Close is
+// called. Once one fails, the others wait for its next attempt, so a
+// receiver that is down gets one at a time.
+func (a *Accounts) deliverAll(client *http.Client, deliveries []database.Delivery) {
+	var retry time.Time
+	for i := range deliveries {
+		select {
+		case <-a.stop:
+			return
+		default:
+		}
+
+		delivery := &deliveries[i]
+		if !retry.IsZero() {
+			if delivery.NextAttempt.Before(retry) {
+				delivery.NextAttempt = retry
+				logQueueError(a.Store.UpdateDelivery(delivery))
+			}
+			continue
+		}
+		if a.deliver(client, delivery) {
+			retry = delivery.NextAttempt
+		}
+	}
+}
+
+// deliver sends a delivery, and removes it from the queue or schedules the
+// next attempt, reporting whether it did the latter
+func (a *Accounts) deliver(client *http.Client, delivery *database.Delivery) (retry bool) {
+	hook, err := a.Store.GetWebhook(delivery.WebhookID)
+	if errors.Is(err, database.ErrWebhookNotFound) {
+		logQueueError(a.Store.RemoveDelivery(delivery.ID))
+		return false
+	}
+	if err != nil {
+		log.Printf("Error getting webhook %s: %v", delivery.WebhookID, err)
+		return false
+	}
+
+	sendErr := send(client, hook, delivery)
+	if sendErr == nil {
+		logQueueError(a.Store.RemoveDelivery(delivery.ID))
+		a.recordDelivery(hook.ID, nil)
+		return false
+	}
+
+	delivery.Attempts++
+	if delivery.Attempts >= webhook.MaxAttempts {
+		log.Printf("Giving up %s delivery %d to %s after %d attempts: %v",
+			delivery.Event, delivery.ID, hook.URL, delivery.Attempts, sendErr)
+		logQueueError(a.Store.RemoveDelivery(delivery.ID))
+	} else {
+		delivery.NextAttempt = time.Now().Add(webhook.Backoff(delivery.Attempts))
+		logQueueError(a.Store.UpdateDelivery(delivery))
+		retry = true
+	}
+	a.recordDelivery(hook.ID, sendErr)
+	return retry
+}
+
+// send POSTs a delivery to hook, signed with its secret
+func send(client *http.Client, hook *database.Webhook, delivery *database.Delivery) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "done-webhooks")
+	req.Header.Set(webhook.EventHeader, delivery.Event)
+	req.Header.Set(webhook.DeliveryHeader, strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(hook.Secret, now, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return nil
+}
+
+// publicHost reports whether the host of a webhook URL could be public. Host
+// names are only checked as the client connects, when they are resolved.
+func publicHost(rawURL string) bool {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return webhook.Public(ip)
+	}
+	return true
+}
+
+// recordDelivery notes on the webhook how its latest delivery went
+func (a *Accounts) recordDelivery(id string, sendErr error) {
+	a.webhooks.Lock()
+	defer a.webhooks.Unlock()
+
+	hook, err := a.Store.GetWebhook(id)
+	if err != nil {
+		// Removed while it was being delivered to
+		return
+	}
+	if sendErr == nil {
+		now := time.Now()
+		hook.LastDelivered = &now
+		hook.LastError = ""
+	} else {
+		hook.LastError = sendErr.Error()
+	}
+	logQueueError(a.Store.SaveWebhook(hook))
+}
+
+// logQueueError logs an error of the delivery queue, which has no request
+// to fail
+func logQueueError(err error) {
+	if err != nil {
+		log.Printf("Error updating webhook deliveries: %v", err)
+	}
+}
+
+// CreateWebhook subscribes url to events, or to all of them when events is
+// empty, of user's tasks or, given its ID or name, of a shared list user is
+// a member of. The returned webhook holds the secret deliveries are signed
+// with, which is not shown again.
+func (a *Accounts) CreateWebhook(user *database.User, list, url string, events []string) (*database.Webhook, error) {
+	if err := webhook.CheckURL(url); err != nil {
+		return nil, err
+	}
+	if a.PublicWebhooks && !publicHost(url) {
+		return nil, errPrivateWebhook
+	}
+	for _, event := range events {
+		if !validWebhookEvent(event) {
+			return nil, fmt.Errorf("unknown event %q: use %s", event, strings.Join(webhookEvents, ", "))
+		}
+	}
+
+	hook := database.Webhook{
+		ID:      uuid.NewV4().String(),
+		URL:     strings.TrimSpace(url),
+		Events:  events,
+		Created: time.Now(),
+	}
+	if user != nil {
+		hook.UserID = user.ID
+	}
+	if list != "" {
+		if user == nil {
+			return nil, errNoUsers
+		}
+		shared, err := a.FindList(list)
+		if err != nil {
+			return nil, err
+		}
+		if !isMember(shared, user) {
+			return nil, errNotMember
+		}
+		hook.List = shared.ID
+	}
+
+	secret, err := auth.NewSecret(webhook.SecretPrefix)
+	if err != nil {
+		return nil, err
+	}
+	hook.Secret = secret
+
+	a.webhooks.Lock()
+	defer a.webhooks.Unlock()
+	if err := a.Store.SaveWebhook(&hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// UserWebhooks returns the webhooks user created, without their secrets
+func (a *Accounts) UserWebhooks(user *database.User) ([]database.Webhook, error) {
+	hooks, err := a.Store.GetWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	owned := []database.Webhook{}
+	for _, hook := range hooks {
+		if ownsWebhook(user, &hook) {
+			hook.Secret = ""
+			owned = append(owned, hook)
+		}
+	}
+	return owned, nil
+}
+
+// ownWebhook returns the webhook with the given ID if user created it
+func (a *Accounts) ownWebhook(user *database.User, id string) (*database.Webhook, error) {
+	hook, err := a.Store.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ownsWebhook(user, hook) {
+		return nil, errNotWebhookOwner
+	}
+	return hook, nil
+}
+
+// RemoveWebhook deletes one of user's webhooks with its queued deliveries
+func (a *Accounts) RemoveWebhook(user *database.User, id string) error {
+	a.webhooks.Lock()
+	defer a.webhooks.Unlock()
+
+	if _, err := a.ownWebhook(user, id); err != nil {
+		return err
+	}
+	return a.Store.RemoveWebhook(id)
+}
+
+// PingWebhook queues a ping event to one of user's webhooks, so receivers
+// can be checked without changing any task
+func (a *Accounts) PingWebhook(user *database.User, id string) error {
+	hook, err := a.ownWebhook(user, id)
+	if err != nil {
+		return err
+	}
+
+	payload := EventPayload{
+		Event: EventPing,
+		Time:  time.Now(),
+		Data:  map[string]string{"webhook": hook.ID},
+	}
+	if user != nil {
+		payload.User = user.Name
+	}
+	if hook.List != "" {
+		if shared, err := a.Store.GetSharedList(hook.List); err == nil {
+			payload.List = shared.Name
+		}
+	}
+	return a.enqueue(hook, &payload)
+}
+
+// Webhooks lists the user's webhooks on GET, and creates one posted as JSON
+// ({"url": "https://...", "events": ["task.completed"], "list": "release"})
+// on POST. The response to POST holds the signing secret, which is not
+// shown again.
+func (a *Accounts) Webhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identity, ok := a.manager(w, r, false)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		hooks, err := a.UserWebhooks(identity.User)
+		errHandler(err)
+		writeJSON(w, hooks)
+		return
+	}
+
+	var create struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		List   string   `json:"list"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		http.Error(w, "Invalid webhook", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := a.CreateWebhook(identity.User, create.List, create.URL, create.Events)
+	switch {
+	case errors.Is(err, database.ErrListNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, errNotMember):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Added webhook %s to %s", hook.ID, hook.URL)
+	writeJSON(w, hook)
+}
+
+// webhookRequest decodes the webhook ID posted as JSON ({"id": "..."})
+func (a *Accounts) webhookRequest(w http.ResponseWriter, r *http.Request) (*database.User, string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, "", false
+	}
+
+	identity, ok := a.manager(w, r, false)
+	if !ok {
+		return nil, "", false
+	}
+
+	var request struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid webhook", http.StatusBadRequest)
+		return nil, "", false
+	}
+	return identity.User, request.ID, true
+}
+
+// writeWebhookError responds with the error that stopped a change to a
+// webhook, reporting whether there was one
+func writeWebhookError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, database.ErrWebhookNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return true
+	case errors.Is(err, errNotWebhookOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return true
+	}
+	errHandler(err)
+	return false
+}
+
+// DeleteWebhook removes the webhook whose ID is posted as JSON
+// ({"id": "..."}), dropping its queued deliveries
+func (a *Accounts) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	user, id, ok := a.webhookRequest(w, r)
+	if !ok {
+		return
+	}
+	if writeWebhookError(w, a.RemoveWebhook(user, id)) {
+		return
+	}
+
+	log.Printf("Removed webhook %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestWebhook queues a ping event to the webhook whose ID is posted as JSON
+// ({"id": "..."})
+func (a *Accounts) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	user, id, ok := a.webhookRequest(w, r)
+	if !ok {
+		return
+	}
+	if writeWebhookError(w, a.PingWebhook(user, id)) {
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
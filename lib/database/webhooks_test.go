@@ -0,0 +1,79 @@
+package database
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlowWebhookDoesNotHoldUpOthers(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	received := make(chan string, 1)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Done-Event")
+	}))
+	defer fast.Close()
+
+	a := NewAccounts(openStore(t))
+	for _, url := range []string{slow.URL, fast.URL} {
+		hook, err := a.CreateWebhook(nil, "", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.PingWebhook(nil, hook.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	go a.RunWebhooks()
+	defer a.Close()
+	defer close(release)
+
+	select {
+	case event := <-received:
+		if event != EventPing {
+			t.Errorf("received %q, want ping", event)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the slow receiver held up the other webhook")
+	}
+}
+
+func TestWebhookClient(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/internal", http.StatusFound)
+		}
+	}))
+	defer target.Close()
+
+	resp, err := newWebhookClient(false).Post(target.URL+"/moved", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("redirect: got %s, want it not followed", resp.Status)
+	}
+
+	if _, err := newWebhookClient(true).Post(target.URL, "application/json", nil); !errors.Is(err, errPrivateWebhook) {
+		t.Errorf("public only, to %s: %v", target.URL, err)
+	}
+
+	a := NewAccounts(openStore(t))
+	a.PublicWebhooks = true
+	for _, url := range []string{"http://127.0.0.1:8080/hook", "http://localhost/hook", "http://169.254.169.254/", "http://[::1]/"} {
+		if _, err := a.CreateWebhook(nil, "", url, nil); !errors.Is(err, errPrivateWebhook) {
+			t.Errorf("CreateWebhook(%s): %v", url, err)
+		}
+	}
+	if _, err := a.CreateWebhook(nil, "", "https://hooks.example.com/done", nil); err != nil {
+		t.Errorf("public host refused: %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+// Package digest builds the daily summary and the weekly report of the
+// tasks a user completed, to be emailed as HTML with inline CSS, which mail
+// clients keep where they drop style sheets, and as plain text. It also
+// tells when each is due.
+package digest
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/utils"
+)
+
+// Kinds of summaries
+const (
+	Daily  = "daily"  // The day before it is sent
+	Weekly = "weekly" // The week before it is sent
+)
+
+// CatchUp is how long after its time a summary is still sent, such as when
+// the server was down then
+const CatchUp = 24 * time.Hour
+
+// Summary is what a user completed over a period
+type Summary struct {
+	Kind  string
+	From  time.Time       // Start of the period
+	To    time.Time       // End of the period, when the summary is due
+	Tasks []database.Task // Completed in the period, oldest first
+	Plan  *Plan           // How the day went against its plan; nil when none was accepted
+}
+
+// Plan compares a day's accepted plan with what was done
+type Plan struct {
+	PlannedSeconds int
+	ActualSeconds  int
+	Tasks          []PlanTask
+}
+
+// PlanTask is what became of one task of a plan
+type PlanTask struct {
+	Status         string // e.g. "Done as planned"
+	Body           string
+	PlannedSeconds int
+	ActualSeconds  int
+}
+
+// Day is the work of one day of the period
+type Day struct {
+	Date      time.Time
+	Completed int
+	Seconds   int
+}
+
+// Totals counts the tasks of a summary
+type Totals struct {
+	Completed     int
+	Seconds       int // Time spent, as counted by timers
+	BeforePlanned int // Finished on or before their planned date
+	DeadlinesMet  int
+	Late          int // Finished after their hard deadline
+}
+
+// Since returns the start of the period a summary of the given kind due at
+// to covers: the day or the week before it
+func Since(kind string, to time.Time) time.Time {
+	if kind == Weekly {
+		return to.AddDate(0, 0, -7)
+	}
+	return to.AddDate(0, 0, -1)
+}
+
+// Slot returns the latest time at or before now that a summary of the
+// given kind is due: each day at the clock time at ("18:00"), or for
+// weekly summaries each week on weekday at it
+func Slot(kind string, now time.Time, at string, weekday time.Weekday) (time.Time, error) {
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid report time %q, expected HH:MM", at)
+	}
+
+	slot := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if kind == Weekly {
+		slot = slot.AddDate(0, 0, -((int(slot.Weekday()) - int(weekday) + 7) % 7))
+	}
+	return slot, nil
+}
+
+// Due reports whether the summary due at slot is still to be sent at now,
+// the last one having been sent at last
+func Due(slot, last, now time.Time) bool {
+	return last.Before(slot) && now.Sub(slot) < CatchUp
+}
+
+// Retry returns how long to wait before sending a summary again after
+// failures failed attempts: a minute, doubling each time, up to an hour
+func Retry(failures int) time.Duration {
+	wait := time.Minute
+	for i := 1; i < failures && wait < time.Hour; i++ {
+		wait *= 2
+	}
+	if wait > time.Hour {
+		wait = time.Hour
+	}
+	return wait
+}
+
+// Totals counts the completed tasks and the time spent on them
+func (s *Summary) Totals() Totals {
+	var totals Totals
+	for _, task := range s.Tasks {
+		totals.Completed++
+		totals.Seconds += task.DurationExecutionRealSeconds
+		switch task.DeadlineOutcome {
+		case database.OutcomeBeforePlanned:
+			totals.BeforePlanned++
+			if task.TimeHardDeadline != nil {
+				totals.DeadlinesMet++
+			}
+		case database.OutcomeOnTime:
+			totals.DeadlinesMet++
+		case database.OutcomeLate:
+			totals.Late++
+		}
+	}
+	return totals
+}
+
+// Days breaks the period down by day, in the time zone of From
+func (s *Summary) Days() []Day {
+	var days []Day
+	for date := midnight(s.From); date.Before(s.To); date = date.AddDate(0, 0, 1) {
+		day := Day{Date: date}
+		for _, task := range s.Tasks {
+			if completed := task.TimeCompleted.In(date.Location()); !completed.Before(date) && completed.Before(date.AddDate(0, 0, 1)) {
+				day.Completed++
+				day.Seconds += task.DurationExecutionRealSeconds
+			}
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// Subject is the subject of the summary's email
+func (s *Summary) Subject() string {
+	if s.Kind == Weekly {
+		return fmt.Sprintf("Done: weekly report, %s to %s", s.From.Format("Mon 2 Jan"), s.To.Format("Mon 2 Jan"))
+	}
+	return "Done: daily summary, " + s.To.Format("Monday 2 January")
+}
+
+// Text is the summary as plain text
+func (s *Summary) Text() string {
+	totals := s.Totals()
+
+	var b strings.Builder
+	b.WriteString(s.Subject() + "\n\n")
+	fmt.Fprintf(&b, "Tasks completed: %d\n", totals.Completed)
+	fmt.Fprintf(&b, "Time spent: %s\n", duration(totals.Seconds))
+	fmt.Fprintf(&b, "Before planned date: %d\n", totals.BeforePlanned)
+	fmt.Fprintf(&b, "Deadlines met: %d\n", totals.DeadlinesMet)
+	if totals.Late > 0 {
+		fmt.Fprintf(&b, "Deadlines missed: %d\n", totals.Late)
+	}
+
+	if s.Kind == Weekly {
+		b.WriteString("\nBy day\n")
+		for _, day := range s.Days() {
+			fmt.Fprintf(&b, "  %s  %d tasks, %s\n", day.Date.Format("Mon 2 Jan"), day.Completed, duration(day.Seconds))
+		}
+	}
+
+	b.WriteString("\nCompleted tasks\n")
+	if len(s.Tasks) == 0 {
+		b.WriteString("  None\n")
+	}
+	for _, task := range s.Tasks {
+		fmt.Fprintf(&b, "  %s  %s (%s)\n", s.completedAt(&task), firstLine(task.Body), duration(task.DurationExecutionRealSeconds))
+	}
+
+	if s.Plan != nil {
+		fmt.Fprintf(&b, "\nPlan vs actual: planned %s, spent %s\n", duration(s.Plan.PlannedSeconds), duration(s.Plan.ActualSeconds))
+		for _, task := range s.Plan.Tasks {
+			fmt.Fprintf(&b, "  %s: %s (planned %s, spent %s)\n", task.Status, firstLine(task.Body), duration(task.PlannedSeconds), duration(task.ActualSeconds))
+		}
+	}
+	return b.String()
+}
+
+// Inline styles of the HTML summary, in the colors of the app
+const (
+	styleBody    = "margin:0;padding:24px;background:#0a0e27;color:#ffffff;font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',Roboto,Helvetica,Arial,sans-serif;line-height:1.5"
+	styleTitle   = "margin:0 0 20px;padding-bottom:10px;border-bottom:2px solid #00ff41;color:#00ff41;font-size:24px"
+	styleHeading = "margin:28px 0 12px;color:#00ff41;font-size:18px"
+	styleStat    = "padding:12px;border:1px solid #2a2f4a;border-radius:8px;text-align:center"
+	styleValue   = "color:#00ff41;font-size:26px;font-weight:bold"
+	styleLabel   = "color:#888888;font-size:12px;text-transform:uppercase"
+	styleItem    = "margin-bottom:8px;padding:10px 14px;border:1px solid #2a2f4a;border-radius:8px"
+	styleMeta    = "color:#888888;font-size:13px"
+	styleWarning = "color:#ffd700;font-size:13px"
+	styleFooter  = "margin-top:32px;color:#666666;font-size:12px;text-align:center"
+)
+
+// HTML is the summary as an HTML email. Every style is inline.
+func (s *Summary) HTML() string {
+	totals := s.Totals()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>%s</title></head>
+<body style="%s">
+<div style="max-width:640px;margin:0 auto">
+<h1 style="%s">%s</h1>
+`, esc(s.Subject()), styleBody, styleTitle, esc(s.Subject()))
+
+	b.WriteString(`<table role="presentation" width="100%" cellspacing="8" cellpadding="0"><tr>` + "\n")
+	stats := []struct {
+		value, label string
+	}{
+		{fmt.Sprint(totals.Completed), "Tasks completed"},
+		{duration(totals.Seconds), "Time spent"},
+		{fmt.Sprint(totals.BeforePlanned), "Before planned date"},
+		{fmt.Sprint(totals.DeadlinesMet), "Deadlines met"},
+	}
+	for _, stat := range stats {
+		fmt.Fprintf(&b, `<td style="%s"><div style="%s">%s</div><div style="%s">%s</div></td>`+"\n", styleStat, styleValue, esc(stat.value), styleLabel, stat.label)
+	}
+	b.WriteString("</tr></table>\n")
+	if totals.Late > 0 {
+		fmt.Fprintf(&b, `<p style="%s">⚠️ %d missed their hard deadline</p>`+"\n", styleWarning, totals.Late)
+	}
+
+	if s.Kind == Weekly {
+		fmt.Fprintf(&b, `<h2 style="%s">By day</h2>`+"\n", styleHeading)
+		b.WriteString(`<table role="presentation" width="100%" cellspacing="0" cellpadding="6">` + "\n")
+		for _, day := range s.Days() {
+			fmt.Fprintf(&b, `<tr><td style="%s">%s</td><td>%d tasks</td><td style="%s">%s</td></tr>`+"\n",
+				styleMeta, day.Date.Format("Mon 2 Jan"), day.Completed, styleMeta, duration(day.Seconds))
+		}
+		b.WriteString("</table>\n")
+	}
+
+	fmt.Fprintf(&b, `<h2 style="%s">Completed tasks</h2>`+"\n", styleHeading)
+	if len(s.Tasks) == 0 {
+		fmt.Fprintf(&b, `<p style="%s">None</p>`+"\n", styleMeta)
+	}
+	for _, task := range s.Tasks {
+		fmt.Fprintf(&b, `<div style="%s"><div style="%s">✅ %s · ⏱️ %s</div><div>%s</div>%s</div>`+"\n",
+			styleItem, styleMeta, s.completedAt(&task), duration(task.DurationExecutionRealSeconds), esc(utils.CleanTaskText(task.Body)), outcome(task.DeadlineOutcome))
+	}
+
+	if s.Plan != nil {
+		fmt.Fprintf(&b, `<h2 style="%s">Plan vs actual</h2>`+"\n", styleHeading)
+		fmt.Fprintf(&b, `<p style="%s">Planned %s, spent %s on completed tasks</p>`+"\n", styleMeta, duration(s.Plan.PlannedSeconds), duration(s.Plan.ActualSeconds))
+		for _, task := range s.Plan.Tasks {
+			fmt.Fprintf(&b, `<div style="%s"><div style="%s">%s</div><div>%s</div><div style="%s">Planned %s, spent %s</div></div>`+"\n",
+				styleItem, styleMeta, esc(task.Status), esc(utils.CleanTaskText(task.Body)), styleMeta, duration(task.PlannedSeconds), duration(task.ActualSeconds))
+		}
+	}
+
+	fmt.Fprintf(&b, `<div style="%s">Sent by Done. Turn these emails off with "done reports --%s off".</div>
+</div>
+</body>
+</html>
+`, styleFooter, s.Kind)
+	return b.String()
+}
+
+// completedAt formats when a task was completed, with the day in weekly
+// summaries
+func (s *Summary) completedAt(task *database.Task) string {
+	completed := task.TimeCompleted.In(s.To.Location())
+	if s.Kind == Weekly {
+		return completed.Format("Mon 15:04")
+	}
+	return completed.Format("15:04")
+}
+
+// outcome is the HTML line of a task's deadline outcome, if it is worth
+// telling
+func outcome(outcome string) string {
+	switch outcome {
+	case database.OutcomeBeforePlanned:
+		return `<div style="` + styleWarning + `">🎯 Finished before the planned date</div>`
+	case database.OutcomeOnTime:
+		return `<div style="` + styleWarning + `">⏰ Made the hard deadline</div>`
+	case database.OutcomeLate:
+		return `<div style="` + styleWarning + `">⚠️ Missed the hard deadline</div>`
+	}
+	return ""
+}
+
+// duration formats time spent, 0m when none
+func duration(seconds int) string {
+	if seconds <= 0 {
+		return "0m"
+	}
+	return utils.FormatEstimate(seconds)
+}
+
+func esc(text string) string {
+	return html.EscapeString(text)
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(utils.CleanTaskText(text)), "\n")
+	return line
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,144 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// Monday 19 October 2026
+var monday = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+func at(day, hour, minute int) time.Time {
+	return monday.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+func TestSlotDaily(t *testing.T) {
+	cases := []struct {
+		now, want time.Time
+	}{
+		{at(0, 18, 0), at(0, 18, 0)},
+		{at(0, 20, 15), at(0, 18, 0)},
+		{at(0, 17, 59), at(-1, 18, 0)},
+		{at(1, 9, 0), at(0, 18, 0)},
+	}
+	for _, c := range cases {
+		if got, err := Slot(Daily, c.now, "18:00", 0); err != nil || !got.Equal(c.want) {
+			t.Errorf("Slot(daily, %v) = %v, %v; want %v", c.now, got, err, c.want)
+		}
+	}
+}
+
+func TestSlotWeekly(t *testing.T) {
+	cases := []struct {
+		now, want time.Time
+	}{
+		{at(4, 17, 0), at(4, 17, 0)},  // Friday at the time
+		{at(4, 16, 0), at(-3, 17, 0)}, // Friday before it: last week's
+		{at(6, 10, 0), at(4, 17, 0)},  // Sunday
+		{at(7, 10, 0), at(4, 17, 0)},  // Next Monday
+	}
+	for _, c := range cases {
+		if got, err := Slot(Weekly, c.now, "17:00", time.Friday); err != nil || !got.Equal(c.want) {
+			t.Errorf("Slot(weekly, %v) = %v, %v; want %v", c.now, got, err, c.want)
+		}
+	}
+}
+
+func TestSlotInvalidTime(t *testing.T) {
+	for _, bad := range []string{"", "6pm", "25:00"} {
+		if _, err := Slot(Daily, monday, bad, 0); err == nil {
+			t.Errorf("Slot accepted %q", bad)
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	slot := at(0, 18, 0)
+	cases := []struct {
+		name      string
+		last, now time.Time
+		want      bool
+	}{
+		{"never sent", time.Time{}, slot, true},
+		{"sent yesterday", at(-1, 18, 0), slot.Add(time.Minute), true},
+		{"already sent", slot.Add(time.Second), slot.Add(time.Minute), false},
+		{"server was down for hours", at(-1, 18, 0), at(1, 9, 0), true},
+		{"missed by over a day", at(-2, 18, 0), at(1, 18, 0), false},
+	}
+	for _, c := range cases {
+		if got := Due(slot, c.last, c.now); got != c.want {
+			t.Errorf("%s: Due = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for failures, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 7: time.Hour, 50: time.Hour} {
+		if got := Retry(failures); got != want {
+			t.Errorf("Retry(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func completed(when time.Time, body string, seconds int, outcome string) database.Task {
+	return database.Task{Body: body, TimeCompleted: when, DurationExecutionRealSeconds: seconds, DeadlineOutcome: outcome}
+}
+
+func TestTotalsAndDays(t *testing.T) {
+	deadline := at(3, 12, 0)
+	s := &Summary{
+		Kind: Weekly,
+		From: at(-3, 0, 0),
+		To:   at(4, 0, 0),
+		Tasks: []database.Task{
+			completed(at(-2, 10, 0), "Write spec", 3600, database.OutcomeBeforePlanned),
+			completed(at(0, 9, 0), "Review", 1800, database.OutcomeOnTime),
+			completed(at(0, 15, 0), "Deploy", 600, database.OutcomeLate),
+		},
+	}
+	s.Tasks[0].TimeHardDeadline = &deadline
+
+	want := Totals{Completed: 3, Seconds: 6000, BeforePlanned: 1, DeadlinesMet: 2, Late: 1}
+	if got := s.Totals(); got != want {
+		t.Errorf("Totals = %+v, want %+v", got, want)
+	}
+
+	days := s.Days()
+	if len(days) != 7 {
+		t.Fatalf("got %d days, want 7", len(days))
+	}
+	if days[1].Completed != 1 || days[3].Completed != 2 || days[3].Seconds != 2400 || days[0].Completed != 0 {
+		t.Errorf("Days = %+v", days)
+	}
+}
+
+func TestHTMLEscapesAndInlinesStyles(t *testing.T) {
+	s := &Summary{
+		Kind:  Daily,
+		From:  at(-1, 18, 0),
+		To:    at(0, 18, 0),
+		Tasks: []database.Task{completed(at(0, 11, 0), "Fix <script>alert(1)</script> & ship", 60, "")},
+		Plan:  &Plan{PlannedSeconds: 3600, Tasks: []PlanTask{{Status: "Planned, still open", Body: "Write the docs"}}},
+	}
+
+	page := s.HTML()
+	if strings.Contains(page, "<script>") || !strings.Contains(page, "&lt;script&gt;") {
+		t.Error("task text is not escaped")
+	}
+	if strings.Contains(page, "<style") || strings.Contains(page, "class=") {
+		t.Error("styles are not all inline")
+	}
+	if !strings.Contains(page, "Plan vs actual") || !strings.Contains(page, "Write the docs") {
+		t.Error("plan section is missing")
+	}
+
+	text := s.Text()
+	for _, want := range []string{"Done: daily summary, Monday 19 October", "Tasks completed: 1", "11:00  Fix <script>alert(1)</script> & ship (1m)", "Planned, still open: Write the docs"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text lacks %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Package estimator suggests how long a new task will take, learned from the
+// estimated and real durations of completed tasks.
+package estimator
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	database "done/lib/database/interface"
+)
+
+const (
+	neighbours    = 5   // Similar tasks a suggestion is based on
+	minSimilarity = 0.2 // Below this, a past task is not considered similar
+	minTagSamples = 2   // Tasks a tag needs before it has its own factor
+)
+
+// Confidence of a suggestion
+const (
+	ConfidenceNone   = "none"   // Nothing to learn from
+	ConfidenceLow    = "low"    // Little or scattered history
+	ConfidenceMedium = "medium" // Some consistent history
+	ConfidenceHigh   = "high"   // Several similar tasks that took about as long
+)
+
+// Input describes the task to estimate. EstimateSeconds is the user's own
+// estimate, if any, which is corrected by how their past estimates turned
+// out.
+type Input struct {
+	Body            string   `json:"body"`
+	Tags            []string `json:"tags"`
+	EstimateSeconds int      `json:"estimate_seconds"`
+}
+
+// Suggestion is a duration with a range the real duration likely falls in
+type Suggestion struct {
+	Seconds      int                `json:"seconds"`
+	LowSeconds   int                `json:"low_seconds"`
+	HighSeconds  int                `json:"high_seconds"`
+	Confidence   string             `json:"confidence"`
+	Ratio        float64            `json:"ratio"`         // Real time over estimated time, over all tasks; above 1 means underestimating
+	RatioSamples int                `json:"ratio_samples"` // Tasks the ratio is based on
+	TagFactors   map[string]float64 `json:"tag_factors"`   // Ratio of each input tag with enough history
+	Similar      []Match            `json:"similar"`
+}
+
+// Match is a past task similar to the input
+type Match struct {
+	UUID        string  `json:"uuid"`
+	Body        string  `json:"body"`
+	RealSeconds int     `json:"real_seconds"`
+	Similarity  float64 `json:"similarity"`
+}
+
+// sample is a completed task reduced to what the model needs
+type sample struct {
+	task   *database.Task
+	vector map[string]float64
+}
+
+// Model holds the completed tasks with a recorded real duration
+type Model struct {
+	samples   []sample
+	idf       map[string]float64
+	logRatios []float64            // log(real/estimated) of every task with both
+	tagRatios map[string][]float64 // The same, by tag
+}
+
+// New builds a model from completed tasks. Tasks without a recorded real
+// duration are ignored.
+func New(completed []database.Task) *Model {
+	m := &Model{idf: make(map[string]float64), tagRatios: make(map[string][]float64)}
+
+	var documents [][]string
+	for i := range completed {
+		task := &completed[i]
+		if task.DurationExecutionRealSeconds <= 0 {
+			continue
+		}
+		documents = append(documents, terms(task.Body, task.Tags))
+		m.samples = append(m.samples, sample{task: task})
+
+		if task.DurationExecutionEstimatedSeconds > 0 {
+			r := math.Log(float64(task.DurationExecutionRealSeconds) / float64(task.DurationExecutionEstimatedSeconds))
+			m.logRatios = append(m.logRatios, r)
+			for _, tag := range uniqueTags(task.Tags) {
+				m.tagRatios[tag] = append(m.tagRatios[tag], r)
+			}
+		}
+	}
+
+	df := make(map[string]int)
+	for _, document := range documents {
+		for term := range counts(document) {
+			df[term]++
+		}
+	}
+	for term, n := range df {
+		m.idf[term] = math.Log(float64(len(documents)+1)/float64(n+1)) + 1
+	}
+	for i, document := range documents {
+		m.samples[i].vector = m.vectorize(document)
+	}
+
+	return m
+}
+
+// Suggest estimates the input from the durations of similar past tasks and,
+// when the input has its own estimate, from that estimate corrected by the
+// ratio of real to estimated time of past tasks with the same tags, or of all
+// past tasks.
+func (m *Model) Suggest(in Input) *Suggestion {
+	s := &Suggestion{
+		Confidence: ConfidenceNone,
+		TagFactors: map[string]float64{},
+		Similar:    []Match{},
+	}
+
+	if len(m.logRatios) > 0 {
+		s.Ratio = round(math.Exp(median(m.logRatios)))
+		s.RatioSamples = len(m.logRatios)
+	}
+
+	// Each value is the logarithm of a duration the task could take
+	var values, weights []float64
+
+	for _, match := range m.similar(in) {
+		s.Similar = append(s.Similar, match)
+		values = append(values, math.Log(float64(match.RealSeconds)))
+		weights = append(weights, match.Similarity)
+	}
+
+	if in.EstimateSeconds > 0 {
+		ratios := m.logRatios
+		var tagged []float64
+		for _, tag := range uniqueTags(in.Tags) {
+			if r := m.tagRatios[tag]; len(r) >= minTagSamples {
+				s.TagFactors[tag] = round(math.Exp(median(r)))
+				tagged = append(tagged, r...)
+			}
+		}
+		if len(tagged) > 0 {
+			ratios = tagged
+		}
+
+		estimate := math.Log(float64(in.EstimateSeconds))
+		if len(ratios) == 0 {
+			ratios = []float64{0}
+		}
+		// The user's estimate counts as much as one very similar task
+		for _, r := range ratios {
+			values = append(values, estimate+r)
+			weights = append(weights, 1/float64(len(ratios)))
+		}
+	}
+
+	if len(values) == 0 {
+		return s
+	}
+
+	mean, spread := weightedStats(values, weights)
+	// Few samples say little about the spread
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	spread = math.Max(spread, 0.5/math.Sqrt(total))
+
+	s.Seconds = roundSeconds(math.Exp(mean))
+	s.LowSeconds = roundSeconds(math.Exp(mean - spread))
+	s.HighSeconds = roundSeconds(math.Exp(mean + spread))
+
+	switch {
+	case len(s.Similar) >= 3 && spread < 0.4:
+		s.Confidence = ConfidenceHigh
+	case total >= 1 && spread < 0.8:
+		s.Confidence = ConfidenceMedium
+	default:
+		s.Confidence = ConfidenceLow
+	}
+	return s
+}
+
+// similar returns the past tasks most similar to the input, most similar
+// first
+func (m *Model) similar(in Input) []Match {
+	query := m.vectorize(terms(in.Body, in.Tags))
+	if len(query) == 0 {
+		return nil
+	}
+
+	var matches []Match
+	for _, sample := range m.samples {
+		similarity := 0.0
+		for term, weight := range query {
+			similarity += weight * sample.vector[term]
+		}
+		if similarity < minSimilarity {
+			continue
+		}
+		matches = append(matches, Match{
+			UUID:        sample.task.UUID,
+			Body:        sample.task.Body,
+			RealSeconds: sample.task.DurationExecutionRealSeconds,
+			Similarity:  round(similarity),
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	if len(matches) > neighbours {
+		matches = matches[:neighbours]
+	}
+	return matches
+}
+
+// vectorize weighs the terms of a document by TF-IDF and normalizes the
+// vector to unit length. Terms never seen in the history are left out.
+func (m *Model) vectorize(document []string) map[string]float64 {
+	vector := make(map[string]float64)
+	norm := 0.0
+	for term, n := range counts(document) {
+		idf, ok := m.idf[term]
+		if !ok {
+			continue
+		}
+		vector[term] = float64(n) * idf
+		norm += vector[term] * vector[term]
+	}
+	norm = math.Sqrt(norm)
+	for term := range vector {
+		vector[term] /= norm
+	}
+	return vector
+}
+
+// stopWords are too common to tell tasks apart
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "at": true, "by": true, "for": true,
+	"from": true, "in": true, "into": true, "it": true, "of": true, "on": true,
+	"or": true, "the": true, "to": true, "with": true,
+}
+
+// terms splits a task into lowercase words and "#tag" terms
+func terms(body string, tags []string) []string {
+	var result []string
+	words := strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if len([]rune(word)) < 2 || stopWords[word] {
+			continue
+		}
+		result = append(result, stem(word))
+	}
+	for _, tag := range uniqueTags(tags) {
+		result = append(result, "#"+tag)
+	}
+	return result
+}
+
+// stem strips common English endings, so "migrations" and "migration" or
+// "fixing" and "fix" are the same term
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s", "e"} {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+func uniqueTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func counts(document []string) map[string]int {
+	result := make(map[string]int, len(document))
+	for _, term := range document {
+		result[term]++
+	}
+	return result
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// weightedStats returns the weighted mean and standard deviation
+func weightedStats(values, weights []float64) (mean, deviation float64) {
+	total := 0.0
+	for i, v := range values {
+		mean += v * weights[i]
+		total += weights[i]
+	}
+	mean /= total
+
+	for i, v := range values {
+		deviation += weights[i] * (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(deviation / total)
+}
+
+// roundSeconds rounds a duration to whole minutes, and at least one minute
+func roundSeconds(seconds float64) int {
+	minutes := math.Round(seconds / 60)
+	if minutes < 1 {
+		minutes = 1
+	}
+	return int(minutes) * 60
+}
+
+func round(x float64) float64 {
+	return math.Round(x*100) / 100
+}
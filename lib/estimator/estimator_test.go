@@ -0,0 +1,77 @@
+package estimator
+
+import (
+	"testing"
+
+	database "done/lib/database/interface"
+)
+
+func done(body string, tags []string, estimated, real int) database.Task {
+	return database.Task{
+		UUID:                              body,
+		Body:                              body,
+		Tags:                              tags,
+		DurationExecutionEstimatedSeconds: estimated,
+		DurationExecutionRealSeconds:      real,
+	}
+}
+
+var history = []database.Task{
+	done("Migrate billing database", []string{"backend"}, 3600, 7200),
+	done("Migrate users database", []string{"backend"}, 3600, 7800),
+	done("Migrating search database", []string{"backend"}, 3600, 6600),
+	done("Write release notes", []string{"docs"}, 1800, 1800),
+	done("Write onboarding guide", []string{"docs"}, 3600, 3600),
+	done("Call the bank", nil, 600, 900),
+	done("Never timed", nil, 600, 0),
+}
+
+func TestSuggestFromSimilarTasks(t *testing.T) {
+	s := New(history).Suggest(Input{Body: "Migrate orders database"})
+
+	if len(s.Similar) != 3 {
+		t.Fatalf("similar = %+v", s.Similar)
+	}
+	if s.Seconds < 6600 || s.Seconds > 7800 {
+		t.Errorf("seconds = %d, want about 2h", s.Seconds)
+	}
+	if s.LowSeconds >= s.Seconds || s.HighSeconds <= s.Seconds {
+		t.Errorf("range %d-%d does not contain %d", s.LowSeconds, s.HighSeconds, s.Seconds)
+	}
+	if s.Confidence != ConfidenceHigh {
+		t.Errorf("confidence = %s", s.Confidence)
+	}
+}
+
+func TestSuggestCorrectsOwnEstimateByTag(t *testing.T) {
+	m := New(history)
+
+	backend := m.Suggest(Input{Body: "Rotate certificates", Tags: []string{"backend"}, EstimateSeconds: 3600})
+	if backend.TagFactors["backend"] < 1.8 || backend.Seconds < 6000 {
+		t.Errorf("backend: factors %v, seconds %d", backend.TagFactors, backend.Seconds)
+	}
+
+	docs := m.Suggest(Input{Body: "Proofread FAQ", Tags: []string{"#Docs"}, EstimateSeconds: 3600})
+	if docs.TagFactors["docs"] != 1 || docs.Seconds > 4000 {
+		t.Errorf("docs: factors %v, seconds %d", docs.TagFactors, docs.Seconds)
+	}
+}
+
+func TestRatio(t *testing.T) {
+	s := New(history).Suggest(Input{Body: "Something new"})
+
+	if s.RatioSamples != 6 || s.Ratio <= 1 {
+		t.Errorf("ratio = %v over %d tasks", s.Ratio, s.RatioSamples)
+	}
+	if s.Seconds != 0 || s.Confidence != ConfidenceNone {
+		t.Errorf("unrelated task got a suggestion: %+v", s)
+	}
+}
+
+func TestStem(t *testing.T) {
+	for word, want := range map[string]string{"migrating": "migrat", "migrate": "migrat", "fixed": "fix", "notes": "not", "bus": "bus"} {
+		if got := stem(word); got != want {
+			t.Errorf("stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package events is an in-memory publish/subscribe bus that keeps the most
+// recent events, so a subscriber that reconnects can catch up on what it
+// missed.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped
+const subscriberBuffer = 64
+
+// Event is something that happened, numbered in publishing order from 1
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Bus delivers published events to every subscriber. It is safe for
+// concurrent use.
+type Bus struct {
+	mu          sync.Mutex
+	epoch       string // Tells the IDs of this bus from those of one before a restart
+	lastID      uint64
+	history     []Event // The latest events, oldest first
+	size        int     // Maximum length of history
+	subscribers map[*Subscription]bool
+}
+
+// Subscription receives the events published after it was made
+type Subscription struct {
+	// C is closed when the subscription is closed, or when the subscriber
+	// fell too far behind; it should then subscribe again from the last
+	// event it received
+	C <-chan Event
+
+	bus *Bus
+	c   chan Event
+}
+
+// NewBus returns a bus that keeps the latest size events for replay
+func NewBus(size int) *Bus {
+	epoch := make([]byte, 6)
+	rand.Read(epoch)
+	return &Bus{epoch: hex.EncodeToString(epoch), size: size, subscribers: make(map[*Subscription]bool)}
+}
+
+// Publish numbers an event and sends it to the subscribers
+func (b *Bus) Publish(typ string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: b.lastID, Type: typ, Time: time.Now(), Data: data}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.size {
+		b.history = append(b.history[:0], b.history[len(b.history)-b.size:]...)
+	}
+
+	for s := range b.subscribers {
+		select {
+		case s.c <- event:
+		default:
+			b.drop(s)
+		}
+	}
+	return event
+}
+
+// Subscribe starts receiving events. Given the ID of the last event a
+// subscriber saw, it also returns the events published since; complete is
+// false when some of them are no longer kept, or when lastID is not one of
+// this bus, and the subscriber should reload everything instead. A lastID of
+// 0 subscribes from now on.
+func (b *Bus) Subscribe(lastID uint64) (s *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Event, subscriberBuffer)
+	s = &Subscription{C: c, bus: b, c: c}
+	b.subscribers[s] = true
+
+	if lastID == 0 || lastID == b.lastID {
+		return s, nil, true
+	}
+	if lastID > b.lastID {
+		return s, nil, false
+	}
+
+	complete = len(b.history) > 0 && b.history[0].ID <= lastID+1
+	for _, event := range b.history {
+		if event.ID > lastID {
+			missed = append(missed, event)
+		}
+	}
+	return s, missed, complete
+}
+
+// LastID returns the ID of the latest event, or 0 if there is none
+func (b *Bus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// Cursor returns the ID of an event as clients see it, <epoch>-<id>. IDs
+// start again from 1 when the server restarts, and the epoch tells them
+// apart.
+func (b *Bus) Cursor(id uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(id, 10)
+}
+
+// ParseCursor returns the event ID of a cursor from Cursor. ok is false
+// when the cursor is malformed or from another bus, such as the one before
+// a restart.
+func (b *Bus) ParseCursor(cursor string) (id uint64, ok bool) {
+	epoch, number, found := strings.Cut(cursor, "-")
+	if !found || epoch != b.epoch {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(number, 10, 64)
+	return id, err == nil
+}
+
+// Close stops the subscription and closes its channel
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
+
+func (b *Bus) drop(s *Subscription) {
+	if b.subscribers[s] {
+		delete(b.subscribers, s)
+		close(s.c)
+	}
+}
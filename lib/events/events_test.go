@@ -0,0 +1,96 @@
+package events
+
+import "testing"
+
+func ids(events []Event) []uint64 {
+	var result []uint64
+	for _, event := range events {
+		result = append(result, event.ID)
+	}
+	return result
+}
+
+func TestSubscribeReceivesPublishedEvents(t *testing.T) {
+	bus := NewBus(10)
+	s, missed, complete := bus.Subscribe(0)
+	defer s.Close()
+	if len(missed) != 0 || !complete {
+		t.Fatalf("fresh subscription: missed %v, complete %v", ids(missed), complete)
+	}
+
+	bus.Publish("task.added", "a")
+	event := <-s.C
+	if event.ID != 1 || event.Type != "task.added" || event.Data != "a" {
+		t.Fatalf("got %+v", event)
+	}
+}
+
+func TestSubscribeReplaysMissedEvents(t *testing.T) {
+	bus := NewBus(3)
+	for i := 0; i < 5; i++ {
+		bus.Publish("task.updated", i)
+	}
+
+	cases := []struct {
+		lastID   uint64
+		missed   []uint64
+		complete bool
+	}{
+		{5, nil, true},
+		{3, []uint64{4, 5}, true},
+		{2, []uint64{3, 4, 5}, true},
+		{1, []uint64{3, 4, 5}, false}, // Event 2 is no longer kept
+		{9, nil, false},               // From before a restart
+	}
+	for _, c := range cases {
+		s, missed, complete := bus.Subscribe(c.lastID)
+		s.Close()
+		if got := ids(missed); len(got) != len(c.missed) || complete != c.complete {
+			t.Errorf("after %d: missed %v, complete %v; want %v, %v", c.lastID, got, complete, c.missed, c.complete)
+			continue
+		}
+		for i := range c.missed {
+			if missed[i].ID != c.missed[i] {
+				t.Errorf("after %d: missed %v, want %v", c.lastID, ids(missed), c.missed)
+				break
+			}
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	bus := NewBus(10)
+	for i := 0; i < 3; i++ {
+		bus.Publish("task.added", i)
+	}
+
+	if id, ok := bus.ParseCursor(bus.Cursor(2)); !ok || id != 2 {
+		t.Errorf("ParseCursor(Cursor(2)) = %d, %v", id, ok)
+	}
+
+	// After a restart the new bus numbers its events from 1 again
+	restarted := NewBus(10)
+	for _, cursor := range []string{restarted.Cursor(2), "2", "", bus.Cursor(2) + "x"} {
+		if _, ok := bus.ParseCursor(cursor); ok {
+			t.Errorf("ParseCursor(%q) accepted", cursor)
+		}
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	bus := NewBus(1)
+	s, _, _ := bus.Subscribe(0)
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		bus.Publish("task.updated", i)
+	}
+
+	received := 0
+	for range s.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Fatalf("received %d events before the channel closed, want %d", received, subscriberBuffer)
+	}
+	s.Close() // Closing again is harmless
+}
@@ -0,0 +1,159 @@
+// Package hooks runs executables from a hooks directory when something
+// happens, like Taskwarrior hooks. A hook gets the event as JSON on its
+// standard input. Several hooks of the same kind are named after it with a
+// dot, e.g. on-add.10-log and on-add.20-notify, and run in name order.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long a hook may run before it is killed
+const DefaultTimeout = 10 * time.Second
+
+// ErrRejected is wrapped by the error of a filter hook that refused its
+// input by exiting with a non-zero status
+var ErrRejected = errors.New("rejected by hook")
+
+// Runner runs the hooks in a directory. A nil Runner has no hooks.
+type Runner struct {
+	Dir     string
+	Timeout time.Duration // DefaultTimeout when zero
+}
+
+// New returns a runner of the hooks in dir
+func New(dir string, timeout time.Duration) *Runner {
+	return &Runner{Dir: dir, Timeout: timeout}
+}
+
+// Scripts returns the paths of the executables for hooks of the given kind
+// in name order. A missing directory has none.
+func (r *Runner) Scripts(kind string) ([]string, error) {
+	if r == nil || r.Dir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(r.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var scripts []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if name != kind && !strings.HasPrefix(name, kind+".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		scripts = append(scripts, filepath.Join(r.Dir, name))
+	}
+	sort.Strings(scripts)
+	return scripts, nil
+}
+
+// Run runs every hook of the given kind with input on its standard input.
+// All of them run even if some fail; the error tells which did.
+func (r *Runner) Run(kind string, input []byte) error {
+	scripts, err := r.Scripts(kind)
+	if err != nil {
+		return err
+	}
+
+	var failed []error
+	for _, script := range scripts {
+		_, err := r.run(script, input)
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			err = fmt.Errorf("hook %s failed with %v: %s", filepath.Base(script), exit, reason(nil, exit.Stderr))
+		}
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// Filter passes input through the hooks of the given kind in turn. Each
+// hook may write a replacement to its standard output, which the next one
+// gets; one that writes nothing leaves it as it is. A hook that exits with
+// a non-zero status rejects the input, giving the reason on its output.
+func (r *Runner) Filter(kind string, input []byte) ([]byte, error) {
+	scripts, err := r.Scripts(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, script := range scripts {
+		output, err := r.run(script, input)
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return nil, fmt.Errorf("%w %s: %s", ErrRejected, filepath.Base(script), reason(output, exit.Stderr))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(output)) > 0 {
+			input = output
+		}
+	}
+	return input, nil
+}
+
+// run runs one hook, returning what it wrote to its standard output. An
+// *exec.ExitError carries its standard error.
+func (r *Runner) run(script string, input []byte) ([]byte, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, script)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait for children that keep the output open after a kill
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("hook %s timed out after %v", filepath.Base(script), timeout)
+	}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		exit.Stderr = stderr.Bytes()
+		return stdout.Bytes(), exit
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hook %s: %w", filepath.Base(script), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// reason returns the first line a rejecting hook wrote, preferring its
+// standard output
+func reason(stdout, stderr []byte) string {
+	for _, output := range [][]byte{stdout, stderr} {
+		if line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0]); line != "" {
+			return line
+		}
+	}
+	return "no reason given"
+}
@@ -0,0 +1,104 @@
+package hooks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// script writes an executable shell script to dir
+func script(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScripts(t *testing.T) {
+	dir := t.TempDir()
+	script(t, dir, "on-add.20-notify", "true")
+	script(t, dir, "on-add", "true")
+	script(t, dir, "on-add.10-log", "true")
+	script(t, dir, "on-added", "true")
+	script(t, dir, "on-complete", "true")
+	if err := os.WriteFile(filepath.Join(dir, "on-add.30-disabled"), []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	scripts, err := New(dir, 0).Scripts("on-add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range scripts {
+		names = append(names, filepath.Base(s))
+	}
+	if got := strings.Join(names, " "); got != "on-add on-add.10-log on-add.20-notify" {
+		t.Errorf("Scripts = %s", got)
+	}
+
+	if scripts, err := New(filepath.Join(dir, "missing"), 0).Scripts("on-add"); err != nil || scripts != nil {
+		t.Errorf("missing directory: %v, %v", scripts, err)
+	}
+	var none *Runner
+	if scripts, err := none.Scripts("on-add"); err != nil || scripts != nil {
+		t.Errorf("nil runner: %v, %v", scripts, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script(t, dir, "on-add.1", "cat >> "+out)
+	script(t, dir, "on-add.2", "echo broken >&2; exit 3")
+	script(t, dir, "on-add.3", "cat >> "+out)
+
+	err := New(dir, 0).Run("on-add", []byte("event\n"))
+	if err == nil || !strings.Contains(err.Error(), "on-add.2") || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Run error = %v", err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "event\nevent\n" {
+		t.Errorf("hooks got %q", data)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	dir := t.TempDir()
+	script(t, dir, "pre-add.1", "sed s/draft/final/")
+	script(t, dir, "pre-add.2", "cat > /dev/null")
+	script(t, dir, "pre-add.3", "tr a-z A-Z")
+
+	output, err := New(dir, 0).Filter("pre-add", []byte("draft"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(output)) != "FINAL" {
+		t.Errorf("Filter = %q", output)
+	}
+
+	script(t, dir, "pre-add.4", "echo 'Tasks need a tag'; exit 1")
+	_, err = New(dir, 0).Filter("pre-add", []byte("draft"))
+	if !errors.Is(err, ErrRejected) || !strings.HasSuffix(err.Error(), "pre-add.4: Tasks need a tag") {
+		t.Errorf("rejection = %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	dir := t.TempDir()
+	script(t, dir, "pre-add", "sleep 5")
+
+	start := time.Now()
+	_, err := New(dir, 100*time.Millisecond).Filter("pre-add", []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("err = %v", err)
+	}
+	if errors.Is(err, ErrRejected) {
+		t.Error("a timeout is not a rejection")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("took %v", elapsed)
+	}
+}
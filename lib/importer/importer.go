@@ -0,0 +1,169 @@
+// Package importer reads task lists exported from other task managers
+// (Taskwarrior JSON, todo.txt and Todoist CSV) and maps them onto Done tasks.
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// Supported source formats
+const (
+	FormatTaskwarrior = "taskwarrior"
+	FormatTodoTxt     = "todotxt"
+	FormatTodoist     = "todoist"
+)
+
+// Notice describes an entry of the source that was skipped or only partly
+// imported
+type Notice struct {
+	Line   int    `json:"line"` // Line or entry number in the source, from 1
+	Text   string `json:"text"`
+	Reason string `json:"reason"`
+}
+
+// Result holds the parsed tasks, in the order they should appear in the task
+// list, and what could not be imported
+type Result struct {
+	Format   string
+	Tasks    []database.Task
+	Skipped  []Notice
+	Warnings []Notice
+}
+
+// entry is a parsed task with the information needed to order it
+type entry struct {
+	task database.Task
+	// rank orders tasks by priority: 1 is the highest, noPriority the lowest
+	rank int
+}
+
+const noPriority = 1 << 30
+
+// Detect guesses the format of an export from its content
+func Detect(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	trimmed = bytes.TrimPrefix(trimmed, []byte("\xef\xbb\xbf"))
+
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return FormatTaskwarrior
+	}
+
+	firstLine := trimmed
+	if i := bytes.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if bytes.HasPrefix(bytes.ToUpper(firstLine), []byte("TYPE,CONTENT")) {
+		return FormatTodoist
+	}
+
+	return FormatTodoTxt
+}
+
+// Parse reads an export in the given format, detecting it when format is
+// empty or "auto". Tasks are ordered by priority, highest first, and keep
+// the source order otherwise.
+func Parse(format string, data []byte) (*Result, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	if format == "" || format == "auto" {
+		format = Detect(data)
+	}
+
+	result := &Result{Format: format}
+
+	var entries []entry
+	var err error
+	switch format {
+	case FormatTaskwarrior:
+		entries, err = parseTaskwarrior(data, result)
+	case FormatTodoTxt:
+		entries, err = parseTodoTxt(data, result)
+	case FormatTodoist:
+		entries, err = parseTodoist(data, result)
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].rank < entries[j].rank
+	})
+
+	for i, e := range entries {
+		e.task.Order = i
+		e.task.Priority = rankPriority(e.rank)
+		result.Tasks = append(result.Tasks, e.task)
+	}
+
+	return result, nil
+}
+
+// rankPriority maps a source priority rank onto a task priority: the first
+// rank is high, the second medium and any lower one low
+func rankPriority(rank int) string {
+	switch {
+	case rank == noPriority:
+		return ""
+	case rank <= 1:
+		return database.PriorityHigh
+	case rank == 2:
+		return database.PriorityMedium
+	default:
+		return database.PriorityLow
+	}
+}
+
+// addTag appends tag unless it is empty or already present
+func addTag(task *database.Task, tag string) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return
+	}
+	for _, existing := range task.Tags {
+		if strings.EqualFold(existing, tag) {
+			return
+		}
+	}
+	task.Tags = append(task.Tags, tag)
+}
+
+// setDeadline sets the hard deadline, treating midnight and the last second
+// of a day as a deadline without a time of day
+func setDeadline(task *database.Task, t time.Time) {
+	t = t.In(time.Local)
+	hour, min, sec := t.Clock()
+	switch {
+	case hour == 0 && min == 0 && sec == 0:
+		task.DeadlineHasTime = false
+	case hour == 23 && min == 59 && sec == 59:
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		task.DeadlineHasTime = false
+	default:
+		task.DeadlineHasTime = true
+	}
+	task.TimeHardDeadline = &t
+}
+
+// setPlanned sets the planned date to the day of t
+func setPlanned(task *database.Task, t time.Time) {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	task.TimePlanned = &day
+}
+
+// shorten trims source text quoted in notices
+func shorten(text string) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) > 80 {
+		return string(runes[:77]) + "..."
+	}
+	return string(runes)
+}
@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		`[{"description":"a"}]`:                        FormatTaskwarrior,
+		"{\"description\":\"a\"}\n":                    FormatTaskwarrior,
+		"TYPE,CONTENT,PRIORITY\ntask,Buy milk,1\n":     FormatTodoist,
+		"(A) Call mom +family @phone due:2026-11-01\n": FormatTodoTxt,
+	}
+	for input, want := range cases {
+		if got := Detect([]byte(input)); got != want {
+			t.Errorf("Detect(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParseTaskwarrior(t *testing.T) {
+	export := `[
+{"id":1,"description":"Write report","status":"pending","entry":"20261001T080000Z","due":"20261105T150000Z","project":"work","tags":["writing"],"priority":"L","estimate":"PT1H30M","annotations":[{"entry":"20261001T080000Z","description":"see notes"}]},
+{"id":0,"description":"Old task","status":"completed"},
+{"id":2,"description":"Fix bug","status":"pending","priority":"H","scheduled":"20261102T100000Z"}
+]`
+
+	result, err := Parse(FormatTaskwarrior, []byte(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Tasks) != 2 || len(result.Skipped) != 1 {
+		t.Fatalf("got %d tasks and %d skipped, want 2 and 1", len(result.Tasks), len(result.Skipped))
+	}
+
+	fix, report := result.Tasks[0], result.Tasks[1]
+	if fix.Body != "Fix bug" || fix.Order != 0 || fix.TimePlanned == nil || fix.Priority != "high" {
+		t.Errorf("high priority task = %+v", fix)
+	}
+	if report.Body != "Write report\nsee notes" || report.Order != 1 {
+		t.Errorf("body = %q, order = %d", report.Body, report.Order)
+	}
+	if report.DurationExecutionEstimatedSeconds != 90*60 {
+		t.Errorf("estimate = %d", report.DurationExecutionEstimatedSeconds)
+	}
+	if !reflect.DeepEqual(report.Tags, []string{"work", "writing"}) {
+		t.Errorf("tags = %q", report.Tags)
+	}
+	want := time.Date(2026, 11, 5, 15, 0, 0, 0, time.UTC)
+	if report.TimeHardDeadline == nil || !report.TimeHardDeadline.Equal(want) {
+		t.Errorf("deadline = %v, want %v", report.TimeHardDeadline, want)
+	}
+}
+
+func TestParseTodoTxt(t *testing.T) {
+	todo := `(B) 2026-10-01 Call @mom about the party +family @phone due:2026-11-01 est:30m
+x 2026-10-02 Done already
+Buy milk
+(A) Pay rent t:2026-10-28 see http://bank.example
+`
+
+	result, err := Parse(FormatTodoTxt, []byte(todo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Tasks) != 3 || len(result.Skipped) != 1 {
+		t.Fatalf("got %d tasks and %d skipped, want 3 and 1", len(result.Tasks), len(result.Skipped))
+	}
+
+	rent, call, milk := result.Tasks[0], result.Tasks[1], result.Tasks[2]
+	if rent.Body != "Pay rent see http://bank.example" || rent.TimePlanned == nil {
+		t.Errorf("rent = %+v", rent)
+	}
+	if call.Body != "Call @mom about the party" {
+		t.Errorf("body = %q", call.Body)
+	}
+	if !reflect.DeepEqual(call.Tags, []string{"mom", "family", "phone"}) {
+		t.Errorf("tags = %q", call.Tags)
+	}
+	if call.TimeHardDeadline == nil || call.DeadlineHasTime || call.TimeHardDeadline.Day() != 1 {
+		t.Errorf("deadline = %v, has time %v", call.TimeHardDeadline, call.DeadlineHasTime)
+	}
+	if call.DurationExecutionEstimatedSeconds != 30*60 || call.TimeCreated.Day() != 1 {
+		t.Errorf("estimate = %d, created = %v", call.DurationExecutionEstimatedSeconds, call.TimeCreated)
+	}
+	if milk.Body != "Buy milk" || milk.Order != 2 {
+		t.Errorf("milk = %+v", milk)
+	}
+}
+
+func TestParseTodoist(t *testing.T) {
+	csv := `TYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,AUTHOR,RESPONSIBLE,DATE,DATE_LANG,TIMEZONE,DURATION,DURATION_UNIT
+section,Errands,,,,,,,,,,
+task,Buy milk @shop,,4,1,,,2026-11-01,en,,,
+note,Oat milk,,,,,,,,,,
+task,Renew passport,Bring photos,1,1,,,every year,en,,90,minute
+`
+
+	result, err := Parse("", []byte(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Format != FormatTodoist {
+		t.Errorf("format = %q", result.Format)
+	}
+
+	if len(result.Tasks) != 2 || len(result.Warnings) != 1 {
+		t.Fatalf("got %d tasks and %d warnings, want 2 and 1", len(result.Tasks), len(result.Warnings))
+	}
+
+	passport, milk := result.Tasks[0], result.Tasks[1]
+	if passport.Body != "Renew passport\nBring photos" || passport.DurationExecutionEstimatedSeconds != 90*60 {
+		t.Errorf("passport = %+v", passport)
+	}
+	if passport.TimeHardDeadline != nil {
+		t.Errorf("recurring date should not become a deadline")
+	}
+	if milk.Body != "Buy milk\nOat milk" || !reflect.DeepEqual(milk.Tags, []string{"shop", "Errands"}) {
+		t.Errorf("milk = %q, tags %q", milk.Body, milk.Tags)
+	}
+	if milk.TimeHardDeadline == nil || milk.TimeHardDeadline.Month() != time.November {
+		t.Errorf("deadline = %v", milk.TimeHardDeadline)
+	}
+}
+
+func TestParseISODuration(t *testing.T) {
+	cases := map[string]int{"PT45M": 45 * 60, "PT1H30M": 90 * 60, "P1D": 8 * 3600, "P1DT1H": 9 * 3600}
+	for input, want := range cases {
+		if got, err := parseISODuration(input); err != nil || got != want {
+			t.Errorf("parseISODuration(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	if _, err := parseISODuration("P2W"); err == nil {
+		t.Error("weeks should not be accepted")
+	}
+}
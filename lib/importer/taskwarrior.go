@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/utils"
+)
+
+// taskwarriorTimeLayout is the format of dates in "task export"
+const taskwarriorTimeLayout = "20060102T150405Z"
+
+// taskwarriorTask holds the fields of a Taskwarrior export that Done uses
+type taskwarriorTask struct {
+	Description string   `json:"description"`
+	Status      string   `json:"status"`
+	Entry       string   `json:"entry"`
+	Due         string   `json:"due"`
+	Scheduled   string   `json:"scheduled"`
+	Project     string   `json:"project"`
+	Tags        []string `json:"tags"`
+	Priority    string   `json:"priority"`
+	// Estimate is the common "estimate" UDA, an ISO 8601 duration
+	Estimate    json.RawMessage `json:"estimate"`
+	Annotations []struct {
+		Description string `json:"description"`
+	} `json:"annotations"`
+}
+
+var taskwarriorPriorities = map[string]int{"H": 1, "M": 2, "L": 3}
+
+// parseTaskwarrior reads the output of "task export": a JSON array, or one
+// object per line as written by older versions
+func parseTaskwarrior(data []byte, result *Result) ([]entry, error) {
+	var tasks []taskwarriorTask
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &tasks); err != nil {
+			return nil, fmt.Errorf("invalid Taskwarrior export: %w", err)
+		}
+	} else {
+		for i, line := range strings.Split(string(trimmed), "\n") {
+			line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+			if line == "" {
+				continue
+			}
+			var task taskwarriorTask
+			if err := json.Unmarshal([]byte(line), &task); err != nil {
+				return nil, fmt.Errorf("invalid Taskwarrior export on line %d: %w", i+1, err)
+			}
+			tasks = append(tasks, task)
+		}
+	}
+
+	var entries []entry
+	for i, source := range tasks {
+		number := i + 1
+
+		switch source.Status {
+		case "", "pending", "waiting":
+		default:
+			result.Skipped = append(result.Skipped, Notice{Line: number, Text: shorten(source.Description), Reason: "status " + source.Status})
+			continue
+		}
+
+		body := strings.TrimSpace(source.Description)
+		if body == "" {
+			result.Skipped = append(result.Skipped, Notice{Line: number, Reason: "empty description"})
+			continue
+		}
+		for _, annotation := range source.Annotations {
+			if text := strings.TrimSpace(annotation.Description); text != "" {
+				body += "\n" + text
+			}
+		}
+
+		e := entry{task: database.Task{Body: body}, rank: noPriority}
+		if rank, ok := taskwarriorPriorities[source.Priority]; ok {
+			e.rank = rank
+		}
+
+		if t, err := time.Parse(taskwarriorTimeLayout, source.Entry); err == nil {
+			e.task.TimeCreated = t.In(time.Local)
+		}
+		if source.Due != "" {
+			if t, err := time.Parse(taskwarriorTimeLayout, source.Due); err == nil {
+				setDeadline(&e.task, t)
+			} else {
+				result.Warnings = append(result.Warnings, Notice{Line: number, Text: shorten(body), Reason: "unreadable due date " + source.Due})
+			}
+		}
+		if source.Scheduled != "" {
+			if t, err := time.Parse(taskwarriorTimeLayout, source.Scheduled); err == nil {
+				setPlanned(&e.task, t)
+			}
+		}
+
+		if len(source.Estimate) > 0 {
+			seconds, err := parseTaskwarriorEstimate(source.Estimate)
+			if err != nil {
+				result.Warnings = append(result.Warnings, Notice{Line: number, Text: shorten(body), Reason: err.Error()})
+			}
+			e.task.DurationExecutionEstimatedSeconds = seconds
+		}
+
+		if source.Project != "" {
+			addTag(&e.task, source.Project)
+		}
+		for _, tag := range source.Tags {
+			addTag(&e.task, tag)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// parseTaskwarriorEstimate reads the estimate UDA, which is an ISO 8601
+// duration like "PT1H30M", a number of seconds, or an estimate as Done
+// writes it
+func parseTaskwarriorEstimate(raw json.RawMessage) (int, error) {
+	var seconds int
+	if err := json.Unmarshal(raw, &seconds); err == nil {
+		return seconds, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return 0, fmt.Errorf("unreadable estimate %s", raw)
+	}
+	if strings.HasPrefix(strings.ToUpper(text), "P") {
+		return parseISODuration(text)
+	}
+	return utils.ParseEstimate(text)
+}
+
+// parseISODuration converts an ISO 8601 duration such as "P1DT2H" into
+// seconds. As everywhere in Done, a day of work is 8 hours; weeks, months
+// and years are not supported.
+func parseISODuration(s string) (int, error) {
+	invalid := fmt.Errorf("unreadable estimate %q", s)
+
+	s = strings.ToUpper(s)
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, invalid
+	}
+
+	seconds := 0
+	inTime := false
+	number := ""
+	for _, c := range s[1:] {
+		switch {
+		case c >= '0' && c <= '9':
+			number += string(c)
+		case c == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, invalid
+			}
+			number = ""
+			switch {
+			case c == 'D' && !inTime:
+				seconds += n * utils.WorkdaySeconds
+			case c == 'H' && inTime:
+				seconds += n * 3600
+			case c == 'M' && inTime:
+				seconds += n * 60
+			case c == 'S' && inTime:
+				seconds += n
+			default:
+				return 0, invalid
+			}
+		}
+	}
+	if number != "" {
+		return 0, invalid
+	}
+
+	return seconds, nil
+}
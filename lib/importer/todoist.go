@@ -0,0 +1,153 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"done/lib/utils"
+)
+
+// todoistDateLayouts are the date forms found in the DATE column of Todoist
+// CSV exports. Recurring dates such as "every monday" cannot be imported.
+var todoistDateLayouts = []struct {
+	layout  string
+	hasTime bool
+}{
+	{"2006-01-02", false},
+	{"2006-01-02 15:04", true},
+	{"2006-01-02T15:04:05", true},
+	{"Jan 2 2006", false},
+	{"Jan 2 2006 15:04", true},
+	{"Jan 2 2006 3:04 PM", true},
+	{"2 Jan 2006", false},
+	{"2 Jan 2006 15:04", true},
+}
+
+// parseTodoist reads a Todoist CSV export. Sections become tags of the tasks
+// below them, trailing @labels become tags and notes are appended to the
+// task above. With a DEADLINE column, DATE is the planned date; otherwise
+// DATE is the deadline.
+func parseTodoist(data []byte, result *Result) ([]entry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Todoist CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToUpper(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["CONTENT"]; !ok {
+		return nil, fmt.Errorf("invalid Todoist CSV: no CONTENT column")
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	_, hasDeadlineColumn := columns["DEADLINE"]
+
+	var entries []entry
+	section := ""
+	for i, record := range records[1:] {
+		number := i + 2
+		content := field(record, "CONTENT")
+
+		switch strings.ToLower(field(record, "TYPE")) {
+		case "section":
+			section = content
+			continue
+		case "note":
+			if len(entries) > 0 && content != "" {
+				entries[len(entries)-1].task.Body += "\n" + content
+			}
+			continue
+		case "task":
+		default:
+			continue
+		}
+
+		e := entry{rank: noPriority}
+
+		words := strings.Fields(content)
+		labels := len(words)
+		for labels > 0 && len(words[labels-1]) > 1 && words[labels-1][0] == '@' {
+			labels--
+		}
+		for _, label := range words[labels:] {
+			addTag(&e.task, label[1:])
+		}
+		words = words[:labels]
+		if section != "" {
+			addTag(&e.task, section)
+		}
+
+		e.task.Body = strings.Join(words, " ")
+		if description := field(record, "DESCRIPTION"); description != "" {
+			e.task.Body += "\n" + description
+		}
+		if e.task.Body == "" {
+			result.Skipped = append(result.Skipped, Notice{Line: number, Reason: "empty task"})
+			continue
+		}
+
+		// PRIORITY runs from 1 (p1, highest) to 4 (p4, no priority)
+		if priority, err := strconv.Atoi(field(record, "PRIORITY")); err == nil && priority >= 1 && priority < 4 {
+			e.rank = priority
+		}
+
+		date := field(record, "DATE")
+		deadline := date
+		if hasDeadlineColumn {
+			deadline = field(record, "DEADLINE")
+			if date != "" {
+				if t, _, ok := parseTodoistDate(date); ok {
+					setPlanned(&e.task, t)
+				} else {
+					result.Warnings = append(result.Warnings, Notice{Line: number, Text: shorten(content), Reason: "date not imported: " + date})
+				}
+			}
+		}
+		if deadline != "" {
+			if t, hasTime, ok := parseTodoistDate(deadline); ok {
+				setDeadline(&e.task, t)
+				e.task.DeadlineHasTime = hasTime
+			} else {
+				result.Warnings = append(result.Warnings, Notice{Line: number, Text: shorten(content), Reason: "deadline not imported: " + deadline})
+			}
+		}
+
+		if duration, err := strconv.Atoi(field(record, "DURATION")); err == nil && duration > 0 {
+			if strings.EqualFold(field(record, "DURATION_UNIT"), "day") {
+				e.task.DurationExecutionEstimatedSeconds = duration * utils.WorkdaySeconds
+			} else {
+				e.task.DurationExecutionEstimatedSeconds = duration * 60
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func parseTodoistDate(s string) (time.Time, bool, bool) {
+	for _, l := range todoistDateLayouts {
+		if t, err := time.ParseInLocation(l.layout, s, time.Local); err == nil {
+			return t, l.hasTime, true
+		}
+	}
+	return time.Time{}, false, false
+}
@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"strings"
+	"time"
+
+	"done/lib/utils"
+)
+
+const todoTxtDateLayout = "2006-01-02"
+
+// parseTodoTxt reads a todo.txt file: one task per line with an optional
+// "(A)" priority, creation date, +project and @context tags and key:value
+// metadata. due: becomes the deadline, t: (threshold) the planned date and
+// est: the estimate. Completed "x " lines are skipped.
+func parseTodoTxt(data []byte, result *Result) ([]entry, error) {
+	var entries []entry
+
+	for i, line := range strings.Split(string(data), "\n") {
+		number := i + 1
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "x ") {
+			result.Skipped = append(result.Skipped, Notice{Line: number, Text: shorten(line), Reason: "completed"})
+			continue
+		}
+
+		e := entry{rank: noPriority}
+		fields := strings.Fields(line)
+
+		if len(fields) > 0 && isTodoTxtPriority(fields[0]) {
+			e.rank = int(fields[0][1]-'A') + 1
+			fields = fields[1:]
+		}
+		if len(fields) > 0 {
+			if t, err := time.ParseInLocation(todoTxtDateLayout, fields[0], time.Local); err == nil {
+				e.task.TimeCreated = t
+				fields = fields[1:]
+			}
+		}
+
+		// Metadata is removed from the text wherever it appears; tags only
+		// when they trail the text, so "Call @mom" keeps its meaning
+		var words []string
+		for _, field := range fields {
+			key, value, ok := strings.Cut(field, ":")
+			if !ok || value == "" {
+				words = append(words, field)
+				continue
+			}
+
+			switch key {
+			case "due":
+				if t, err := time.ParseInLocation(todoTxtDateLayout, value, time.Local); err == nil {
+					setDeadline(&e.task, t)
+				} else {
+					result.Warnings = append(result.Warnings, Notice{Line: number, Text: shorten(line), Reason: "unreadable due date " + value})
+				}
+			case "t":
+				if t, err := time.ParseInLocation(todoTxtDateLayout, value, time.Local); err == nil {
+					setPlanned(&e.task, t)
+				}
+			case "est":
+				seconds, err := utils.ParseEstimate(value)
+				if err != nil {
+					result.Warnings = append(result.Warnings, Notice{Line: number, Text: shorten(line), Reason: err.Error()})
+				}
+				e.task.DurationExecutionEstimatedSeconds = seconds
+			case "pri":
+				if len(value) == 1 && value[0] >= 'A' && value[0] <= 'Z' {
+					e.rank = int(value[0]-'A') + 1
+				}
+			default:
+				words = append(words, field)
+			}
+		}
+
+		for _, word := range words {
+			if isTodoTxtTag(word) {
+				addTag(&e.task, word[1:])
+			}
+		}
+		for len(words) > 0 && isTodoTxtTag(words[len(words)-1]) {
+			words = words[:len(words)-1]
+		}
+
+		e.task.Body = strings.Join(words, " ")
+		if e.task.Body == "" {
+			result.Skipped = append(result.Skipped, Notice{Line: number, Text: shorten(line), Reason: "no task text"})
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// isTodoTxtPriority reports whether field is a priority like "(A)"
+func isTodoTxtPriority(field string) bool {
+	return len(field) == 3 && field[0] == '(' && field[2] == ')' && field[1] >= 'A' && field[1] <= 'Z'
+}
+
+// isTodoTxtTag reports whether word is a +project or @context tag
+func isTodoTxtTag(word string) bool {
+	return len(word) > 1 && (word[0] == '+' || word[0] == '@')
+}
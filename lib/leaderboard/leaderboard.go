@@ -0,0 +1,111 @@
+// Package leaderboard ranks the users of a shared server and tracks team
+// challenges. It only ever sees numbers, never the tasks behind them.
+package leaderboard
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Metrics users are ranked by
+const (
+	MetricPoints    = "points"    // Points earned in the week
+	MetricCompleted = "completed" // Tasks completed in the week
+	MetricStreak    = "streak"    // Days in a row with a completed task, up to now
+)
+
+// Challenge states
+const (
+	StateActive    = "active"
+	StateCompleted = "completed" // The target was reached
+	StateFailed    = "failed"    // The deadline passed first
+)
+
+// ValidMetric reports whether users can be ranked by metric
+func ValidMetric(metric string) bool {
+	return metric == MetricPoints || metric == MetricCompleted || metric == MetricStreak
+}
+
+// ValidChallengeMetric reports whether a challenge can count metric. Streaks
+// are personal, so challenges add up points or completed tasks.
+func ValidChallengeMetric(metric string) bool {
+	return metric == MetricPoints || metric == MetricCompleted
+}
+
+// Entry is one user's line on a leaderboard
+type Entry struct {
+	Rank      int    `json:"rank"`
+	User      string `json:"user"`
+	Points    int    `json:"points"`
+	Completed int    `json:"completed"`
+	Streak    int    `json:"streak"`
+}
+
+// Value returns the entry's number for metric
+func (e *Entry) Value(metric string) int {
+	switch metric {
+	case MetricCompleted:
+		return e.Completed
+	case MetricStreak:
+		return e.Streak
+	}
+	return e.Points
+}
+
+// Rank sorts entries by metric, highest first, and numbers them. Equal
+// values share a rank, and the next rank skips as many places (1, 2, 2, 4).
+// Ties are listed by name.
+func Rank(entries []Entry, metric string) []Entry {
+	ranked := append([]Entry(nil), entries...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i].Value(metric), ranked[j].Value(metric)
+		if a != b {
+			return a > b
+		}
+		return strings.ToLower(ranked[i].User) < strings.ToLower(ranked[j].User)
+	})
+
+	for i := range ranked {
+		if i > 0 && ranked[i].Value(metric) == ranked[i-1].Value(metric) {
+			ranked[i].Rank = ranked[i-1].Rank
+		} else {
+			ranked[i].Rank = i + 1
+		}
+	}
+	return ranked
+}
+
+// WeekStart returns midnight of the Monday starting the week of t, in t's
+// location
+func WeekStart(t time.Time) time.Time {
+	days := (int(t.Weekday()) + 6) % 7 // Days since Monday
+	year, month, day := t.Date()
+	return time.Date(year, month, day-days, 0, 0, 0, 0, t.Location())
+}
+
+// ActiveStreak returns a stored streak as of now. A streak whose last
+// completion was before yesterday has been broken, even though it is only
+// reset by the next completion.
+func ActiveStreak(streak int, last *time.Time, now time.Time) int {
+	if last == nil {
+		return 0
+	}
+	year, month, day := now.Date()
+	yesterday := time.Date(year, month, day-1, 0, 0, 0, 0, now.Location())
+	if last.In(now.Location()).Before(yesterday) {
+		return 0
+	}
+	return streak
+}
+
+// State returns the state of a challenge with the given progress at now
+func State(progress, target int, deadline, now time.Time) string {
+	switch {
+	case progress >= target:
+		return StateCompleted
+	case now.After(deadline):
+		return StateFailed
+	}
+	return StateActive
+}
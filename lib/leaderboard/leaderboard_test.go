@@ -0,0 +1,93 @@
+package leaderboard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRankSharesPlacesOnTies(t *testing.T) {
+	entries := []Entry{
+		{User: "dave", Points: 40, Completed: 2},
+		{User: "Carol", Points: 70, Completed: 5},
+		{User: "bob", Points: 70, Completed: 3},
+		{User: "alice", Points: 90, Completed: 3},
+	}
+
+	ranked := Rank(entries, MetricPoints)
+	want := []struct {
+		user string
+		rank int
+	}{{"alice", 1}, {"bob", 2}, {"Carol", 2}, {"dave", 4}}
+	for i, w := range want {
+		if ranked[i].User != w.user || ranked[i].Rank != w.rank {
+			t.Errorf("place %d: got %s ranked %d, want %s ranked %d", i, ranked[i].User, ranked[i].Rank, w.user, w.rank)
+		}
+	}
+
+	if entries[0].Rank != 0 {
+		t.Error("Rank changed its argument")
+	}
+
+	byCompleted := Rank(entries, MetricCompleted)
+	if byCompleted[0].User != "Carol" || byCompleted[1].Rank != 2 || byCompleted[2].Rank != 2 || byCompleted[3].Rank != 4 {
+		t.Errorf("by completed: %+v", byCompleted)
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	cases := []struct {
+		t, want time.Time
+	}{
+		{time.Date(2026, 10, 19, 9, 0, 0, 0, loc), time.Date(2026, 10, 19, 0, 0, 0, 0, loc)},   // Monday
+		{time.Date(2026, 10, 25, 23, 59, 0, 0, loc), time.Date(2026, 10, 19, 0, 0, 0, 0, loc)}, // Sunday
+		{time.Date(2026, 11, 1, 12, 0, 0, 0, loc), time.Date(2026, 10, 26, 0, 0, 0, 0, loc)},   // Across months
+	}
+	for _, c := range cases {
+		if got := WeekStart(c.t); !got.Equal(c.want) {
+			t.Errorf("WeekStart(%v) = %v, want %v", c.t, got, c.want)
+		}
+	}
+}
+
+func TestActiveStreak(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	at := func(days int) *time.Time {
+		t := now.AddDate(0, 0, -days)
+		return &t
+	}
+
+	if got := ActiveStreak(5, at(0), now); got != 5 {
+		t.Errorf("completed today: %d", got)
+	}
+	if got := ActiveStreak(5, at(1), now); got != 5 {
+		t.Errorf("completed yesterday: %d", got)
+	}
+	if got := ActiveStreak(5, at(2), now); got != 0 {
+		t.Errorf("completed two days ago: %d", got)
+	}
+	if got := ActiveStreak(5, nil, now); got != 0 {
+		t.Errorf("never completed: %d", got)
+	}
+}
+
+func TestState(t *testing.T) {
+	deadline := time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)
+	before, after := deadline.Add(-time.Hour), deadline.Add(time.Hour)
+
+	cases := []struct {
+		progress int
+		now      time.Time
+		want     string
+	}{
+		{50, before, StateActive},
+		{100, before, StateCompleted},
+		{120, after, StateCompleted},
+		{50, after, StateFailed},
+	}
+	for _, c := range cases {
+		if got := State(c.progress, 100, deadline, c.now); got != c.want {
+			t.Errorf("State(%d, %v) = %s, want %s", c.progress, c.now, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+var date = time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC)
+
+func TestPlainText(t *testing.T) {
+	msg := &Message{To: "ann@example.com", Subject: "Due in 1h: Ship\r\nBcc: eve@example.com", Text: "Ship the release\nToday"}
+	data, err := msg.Bytes("done@example.com", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header.Get("Bcc") != "" {
+		t.Error("the subject injected a header")
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != "Due in 1h: Ship Bcc: eve@example.com" {
+		t.Errorf("subject = %q", subject)
+	}
+	if got := parsed.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("content type = %q", got)
+	}
+	body, _ := io.ReadAll(parsed.Body)
+	if string(body) != "Ship the release\r\nToday" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestAlternative(t *testing.T) {
+	msg := &Message{To: "ann@example.com", Subject: "Weekly report", Text: "12 tasks", HTML: `<p style="color: #333">12 tasks</p>`}
+	data, err := msg.Bytes("done@example.com", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v", mediaType, err)
+	}
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}
+	if strings.Join(types, ", ") != "text/plain; charset=utf-8, text/html; charset=utf-8" {
+		t.Errorf("parts = %v", types)
+	}
+	if len(bodies) == 2 && (bodies[0] != "12 tasks" || bodies[1] != msg.HTML) {
+		t.Errorf("bodies = %q", bodies)
+	}
+}
+
+func TestSendChecksAddresses(t *testing.T) {
+	s := &Sender{Addr: "localhost:2525", From: "done@example.com"}
+	if err := s.Send(&Message{To: "not an address"}); err == nil {
+		t.Error("sent to an invalid address")
+	}
+	if err := (&Sender{From: "done@example.com"}).Send(&Message{To: "ann@example.com"}); err == nil {
+		t.Error("sent without a relay")
+	}
+}
+
+// standIn is an SMTP relay that accepts one message and records it
+func standIn(t *testing.T) (addr string, received <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost stand-in")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.Fields(line + " ")[0]); verb {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				messages <- string(data)
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), messages
+}
+
+func TestSendThroughRelay(t *testing.T) {
+	addr, received := standIn(t)
+	s := &Sender{Addr: addr, From: "Done <done@example.com>"}
+	if err := s.Send(&Message{To: "ann@example.com", Subject: "Weekly report", Text: "12 tasks", HTML: "<p>12 tasks</p>"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case data := <-received:
+		msg, err := mail.ReadMessage(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := msg.Header.Get("To"); got != "<ann@example.com>" {
+			t.Errorf("To = %q", got)
+		}
+		if !strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/alternative") {
+			t.Errorf("Content-Type = %q", msg.Header.Get("Content-Type"))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the relay got no message")
+	}
+}
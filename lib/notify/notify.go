@@ -0,0 +1,43 @@
+// Package notify shows desktop notifications on the machine Done runs on:
+// with notify-send, or D-Bus directly, on Linux and with osascript on
+// macOS.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Desktop shows a notification with a title and a message
+func Desktop(title, message string) error {
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd":
+		if path, err := exec.LookPath("notify-send"); err == nil {
+			return run(path, "--app-name=Done", title, message)
+		}
+		if path, err := exec.LookPath("gdbus"); err == nil {
+			return run(path, "call", "--session",
+				"--dest", "org.freedesktop.Notifications",
+				"--object-path", "/org/freedesktop/Notifications",
+				"--method", "org.freedesktop.Notifications.Notify", "--",
+				"Done", "0", "", title, message, "[]", "{}", "-1")
+		}
+		return errors.New("desktop notifications need notify-send or gdbus")
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(message), strconv.Quote(title))
+		return run("osascript", "-e", script)
+	}
+	return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+}
+
+func run(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+// Package planner proposes which tasks fit into the working hours of the
+// coming days, given their estimates, deadlines and the time taken by
+// meetings.
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/utils"
+)
+
+// Config is the time available for tasks
+type Config struct {
+	Start time.Duration         // Start of the working day, from midnight
+	End   time.Duration         // End of the working day, from midnight
+	Days  map[time.Weekday]bool // Working weekdays
+	Busy  []database.BusyBlock  // Meetings and other unavailable time
+}
+
+// NewConfig reads working hours such as 09:00 to 17:00
+func NewConfig(hours database.WorkingHours, busy []database.BusyBlock) (Config, error) {
+	start, err := parseClock(hours.Start)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid start of working hours: %w", err)
+	}
+	end, err := parseClock(hours.End)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid end of working hours: %w", err)
+	}
+	if end <= start {
+		return Config{}, fmt.Errorf("working hours end before they start")
+	}
+
+	days := make(map[time.Weekday]bool)
+	for _, day := range hours.Days {
+		if day < 0 || day > 6 {
+			return Config{}, fmt.Errorf("invalid working day %d", day)
+		}
+		days[time.Weekday(day)] = true
+	}
+
+	return Config{Start: start, End: end, Days: days, Busy: busy}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Plan is a proposal for the coming days
+type Plan struct {
+	Generated   time.Time `json:"generated"`
+	Days        []Day     `json:"days"`
+	Unscheduled []Note    `json:"unscheduled"` // Tasks left out of the plan
+	Warnings    []Note    `json:"warnings"`    // Deadlines the plan cannot meet
+}
+
+// Day is the proposal for one day
+type Day struct {
+	Date            string                 `json:"date"` // 2006-01-02
+	CapacitySeconds int                    `json:"capacity_seconds"`
+	PlannedSeconds  int                    `json:"planned_seconds"`
+	Tasks           []database.PlannedTask `json:"tasks"`
+}
+
+// Note explains why a task is left out or at risk
+type Note struct {
+	UUID   string `json:"uuid"`
+	Body   string `json:"body"`
+	Reason string `json:"reason"`
+}
+
+// interval is free working time
+type interval struct {
+	day        int
+	start, end time.Time
+}
+
+// Propose fills the free working time of the given number of days, starting
+// now, with tasks in list order. A task can be split over several intervals
+// and days. Tasks without an estimate are left out, and a blocked task waits
+// until the tasks blocking it are planned; tasks whose blockers do not fit
+// are left out. Tasks that would finish after their hard deadline,
+// or do not fit at all before it, are reported in the warnings.
+func Propose(tasks []database.Task, cfg Config, now time.Time, days int) *Plan {
+	plan := &Plan{
+		Generated:   now,
+		Days:        make([]Day, days),
+		Unscheduled: []Note{},
+		Warnings:    []Note{},
+	}
+
+	free := freeTime(cfg, now, plan.Days)
+
+	active := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		active[task.UUID] = true
+	}
+	settled := make(map[string]bool) // Tasks scheduled or left out
+	planned := make(map[string]bool) // Tasks that fit into the plan
+
+	pending := tasks
+	for len(pending) > 0 {
+		var waiting []database.Task
+		for _, task := range pending {
+			ready, fits := blockers(&task, active, settled, planned)
+			if !ready {
+				waiting = append(waiting, task)
+				continue
+			}
+			settled[task.UUID] = true
+			if !fits {
+				plan.Unscheduled = append(plan.Unscheduled, note(&task, "blocked by tasks that are not planned"))
+				continue
+			}
+			free, planned[task.UUID] = schedule(plan, &task, free, now)
+		}
+		if len(waiting) == len(pending) {
+			for _, task := range waiting {
+				plan.Unscheduled = append(plan.Unscheduled, note(&task, "blocked by tasks that are not planned"))
+			}
+			break
+		}
+		pending = waiting
+	}
+
+	return plan
+}
+
+// freeTime returns the working time of each day, minus busy blocks, and sets
+// the date and capacity of the days
+func freeTime(cfg Config, now time.Time, days []Day) []interval {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var free []interval
+	for i := range days {
+		date := today.AddDate(0, 0, i)
+		days[i].Date = date.Format("2006-01-02")
+		days[i].Tasks = []database.PlannedTask{}
+		if !cfg.Days[date.Weekday()] {
+			continue
+		}
+
+		// Today starts at the next whole minute
+		start, end := date.Add(cfg.Start), date.Add(cfg.End)
+		if start.Before(now) {
+			start = now.Add(time.Minute - 1).Truncate(time.Minute)
+		}
+		if !start.Before(end) {
+			continue
+		}
+
+		dayFree := subtractBusy(interval{day: i, start: start, end: end}, cfg.Busy)
+		for _, f := range dayFree {
+			days[i].CapacitySeconds += int(f.end.Sub(f.start).Seconds())
+		}
+		free = append(free, dayFree...)
+	}
+	return free
+}
+
+// subtractBusy cuts busy blocks out of a working interval
+func subtractBusy(working interval, busy []database.BusyBlock) []interval {
+	blocks := append([]database.BusyBlock(nil), busy...)
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Start.Before(blocks[j].Start) })
+
+	var free []interval
+	cursor := working.start
+	for _, block := range blocks {
+		if !block.End.After(cursor) || !block.Start.Before(working.end) {
+			continue
+		}
+		if block.Start.After(cursor) {
+			free = append(free, interval{day: working.day, start: cursor, end: block.Start})
+		}
+		cursor = block.End
+	}
+	if cursor.Before(working.end) {
+		free = append(free, interval{day: working.day, start: cursor, end: working.end})
+	}
+	return free
+}
+
+// blockers reports whether all active blockers of the task are settled, and
+// whether they all fit into the plan
+func blockers(task *database.Task, active, settled, planned map[string]bool) (ready, fits bool) {
+	fits = true
+	for _, blocker := range task.BlockedBy {
+		if !active[blocker] {
+			continue
+		}
+		if !settled[blocker] {
+			return false, false
+		}
+		fits = fits && planned[blocker]
+	}
+	return true, fits
+}
+
+// schedule books the remaining estimate of a task in the free intervals and
+// returns the intervals still free and whether the task fits
+func schedule(plan *Plan, task *database.Task, free []interval, now time.Time) ([]interval, bool) {
+	if task.DurationExecutionEstimatedSeconds == 0 {
+		plan.Unscheduled = append(plan.Unscheduled, note(task, "no estimate"))
+		return free, false
+	}
+
+	remaining := time.Duration(task.DurationExecutionEstimatedSeconds-task.DurationExecutionRealSeconds) * time.Second
+	if remaining <= 0 {
+		plan.Unscheduled = append(plan.Unscheduled, note(task, "time spent exceeds the estimate"))
+		return free, false
+	}
+
+	deadline, hasDeadline := task.HardDeadline()
+	if hasDeadline && deadline.Before(now) {
+		plan.Warnings = append(plan.Warnings, note(task, "the deadline has passed"))
+	}
+
+	var finish time.Time
+	for remaining > 0 && len(free) > 0 {
+		f := &free[0]
+		length := f.end.Sub(f.start)
+		if length > remaining {
+			length = remaining
+		}
+
+		day := &plan.Days[f.day]
+		day.Tasks = append(day.Tasks, database.PlannedTask{
+			UUID:    task.UUID,
+			Body:    task.Body,
+			Seconds: int(length.Seconds()),
+			Start:   f.start,
+			End:     f.start.Add(length),
+		})
+		day.PlannedSeconds += int(length.Seconds())
+
+		remaining -= length
+		finish = f.start.Add(length)
+		f.start = finish
+		if !f.start.Before(f.end) {
+			free = free[1:]
+		}
+	}
+
+	switch {
+	case remaining > 0:
+		reason := fmt.Sprintf("%s of the estimate does not fit into the plan", utils.FormatEstimate(int(remaining.Seconds())))
+		if hasDeadline && !deadline.Before(now) {
+			reason += "; the deadline cannot be met"
+			plan.Warnings = append(plan.Warnings, note(task, reason))
+		} else {
+			plan.Unscheduled = append(plan.Unscheduled, note(task, reason))
+		}
+	case hasDeadline && !deadline.Before(now) && finish.After(deadline):
+		plan.Warnings = append(plan.Warnings, note(task, "planned to finish "+finish.Format("Mon 15:04")+", after the deadline "+deadline.Format("Mon 15:04")))
+	}
+
+	return free, remaining <= 0
+}
+
+func note(task *database.Task, reason string) Note {
+	return Note{UUID: task.UUID, Body: task.Body, Reason: reason}
+}
@@ -0,0 +1,146 @@
+package planner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// Monday
+var now = time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+func config(t *testing.T) Config {
+	lunch := database.BusyBlock{
+		Title: "Lunch",
+		Start: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC),
+	}
+	cfg, err := NewConfig(database.DefaultSettings().WorkingHours, []database.BusyBlock{lunch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func hours(h float64) int {
+	return int(h * 3600)
+}
+
+func TestNewConfigRejectsInvalidHours(t *testing.T) {
+	invalid := []database.WorkingHours{
+		{Start: "9", End: "17:00"},
+		{Start: "17:00", End: "09:00"},
+		{Start: "09:00", End: "17:00", Days: []int{7}},
+	}
+	for _, hours := range invalid {
+		if _, err := NewConfig(hours, nil); err == nil {
+			t.Errorf("%+v: expected an error", hours)
+		}
+	}
+}
+
+func TestProposeCapacity(t *testing.T) {
+	plan := Propose(nil, config(t), now, 7)
+
+	// Monday from 10:00 without lunch, Tuesday to Friday, no weekend
+	want := []int{hours(6), hours(8), hours(8), hours(8), hours(8), 0, 0}
+	for i, day := range plan.Days {
+		if day.CapacitySeconds != want[i] {
+			t.Errorf("%s: capacity %d, want %d", day.Date, day.CapacitySeconds, want[i])
+		}
+	}
+}
+
+func TestProposeSplitsAroundBusyTime(t *testing.T) {
+	tasks := []database.Task{
+		{UUID: "a", DurationExecutionEstimatedSeconds: hours(3)},
+		{UUID: "b", DurationExecutionEstimatedSeconds: hours(4), DurationExecutionRealSeconds: hours(1)},
+	}
+
+	plan := Propose(tasks, config(t), now, 2)
+
+	monday := plan.Days[0].Tasks
+	if len(monday) != 3 {
+		t.Fatalf("monday: %+v", monday)
+	}
+	if monday[0].UUID != "a" || monday[0].Seconds != hours(2) || monday[1].UUID != "a" || monday[1].Start.Hour() != 13 {
+		t.Errorf("a is not split around lunch: %+v", monday[:2])
+	}
+	if monday[2].UUID != "b" || monday[2].Seconds != hours(3) || plan.Days[0].PlannedSeconds != hours(6) {
+		t.Errorf("b does not fill the rest of the day: %+v", monday[2])
+	}
+	if len(plan.Days[1].Tasks) != 0 {
+		t.Errorf("tuesday: %+v", plan.Days[1].Tasks)
+	}
+}
+
+func TestProposeUnscheduled(t *testing.T) {
+	tasks := []database.Task{
+		{UUID: "blocked", DurationExecutionEstimatedSeconds: hours(1), BlockedBy: []string{"unknown"}},
+		{UUID: "waits", DurationExecutionEstimatedSeconds: hours(1), BlockedBy: []string{"none"}},
+		{UUID: "none"},
+		{UUID: "spent", DurationExecutionEstimatedSeconds: hours(1), DurationExecutionRealSeconds: hours(2)},
+		{UUID: "huge", DurationExecutionEstimatedSeconds: hours(100)},
+	}
+
+	plan := Propose(tasks, config(t), now, 1)
+
+	// Blockers that are not active do not hold a task back
+	if len(plan.Days[0].Tasks) == 0 || plan.Days[0].Tasks[0].UUID != "blocked" {
+		t.Errorf("blocked by a completed task is not planned first: %+v", plan.Days[0].Tasks)
+	}
+
+	reasons := make(map[string]string)
+	for _, n := range plan.Unscheduled {
+		reasons[n.UUID] = n.Reason
+	}
+	for uuid, reason := range map[string]string{
+		"none":  "no estimate",
+		"spent": "exceeds",
+		"huge":  "does not fit",
+		"waits": "blocked",
+	} {
+		if !strings.Contains(reasons[uuid], reason) {
+			t.Errorf("%s: reason %q, want %q", uuid, reasons[uuid], reason)
+		}
+	}
+}
+
+func TestProposeWaitsForBlockers(t *testing.T) {
+	tasks := []database.Task{
+		{UUID: "second", DurationExecutionEstimatedSeconds: hours(1), BlockedBy: []string{"first"}},
+		{UUID: "first", DurationExecutionEstimatedSeconds: hours(1)},
+	}
+
+	plan := Propose(tasks, config(t), now, 1)
+
+	planned := plan.Days[0].Tasks
+	if len(planned) != 2 || planned[0].UUID != "first" || planned[1].UUID != "second" {
+		t.Errorf("blocked task is planned before its blocker: %+v", planned)
+	}
+}
+
+func TestProposeWarnsAboutDeadlines(t *testing.T) {
+	tomorrowNoon := time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC)
+	yesterday := now.AddDate(0, 0, -1)
+	tasks := []database.Task{
+		{UUID: "fits", DurationExecutionEstimatedSeconds: hours(5), TimeHardDeadline: &tomorrowNoon, DeadlineHasTime: true},
+		{UUID: "late", DurationExecutionEstimatedSeconds: hours(5), TimeHardDeadline: &tomorrowNoon, DeadlineHasTime: true},
+		{UUID: "overdue", DurationExecutionEstimatedSeconds: hours(1), TimeHardDeadline: &yesterday},
+		{UUID: "impossible", DurationExecutionEstimatedSeconds: hours(100), TimeHardDeadline: &tomorrowNoon, DeadlineHasTime: true},
+	}
+
+	plan := Propose(tasks, config(t), now, 2)
+
+	warned := make(map[string]bool)
+	for _, n := range plan.Warnings {
+		warned[n.UUID] = true
+	}
+	for uuid, want := range map[string]bool{"fits": false, "late": true, "overdue": true, "impossible": true} {
+		if warned[uuid] != want {
+			t.Errorf("%s: warned %v, want %v", uuid, warned[uuid], want)
+		}
+	}
+}
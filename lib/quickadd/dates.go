@@ -0,0 +1,236 @@
+package quickadd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// date is a parsed date expression
+type date struct {
+	time    time.Time
+	hasTime bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+// parseDate reads a date expression, optionally followed by a time of day,
+// from the start of tokens. It returns the number of tokens used.
+func parseDate(tokens []string, now time.Time) (date, int, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	day, consumed, ok := parseDay(tokens, today)
+	if !ok {
+		// A time alone means today, or tomorrow once it has passed
+		if len(tokens) == 0 {
+			return date{}, 0, false
+		}
+		hour, minute, ok := parseClock(tokens[0])
+		if !ok {
+			return date{}, 0, false
+		}
+		t := today.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+		if t.Before(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return date{time: t, hasTime: true}, 1, true
+	}
+
+	rest := tokens[consumed:]
+	clockTokens := 0
+	if len(rest) > 1 && strings.EqualFold(rest[0], "at") {
+		rest = rest[1:]
+		clockTokens = 1
+	}
+	if len(rest) > 0 {
+		if hour, minute, ok := parseClock(rest[0]); ok {
+			day = day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+			return date{time: day, hasTime: true}, consumed + clockTokens + 1, true
+		}
+	}
+
+	return date{time: day}, consumed, true
+}
+
+// parseDay reads a day from the start of tokens, relative to today
+func parseDay(tokens []string, today time.Time) (time.Time, int, bool) {
+	if len(tokens) == 0 {
+		return time.Time{}, 0, false
+	}
+
+	word := strings.ToLower(tokens[0])
+	next := ""
+	if len(tokens) > 1 {
+		next = strings.ToLower(tokens[1])
+	}
+
+	switch word {
+	case "today", "tod":
+		return today, 1, true
+	case "tomorrow", "tmr", "tom":
+		return today.AddDate(0, 0, 1), 1, true
+	case "next":
+		if weekday, ok := weekdays[next]; ok {
+			return upcoming(today, weekday).AddDate(0, 0, 7), 2, true
+		}
+		switch next {
+		case "week":
+			return upcoming(today.AddDate(0, 0, 1), time.Monday), 2, true
+		case "month":
+			return time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location()), 2, true
+		}
+		return time.Time{}, 0, false
+	case "in":
+		return parseOffset(tokens[1:], today)
+	}
+
+	if weekday, ok := weekdays[word]; ok {
+		return upcoming(today, weekday), 1, true
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", word, today.Location()); err == nil {
+		return t, 1, true
+	}
+
+	// "nov 5" or "5 nov", with an optional year
+	month, monthOK := months[word]
+	dayOfMonth, dayOK := parseDayOfMonth(next)
+	if !monthOK || !dayOK {
+		month, monthOK = months[next]
+		dayOfMonth, dayOK = parseDayOfMonth(word)
+	}
+	if monthOK && dayOK {
+		consumed := 2
+		year := today.Year()
+		explicitYear := false
+		if len(tokens) > 2 {
+			if y, err := strconv.Atoi(tokens[2]); err == nil && y >= 1000 && y <= 9999 {
+				year = y
+				explicitYear = true
+				consumed = 3
+			}
+		}
+		t := time.Date(year, month, dayOfMonth, 0, 0, 0, 0, today.Location())
+		if t.Month() != month {
+			return time.Time{}, 0, false
+		}
+		if !explicitYear && t.Before(today) {
+			t = t.AddDate(1, 0, 0)
+		}
+		return t, consumed, true
+	}
+
+	return time.Time{}, 0, false
+}
+
+// parseOffset reads the part after "in": "3 days", "2w" or "1 month"
+func parseOffset(tokens []string, today time.Time) (time.Time, int, bool) {
+	if len(tokens) == 0 {
+		return time.Time{}, 0, false
+	}
+
+	amount, unit, consumed := tokens[0], "", 2
+	if i := strings.IndexFunc(amount, func(r rune) bool { return r < '0' || r > '9' }); i > 0 {
+		amount, unit = amount[:i], amount[i:]
+	} else if len(tokens) > 1 {
+		unit = tokens[1]
+		consumed = 3
+	}
+
+	n, err := strconv.Atoi(amount)
+	if err != nil || n < 0 {
+		return time.Time{}, 0, false
+	}
+
+	switch strings.ToLower(unit) {
+	case "d", "day", "days":
+		return today.AddDate(0, 0, n), consumed, true
+	case "w", "week", "weeks":
+		return today.AddDate(0, 0, 7*n), consumed, true
+	case "month", "months":
+		return today.AddDate(0, n, 0), consumed, true
+	}
+	return time.Time{}, 0, false
+}
+
+// upcoming returns the first given weekday on or after day
+func upcoming(day time.Time, weekday time.Weekday) time.Time {
+	return day.AddDate(0, 0, (int(weekday)-int(day.Weekday())+7)%7)
+}
+
+// parseDayOfMonth reads "5" or "5th"
+func parseDayOfMonth(s string) (int, bool) {
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		s = strings.TrimSuffix(s, suffix)
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n >= 1 && n <= 31
+}
+
+// parseClock reads a time of day: "15:00", "3pm" or "3:30pm"
+func parseClock(s string) (hour, minute int, ok bool) {
+	s = strings.ToLower(s)
+
+	offset := -1
+	switch {
+	case strings.HasSuffix(s, "am"):
+		offset = 0
+		s = strings.TrimSuffix(s, "am")
+	case strings.HasSuffix(s, "pm"):
+		offset = 12
+		s = strings.TrimSuffix(s, "pm")
+	}
+
+	hourText, minuteText, hasMinutes := strings.Cut(s, ":")
+	if !hasMinutes && offset < 0 {
+		// A bare number is not a time
+		return 0, 0, false
+	}
+
+	hour, err := strconv.Atoi(hourText)
+	if err != nil {
+		return 0, 0, false
+	}
+	if hasMinutes {
+		if len(minuteText) != 2 {
+			return 0, 0, false
+		}
+		if minute, err = strconv.Atoi(minuteText); err != nil || minute > 59 {
+			return 0, 0, false
+		}
+	}
+
+	if offset >= 0 {
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour = hour%12 + offset
+	} else if hour > 23 {
+		return 0, 0, false
+	}
+
+	return hour, minute, true
+}
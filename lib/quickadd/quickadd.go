@@ -0,0 +1,142 @@
+// Package quickadd parses a one-line task description such as
+//
+//	Write RFC draft ~2h30m due fri 15:00 #docs !high
+//
+// into the task text, estimate, deadline, planned date, tags and priority.
+package quickadd
+
+import (
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+	"done/lib/utils"
+)
+
+// Parsed is the structure of a quick-add line, in the JSON form of a task
+type Parsed struct {
+	Body                              string     `json:"body"`
+	DurationExecutionEstimatedSeconds int        `json:"duration_execution_estimated_seconds"`
+	TimeHardDeadline                  *time.Time `json:"time_hard_dead_line"`
+	DeadlineHasTime                   bool       `json:"deadline_has_time"`
+	TimePlanned                       *time.Time `json:"time_planned"`
+	Tags                              []string   `json:"tags"`
+	Priority                          string     `json:"priority"`
+}
+
+// priorities maps the !priority markers onto task priorities
+var priorities = map[string]string{
+	"high":   database.PriorityHigh,
+	"h":      database.PriorityHigh,
+	"1":      database.PriorityHigh,
+	"medium": database.PriorityMedium,
+	"med":    database.PriorityMedium,
+	"m":      database.PriorityMedium,
+	"2":      database.PriorityMedium,
+	"low":    database.PriorityLow,
+	"l":      database.PriorityLow,
+	"3":      database.PriorityLow,
+}
+
+// Parse reads a quick-add line. Relative dates are resolved against now,
+// whose location is the time zone of the result:
+//
+//	~45m, ~1h30m, ~2d   estimate (a day is 8 hours)
+//	due <date>          hard deadline, optionally with a time: "due fri 15:00"
+//	plan <date>         planned date
+//	#tag                tag
+//	!high, !med, !low   priority, also !h, !m, !l or !1 to !3
+//
+// Dates are "today", "tomorrow", weekdays ("fri", "next fri"), "in 3 days",
+// "next week", "nov 5", "5 nov" or "2026-11-05". Keywords may also be
+// written as "due:fri". Anything that is not understood stays in the text,
+// and a leading backslash keeps a word as it is, e.g. "\#1".
+func Parse(line string, now time.Time) *Parsed {
+	parsed := &Parsed{Tags: []string{}}
+	tokens := strings.Fields(line)
+
+	var words []string
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		lower := strings.ToLower(token)
+
+		switch {
+		case strings.HasPrefix(token, `\`) && len(token) > 1:
+			words = append(words, token[1:])
+			continue
+
+		case strings.HasPrefix(token, "~") && len(token) > 1:
+			if seconds, err := utils.ParseEstimate(token[1:]); err == nil {
+				parsed.DurationExecutionEstimatedSeconds = seconds
+				continue
+			}
+
+		case strings.HasPrefix(token, "#") && len(token) > 1:
+			addTag(parsed, token[1:])
+			continue
+
+		case strings.HasPrefix(token, "!") && len(token) > 1:
+			if priority, ok := priorities[lower[1:]]; ok {
+				parsed.Priority = priority
+				continue
+			}
+
+		case lower == "due" || lower == "plan":
+			if d, consumed, ok := parseDate(tokens[i+1:], now); ok {
+				parsed.set(lower, d)
+				i += consumed
+				continue
+			}
+
+		case strings.HasPrefix(lower, "due:") || strings.HasPrefix(lower, "plan:"):
+			keyword, first, _ := strings.Cut(token, ":")
+			rest := append([]string{first}, tokens[i+1:]...)
+			if d, consumed, ok := parseDate(rest, now); ok && consumed >= 1 {
+				parsed.set(strings.ToLower(keyword), d)
+				i += consumed - 1
+				continue
+			}
+		}
+
+		words = append(words, token)
+	}
+
+	parsed.Body = strings.Join(words, " ")
+	return parsed
+}
+
+// set stores a date parsed after the "due" or "plan" keyword
+func (p *Parsed) set(keyword string, d date) {
+	if keyword == "due" {
+		p.TimeHardDeadline = &d.time
+		p.DeadlineHasTime = d.hasTime
+		return
+	}
+	day := time.Date(d.time.Year(), d.time.Month(), d.time.Day(), 0, 0, 0, 0, d.time.Location())
+	p.TimePlanned = &day
+}
+
+// Task returns a new task with the parsed fields
+func (p *Parsed) Task() database.Task {
+	task := database.Task{
+		Body:                              p.Body,
+		DurationExecutionEstimatedSeconds: p.DurationExecutionEstimatedSeconds,
+		TimeHardDeadline:                  p.TimeHardDeadline,
+		DeadlineHasTime:                   p.DeadlineHasTime,
+		TimePlanned:                       p.TimePlanned,
+		Priority:                          p.Priority,
+	}
+	if len(p.Tags) > 0 {
+		task.Tags = append([]string(nil), p.Tags...)
+	}
+	return task
+}
+
+func addTag(parsed *Parsed, tag string) {
+	for _, existing := range parsed.Tags {
+		if strings.EqualFold(existing, tag) {
+			return
+		}
+	}
+	parsed.Tags = append(parsed.Tags, tag)
+}
@@ -0,0 +1,76 @@
+package quickadd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// now is Monday, October 19 2026, 10:00
+var now = time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	parsed := Parse("Write RFC draft ~2h30m due fri 15:00 #docs !high", now)
+
+	if parsed.Body != "Write RFC draft" {
+		t.Errorf("body = %q", parsed.Body)
+	}
+	if parsed.DurationExecutionEstimatedSeconds != 150*60 {
+		t.Errorf("estimate = %d", parsed.DurationExecutionEstimatedSeconds)
+	}
+	want := day(time.October, 23).Add(15 * time.Hour)
+	if parsed.TimeHardDeadline == nil || !parsed.TimeHardDeadline.Equal(want) || !parsed.DeadlineHasTime {
+		t.Errorf("deadline = %v, has time %v; want %v", parsed.TimeHardDeadline, parsed.DeadlineHasTime, want)
+	}
+	if !reflect.DeepEqual(parsed.Tags, []string{"docs"}) || parsed.Priority != "high" {
+		t.Errorf("tags = %q, priority = %q", parsed.Tags, parsed.Priority)
+	}
+}
+
+func TestParseDates(t *testing.T) {
+	cases := map[string]time.Time{
+		"due today":          day(time.October, 19),
+		"due tomorrow":       day(time.October, 20),
+		"due mon":            day(time.October, 19),
+		"due next mon":       day(time.October, 26),
+		"due next week":      day(time.October, 26),
+		"due next month":     day(time.November, 1),
+		"due in 3 days":      day(time.October, 22),
+		"due in 2w":          day(time.November, 2),
+		"due nov 5":          day(time.November, 5),
+		"due 5th nov":        day(time.November, 5),
+		"due:2026-12-01":     day(time.December, 1),
+		"due jan 2":          time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC),
+		"due oct 1 2026":     day(time.October, 1),
+		"due 9am":            day(time.October, 20).Add(9 * time.Hour),
+		"due fri at 3:30pm":  day(time.October, 23).Add(15*time.Hour + 30*time.Minute),
+		"due:tomorrow 18:00": day(time.October, 20).Add(18 * time.Hour),
+	}
+	for line, want := range cases {
+		parsed := Parse("Task "+line, now)
+		if parsed.Body != "Task" {
+			t.Errorf("%q: body = %q", line, parsed.Body)
+		}
+		if parsed.TimeHardDeadline == nil || !parsed.TimeHardDeadline.Equal(want) {
+			t.Errorf("%q: deadline = %v, want %v", line, parsed.TimeHardDeadline, want)
+		}
+	}
+}
+
+func TestParseKeepsUnknownWords(t *testing.T) {
+	parsed := Parse(`Plan due diligence !important \#1 ~later plan tomorrow`, now)
+
+	if parsed.Body != "Plan due diligence !important #1 ~later" {
+		t.Errorf("body = %q", parsed.Body)
+	}
+	if parsed.TimeHardDeadline != nil || parsed.Priority != "" || parsed.DurationExecutionEstimatedSeconds != 0 {
+		t.Errorf("parsed = %+v", parsed)
+	}
+	if parsed.TimePlanned == nil || !parsed.TimePlanned.Equal(day(time.October, 20)) {
+		t.Errorf("planned = %v", parsed.TimePlanned)
+	}
+}
@@ -0,0 +1,94 @@
+// Package ranking orders tasks for the smart sort mode by a score over their
+// priority, deadline proximity and estimate.
+package ranking
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// Score weights. Priority dominates; a deadline due now outweighs one
+// priority level, and short tasks get a small boost as quick wins.
+const (
+	priorityWeight = 30.0 // Per priority level above none
+	deadlineWeight = 40.0 // Deadline due now or overdue
+	quickWinWeight = 5.0  // Task with a tiny estimate
+)
+
+var priorityLevels = map[string]float64{
+	database.PriorityHigh:   3,
+	database.PriorityMedium: 2,
+	database.PriorityLow:    1,
+}
+
+// Score rates how soon a task should be done; higher scores come first.
+//
+// The deadline term uses the slack left once the estimate is worked off, so
+// a long task due on Friday is more urgent than a short one due on Friday.
+// It halves when the slack grows by a day, and is at its full weight for
+// tasks that can no longer be finished in time.
+func Score(task *database.Task, now time.Time) float64 {
+	score := priorityWeight * priorityLevels[task.Priority]
+
+	if deadline, ok := task.HardDeadline(); ok {
+		estimate := time.Duration(task.DurationExecutionEstimatedSeconds-task.DurationExecutionRealSeconds) * time.Second
+		if estimate < 0 {
+			estimate = 0
+		}
+		slackDays := deadline.Sub(now.Add(estimate)).Hours() / 24
+		if slackDays <= 0 {
+			score += deadlineWeight
+		} else {
+			score += deadlineWeight / (1 + slackDays)
+		}
+	}
+
+	if estimate := task.DurationExecutionEstimatedSeconds; estimate > 0 {
+		score += quickWinWeight / (1 + float64(estimate)/3600)
+	}
+
+	return math.Round(score*1000) / 1000
+}
+
+// Sort returns tasks, given in manual order, in smart order. Pinned tasks
+// keep their position in the manual order; the other tasks fill the
+// remaining positions by descending score, ties keeping their manual order.
+// Blocked tasks come after the tasks that can be started. The Order of every
+// returned task is set to its position.
+func Sort(tasks []database.Task, now time.Time) []database.Task {
+	var unpinned []database.Task
+	pinnedAt := make(map[int]database.Task)
+	for i, task := range tasks {
+		if task.Pinned {
+			pinnedAt[i] = task
+		} else {
+			unpinned = append(unpinned, task)
+		}
+	}
+
+	scores := make(map[string]float64, len(unpinned))
+	for i := range unpinned {
+		scores[unpinned[i].UUID] = Score(&unpinned[i], now)
+	}
+	sort.SliceStable(unpinned, func(i, j int) bool {
+		if unpinned[i].Blocked != unpinned[j].Blocked {
+			return !unpinned[i].Blocked
+		}
+		return scores[unpinned[i].UUID] > scores[unpinned[j].UUID]
+	})
+
+	sorted := make([]database.Task, 0, len(tasks))
+	for i := range tasks {
+		if task, ok := pinnedAt[i]; ok {
+			sorted = append(sorted, task)
+		} else {
+			sorted = append(sorted, unpinned[0])
+			unpinned = unpinned[1:]
+		}
+		sorted[i].Order = i
+	}
+	return sorted
+}
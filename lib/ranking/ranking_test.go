@@ -0,0 +1,71 @@
+package ranking
+
+import (
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+var now = time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+func due(days int) *time.Time {
+	t := now.AddDate(0, 0, days)
+	return &t
+}
+
+func TestScore(t *testing.T) {
+	high := database.Task{Priority: database.PriorityHigh}
+	low := database.Task{Priority: database.PriorityLow}
+	dueToday := database.Task{TimeHardDeadline: due(0), DeadlineHasTime: true}
+	dueNextWeek := database.Task{TimeHardDeadline: due(7), DeadlineHasTime: true}
+	longDueSoon := database.Task{TimeHardDeadline: due(2), DeadlineHasTime: true, DurationExecutionEstimatedSeconds: 3 * 8 * 3600}
+	shortDueSoon := database.Task{TimeHardDeadline: due(2), DeadlineHasTime: true, DurationExecutionEstimatedSeconds: 3 * 3600}
+	quick := database.Task{DurationExecutionEstimatedSeconds: 15 * 60}
+
+	higher := []struct {
+		name        string
+		first, then database.Task
+	}{
+		{"high over low priority", high, low},
+		{"due today over next week", dueToday, dueNextWeek},
+		{"overdue estimate over short one", longDueSoon, shortDueSoon},
+		{"quick win over no estimate", quick, database.Task{}},
+		{"due today over low priority", dueToday, low},
+	}
+	for _, c := range higher {
+		if Score(&c.first, now) <= Score(&c.then, now) {
+			t.Errorf("%s: %v <= %v", c.name, Score(&c.first, now), Score(&c.then, now))
+		}
+	}
+}
+
+func TestSortKeepsPinnedTasks(t *testing.T) {
+	tasks := []database.Task{
+		{UUID: "a"},
+		{UUID: "b", Priority: database.PriorityLow, Pinned: true},
+		{UUID: "c", Priority: database.PriorityMedium},
+		{UUID: "d"},
+		{UUID: "e", Priority: database.PriorityHigh},
+	}
+
+	sorted := Sort(tasks, now)
+
+	want := []string{"e", "b", "c", "a", "d"}
+	for i, task := range sorted {
+		if task.UUID != want[i] || task.Order != i {
+			t.Fatalf("position %d: %s (order %d), want %s", i, task.UUID, task.Order, want[i])
+		}
+	}
+}
+
+func TestSortPutsBlockedTasksLast(t *testing.T) {
+	tasks := []database.Task{
+		{UUID: "blocked", Priority: database.PriorityHigh, Blocked: true},
+		{UUID: "free"},
+	}
+
+	if sorted := Sort(tasks, now); sorted[0].UUID != "free" {
+		t.Errorf("first task = %s, want free", sorted[0].UUID)
+	}
+}
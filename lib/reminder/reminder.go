@@ -0,0 +1,140 @@
+// Package reminder decides when to remind of a task's deadline: at offsets
+// before it, such as a day and an hour, and once a day while it is overdue,
+// unless the reminder is snoozed.
+package reminder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+// Kinds of reminders
+const (
+	Upcoming = "upcoming" // The deadline is near
+	Overdue  = "overdue"  // The deadline has passed
+)
+
+// OverdueEvery is how often an overdue task is reminded of
+const OverdueEvery = 24 * time.Hour
+
+// ParseOffset reads how long before a deadline to remind, as a Go duration
+// like "1h30m" or a number of days like "2d"
+func ParseOffset(offset string) (time.Duration, error) {
+	offset = strings.TrimSpace(offset)
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(offset, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(offset)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid reminder offset %q: use a duration like 1d, 2h or 30m", offset)
+	}
+	return d, nil
+}
+
+// ParseOffsets reads the offsets of the reminder settings
+func ParseOffsets(offsets []string) ([]time.Duration, error) {
+	var parsed []time.Duration
+	for _, offset := range offsets {
+		d, err := ParseOffset(offset)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, d)
+	}
+	return parsed, nil
+}
+
+// Check reports which reminder, if any, is due at now for a task with the
+// given deadline, and records it in state. Of the offsets that have passed
+// only the shortest is reminded of, so a task added an hour before its
+// deadline does not get the reminder meant for a day before as well. A new
+// deadline starts over.
+func Check(deadline time.Time, offsets []time.Duration, overdue bool, state *database.ReminderState, now time.Time) (kind string, due bool) {
+	if !state.Deadline.Equal(deadline) {
+		*state = database.ReminderState{Deadline: deadline}
+	}
+
+	passed := !now.Before(deadline)
+
+	if state.Snoozed != nil {
+		if now.Before(*state.Snoozed) {
+			return "", false
+		}
+		state.Snoozed = nil
+		if passed {
+			state.Overdue = &now
+			return Overdue, true
+		}
+		if shortest, ok := shortestPassed(deadline, offsets, now); ok {
+			state.Before = shortest
+		}
+		return Upcoming, true
+	}
+
+	if passed {
+		if !overdue || (state.Overdue != nil && now.Sub(*state.Overdue) < OverdueEvery) {
+			return "", false
+		}
+		state.Overdue = &now
+		return Overdue, true
+	}
+
+	shortest, ok := shortestPassed(deadline, offsets, now)
+	if !ok || (state.Before != 0 && shortest >= state.Before) {
+		return "", false
+	}
+	state.Before = shortest
+	return Upcoming, true
+}
+
+// shortestPassed returns the shortest offset before deadline that now is
+// past
+func shortestPassed(deadline time.Time, offsets []time.Duration, now time.Time) (time.Duration, bool) {
+	left := deadline.Sub(now)
+	var shortest time.Duration
+	for _, offset := range offsets {
+		if left <= offset && (shortest == 0 || offset < shortest) {
+			shortest = offset
+		}
+	}
+	return shortest, shortest != 0
+}
+
+// Snooze holds off the reminders of a task until until, when it is
+// reminded of once more
+func Snooze(state *database.ReminderState, deadline, until time.Time) {
+	if !state.Deadline.Equal(deadline) {
+		*state = database.ReminderState{Deadline: deadline}
+	}
+	state.Snoozed = &until
+}
+
+// Text is the message of a reminder
+func Text(kind, body string, deadline, now time.Time) string {
+	if kind == Overdue {
+		return fmt.Sprintf("Overdue by %s: %s", Span(now.Sub(deadline)), body)
+	}
+	return fmt.Sprintf("Due in %s: %s", Span(deadline.Sub(now)), body)
+}
+
+// Span formats a duration the way people say it: "3 days", "5h", "20m"
+func Span(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return "less than a minute"
+}
@@ -0,0 +1,117 @@
+package reminder
+
+import (
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+var (
+	deadline = time.Date(2026, 10, 20, 17, 0, 0, 0, time.UTC)
+	offsets  = []time.Duration{24 * time.Hour, time.Hour}
+)
+
+func TestParseOffset(t *testing.T) {
+	cases := map[string]time.Duration{
+		"1d":    24 * time.Hour,
+		"2h":    2 * time.Hour,
+		"1h30m": 90 * time.Minute,
+		" 15m ": 15 * time.Minute,
+	}
+	for offset, want := range cases {
+		if got, err := ParseOffset(offset); err != nil || got != want {
+			t.Errorf("ParseOffset(%q) = %v, %v; want %v", offset, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "0", "-1h", "xd", "soon"} {
+		if _, err := ParseOffset(bad); err == nil {
+			t.Errorf("ParseOffset(%q) accepted it", bad)
+		}
+	}
+}
+
+func TestCheckOffsets(t *testing.T) {
+	var state database.ReminderState
+	steps := []struct {
+		now  time.Time
+		kind string
+	}{
+		{deadline.Add(-30 * time.Hour), ""},
+		{deadline.Add(-23 * time.Hour), Upcoming},
+		{deadline.Add(-22 * time.Hour), ""},
+		{deadline.Add(-59 * time.Minute), Upcoming},
+		{deadline.Add(-time.Minute), ""},
+		{deadline, Overdue},
+		{deadline.Add(23 * time.Hour), ""},
+		{deadline.Add(24 * time.Hour), Overdue},
+	}
+	for _, step := range steps {
+		kind, due := Check(deadline, offsets, true, &state, step.now)
+		if kind != step.kind || due != (step.kind != "") {
+			t.Errorf("at %v: got %q, %v; want %q", step.now.Sub(deadline), kind, due, step.kind)
+		}
+	}
+}
+
+func TestCheckOnlyShortestOffset(t *testing.T) {
+	var state database.ReminderState
+	now := deadline.Add(-30 * time.Minute)
+	if kind, _ := Check(deadline, offsets, true, &state, now); kind != Upcoming {
+		t.Fatalf("got %q", kind)
+	}
+	if state.Before != time.Hour {
+		t.Errorf("reminded of %v", state.Before)
+	}
+	if _, due := Check(deadline, offsets, true, &state, now.Add(time.Minute)); due {
+		t.Error("reminded twice")
+	}
+}
+
+func TestCheckWithoutOverdue(t *testing.T) {
+	var state database.ReminderState
+	if _, due := Check(deadline, offsets, false, &state, deadline.Add(time.Hour)); due {
+		t.Error("reminded of an overdue task")
+	}
+}
+
+func TestCheckNewDeadlineStartsOver(t *testing.T) {
+	var state database.ReminderState
+	Check(deadline, offsets, true, &state, deadline.Add(-30*time.Minute))
+
+	later := deadline.Add(48 * time.Hour)
+	if kind, _ := Check(later, offsets, true, &state, later.Add(-2*time.Hour)); kind != Upcoming {
+		t.Errorf("moved deadline: got %q", kind)
+	}
+}
+
+func TestSnooze(t *testing.T) {
+	var state database.ReminderState
+	now := deadline.Add(-2 * time.Hour)
+	Check(deadline, offsets, true, &state, now)
+
+	Snooze(&state, deadline, now.Add(90*time.Minute))
+	if _, due := Check(deadline, offsets, true, &state, now.Add(70*time.Minute)); due {
+		t.Error("reminded while snoozed")
+	}
+	if kind, _ := Check(deadline, offsets, true, &state, now.Add(90*time.Minute)); kind != Upcoming {
+		t.Errorf("after snooze: got %q", kind)
+	}
+	if _, due := Check(deadline, offsets, true, &state, now.Add(91*time.Minute)); due {
+		t.Error("the hour reminder followed the snoozed one")
+	}
+
+	Snooze(&state, deadline, deadline.Add(time.Hour))
+	if kind, _ := Check(deadline, offsets, true, &state, deadline.Add(time.Hour)); kind != Overdue {
+		t.Errorf("snoozed past the deadline: got %q", kind)
+	}
+}
+
+func TestText(t *testing.T) {
+	if got := Text(Upcoming, "Ship it", deadline, deadline.Add(-90*time.Minute)); got != "Due in 1h: Ship it" {
+		t.Errorf("upcoming: %q", got)
+	}
+	if got := Text(Overdue, "Ship it", deadline, deadline.Add(50*time.Hour)); got != "Overdue by 2 days: Ship it" {
+		t.Errorf("overdue: %q", got)
+	}
+}
@@ -0,0 +1,230 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query is a parsed search, e.g.
+// migrat* "user table" #backend in:completed on:march
+type Query struct {
+	Words   []string   `json:"words"`   // Words that must all appear; "migrat*" matches by prefix
+	Phrases [][]string `json:"phrases"` // Words that must appear in this order
+	Tags    []string   `json:"tags"`    // Tags the task must all have
+	Lists   []string   `json:"lists"`   // Lists to search; all when empty
+	After   time.Time  `json:"after"`   // Tasks from this moment on, when set
+	Before  time.Time  `json:"before"`  // Tasks before this moment, when set
+}
+
+// listNames maps the names accepted by in: to lists
+var listNames = map[string]string{
+	"active":    ListActive,
+	"open":      ListActive,
+	"completed": ListCompleted,
+	"done":      ListCompleted,
+	"trashed":   ListTrashed,
+	"trash":     ListTrashed,
+}
+
+// ParseQuery reads a search query. Besides words and "quoted phrases" it
+// accepts:
+//
+//	#tag or tag:name       tasks with the tag
+//	in:active|completed|trashed
+//	after:<date>           that day or later
+//	before:<date>          before that day
+//	on:<date>              within that day, month or year
+//
+// Dates are 2026-03-15, 2026-03, 2026, or a month name such as march for its
+// latest occurrence. They refer to when a task was completed, deleted or,
+// for active tasks, created, and are read in the time zone of now.
+func ParseQuery(s string, now time.Time) (*Query, error) {
+	q := &Query{}
+
+	tokens, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, token := range tokens {
+		if token.phrase {
+			words := tokenize(token.text)
+			switch len(words) {
+			case 0:
+			case 1:
+				q.Words = append(q.Words, words[0])
+			default:
+				q.Phrases = append(q.Phrases, words)
+			}
+			continue
+		}
+
+		text := token.text
+		key, value, hasKey := strings.Cut(text, ":")
+		switch {
+		case strings.HasPrefix(text, "#") && len(text) > 1:
+			q.Tags = append(q.Tags, strings.ToLower(text[1:]))
+		case hasKey && key == "tag" && value != "":
+			q.Tags = append(q.Tags, strings.ToLower(strings.TrimPrefix(value, "#")))
+		case hasKey && key == "in":
+			list, ok := listNames[strings.ToLower(value)]
+			if !ok {
+				return nil, fmt.Errorf("unknown list %q, expected active, completed or trashed", value)
+			}
+			q.Lists = append(q.Lists, list)
+		case hasKey && (key == "after" || key == "before" || key == "on"):
+			start, end, err := parseRange(value, now)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "after":
+				q.After = start
+			case "before":
+				q.Before = start
+			case "on":
+				q.After, q.Before = start, end
+			}
+		default:
+			q.Words = append(q.Words, queryWords(text)...)
+		}
+	}
+
+	return q, nil
+}
+
+// queryWords tokenizes a query word, keeping a trailing * for prefixes
+func queryWords(text string) []string {
+	words := tokenize(text)
+	if strings.HasSuffix(text, "*") && len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return words
+}
+
+type queryToken struct {
+	text   string
+	phrase bool
+}
+
+// splitQuery splits a query at spaces outside of double quotes
+func splitQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	var current strings.Builder
+	quoted := false
+
+	flush := func() {
+		if current.Len() > 0 || quoted {
+			tokens = append(tokens, queryToken{text: current.String(), phrase: quoted})
+		}
+		current.Reset()
+	}
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			flush()
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	flush()
+	return tokens, nil
+}
+
+// months lets dates be written as month names
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+// parseRange returns the start and end of a day, month or year
+func parseRange(value string, now time.Time) (start, end time.Time, err error) {
+	loc := now.Location()
+	value = strings.ToLower(value)
+
+	if month, ok := months[value]; ok {
+		year := now.Year()
+		if month > now.Month() {
+			year--
+		}
+		start = time.Date(year, month, 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0), nil
+	}
+
+	layouts := []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	}
+	for _, l := range layouts {
+		if start, err := time.ParseInLocation(l.layout, value, loc); err == nil {
+			return start, start.AddDate(l.years, l.months, l.days), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD, YYYY-MM, YYYY or a month name", value)
+}
+
+// allWords returns the words of the query, including those of its phrases
+func (q *Query) allWords() []string {
+	words := append([]string(nil), q.Words...)
+	for _, phrase := range q.Phrases {
+		words = append(words, phrase...)
+	}
+	return words
+}
+
+// matchesFilters checks the tags, lists and dates of the query
+func (q *Query) matchesFilters(doc *document) bool {
+	if len(q.Lists) > 0 {
+		found := false
+		for _, list := range q.Lists {
+			found = found || list == doc.list
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, want := range q.Tags {
+		found := false
+		for _, tag := range doc.task.Tags {
+			found = found || strings.EqualFold(tag, want)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	t := listTime(doc.list, &doc.task)
+	if !q.After.IsZero() && t.Before(q.After) {
+		return false
+	}
+	if !q.Before.IsZero() && !t.Before(q.Before) {
+		return false
+	}
+	return true
+}
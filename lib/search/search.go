@@ -0,0 +1,282 @@
+// Package search keeps an inverted index of task bodies and answers queries
+// with words, "quoted phrases", #tags, lists and dates.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	database "done/lib/database/interface"
+)
+
+// Lists a task can be found in
+const (
+	ListActive    = "active"
+	ListCompleted = "completed"
+	ListTrashed   = "trashed"
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Result is a matching task and the list it is in
+type Result struct {
+	List  string        `json:"list"`
+	Score float64       `json:"score"`
+	Date  time.Time     `json:"date"` // When the task was completed, deleted or, if active, created
+	Task  database.Task `json:"task"`
+}
+
+// document is an indexed task
+type document struct {
+	list   string
+	task   database.Task
+	length int // Number of words in the body
+}
+
+// Index is an inverted index of the tasks in all lists. It is safe for
+// concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string][]int // term -> document ID -> word positions
+	words    int                         // Total words of all documents
+}
+
+// New returns an empty index
+func New() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string][]int),
+	}
+}
+
+// docID identifies a task within a list, since a task moves from list to
+// list as it is completed, deleted or restored
+func docID(list, uuid string) string {
+	return list + "/" + uuid
+}
+
+// Put adds a task to the index, or updates it
+func (ix *Index) Put(list string, task *database.Task) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	id := docID(list, task.UUID)
+	if old, ok := ix.docs[id]; ok {
+		if old.task.Body == task.Body {
+			old.task = *task
+			return
+		}
+		ix.remove(id)
+	}
+
+	words := tokenize(task.Body)
+	for position, word := range words {
+		if ix.postings[word] == nil {
+			ix.postings[word] = make(map[string][]int)
+		}
+		ix.postings[word][id] = append(ix.postings[word][id], position)
+	}
+	ix.docs[id] = &document{list: list, task: *task, length: len(words)}
+	ix.words += len(words)
+}
+
+// Delete removes a task from a list's part of the index
+func (ix *Index) Delete(list, uuid string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(docID(list, uuid))
+}
+
+// Len returns the number of indexed tasks
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+func (ix *Index) remove(id string) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, word := range tokenize(doc.task.Body) {
+		delete(ix.postings[word], id)
+		if len(ix.postings[word]) == 0 {
+			delete(ix.postings, word)
+		}
+	}
+	ix.words -= doc.length
+	delete(ix.docs, id)
+}
+
+// Search returns the tasks matching every word, phrase and filter of the
+// query, best matches first. Tasks that match only filters are sorted by
+// date, newest first.
+func (ix *Index) Search(q *Query, limit int) []Result {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	candidates := ix.candidates(q)
+
+	results := []Result{}
+	for id := range candidates {
+		doc := ix.docs[id]
+		if !q.matchesFilters(doc) || !ix.matchesPhrases(q, id) {
+			continue
+		}
+		results = append(results, Result{
+			List:  doc.list,
+			Score: ix.score(q, id),
+			Date:  listTime(doc.list, &doc.task),
+			Task:  doc.task,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if !results[i].Date.Equal(results[j].Date) {
+			return results[i].Date.After(results[j].Date)
+		}
+		return results[i].Task.UUID < results[j].Task.UUID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// candidates returns the documents containing every word of the query, or
+// all documents when the query has no words
+func (ix *Index) candidates(q *Query) map[string]bool {
+	words := q.allWords()
+	if len(words) == 0 {
+		all := make(map[string]bool, len(ix.docs))
+		for id := range ix.docs {
+			all[id] = true
+		}
+		return all
+	}
+
+	var result map[string]bool
+	for _, word := range words {
+		matching := make(map[string]bool)
+		for _, term := range ix.expand(word) {
+			for id := range ix.postings[term] {
+				if result == nil || result[id] {
+					matching[id] = true
+				}
+			}
+		}
+		result = matching
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+// expand returns the indexed terms a query word stands for: itself, or every
+// term starting with a prefix written as "migrat*"
+func (ix *Index) expand(word string) []string {
+	prefix := strings.TrimSuffix(word, "*")
+	if prefix == word {
+		return []string{word}
+	}
+
+	var terms []string
+	for term := range ix.postings {
+		if strings.HasPrefix(term, prefix) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// matchesPhrases reports whether the document contains every phrase of the
+// query as consecutive words
+func (ix *Index) matchesPhrases(q *Query, id string) bool {
+	for _, phrase := range q.Phrases {
+		found := false
+		for _, start := range ix.postings[phrase[0]][id] {
+			found = true
+			for offset, word := range phrase[1:] {
+				if !contains(ix.postings[word][id], start+offset+1) {
+					found = false
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// score ranks a document by BM25 over the words of the query
+func (ix *Index) score(q *Query, id string) float64 {
+	doc := ix.docs[id]
+	average := float64(ix.words) / float64(len(ix.docs))
+	if average == 0 {
+		average = 1
+	}
+
+	score := 0.0
+	for _, word := range q.allWords() {
+		for _, term := range ix.expand(word) {
+			frequency := float64(len(ix.postings[term][id]))
+			if frequency == 0 {
+				continue
+			}
+			n := float64(len(ix.postings[term]))
+			idf := math.Log(1 + (float64(len(ix.docs))-n+0.5)/(n+0.5))
+			score += idf * frequency * (k1 + 1) / (frequency + k1*(1-b+b*float64(doc.length)/average))
+		}
+	}
+	return math.Round(score*1000) / 1000
+}
+
+// listTime is the date filters and ordering use: when the task was
+// completed, deleted or, for active tasks, created
+func listTime(list string, task *database.Task) time.Time {
+	switch list {
+	case ListCompleted:
+		return task.TimeCompleted
+	case ListTrashed:
+		if task.TimeDeleted != nil {
+			return *task.TimeDeleted
+		}
+	}
+	return task.TimeCreated
+}
+
+// tokenize splits text into lowercase words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func contains(positions []int, position int) bool {
+	for _, p := range positions {
+		if p == position {
+			return true
+		}
+	}
+	return false
+}
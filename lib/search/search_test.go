@@ -0,0 +1,92 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	database "done/lib/database/interface"
+)
+
+var now = time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+func index() *Index {
+	ix := New()
+	march := time.Date(2026, 3, 12, 15, 0, 0, 0, time.UTC)
+	ix.Put(ListCompleted, &database.Task{UUID: "march", Body: "Run the user table migration", Tags: []string{"backend"}, TimeCompleted: march})
+	ix.Put(ListActive, &database.Task{UUID: "active", Body: "Plan migration of the billing table", TimeCreated: now})
+	ix.Put(ListActive, &database.Task{UUID: "docs", Body: "Document the migration, the migration guide and migrations", Tags: []string{"docs"}, TimeCreated: now.Add(-time.Hour)})
+	ix.Put(ListTrashed, &database.Task{UUID: "trashed", Body: "User table cleanup", TimeCreated: now})
+	return ix
+}
+
+func search(t *testing.T, ix *Index, query string) []string {
+	q, err := ParseQuery(query, now)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	var uuids []string
+	for _, r := range ix.Search(q, 0) {
+		uuids = append(uuids, r.Task.UUID)
+	}
+	return uuids
+}
+
+func TestSearch(t *testing.T) {
+	ix := index()
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"migration", []string{"docs", "march", "active"}},
+		{`"user table"`, []string{"trashed", "march"}},
+		{`"table user"`, nil},
+		{"migrat*", []string{"docs", "march", "active"}},
+		{"migration #backend", []string{"march"}},
+		{"table in:active", []string{"active"}},
+		{"migration on:march", []string{"march"}},
+		{"migration after:2026-04", []string{"docs", "active"}},
+		{"in:trash", []string{"trashed"}},
+		{"nothing", nil},
+	}
+	for _, c := range cases {
+		got := search(t, ix, c.query)
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %v, want %v", c.query, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: got %v, want %v", c.query, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	ix := index()
+
+	ix.Put(ListActive, &database.Task{UUID: "active", Body: "Plan the release"})
+	if got := search(t, ix, "billing"); len(got) != 0 {
+		t.Errorf("old body still found: %v", got)
+	}
+	if got := search(t, ix, "release"); len(got) != 1 {
+		t.Errorf("new body not found: %v", got)
+	}
+
+	// Completing moves a task from one list to the other
+	ix.Put(ListCompleted, &database.Task{UUID: "active", Body: "Plan the release"})
+	ix.Delete(ListActive, "active")
+	if got := search(t, ix, "release in:completed"); len(got) != 1 || ix.Len() != 4 {
+		t.Errorf("completed task not found: %v, %d indexed", got, ix.Len())
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{`"open quote`, "in:archive", "on:someday"} {
+		if _, err := ParseQuery(query, now); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
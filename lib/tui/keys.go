@@ -0,0 +1,96 @@
+package tui
+
+import (
+	"io"
+)
+
+// Keys understood by the terminal UI. Printable characters are passed on as
+// themselves, e.g. "q" or "K".
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyShiftUp   = "shift-up"
+	keyShiftDown = "shift-down"
+	keyEnter     = "enter"
+	keySpace     = "space"
+	keyEscape    = "esc"
+	keyCtrlC     = "ctrl-c"
+)
+
+// escapeSequences maps the terminal escape sequences of special keys
+var escapeSequences = map[string]string{
+	"\x1b[A":    keyUp,
+	"\x1b[B":    keyDown,
+	"\x1bOA":    keyUp,
+	"\x1bOB":    keyDown,
+	"\x1b[1;2A": keyShiftUp,
+	"\x1b[1;2B": keyShiftDown,
+}
+
+// readKeys sends the keys typed on in until it is closed
+func readKeys(in io.Reader) <-chan string {
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 64)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, key := range decodeKeys(buf[:n]) {
+				keys <- key
+			}
+		}
+	}()
+	return keys
+}
+
+// decodeKeys splits the bytes of one terminal read into keys. Unknown escape
+// sequences are dropped.
+func decodeKeys(data []byte) []string {
+	var keys []string
+	for len(data) > 0 {
+		if data[0] == 0x1b {
+			if len(data) == 1 {
+				keys = append(keys, keyEscape)
+				break
+			}
+			length := escapeLength(data)
+			if key, ok := escapeSequences[string(data[:length])]; ok {
+				keys = append(keys, key)
+			}
+			data = data[length:]
+			continue
+		}
+
+		switch data[0] {
+		case '\r', '\n':
+			keys = append(keys, keyEnter)
+		case ' ':
+			keys = append(keys, keySpace)
+		case 0x03:
+			keys = append(keys, keyCtrlC)
+		default:
+			if data[0] >= 0x20 && data[0] < 0x7f {
+				keys = append(keys, string(data[0]))
+			}
+		}
+		data = data[1:]
+	}
+	return keys
+}
+
+// escapeLength returns the length of the escape sequence at the start of
+// data: ESC, an optional [ or O, parameters and a final letter
+func escapeLength(data []byte) int {
+	if len(data) < 2 || (data[1] != '[' && data[1] != 'O') {
+		return 1
+	}
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(data)
+}
@@ -0,0 +1,41 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{"q", []string{"q"}},
+		{"jjK", []string{"j", "j", "K"}},
+		{"\r \n", []string{keyEnter, keySpace, keyEnter}},
+		{"\x03", []string{keyCtrlC}},
+		{"\x1b", []string{keyEscape}},
+		{"\x1b[A\x1b[B", []string{keyUp, keyDown}},
+		{"\x1bOA\x1bOB", []string{keyUp, keyDown}},
+		{"\x1b[1;2Ac\x1b[1;2B", []string{keyShiftUp, "c", keyShiftDown}},
+		// Unknown sequences and control characters are dropped
+		{"\x1b[5~r\x1b[C\x7f\x01", []string{"r"}},
+		{"\x1bx", []string{"x"}},
+	}
+	for _, test := range tests {
+		if got := decodeKeys([]byte(test.data)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("decodeKeys(%q) = %q, want %q", test.data, got, test.want)
+		}
+	}
+}
+
+func TestReadKeys(t *testing.T) {
+	var got []string
+	for key := range readKeys(strings.NewReader("j\x1b[Bc")) {
+		got = append(got, key)
+	}
+	if want := []string{"j", keyDown, "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %q, want %q", got, want)
+	}
+}
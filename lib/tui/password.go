@@ -0,0 +1,50 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadPassword prints prompt to stderr and reads a line from stdin without
+// echoing it. When stdin is not a terminal, e.g. a pipe, the line is read
+// as it is.
+func ReadPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	fd := int(os.Stdin.Fd())
+	state, err := makeRaw(fd)
+	if err != nil {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer func() {
+		restore(fd, state)
+		fmt.Fprint(os.Stderr, "\n")
+	}()
+
+	var password []byte
+	buf := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(buf); err != nil {
+			return "", err
+		}
+		switch buf[0] {
+		case '\r', '\n':
+			return string(password), nil
+		case 3, 4: // Ctrl+C, Ctrl+D
+			return "", errors.New("cancelled")
+		case 127, 8: // Backspace
+			if len(password) > 0 {
+				password = password[:len(password)-1]
+			}
+		default:
+			password = append(password, buf[0])
+		}
+	}
+}
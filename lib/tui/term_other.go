@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package tui
+
+import "errors"
+
+type terminalState struct{}
+
+func makeRaw(fd int) (*terminalState, error) {
+	return nil, errors.New("the terminal UI is not supported on this platform")
+}
+
+func restore(fd int, state *terminalState) error {
+	return nil
+}
+
+func terminalSize(fd int) (width, height int, err error) {
+	return 80, 24, nil
+}
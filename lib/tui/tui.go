@@ -0,0 +1,446 @@
+// Package tui is the interactive terminal mode of Done ("done tui"). It shows
+// the ordered task list, the golden "Done Today" results, the running timer
+// and the points, and changes tasks through the same client as the
+// subcommands, so completions are scored by the same handler logic as in the
+// web UI.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"done/lib/client"
+	database "done/lib/database/interface"
+	"done/lib/utils"
+)
+
+// ANSI escape sequences used for drawing
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	cursorHome     = "\x1b[H"
+	clearLine      = "\x1b[K"
+	clearBelow     = "\x1b[J"
+
+	styleReset     = "\x1b[0m"
+	styleBold      = "\x1b[1m"
+	styleDim       = "\x1b[2m"
+	styleReverse   = "\x1b[7m"
+	styleGolden    = "\x1b[1;33m"
+	styleTimer     = "\x1b[32m"
+	styleOverdue   = "\x1b[31m"
+	styleCelebrate = "\x1b[1;32m"
+)
+
+const (
+	// reloadEvery is how often the lists are fetched again, so changes made
+	// in the web UI show up
+	reloadEvery = 10 * time.Second
+	// maxTodayRows bounds the Done Today section, newest completions shown
+	maxTodayRows = 5
+)
+
+const helpLine = "↑/↓ select  K/J move  space timer  c complete  C force complete  r refresh  q quit"
+
+type app struct {
+	client client.Client
+	out    io.Writer
+	fd     int
+
+	tasks        []database.Task
+	today        []database.Task
+	gamification *database.Gamification
+
+	cursor int    // Index of the selected task
+	offset int    // Index of the first task shown
+	timing string // UUID of the task whose timer is running
+	status string // Result of the last action
+}
+
+// Run shows the terminal UI on the terminal behind in and out until the user
+// quits. A running timer is stopped and saved on exit.
+func Run(c client.Client, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	state, err := makeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("cannot set up the terminal: %w", err)
+	}
+	defer restore(fd, state)
+
+	fmt.Fprint(out, enterAltScreen)
+	defer fmt.Fprint(out, leaveAltScreen)
+
+	// Handler logging would scribble over the screen
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	a := &app{client: c, out: out, fd: fd}
+	a.reload()
+
+	keys := readKeys(in)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	lastReload := time.Now()
+
+	for {
+		a.render()
+
+		select {
+		case key, ok := <-keys:
+			if !ok || key == "q" || key == keyEscape || key == keyCtrlC {
+				return nil
+			}
+			a.handle(key)
+		case now := <-ticker.C:
+			a.tick()
+			if now.Sub(lastReload) >= reloadEvery {
+				a.reload()
+				lastReload = now
+			}
+		}
+	}
+}
+
+// reload fetches the task lists and the gamification stats
+func (a *app) reload() {
+	tasks, err := a.client.Tasks()
+	if err != nil {
+		a.status = "Cannot load tasks: " + err.Error()
+		return
+	}
+	a.tasks = tasks
+
+	if today, err := a.client.CompletedToday(); err == nil {
+		a.today = today
+	}
+	if gamification, err := a.client.Gamification(); err == nil {
+		a.gamification = gamification
+	}
+
+	if a.cursor >= len(a.tasks) {
+		a.cursor = len(a.tasks) - 1
+	}
+	if a.cursor < 0 {
+		a.cursor = 0
+	}
+	if a.timing != "" && a.indexOf(a.timing) < 0 {
+		// Completed or deleted elsewhere
+		a.timing = ""
+	}
+}
+
+func (a *app) handle(key string) {
+	switch key {
+	case keyUp, "k":
+		if a.cursor > 0 {
+			a.cursor--
+		}
+	case keyDown, "j":
+		if a.cursor < len(a.tasks)-1 {
+			a.cursor++
+		}
+	case keyShiftUp, "K":
+		a.move(-1)
+	case keyShiftDown, "J":
+		a.move(1)
+	case keySpace, keyEnter:
+		a.toggleTimer()
+	case "c":
+		a.complete(false)
+	case "C":
+		a.complete(true)
+	case "r":
+		a.status = ""
+		a.reload()
+	}
+}
+
+// move swaps the selected task with its neighbour in the given direction
+func (a *app) move(direction int) {
+	target := a.cursor + direction
+	if len(a.tasks) == 0 || target < 0 || target >= len(a.tasks) {
+		return
+	}
+
+	if err := a.client.Move(a.tasks[a.cursor].UUID, a.tasks[target].UUID); err != nil {
+		a.status = "Cannot move task: " + err.Error()
+		return
+	}
+
+	a.cursor = target
+	a.reload()
+}
+
+// toggleTimer starts the timer on the selected task, or stops it if it is
+// already running there. Only one timer runs at a time, as in the web UI.
+func (a *app) toggleTimer() {
+	if len(a.tasks) == 0 {
+		return
+	}
+
+	uuid := a.tasks[a.cursor].UUID
+	if a.timing == uuid {
+		a.timing = ""
+		a.status = "Timer stopped"
+		return
+	}
+
+	a.timing = uuid
+	a.status = "Timer started: " + firstLine(a.tasks[a.cursor].Body)
+}
+
+// tick counts a second on the running timer and saves it
+func (a *app) tick() {
+	if a.timing == "" {
+		return
+	}
+
+	i := a.indexOf(a.timing)
+	if i < 0 {
+		a.timing = ""
+		return
+	}
+
+	a.tasks[i].DurationExecutionRealSeconds++
+	if err := a.client.SetRealSeconds(a.timing, a.tasks[i].DurationExecutionRealSeconds); err != nil {
+		a.status = "Cannot save timer: " + err.Error()
+	}
+}
+
+// complete marks the selected task as done and reports the points earned.
+// A task blocked by open tasks is only completed when forced.
+func (a *app) complete(force bool) {
+	if len(a.tasks) == 0 {
+		return
+	}
+
+	task := a.tasks[a.cursor]
+	before := a.gamification
+
+	unblocked, err := a.client.Complete(task.UUID, force)
+	if err != nil {
+		a.status = "Cannot complete task: " + err.Error()
+		if task.Blocked {
+			a.status += " (C completes it anyway)"
+		}
+		return
+	}
+	if a.timing == task.UUID {
+		a.timing = ""
+	}
+
+	a.reload()
+	a.status = "Completed: " + firstLine(task.Body)
+
+	if before != nil && a.gamification != nil {
+		a.status += fmt.Sprintf("  +%d points", a.gamification.TotalPoints-before.TotalPoints)
+		for _, achievement := range a.gamification.Achievements {
+			if !contains(before.Achievements, achievement) {
+				a.status += "  ★ " + achievement
+			}
+		}
+	}
+	for _, task := range unblocked {
+		a.status += "  ⇢ unblocked: " + firstLine(task.Body)
+	}
+}
+
+func (a *app) indexOf(uuid string) int {
+	for i := range a.tasks {
+		if a.tasks[i].UUID == uuid {
+			return i
+		}
+	}
+	return -1
+}
+
+// render draws the whole screen
+func (a *app) render() {
+	width, height, err := terminalSize(a.fd)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	var header, footer []string
+
+	header = append(header, styleBold+truncate("DONE", width)+styleReset+styleDim+truncate(a.statsLine(), width-4)+styleReset)
+	header = append(header, "")
+	header = append(header, styleBold+"TASKS"+styleReset)
+
+	footer = append(footer, "")
+	footer = append(footer, a.todayLines(width)...)
+	footer = append(footer, "")
+	if a.status != "" {
+		footer = append(footer, styleCelebrate+truncate(a.status, width)+styleReset)
+	}
+	footer = append(footer, styleDim+truncate(helpLine, width)+styleReset)
+
+	rows := height - len(header) - len(footer)
+	if rows < 1 {
+		rows = 1
+	}
+
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	for _, line := range header {
+		b.WriteString(line + clearLine + "\r\n")
+	}
+	for _, line := range a.taskLines(width, rows) {
+		b.WriteString(line + clearLine + "\r\n")
+	}
+	for i, line := range footer {
+		b.WriteString(line + clearLine)
+		if i < len(footer)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString(clearBelow)
+
+	io.WriteString(a.out, b.String())
+}
+
+// statsLine summarizes the gamification stats for the header
+func (a *app) statsLine() string {
+	g := a.gamification
+	if g == nil {
+		return ""
+	}
+	return fmt.Sprintf("  Level %d · %d points · %d day streak · %d/100 towards level %d",
+		g.Level, g.TotalPoints, g.CurrentStreak, g.TotalPoints%100, g.Level+1)
+}
+
+// taskLines renders at most rows tasks, scrolled so the selection is visible
+func (a *app) taskLines(width, rows int) []string {
+	if len(a.tasks) == 0 {
+		return []string{styleDim + "  No tasks. Add one with: done add \"text\"" + styleReset}
+	}
+
+	if a.cursor < a.offset {
+		a.offset = a.cursor
+	}
+	if a.cursor >= a.offset+rows {
+		a.offset = a.cursor - rows + 1
+	}
+
+	var lines []string
+	now := time.Now()
+	for i := a.offset; i < len(a.tasks) && i < a.offset+rows; i++ {
+		task := &a.tasks[i]
+
+		marker := " "
+		if a.timing == task.UUID {
+			marker = "▶"
+		}
+		prefix := fmt.Sprintf("%s %2d. ", marker, i+1)
+
+		details := ""
+		if task.Blocked {
+			details += "  blocked"
+		}
+		if task.DurationExecutionEstimatedSeconds > 0 {
+			details += "  est " + utils.FormatEstimate(task.DurationExecutionEstimatedSeconds)
+		}
+		if task.TimeHardDeadline != nil {
+			details += "  due " + formatDeadline(task)
+		}
+		if task.DurationExecutionRealSeconds > 0 || a.timing == task.UUID {
+			details += "  " + formatElapsed(task.DurationExecutionRealSeconds)
+		}
+
+		bodyWidth := width - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(details)
+		line := prefix + pad(truncate(firstLine(task.Body), bodyWidth), bodyWidth) + details
+		line = truncate(line, width)
+
+		switch {
+		case i == a.cursor:
+			line = styleReverse + line + styleReset
+		case a.timing == task.UUID:
+			line = styleTimer + line + styleReset
+		case overdue(task, now):
+			line = styleOverdue + line + styleReset
+		case task.Blocked:
+			line = styleDim + line + styleReset
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// todayLines renders the golden Done Today section
+func (a *app) todayLines(width int) []string {
+	lines := []string{styleGolden + truncate(fmt.Sprintf("DONE TODAY (%d)", len(a.today)), width) + styleReset}
+	if len(a.today) == 0 {
+		return append(lines, styleDim+"  Nothing completed yet"+styleReset)
+	}
+
+	today := a.today
+	if len(today) > maxTodayRows {
+		lines = append(lines, styleGolden+fmt.Sprintf("  … %d more", len(today)-maxTodayRows)+styleReset)
+		today = today[len(today)-maxTodayRows:]
+	}
+	for _, task := range today {
+		line := fmt.Sprintf("  %s  %s", task.TimeCompleted.Local().Format("15:04"), firstLine(task.Body))
+		lines = append(lines, styleGolden+truncate(line, width)+styleReset)
+	}
+	return lines
+}
+
+func overdue(task *database.Task, now time.Time) bool {
+	deadline, ok := task.HardDeadline()
+	return ok && now.After(deadline)
+}
+
+func formatDeadline(task *database.Task) string {
+	if task.DeadlineHasTime {
+		return task.TimeHardDeadline.Local().Format("Jan 2 15:04")
+	}
+	return task.TimeHardDeadline.Local().Format("Jan 2")
+}
+
+// formatElapsed renders timer seconds as h:mm:ss
+func formatElapsed(seconds int) string {
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+}
+
+func firstLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i] + " …"
+	}
+	return text
+}
+
+// truncate shortens s to at most width characters
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	if width == 1 {
+		return string(runes[:1])
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+func pad(s string, width int) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
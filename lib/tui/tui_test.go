@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"done/lib/client"
+)
+
+// newApp returns the UI of a new database file holding the tasks, in the
+// order given, without a terminal
+func newApp(t *testing.T, bodies ...string) *app {
+	t.Helper()
+	// Completing a task writes the report into the home directory
+	t.Setenv("HOME", t.TempDir())
+	c, err := client.OpenLocal(filepath.Join(t.TempDir(), "done.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	for i := len(bodies) - 1; i >= 0; i-- {
+		if err := c.QuickAdd(bodies[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := &app{client: c, out: ioutil.Discard, fd: -1}
+	a.reload()
+	return a
+}
+
+// bodies returns the bodies of the tasks of a, in order
+func bodies(a *app) string {
+	var bodies []string
+	for _, task := range a.tasks {
+		bodies = append(bodies, task.Body)
+	}
+	return strings.Join(bodies, ", ")
+}
+
+func TestSelect(t *testing.T) {
+	a := newApp(t, "Task one", "Task two", "Task three")
+	for _, step := range []struct {
+		key    string
+		cursor int
+	}{
+		{keyUp, 0},
+		{keyDown, 1},
+		{"j", 2},
+		{"j", 2},
+		{"k", 1},
+	} {
+		a.handle(step.key)
+		if a.cursor != step.cursor {
+			t.Fatalf("after %s: cursor = %d, want %d", step.key, a.cursor, step.cursor)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	a := newApp(t, "Task one", "Task two", "Task three")
+	a.handle("J")
+	if got := bodies(a); got != "Task two, Task one, Task three" || a.cursor != 1 {
+		t.Errorf("after J: %s, cursor %d", got, a.cursor)
+	}
+	a.handle(keyShiftUp)
+	a.handle(keyShiftUp)
+	if got := bodies(a); got != "Task one, Task two, Task three" || a.cursor != 0 {
+		t.Errorf("after moving up past the top: %s, cursor %d", got, a.cursor)
+	}
+}
+
+func TestTimer(t *testing.T) {
+	a := newApp(t, "Task one", "Task two")
+	a.handle(keyDown)
+	a.handle(keySpace)
+	if a.timing != a.tasks[1].UUID {
+		t.Fatalf("timing %q, want the selected task", a.timing)
+	}
+	a.tick()
+	a.tick()
+
+	a.reload()
+	if seconds := a.tasks[1].DurationExecutionRealSeconds; seconds != 2 {
+		t.Errorf("saved %d seconds, want 2", seconds)
+	}
+	if line := a.taskLines(80, 5)[1]; !strings.Contains(line, "▶") || !strings.Contains(line, "0:00:02") {
+		t.Errorf("timed task shown as %q", line)
+	}
+
+	a.handle(keyEnter)
+	a.tick()
+	if a.timing != "" || a.tasks[1].DurationExecutionRealSeconds != 2 {
+		t.Errorf("stopped timer: timing %q, %d seconds", a.timing, a.tasks[1].DurationExecutionRealSeconds)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	a := newApp(t, "Task one", "Task two")
+	a.handle(keyDown)
+	a.handle(keySpace)
+	a.handle("c")
+
+	if !strings.HasPrefix(a.status, "Completed: Task two  +") {
+		t.Errorf("status = %q", a.status)
+	}
+	if got := bodies(a); got != "Task one" || a.cursor != 0 || a.timing != "" {
+		t.Errorf("after completing: %s, cursor %d, timing %q", got, a.cursor, a.timing)
+	}
+	if len(a.today) != 1 || a.today[0].Body != "Task two" {
+		t.Errorf("done today = %+v", a.today)
+	}
+}
+
+func TestCompleteBlocked(t *testing.T) {
+	a := newApp(t, "Task one", "Task two")
+	if err := a.client.Block(a.tasks[1].UUID, []string{a.tasks[0].UUID}); err != nil {
+		t.Fatal(err)
+	}
+	a.reload()
+	a.handle(keyDown)
+
+	a.handle("c")
+	if !strings.HasSuffix(a.status, "(C completes it anyway)") || len(a.tasks) != 2 {
+		t.Errorf("completing a blocked task: %q", a.status)
+	}
+	a.handle("C")
+	if got := bodies(a); got != "Task one" {
+		t.Errorf("after forcing: %s (%q)", got, a.status)
+	}
+}
+
+func TestScroll(t *testing.T) {
+	a := newApp(t, "Task one", "Task two", "Task three", "Task four")
+	for i := 0; i < 3; i++ {
+		a.handle(keyDown)
+	}
+	lines := a.taskLines(40, 2)
+	if len(lines) != 2 || !strings.Contains(lines[0], "3. Task three") || !strings.HasPrefix(lines[1], styleReverse) {
+		t.Errorf("lines = %q", lines)
+	}
+}
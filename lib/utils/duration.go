@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WorkdaySeconds is the length of an estimated day, as in the web UI
+const WorkdaySeconds = 8 * 60 * 60
+
+// ParseEstimate converts an estimate like "45m", "1h30m" or "2d" into
+// seconds. A day is a workday of 8 hours and a bare number means minutes.
+func ParseEstimate(s string) (int, error) {
+	s = strings.TrimSpace(s)
+
+	if minutes, err := strconv.Atoi(s); err == nil && minutes >= 0 {
+		return minutes * 60, nil
+	}
+
+	seconds := 0
+	if i := strings.IndexByte(s, 'd'); i >= 0 {
+		days, err := strconv.Atoi(s[:i])
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid estimate %q", s)
+		}
+		seconds = days * WorkdaySeconds
+		s = s[i+1:]
+	}
+
+	if s != "" {
+		duration, err := time.ParseDuration(s)
+		if err != nil || duration < 0 {
+			return 0, fmt.Errorf("invalid estimate %q", s)
+		}
+		seconds += int(duration.Seconds())
+	}
+
+	return seconds, nil
+}
+
+// FormatEstimate renders an estimate in the form ParseEstimate reads
+func FormatEstimate(seconds int) string {
+	if seconds <= 0 {
+		return "-"
+	}
+
+	var b strings.Builder
+	if days := seconds / WorkdaySeconds; days > 0 {
+		fmt.Fprintf(&b, "%dd", days)
+		seconds %= WorkdaySeconds
+	}
+	if hours := seconds / 3600; hours > 0 {
+		fmt.Fprintf(&b, "%dh", hours)
+	}
+	if minutes := seconds % 3600 / 60; minutes > 0 {
+		fmt.Fprintf(&b, "%dm", minutes)
+	}
+	if b.Len() == 0 {
+		return "<1m"
+	}
+	return b.String()
+}
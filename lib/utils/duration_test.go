@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestParseEstimate(t *testing.T) {
+	cases := map[string]int{
+		"45":    45 * 60,
+		"45m":   45 * 60,
+		"1h30m": 90 * 60,
+		"2d":    2 * WorkdaySeconds,
+		"1d2h":  WorkdaySeconds + 2*3600,
+	}
+	for input, want := range cases {
+		got, err := ParseEstimate(input)
+		if err != nil || got != want {
+			t.Errorf("ParseEstimate(%q) = %d, %v; want %d", input, got, err, want)
+		}
+		if back, _ := ParseEstimate(FormatEstimate(got)); back != got {
+			t.Errorf("FormatEstimate(%d) does not round trip", got)
+		}
+	}
+
+	for _, input := range []string{"soon", "-1h", "xd"} {
+		if _, err := ParseEstimate(input); err == nil {
+			t.Errorf("ParseEstimate(%q) should fail", input)
+		}
+	}
+}
@@ -45,6 +45,12 @@ func DecodeTaskText(text string) string {
 	return text
 }
 
+// EncodeTaskText encodes task text the way the web UI sends it: URL-safe
+// base64 without padding
+func EncodeTaskText(text string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(text))
+}
+
 // CleanTaskText removes any encoding artifacts from task text
 func CleanTaskText(text string) string {
 	// First decode
@@ -0,0 +1,83 @@
+// Package webhook signs outgoing webhook deliveries and decides when failed
+// ones are tried again.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a delivery
+const (
+	EventHeader     = "X-Done-Event"     // Type of the event
+	DeliveryHeader  = "X-Done-Delivery"  // ID of the delivery, the same on every attempt
+	TimestampHeader = "X-Done-Timestamp" // Unix time the delivery was signed at
+	SignatureHeader = "X-Done-Signature" // "sha256=" and the hex HMAC of the timestamp and body
+)
+
+const (
+	// SecretPrefix starts the secrets deliveries are signed with
+	SecretPrefix = "whsec_"
+
+	// MaxAttempts is how often a delivery is tried before it is given up
+	MaxAttempts = 10
+
+	firstRetry = 30 * time.Second
+	maxRetry   = 6 * time.Hour
+)
+
+// sharedAddressSpace is 100.64.0.0/10, which carriers use for their
+// internal networks (RFC 6598)
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Sign returns the signature header of a delivery: the HMAC-SHA256, keyed
+// with the secret, of the timestamp, a dot and the body. Receivers compute
+// the same and compare, and reject old timestamps to stop replays.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the one Sign makes
+func Verify(secret string, timestamp time.Time, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Backoff returns how long to wait before trying a delivery again after
+// attempts failed attempts: 30 seconds, doubling each time, up to 6 hours
+func Backoff(attempts int) time.Duration {
+	wait := firstRetry
+	for i := 1; i < attempts && wait < maxRetry; i++ {
+		wait *= 2
+	}
+	if wait > maxRetry {
+		wait = maxRetry
+	}
+	return wait
+}
+
+// CheckURL returns an error unless rawURL is an absolute http or https URL
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("webhook URL must be an http or https URL")
+	}
+	return nil
+}
+
+// Public reports whether ip is an address on the internet: not loopback,
+// unspecified, link-local, multicast or on a private network
+func Public(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsUnspecified() && !ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsMulticast() && !sharedAddressSpace.Contains(ip)
+}
@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	timestamp := time.Unix(1760000000, 0)
+	body := []byte(`{"event":"task.completed"}`)
+
+	signature := Sign("whsec_test", timestamp, body)
+	// echo -n '1760000000.{"event":"task.completed"}' | openssl dgst -sha256 -hmac whsec_test
+	want := "sha256=50b51ef4745cdd1361704d13749c5259385a54af44fd07859b847ce0460bcb95"
+	if signature != want {
+		t.Fatalf("Sign = %q, want %q", signature, want)
+	}
+
+	if !Verify("whsec_test", timestamp, body, signature) {
+		t.Error("Verify rejected the signature")
+	}
+	if Verify("whsec_other", timestamp, body, signature) {
+		t.Error("Verify accepted another secret")
+	}
+	if Verify("whsec_test", timestamp.Add(time.Second), body, signature) {
+		t.Error("Verify accepted another timestamp")
+	}
+	if Verify("whsec_test", timestamp, []byte(`{}`), signature) {
+		t.Error("Verify accepted another body")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{6, 16 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, c := range cases {
+		if got := Backoff(c.attempts); got != c.want {
+			t.Errorf("Backoff(%d) = %v, want %v", c.attempts, got, c.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, ok := range []string{"https://chat.example.com/hooks/1", "http://localhost:8080/done"} {
+		if err := CheckURL(ok); err != nil {
+			t.Errorf("CheckURL(%q) = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "chat.example.com/hook", "ftp://example.com", "https://"} {
+		if err := CheckURL(bad); err == nil {
+			t.Errorf("CheckURL(%q) accepted it", bad)
+		}
+	}
+}
+
+func TestPublic(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.215.14":   true,
+		"2606:4700::6810": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"0.0.0.0":         false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.10":    false,
+		"169.254.169.254": false, // Cloud metadata
+		"100.100.0.1":     false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+		"224.0.0.1":       false,
+	}
+	for address, want := range cases {
+		if got := Public(net.ParseIP(address)); got != want {
+			t.Errorf("Public(%s) = %v, want %v", address, got, want)
+		}
+	}
+}